@@ -8,7 +8,6 @@ require (
 	github.com/gogo/protobuf v1.3.2
 	github.com/golang/mock v1.7.0-rc.1
 	github.com/golang/protobuf v1.5.4
-	github.com/pmezard/go-difflib v1.0.0
 	golang.org/x/net v0.35.0
 	golang.org/x/tools v0.30.0
 	google.golang.org/genproto v0.0.0-20250115164207-1a7da9e5054f
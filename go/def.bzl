@@ -55,6 +55,16 @@ load(
     "//go/private/rules:nogo.bzl",
     _nogo = "nogo_wrapper",
 )
+load(
+    "//go/private/rules:nogo_fix.bzl",
+    _nogo_fix = "nogo_fix",
+    _nogo_fix_aggregate = "nogo_fix_aggregate",
+)
+load(
+    "//go/private/rules:nogo_summary.bzl",
+    _nogo_report = "nogo_report",
+    _nogo_summary = "nogo_summary",
+)
 load(
     "//go/private/rules:sdk.bzl",
     _go_sdk = "go_sdk",
@@ -134,6 +144,10 @@ go_sdk = _go_sdk
 go_tool_library = _go_tool_library
 go_toolchain = _go_toolchain
 nogo = _nogo
+nogo_fix = _nogo_fix
+nogo_fix_aggregate = _nogo_fix_aggregate
+nogo_report = _nogo_report
+nogo_summary = _nogo_summary
 
 # This provider is deprecated and will be removed in a future release.
 # Use GoInfo instead.
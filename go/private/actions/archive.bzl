@@ -20,6 +20,7 @@ load(
     "//go/private:mode.bzl",
     "LINKMODE_C_ARCHIVE",
     "LINKMODE_C_SHARED",
+    "gc_json_diagnostics_enabled",
     "mode_string",
 )
 load(
@@ -66,6 +67,15 @@ def emit_archive(go, source = None, _recompile_suffix = "", recompile_internal_d
         out_facts = go.declare_file(go, name = source.name, ext = pre_ext + ".facts")
         out_nogo_log = go.declare_file(go, name = source.name, ext = pre_ext + ".nogo.log")
         out_nogo_fix = go.declare_file(go, name = source.name, ext = pre_ext + ".nogo.patch")
+        out_nogo_fix_json = go.declare_file(go, name = source.name, ext = pre_ext + ".nogo.fix.json")
+        out_nogo_fix_dir = go.declare_directory(go, name = source.name, ext = pre_ext + ".nogo.patches")
+        out_nogo_inspection = go.declare_file(go, name = source.name, ext = pre_ext + ".nogo.xml")
+        out_nogo_diagnostics = go.declare_file(go, name = source.name, ext = pre_ext + ".nogo.json")
+        out_nogo_summary = go.declare_file(go, name = source.name, ext = pre_ext + ".nogo.summary.json")
+        if go.nogo_metrics != "off":
+            out_nogo_metrics = go.declare_file(go, name = source.name, ext = pre_ext + ".nogo.metrics.json")
+        else:
+            out_nogo_metrics = None
         if validate_nogo(go):
             out_nogo_validation = go.declare_file(go, name = source.name, ext = pre_ext + ".nogo")
         else:
@@ -74,6 +84,12 @@ def emit_archive(go, source = None, _recompile_suffix = "", recompile_internal_d
         out_facts = None
         out_nogo_log = None
         out_nogo_fix = None
+        out_nogo_fix_json = None
+        out_nogo_fix_dir = None
+        out_nogo_inspection = None
+        out_nogo_diagnostics = None
+        out_nogo_summary = None
+        out_nogo_metrics = None
         out_nogo_validation = None
 
     direct = source.deps
@@ -87,12 +103,18 @@ def emit_archive(go, source = None, _recompile_suffix = "", recompile_internal_d
 
     importmap = "main" if source.is_main else source.importmap
     importpath, _ = effective_importpath_pkgpath(source)
+    if gc_json_diagnostics_enabled(go.mode, importpath):
+        out_gc_json_diagnostics = go.declare_directory(go, name = source.name, ext = pre_ext + ".gcjson")
+    else:
+        out_gc_json_diagnostics = None
 
     if source.cgo and not go.mode.pure:
         # TODO(jayconrod): do we need to do full Bourne tokenization here?
         cppopts = [f for fs in source.cppopts for f in fs.split(" ")]
         copts = [f for fs in source.copts for f in fs.split(" ")]
         cxxopts = [f for fs in source.cxxopts for f in fs.split(" ")]
+        objcopts = [f for fs in source.objcopts for f in fs.split(" ")]
+        objcxxopts = [f for fs in source.objcxxopts for f in fs.split(" ")]
         clinkopts = [f for fs in source.clinkopts for f in fs.split(" ")]
         cgo = cgo_configure(
             go,
@@ -101,6 +123,8 @@ def emit_archive(go, source = None, _recompile_suffix = "", recompile_internal_d
             cppopts = cppopts,
             copts = copts,
             cxxopts = cxxopts,
+            objcopts = objcopts,
+            objcxxopts = objcxxopts,
             clinkopts = clinkopts,
         )
         if go.mode.linkmode in (LINKMODE_C_SHARED, LINKMODE_C_ARCHIVE):
@@ -120,9 +144,16 @@ def emit_archive(go, source = None, _recompile_suffix = "", recompile_internal_d
             out_facts = out_facts,
             out_nogo_log = out_nogo_log,
             out_nogo_fix = out_nogo_fix,
+            out_nogo_fix_json = out_nogo_fix_json,
+            out_nogo_fix_dir = out_nogo_fix_dir,
+            out_nogo_inspection = out_nogo_inspection,
+            out_nogo_diagnostics = out_nogo_diagnostics,
+            out_nogo_summary = out_nogo_summary,
+            out_nogo_metrics = out_nogo_metrics,
             out_nogo_validation = out_nogo_validation,
             nogo = nogo,
             out_cgo_export_h = out_cgo_export_h,
+            out_gc_json_diagnostics = out_gc_json_diagnostics,
             gc_goopts = source.gc_goopts,
             cgo = True,
             cgo_inputs = cgo.inputs,
@@ -151,7 +182,14 @@ def emit_archive(go, source = None, _recompile_suffix = "", recompile_internal_d
             out_nogo_log = out_nogo_log,
             out_nogo_validation = out_nogo_validation,
             out_nogo_fix = out_nogo_fix,
+            out_nogo_fix_json = out_nogo_fix_json,
+            out_nogo_fix_dir = out_nogo_fix_dir,
+            out_nogo_inspection = out_nogo_inspection,
+            out_nogo_diagnostics = out_nogo_diagnostics,
+            out_nogo_summary = out_nogo_summary,
+            out_nogo_metrics = out_nogo_metrics,
             nogo = nogo,
+            out_gc_json_diagnostics = out_gc_json_diagnostics,
             gc_goopts = source.gc_goopts,
             cgo = False,
             testfilter = testfilter,
@@ -183,6 +221,8 @@ def emit_archive(go, source = None, _recompile_suffix = "", recompile_internal_d
         _cppopts = tuple(source.cppopts),
         _copts = tuple(source.copts),
         _cxxopts = tuple(source.cxxopts),
+        _objcopts = tuple(source.objcopts),
+        _objcxxopts = tuple(source.objcxxopts),
         _clinkopts = tuple(source.clinkopts),
 
         # Information on dependencies
@@ -194,7 +234,15 @@ def emit_archive(go, source = None, _recompile_suffix = "", recompile_internal_d
         facts_file = out_facts,
         runfiles = source.runfiles,
         _validation_output = out_nogo_validation,
+        _nogo_log_output = out_nogo_log,
         _nogo_fix_output = out_nogo_fix,
+        _nogo_fix_json_output = out_nogo_fix_json,
+        _nogo_fix_dir_output = out_nogo_fix_dir,
+        _nogo_inspection_output = out_nogo_inspection,
+        _nogo_diagnostics_output = out_nogo_diagnostics,
+        _nogo_summary_output = out_nogo_summary,
+        _nogo_metrics_output = out_nogo_metrics,
+        _gc_json_diagnostics_output = out_gc_json_diagnostics,
         _cgo_deps = cgo_deps,
     )
     x_defs = dict(source.x_defs)
@@ -12,10 +12,14 @@
 # See the License for the specific language governing permissions and
 # limitations under the License.
 
+load("@bazel_skylib//lib:paths.bzl", "paths")
+load("@io_bazel_rules_nogo//:scope.bzl", NOGO_PERSISTENT_WORKER = "PERSISTENT_WORKER")
 load("//go/private:common.bzl", "GO_TOOLCHAIN_LABEL", "SUPPORTS_PATH_MAPPING_REQUIREMENT")
 load(
     "//go/private:mode.bzl",
+    "gc_debug_opts",
     "link_mode_arg",
+    "package_gc_goopts",
 )
 load("//go/private/actions:utils.bzl", "quote_opts")
 
@@ -49,6 +53,17 @@ def _embedlookupdir_arg(src):
         root_relative = root_relative[1:]
     return root_relative
 
+def _embedpackagedir_arg(src):
+    # Files generated by rules in other packages are embedded at their path
+    # relative to the directory of that package, which is passed along with
+    # each file since package directories may be nested.
+    if src.is_source or not src.owner:
+        return None
+    return "{}={}".format(
+        paths.join(src.root.path, src.owner.workspace_root, src.owner.package),
+        src.path,
+    )
+
 def emit_compilepkg(
         go,
         sources = None,
@@ -70,9 +85,16 @@ def emit_compilepkg(
         out_facts = None,
         out_nogo_log = None,
         out_nogo_fix = None,
+        out_nogo_fix_json = None,
+        out_nogo_fix_dir = None,
+        out_nogo_inspection = None,
+        out_nogo_diagnostics = None,
+        out_nogo_summary = None,
+        out_nogo_metrics = None,
         out_nogo_validation = None,
         nogo = None,
         out_cgo_export_h = None,
+        out_gc_json_diagnostics = None,
         gc_goopts = [],
         testfilter = None,  # TODO: remove when test action compiles packages
         recompile_internal_deps = [],
@@ -90,6 +112,16 @@ def emit_compilepkg(
         fail("nogo must be specified if and only if out_nogo_log is specified")
     if have_nogo != (out_nogo_fix != None):
         fail("nogo must be specified if and only if out_nogo_fix is specified")
+    if have_nogo != (out_nogo_fix_json != None):
+        fail("nogo must be specified if and only if out_nogo_fix_json is specified")
+    if have_nogo != (out_nogo_fix_dir != None):
+        fail("nogo must be specified if and only if out_nogo_fix_dir is specified")
+    if have_nogo != (out_nogo_inspection != None):
+        fail("nogo must be specified if and only if out_nogo_inspection is specified")
+    if have_nogo != (out_nogo_diagnostics != None):
+        fail("nogo must be specified if and only if out_nogo_diagnostics is specified")
+    if have_nogo != (out_nogo_summary != None):
+        fail("nogo must be specified if and only if out_nogo_summary is specified")
 
     if cover and go.coverdata:
         archives = archives + [go.coverdata]
@@ -100,10 +132,37 @@ def emit_compilepkg(
     inputs_transitive = [sdk.headers, sdk.tools, go.stdlib.libs]
     outputs = [out_lib, out_export]
 
+    cover_mode = None
+    if cover and go.coverdata:
+        if go.mode.race:
+            cover_mode = "atomic"
+        else:
+            cover_mode = "set"
+    package_args = struct(
+        sources = sources,
+        cover_mode = cover_mode,
+        archives = archives,
+        recompile_internal_deps = recompile_internal_deps,
+        importpath = importpath or go.label.name,
+        importmap = importmap,
+        package_list = sdk.package_list,
+        testfilter = testfilter,
+    )
     shared_args = go.builder_args(go, use_path_mapping = True)
-    shared_args.add_all(sources, before_each = "-src")
+    _add_package_args(shared_args, package_args)
 
-    compile_args = go.tool_args(go)
+    if go.compile_worker:
+        # The builder runs as a multiplex persistent worker started with the
+        # arguments of the configuration, which reads those of each package
+        # from the flagfile of the work request.
+        startup_args = go.builder_args(go, use_path_mapping = True)
+        compile_args = go.actions.args()
+        compile_args.use_param_file("@%s", use_always = True)
+        compile_args.set_param_file_format("multiline")
+        _add_package_args(compile_args, package_args)
+    else:
+        startup_args = shared_args
+        compile_args = go.tool_args(go)
     compile_args.add_all(embedsrcs, before_each = "-embedsrc", expand_directories = False)
     compile_args.add_all(
         sources + [out_lib] + embedsrcs,
@@ -119,38 +178,30 @@ def emit_compilepkg(
         uniquify = True,
         expand_directories = False,
     )
+    compile_args.add_all(
+        embedsrcs,
+        map_each = _embedpackagedir_arg,
+        before_each = "-embedpackagedir",
+        uniquify = True,
+        expand_directories = False,
+    )
 
-    if cover and go.coverdata:
-        if go.mode.race:
-            cover_mode = "atomic"
-        else:
-            cover_mode = "set"
-        shared_args.add("-cover_mode", cover_mode)
+    if cover_mode:
         compile_args.add("-cover_format", go.mode.cover_format)
         compile_args.add_all(cover, before_each = "-cover")
 
-    shared_args.add_all(archives, before_each = "-arc", map_each = _archive)
-    if recompile_internal_deps:
-        shared_args.add_all(recompile_internal_deps, before_each = "-recompile_internal_deps")
-    if importpath:
-        shared_args.add("-importpath", importpath)
-    else:
-        shared_args.add("-importpath", go.label.name)
-    if importmap:
-        shared_args.add("-p", importmap)
-    shared_args.add("-package_list", sdk.package_list)
-
     compile_args.add("-lo", out_lib)
     compile_args.add("-o", out_export)
     if out_cgo_export_h:
         compile_args.add("-cgoexport", out_cgo_export_h)
         outputs.append(out_cgo_export_h)
-    if testfilter:
-        shared_args.add("-testfilter", testfilter)
+    if out_gc_json_diagnostics:
+        compile_args.add("-gc_json_diagnostics", out_gc_json_diagnostics)
+        outputs.append(out_gc_json_diagnostics)
 
     link_mode_flag = link_mode_arg(go.mode)
 
-    gc_flags = gc_goopts + go.mode.gc_goopts
+    gc_flags = gc_goopts + go.mode.gc_goopts + gc_debug_opts(go.mode)
     if go.mode.race:
         gc_flags.append("-race")
     if go.mode.msan:
@@ -161,9 +212,12 @@ def emit_compilepkg(
     if link_mode_flag:
         gc_flags.append(link_mode_flag)
     compile_args.add("-gcflags", quote_opts(gc_flags))
+    compile_args.add_all(package_gc_goopts(go.mode, package_args.importpath), before_each = "-gcflags")
 
     if link_mode_flag:
         compile_args.add("-asmflags", link_mode_flag)
+    if go.mode.spectre:
+        compile_args.add_joined("-spectre", go.mode.spectre, join_with = ",")
 
     # cgo and the linker action don't support path mapping yet
     # TODO: Remove the second condition after https://github.com/bazelbuild/bazel/pull/21921.
@@ -200,12 +254,22 @@ def emit_compilepkg(
         compile_args.add("-pgoprofile", go.mode.pgoprofile)
         inputs_direct.append(go.mode.pgoprofile)
 
+    if go.compile_worker:
+        execution_requirements = dict(
+            execution_requirements,
+            **{
+                "requires-worker-protocol": "json",
+                "supports-multiplex-workers": "1",
+                "supports-workers": "1",
+            }
+        )
+
     go.actions.run(
         inputs = depset(inputs_direct, transitive = inputs_transitive),
         outputs = outputs,
         mnemonic = "GoCompilePkgExternal" if is_external_pkg else "GoCompilePkg",
         executable = go.toolchain._builder,
-        arguments = ["compilepkg", shared_args, compile_args],
+        arguments = ["compilepkg", startup_args, compile_args],
         env = env,
         toolchain = GO_TOOLCHAIN_LABEL,
         execution_requirements = execution_requirements,
@@ -215,19 +279,42 @@ def emit_compilepkg(
         _run_nogo(
             go,
             shared_args = shared_args,
+            package_args = package_args,
             sources = sources,
             cgo_go_srcs = cgo_go_srcs_for_nogo,
             archives = archives,
             out_facts = out_facts,
             out_log = out_nogo_log,
             out_fix = out_nogo_fix,
+            out_fix_json = out_nogo_fix_json,
+            out_fix_dir = out_nogo_fix_dir,
+            out_inspection = out_nogo_inspection,
+            out_diagnostics = out_nogo_diagnostics,
+            out_summary = out_nogo_summary,
+            out_metrics = out_nogo_metrics,
             out_validation = out_nogo_validation,
             nogo = nogo,
         )
 
+def _add_package_args(args, package_args):
+    """Adds the arguments shared by the compilepkg and nogo actions of a package."""
+    args.add_all(package_args.sources, before_each = "-src")
+    if package_args.cover_mode:
+        args.add("-cover_mode", package_args.cover_mode)
+    args.add_all(package_args.archives, before_each = "-arc", map_each = _archive)
+    if package_args.recompile_internal_deps:
+        args.add_all(package_args.recompile_internal_deps, before_each = "-recompile_internal_deps")
+    args.add("-importpath", package_args.importpath)
+    if package_args.importmap:
+        args.add("-p", package_args.importmap)
+    args.add("-package_list", package_args.package_list)
+    if package_args.testfilter:
+        args.add("-testfilter", package_args.testfilter)
+
 def _run_nogo(
         go,
         shared_args,
+        package_args,
         *,
         sources,
         cgo_go_srcs,
@@ -236,6 +323,12 @@ def _run_nogo(
         out_log,
         out_validation,
         out_fix,
+        out_fix_json,
+        out_fix_dir,
+        out_inspection,
+        out_diagnostics,
+        out_summary,
+        out_metrics,
         nogo):
     """Runs nogo on Go source files, including those generated by cgo."""
     sdk = go.sdk
@@ -244,9 +337,27 @@ def _run_nogo(
                      [archive.data.facts_file for archive in archives if archive.data.facts_file] +
                      [archive.data.export_file for archive in archives])
     inputs_transitive = [sdk.tools, sdk.headers, go.stdlib.libs]
-    outputs = [out_facts, out_log, out_fix]
+    outputs = [out_facts, out_log, out_fix, out_fix_json, out_fix_dir, out_inspection, out_diagnostics]
 
-    nogo_args = go.tool_args(go)
+    if NOGO_PERSISTENT_WORKER:
+        # The builder runs as a persistent worker started with the arguments
+        # of the configuration, which reads those of each package from the
+        # flagfile of the work request.
+        shared_args = go.builder_args(go, use_path_mapping = True)
+        nogo_args = go.actions.args()
+        nogo_args.use_param_file("@%s", use_always = True)
+        nogo_args.set_param_file_format("multiline")
+        _add_package_args(nogo_args, package_args)
+        execution_requirements = dict(
+            SUPPORTS_PATH_MAPPING_REQUIREMENT,
+            **{
+                "requires-worker-protocol": "json",
+                "supports-workers": "1",
+            }
+        )
+    else:
+        nogo_args = go.tool_args(go)
+        execution_requirements = SUPPORTS_PATH_MAPPING_REQUIREMENT
     if cgo_go_srcs:
         inputs_direct.append(cgo_go_srcs)
         nogo_args.add_all([cgo_go_srcs], before_each = "-ignore_src")
@@ -255,8 +366,24 @@ def _run_nogo(
     nogo_args.add("-out_facts", out_facts)
     nogo_args.add("-out_log", out_log)
     nogo_args.add("-out_fix", out_fix)
+    nogo_args.add("-out_fix_json", out_fix_json)
+    nogo_args.add("-out_fix_dir", out_fix_dir)
+    nogo_args.add("-out_inspection", out_inspection)
+    nogo_args.add("-out_diagnostics", out_diagnostics)
+    if out_metrics:
+        outputs.append(out_metrics)
+        nogo_args.add("-out_metrics", out_metrics)
+        if go.nogo_metrics == "allocs":
+            nogo_args.add("-metrics_allocs")
     nogo_args.add("-nogo", nogo.executable)
 
+    # Used by nogo to make the paths in the fix file relative to the root chosen by the nogo target.
+    nogo_args.add("-workspace_root", go.label.workspace_root)
+    nogo_args.add("-package_dir", paths.join(go.label.workspace_root, go.label.package))
+
+    # Recorded in the metadata of the fix files.
+    nogo_args.add("-label", str(go.label))
+
     # This action runs nogo and produces the facts files for downstream nogo actions.
     # It is important that this action doesn't fail if nogo produces findings, which allows users
     # to get the nogo findings for all targets with --keep_going rather than stopping at the first
@@ -273,7 +400,7 @@ def _run_nogo(
         arguments = ["nogo", shared_args, nogo_args],
         env = go.env_for_path_mapping,
         toolchain = GO_TOOLCHAIN_LABEL,
-        execution_requirements = SUPPORTS_PATH_MAPPING_REQUIREMENT,
+        execution_requirements = execution_requirements,
         progress_message = "Running nogo on %{label}",
     )
 
@@ -283,9 +410,10 @@ def _run_nogo(
         # to actually fail the build on nogo findings, which RunNogo doesn't do.
         validation_args = go.actions.args()
         validation_args.add("nogovalidation")
-        validation_args.add(out_validation)
-        validation_args.add(out_log)
-        validation_args.add(out_fix)
+        validation_args.add("-label", str(go.label))
+        validation_args.add("-validation_output", out_validation)
+        validation_args.add("-log", out_log)
+        validation_args.add("-fix", out_fix)
 
         go.actions.run(
             inputs = [out_log, out_fix],
@@ -293,6 +421,30 @@ def _run_nogo(
             mnemonic = "ValidateNogo",
             executable = go.toolchain._builder,
             arguments = [validation_args],
+            # Lets users configure the output with --action_env, e.g. NOGO_COLOR=always.
+            use_default_shell_env = True,
             execution_requirements = SUPPORTS_PATH_MAPPING_REQUIREMENT,
             progress_message = "Validating nogo output for %{label}",
         )
+
+    # Unlike the validation action, this action doesn't fail on findings, so the summary is
+    # available for every target, e.g. to track the findings of targets over time.
+    summary_args = go.actions.args()
+    summary_args.add("nogovalidation")
+    summary_args.add("-summary_only")
+    summary_args.add("-label", str(go.label))
+    summary_args.add("-validation_output", out_summary)
+    summary_args.add("-log", out_log)
+    summary_args.add("-fix", out_fix)
+
+    go.actions.run(
+        inputs = [out_log, out_fix],
+        outputs = [out_summary],
+        mnemonic = "SummarizeNogo",
+        executable = go.toolchain._builder,
+        arguments = [summary_args],
+        # Picks up NOGO_FAIL_AT like the validation action to report whether it fails.
+        use_default_shell_env = True,
+        execution_requirements = SUPPORTS_PATH_MAPPING_REQUIREMENT,
+        progress_message = "Summarizing nogo output for %{label}",
+    )
@@ -28,6 +28,7 @@ load(
     "LINKMODE_PLUGIN",
     "extld_from_cc_toolchain",
     "extldflags_from_cc_toolchain",
+    "link_buildmode",
 )
 load(
     "//go/private:rpath.bzl",
@@ -101,8 +102,9 @@ def emit_link(
 
     if go.mode.static:
         extldflags.append("-static")
-    if go.mode.linkmode != LINKMODE_NORMAL:
-        builder_args.add("-buildmode", go.mode.linkmode)
+    buildmode = link_buildmode(go.mode)
+    if buildmode:
+        builder_args.add("-buildmode", buildmode)
     if go.mode.linkmode == LINKMODE_PLUGIN:
         tool_args.add("-pluginpath", archive.data.importpath)
 
@@ -170,6 +172,10 @@ def emit_link(
     tool_args.add_all(gc_linkopts)
     tool_args.add_all(go.toolchain.flags.link)
 
+    # Like the go command, record the default GODEBUG settings in the binary.
+    if go.mode.godebug:
+        tool_args.add("-X=runtime.godebugDefault=" + ",".join(go.mode.godebug))
+
     # Do not remove, somehow this is needed when building for darwin/arm only.
     tool_args.add("-buildid=redacted")
     if go.mode.strip:
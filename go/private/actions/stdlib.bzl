@@ -26,7 +26,9 @@ load(
     "//go/private:mode.bzl",
     "LINKMODE_NORMAL",
     "extldflags_from_cc_toolchain",
+    "gc_debug_opts",
     "link_mode_arg",
+    "stdlib_package_gc_goopts",
 )
 load(
     "//go/private:providers.bzl",
@@ -42,11 +44,22 @@ def emit_stdlib(go):
     Otherwise, the standard library will be compiled for the target.
 
     Returns:
-        A list of providers containing GoInfo and GoStdLib.
+        A list of providers containing GoInfo and GoStdLib. If the standard
+        library is compiled, an OutputGroupInfo also provides an archive of it
+        in the prebuilt_stdlib output group.
     """
     go_info = new_go_info(go, {}, coverage_instrumented = False)
-    stdlib = _sdk_stdlib(go) if _should_use_sdk_stdlib(go) else _build_stdlib(go)
-    return [go_info, stdlib]
+    if _should_use_sdk_stdlib(go):
+        return [go_info, _sdk_stdlib(go)]
+    stdlib = _build_stdlib(go)
+    if go.sdk.compiler == "gccgo":
+        # gccgo links the precompiled libgo, so there is nothing to archive.
+        return [go_info, stdlib]
+    return [
+        go_info,
+        stdlib,
+        OutputGroupInfo(prebuilt_stdlib = depset([_archive_stdlib(go, stdlib.root_file)])),
+    ]
 
 def _should_use_sdk_stdlib(go):
     version = parse_version(go.sdk.version)
@@ -60,6 +73,10 @@ def _should_use_sdk_stdlib(go):
             not go.mode.msan and
             not go.mode.pure and
             not go.mode.gc_goopts and
+            not go.mode.gc_debug and
+            not stdlib_package_gc_goopts(go.mode) and
+            not go.mode.spectre and
+            not go.mode.goexperiment and
             go.mode.linkmode == LINKMODE_NORMAL)
 
 def _build_stdlib_list_json(go):
@@ -147,7 +164,10 @@ def _build_stdlib(go):
     if link_mode_flag:
         args.add(link_mode_flag)
 
-    args.add("-gcflags", quote_opts(go.mode.gc_goopts))
+    args.add("-gcflags", quote_opts(go.mode.gc_goopts + gc_debug_opts(go.mode)))
+    args.add_all(stdlib_package_gc_goopts(go.mode), before_each = "-package_gcflags")
+    if go.mode.spectre:
+        args.add_joined("-spectre", go.mode.spectre, join_with = ",")
 
     sdk = go.sdk
     inputs_direct = [sdk.go, sdk.package_list, sdk.root_file]
@@ -157,6 +177,10 @@ def _build_stdlib(go):
         args.add("-pgoprofile", go.mode.pgoprofile)
         inputs_direct.append(go.mode.pgoprofile)
 
+    if go.prebuilt_stdlib:
+        args.add("-prebuilt", go.prebuilt_stdlib)
+        inputs_direct.append(go.prebuilt_stdlib)
+
     outputs = [pkg]
     go.actions.run(
         inputs = depset(direct = inputs_direct, transitive = inputs_transitive),
@@ -175,3 +199,21 @@ def _build_stdlib(go):
         cache_dir = depset([cache_dir]),
         root_file = pkg,
     )
+
+def _archive_stdlib(go, pkg):
+    """Packs the standard library built in pkg into an archive that other builds
+    can use through the prebuilt_stdlib build setting."""
+    out = go.declare_file(go, "stdlib.tar.gz")
+    args = go.builder_args(go, "stdlibarchive")
+    args.add_all("-root", [pkg], map_each = _dirname, expand_directories = False)
+    args.add("-out", out)
+    go.actions.run(
+        inputs = [pkg],
+        outputs = [out],
+        mnemonic = "GoStdlibArchive",
+        executable = go.toolchain._builder,
+        arguments = [args],
+        env = go.env,
+        toolchain = GO_TOOLCHAIN_LABEL,
+    )
+    return out
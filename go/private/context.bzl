@@ -173,6 +173,8 @@ def _builder_args(go, command = None, use_path_mapping = False):
         args.add(command)
     sdk_root_file = go.sdk.root_file
     args.add("-sdk", sdk_root_file.dirname)
+    if go.sdk.compiler != "gc":
+        args.add("-compiler", go.sdk.compiler)
 
     # Path mapping can't map the values of environment variables, so we need to pass GOROOT to the
     # action via an argument instead.
@@ -213,6 +215,8 @@ def _merge_embed(source, embed):
         source["cppopts"] = s.cppopts
         source["copts"] = s.copts
         source["cxxopts"] = s.cxxopts
+        source["objcopts"] = s.objcopts
+        source["objcxxopts"] = s.objcxxopts
         source["clinkopts"] = s.clinkopts
 
 def _dedup_archives(archives):
@@ -327,6 +331,8 @@ def new_go_info(
         "cppopts": _expand_opts(go, "cppopts", getattr(attr, "cppopts", [])),
         "copts": _expand_opts(go, "copts", getattr(attr, "copts", [])),
         "cxxopts": _expand_opts(go, "cxxopts", getattr(attr, "cxxopts", [])),
+        "objcopts": _expand_opts(go, "objcopts", getattr(attr, "objcopts", [])),
+        "objcxxopts": _expand_opts(go, "objcxxopts", getattr(attr, "objcxxopts", [])),
         "clinkopts": _expand_opts(go, "clinkopts", getattr(attr, "clinkopts", [])),
         "pgoprofile": getattr(attr, "pgoprofile", None),
     }
@@ -345,13 +351,13 @@ def new_go_info(
         x_defs[k] = v
     go_info["x_defs"] = x_defs
     if not go_info["cgo"]:
-        for k in ("cdeps", "cppopts", "copts", "cxxopts", "clinkopts"):
+        for k in ("cdeps", "cppopts", "copts", "cxxopts", "objcopts", "objcxxopts", "clinkopts"):
             if getattr(attr, k, None):
                 fail(k + " set without cgo = True")
         for f in go_info["srcs"]:
             # This check won't report directory sources that contain C/C++
             # sources. compilepkg will catch these instead.
-            if f.extension in ("c", "cc", "cxx", "cpp", "hh", "hpp", "hxx"):
+            if f.extension in ("c", "cc", "cxx", "cpp", "hh", "hpp", "hxx", "m", "mm"):
                 fail("source {} has C/C++ extension, but cgo was not enabled (set 'cgo = True')".format(f.path))
 
     if resolver:
@@ -448,10 +454,19 @@ default_go_config_info = GoConfigInfo(
     stamp = False,
     cover_format = None,
     gc_goopts = [],
+    gc_debug = [],
+    gc_json_diagnostics = [],
+    package_gc_goopts = [],
+    spectre = [],
+    goexperiment = [],
+    godebug = [],
     amd64 = None,
     arm = None,
     pgoprofile = None,
     export_stdlib = False,
+    compile_worker = False,
+    prebuilt_stdlib = None,
+    nogo_metrics = "off",
 )
 
 def go_context(
@@ -521,10 +536,14 @@ def go_context(
     else:
         goroot = toolchain.sdk.root_file.dirname
 
+    # Experiments of the goexperiment setting come last, so they override
+    # those the SDK was declared with.
+    experiments = [e for e in toolchain.sdk.experiments.split(",") if e] + mode.goexperiment
+
     env = {
         "GOARCH": mode.goarch,
         "GOOS": mode.goos,
-        "GOEXPERIMENT": toolchain.sdk.experiments,
+        "GOEXPERIMENT": ",".join(experiments),
         "GOROOT": goroot,
         "GOROOT_FINAL": "GOROOT",
         "CGO_ENABLED": "0" if mode.pure else "1",
@@ -625,6 +644,9 @@ def go_context(
         coverage_enabled = ctx.configuration.coverage_enabled,
         coverage_instrumented = ctx.coverage_instrumented(),
         export_stdlib = go_config_info.export_stdlib,
+        compile_worker = go_config_info.compile_worker,
+        nogo_metrics = go_config_info.nogo_metrics,
+        prebuilt_stdlib = go_config_info.prebuilt_stdlib,
         env = env,
         # Path mapping can't map the values of environment variables, so we pass GOROOT to the action
         # via an argument instead in builder_args. We need to drop it from the environment to get cache
@@ -968,6 +990,14 @@ def _go_config_impl(ctx):
     else:
         pgoprofile = None
 
+    prebuilt_stdlib = None
+    if ctx.attr.prebuilt_stdlib:
+        prebuilt_stdlibs = ctx.attr.prebuilt_stdlib.files.to_list()
+        if len(prebuilt_stdlibs) > 1:
+            fail("providing more than one archive to prebuilt_stdlib is not supported")
+        if prebuilt_stdlibs:
+            prebuilt_stdlib = prebuilt_stdlibs[0]
+
     tags = list(ctx.attr.gotags[BuildSettingInfo].value)
     if "gotags" in ctx.var:
         tags += ctx.var["gotags"].split(",")
@@ -997,10 +1027,19 @@ def _go_config_impl(ctx):
         stamp = ctx.attr.stamp,
         cover_format = ctx.attr.cover_format[BuildSettingInfo].value,
         gc_goopts = ctx.attr.gc_goopts[BuildSettingInfo].value,
+        gc_debug = ctx.attr.gc_debug[BuildSettingInfo].value,
+        gc_json_diagnostics = ctx.attr.gc_json_diagnostics[BuildSettingInfo].value,
+        package_gc_goopts = ctx.attr.package_gc_goopts[BuildSettingInfo].value,
+        spectre = ctx.attr.spectre[BuildSettingInfo].value,
+        goexperiment = ctx.attr.goexperiment[BuildSettingInfo].value,
+        godebug = ctx.attr.godebug[BuildSettingInfo].value,
         amd64 = ctx.attr.amd64,
         arm = ctx.attr.arm,
         pgoprofile = pgoprofile,
         export_stdlib = ctx.attr.export_stdlib[BuildSettingInfo].value,
+        compile_worker = ctx.attr.compile_worker[BuildSettingInfo].value,
+        prebuilt_stdlib = prebuilt_stdlib,
+        nogo_metrics = ctx.attr.nogo_metrics[BuildSettingInfo].value,
     )
     validate_mode(go_config_info)
 
@@ -1051,6 +1090,30 @@ go_config = rule(
             mandatory = True,
             providers = [BuildSettingInfo],
         ),
+        "gc_debug": attr.label(
+            mandatory = False,
+            providers = [BuildSettingInfo],
+        ),
+        "gc_json_diagnostics": attr.label(
+            mandatory = False,
+            providers = [BuildSettingInfo],
+        ),
+        "package_gc_goopts": attr.label(
+            mandatory = False,
+            providers = [BuildSettingInfo],
+        ),
+        "spectre": attr.label(
+            mandatory = False,
+            providers = [BuildSettingInfo],
+        ),
+        "goexperiment": attr.label(
+            mandatory = False,
+            providers = [BuildSettingInfo],
+        ),
+        "godebug": attr.label(
+            mandatory = False,
+            providers = [BuildSettingInfo],
+        ),
         "amd64": attr.string(),
         "arm": attr.string(),
         "pgoprofile": attr.label(
@@ -1061,6 +1124,18 @@ go_config = rule(
             mandatory = False,
             providers = [BuildSettingInfo],
         ),
+        "compile_worker": attr.label(
+            mandatory = False,
+            providers = [BuildSettingInfo],
+        ),
+        "prebuilt_stdlib": attr.label(
+            mandatory = False,
+            allow_files = True,
+        ),
+        "nogo_metrics": attr.label(
+            mandatory = False,
+            providers = [BuildSettingInfo],
+        ),
     },
     provides = [GoConfigInfo],
     doc = """Collects information about build settings in the current
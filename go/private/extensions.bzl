@@ -95,6 +95,12 @@ Uses the same format as 'visibility', i.e., every entry must be a label that end
             default = NOGO_DEFAULT_EXCLUDES,
             doc = "See 'includes'.",
         ),
+        "persistent_worker": attr.bool(
+            doc = """
+Whether nogo runs as a persistent worker, which keeps the analyzers and the export data and facts
+of the standard library warm between packages.
+""",
+        ),
     },
 )
 
@@ -148,6 +154,7 @@ def _go_sdk_impl(ctx):
         nogo = DEFAULT_NOGO,
         includes = NOGO_DEFAULT_INCLUDES,
         excludes = NOGO_DEFAULT_EXCLUDES,
+        persistent_worker = False,
     )
     for module in ctx.modules:
         if not module.is_root or not module.tags.nogo:
@@ -175,6 +182,7 @@ def _go_sdk_impl(ctx):
         # scope.
         includes = [str(l) for l in nogo_tag.includes],
         excludes = [str(l) for l in nogo_tag.excludes],
+        persistent_worker = nogo_tag.persistent_worker,
     )
 
     multi_version_module = {}
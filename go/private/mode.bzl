@@ -56,10 +56,38 @@ def mode_string(mode):
         result.append(mode.linkmode)
     if mode.gc_goopts:
         result.extend(mode.gc_goopts)
+    result.extend(gc_debug_opts(mode))
+    if mode.spectre:
+        result.append("spectre=" + ",".join(mode.spectre))
+    if mode.goexperiment:
+        result.append("goexperiment=" + ",".join(mode.goexperiment))
+    if mode.godebug:
+        result.append("godebug=" + ",".join(mode.godebug))
     return "_".join(result)
 
 def validate_mode(mode):
     # TODO(jayconrod): check for more invalid and contradictory settings.
+    for entry in mode.package_gc_goopts:
+        split_package_gc_goopts(entry)
+    for setting in mode.gc_debug:
+        if not setting or setting.startswith("-") or " " in setting:
+            fail("gc_debug entry {} isn't a setting of the compiler's -d flag, like \"ssa/check_bce/debug=1\"".format(repr(setting)))
+    for mitigation in mode.spectre:
+        if mitigation not in ("all", "index", "ret"):
+            fail("unknown Spectre mitigation {}: spectre must only contain \"all\", \"index\", and \"ret\"".format(repr(mitigation)))
+        if mitigation in ("all", "index") and mode.goarch != "amd64":
+            fail("Spectre mitigation '{}' is only supported on amd64, not on {}".format(mitigation, mode.goarch))
+    if not linkmode_supported(mode):
+        fail("linkmode '{}' is not supported on {}".format(mode.linkmode, _platform(mode)))
+    if mode.linkmode == LINKMODE_PIE and mode.race and not _default_pie(mode):
+        fail("linkmode 'pie' can't be used with race instrumentation on {}".format(_platform(mode)))
+    for experiment in mode.goexperiment:
+        if not experiment or "," in experiment or "=" in experiment or " " in experiment:
+            fail("goexperiment entry {} isn't the name of a Go experiment, like \"rangefunc\" or \"noregabi\"".format(repr(experiment)))
+    for setting in mode.godebug:
+        key, sep, _ = setting.partition("=")
+        if not key or not sep or "," in setting or " " in setting:
+            fail("godebug entry {} isn't a GODEBUG setting of the form key=value, like \"http2client=0\"".format(repr(setting)))
     if mode.pure:
         if mode.race:
             fail("race instrumentation can't be enabled when cgo is disabled. Check that pure is not set to \"off\" and a C/C++ toolchain is configured.")
@@ -69,6 +97,84 @@ def validate_mode(mode):
             fail(("linkmode '{}' can't be used when cgo is disabled. Check that pure is not set to \"off\" and that a C/C++ toolchain is configured for " +
                   "your current platform. If you defined a custom platform, make sure that it has the @io_bazel_rules_go//go/toolchain:cgo_on constraint value.").format(mode.linkmode))
 
+def match_package_pattern(pattern, importpath):
+    """Reports whether importpath matches a package pattern of the go command.
+
+    "..." in a pattern matches any string, including the empty string and
+    strings containing slashes, and a pattern ending in "/..." also matches
+    the path before it. "all" matches any package, while "std" and "cmd" match
+    no package that isn't built as part of the standard library.
+    """
+    if pattern == "all":
+        return True
+    if pattern.endswith("/...") and importpath == pattern[:-len("/...")]:
+        return True
+    parts = pattern.split("...")
+    if len(parts) == 1:
+        return pattern == importpath
+    if not importpath.startswith(parts[0]) or not importpath.endswith(parts[-1]):
+        return False
+    start = len(parts[0])
+    end = len(importpath) - len(parts[-1])
+    if start > end:
+        return False
+    for part in parts[1:-1]:
+        i = importpath.find(part, start, end)
+        if i < 0:
+            return False
+        start = i + len(part)
+    return True
+
+def split_package_gc_goopts(entry):
+    """Splits an entry of the package_gc_goopts setting into its pattern and flags."""
+    pattern, sep, flags = entry.partition("=")
+    if not sep or not pattern or pattern.startswith("-"):
+        fail("package_gc_goopts entry {} doesn't have the form pattern=flags".format(repr(entry)))
+    return pattern, flags
+
+def package_gc_goopts(mode, importpath):
+    """Returns the flags of the last package_gc_goopts entry that matches importpath.
+
+    Like the go command, which only applies the last matching -gcflags
+    pattern, this returns a list with at most one string of space-separated
+    compiler flags. They are passed after those that apply to all packages.
+    """
+    flags = []
+    for entry in mode.package_gc_goopts:
+        pattern, entry_flags = split_package_gc_goopts(entry)
+        if match_package_pattern(pattern, importpath):
+            flags = [entry_flags]
+    return flags
+
+def gc_debug_opts(mode):
+    """Returns the compiler flags for the gc_debug setting."""
+    if not mode.gc_debug:
+        return []
+    return ["-d=" + ",".join(mode.gc_debug)]
+
+def gc_json_diagnostics_enabled(mode, importpath):
+    """Reports whether the compiler writes JSON diagnostics for importpath."""
+    for pattern in mode.gc_json_diagnostics:
+        if match_package_pattern(pattern, importpath):
+            return True
+    return False
+
+def stdlib_package_gc_goopts(mode):
+    """Returns the package_gc_goopts entries that may match standard packages.
+
+    Like the go command, this treats a package as standard if the first
+    element of its path doesn't contain a dot. The go command matches the
+    patterns of these entries itself when building the standard library.
+    """
+    entries = []
+    for entry in mode.package_gc_goopts:
+        pattern, _ = split_package_gc_goopts(entry)
+        if pattern == "cmd" or pattern.startswith("cmd/"):
+            continue
+        if pattern in ("all", "std") or pattern.startswith("...") or "." not in pattern.partition("/")[0]:
+            entries.append(entry)
+    return entries
+
 def installsuffix(mode):
     s = mode.goos + "_" + mode.goarch
     if mode.race:
@@ -77,76 +183,177 @@ def installsuffix(mode):
         s += "_msan"
     return s
 
-# Ported from https://github.com/golang/go/blob/master/src/cmd/go/internal/work/init.go#L76
-_LINK_C_ARCHIVE_PLATFORMS = {
-    "darwin/arm64": None,
-    "ios/arm64": None,
-}
-
+# Ported from BuildModeSupported in
+# https://github.com/golang/go/blob/master/src/internal/platform/supported.go
 _LINK_C_ARCHIVE_GOOS = {
-    "dragonfly": None,
-    "freebsd": None,
-    "linux": None,
-    "netbsd": None,
-    "openbsd": None,
-    "solaris": None,
+    "aix": None,
+    "darwin": None,
+    "ios": None,
+    "windows": None,
 }
 
-_LINK_C_SHARED_GOOS = [
-    "android",
-    "freebsd",
-    "linux",
-]
+_LINK_C_ARCHIVE_PLATFORMS = {
+    "freebsd/amd64": None,
+    "linux/386": None,
+    "linux/amd64": None,
+    "linux/arm": None,
+    "linux/armbe": None,
+    "linux/arm64": None,
+    "linux/arm64be": None,
+    "linux/loong64": None,
+    "linux/ppc64": None,
+    "linux/ppc64le": None,
+    "linux/riscv64": None,
+    "linux/s390x": None,
+}
 
-_LINK_PLUGIN_PLATFORMS = {
+_LINK_C_SHARED_PLATFORMS = {
     "linux/amd64": None,
     "linux/arm": None,
     "linux/arm64": None,
+    "linux/loong64": None,
     "linux/386": None,
-    "linux/s390x": None,
+    "linux/ppc64": None,
     "linux/ppc64le": None,
+    "linux/riscv64": None,
+    "linux/s390x": None,
     "android/amd64": None,
     "android/arm": None,
     "android/arm64": None,
     "android/386": None,
+    "freebsd/amd64": None,
     "darwin/amd64": None,
     "darwin/arm64": None,
-    "ios/arm": None,
-    "ios/arm64": None,
+    "windows/amd64": None,
+    "windows/386": None,
+    "windows/arm64": None,
+    "wasip1/wasm": None,
 }
 
-_LINK_PIE_PLATFORMS = {
+_LINK_PLUGIN_PLATFORMS = {
     "linux/amd64": None,
     "linux/arm": None,
     "linux/arm64": None,
     "linux/386": None,
+    "linux/loong64": None,
+    "linux/riscv64": None,
     "linux/s390x": None,
+    "linux/ppc64": None,
     "linux/ppc64le": None,
     "android/amd64": None,
+    "android/386": None,
+    "darwin/amd64": None,
+    "darwin/arm64": None,
+    "freebsd/amd64": None,
+}
+
+_LINK_PIE_PLATFORMS = {
+    "linux/386": None,
+    "linux/amd64": None,
+    "linux/arm": None,
+    "linux/arm64": None,
+    "linux/loong64": None,
+    "linux/ppc64": None,
+    "linux/ppc64le": None,
+    "linux/riscv64": None,
+    "linux/s390x": None,
+    "android/amd64": None,
     "android/arm": None,
     "android/arm64": None,
     "android/386": None,
     "freebsd/amd64": None,
+    "darwin/amd64": None,
+    "darwin/arm64": None,
+    "ios/amd64": None,
+    "ios/arm64": None,
+    "aix/ppc64": None,
+    "openbsd/arm64": None,
+    "windows/386": None,
+    "windows/amd64": None,
+    "windows/arm64": None,
 }
 
 def _platform(mode):
     return mode.goos + "/" + mode.goarch
 
+def linkmode_supported(mode):
+    """Reports whether the go command supports mode.linkmode on the platform of mode."""
+    platform = _platform(mode)
+    if mode.linkmode == LINKMODE_C_ARCHIVE:
+        return mode.goos in _LINK_C_ARCHIVE_GOOS or platform in _LINK_C_ARCHIVE_PLATFORMS
+    elif mode.linkmode == LINKMODE_C_SHARED:
+        return platform in _LINK_C_SHARED_PLATFORMS
+    elif mode.linkmode == LINKMODE_PLUGIN:
+        return platform in _LINK_PLUGIN_PLATFORMS
+    elif mode.linkmode == LINKMODE_PIE:
+        return platform in _LINK_PIE_PLATFORMS
+    return True
+
+# Ported from InternalLinkPIESupported in
+# https://github.com/golang/go/blob/master/src/internal/platform/supported.go
+_INTERNAL_LINK_PIE_PLATFORMS = {
+    "android/arm64": None,
+    "darwin/amd64": None,
+    "darwin/arm64": None,
+    "linux/amd64": None,
+    "linux/arm64": None,
+    "linux/loong64": None,
+    "linux/ppc64": None,
+    "linux/ppc64le": None,
+    "linux/s390x": None,
+    "windows/386": None,
+    "windows/amd64": None,
+    "windows/arm64": None,
+}
+
+def _default_pie(mode):
+    """Reports whether the go command builds position-independent executables
+    for the platform of mode by default.
+
+    Ported from DefaultPIE in
+    https://github.com/golang/go/blob/master/src/internal/platform/supported.go
+    """
+    if mode.goos in ("android", "darwin", "ios"):
+        return True
+    if mode.goos == "windows":
+        # PIE is not supported with -race on Windows.
+        return not mode.race
+    return False
+
+def _normal_linkmode_is_pie(mode):
+    # Without cgo, binaries are linked internally, which doesn't support PIE
+    # on all platforms that default to it. Like before PIE became the default,
+    # they are linked as position-dependent executables there.
+    return _default_pie(mode) and (not mode.pure or _platform(mode) in _INTERNAL_LINK_PIE_PLATFORMS)
+
+def link_buildmode(mode):
+    """Returns the -buildmode to link with, or None for a position-dependent executable."""
+    if mode.linkmode != LINKMODE_NORMAL:
+        return mode.linkmode
+    if _normal_linkmode_is_pie(mode):
+        return LINKMODE_PIE
+    return None
+
 def link_mode_arg(mode):
     # based on buildModeInit in cmd/go/internal/work/init.go
     if mode.linkmode == LINKMODE_C_ARCHIVE:
-        platform = _platform(mode)
-        if (platform in _LINK_C_ARCHIVE_PLATFORMS or
-            mode.goos in _LINK_C_ARCHIVE_GOOS and platform != "linux/ppc64"):
+        if mode.goos in ("darwin", "ios"):
+            if mode.goarch == "arm64":
+                return "-shared"
+        elif mode.goos in ("dragonfly", "freebsd", "illumos", "linux", "netbsd", "openbsd", "solaris"):
+            # Use -shared so that the result is suitable for inclusion in a PIE
+            # or shared library.
             return "-shared"
     elif mode.linkmode == LINKMODE_C_SHARED:
-        if mode.goos in _LINK_C_SHARED_GOOS:
+        if mode.goos in ("android", "freebsd", "linux"):
             return "-shared"
     elif mode.linkmode == LINKMODE_PLUGIN:
-        if _platform(mode) in _LINK_PLUGIN_PLATFORMS:
-            return "-dynlink"
+        return "-dynlink"
     elif mode.linkmode == LINKMODE_PIE:
-        if _platform(mode) in _LINK_PIE_PLATFORMS:
+        if mode.goos not in ("aix", "windows"):
+            return "-shared"
+    elif mode.linkmode == LINKMODE_NORMAL:
+        if _normal_linkmode_is_pie(mode) and mode.goos != "windows":
             return "-shared"
     return None
 
@@ -39,9 +39,11 @@ def _go_register_nogo_impl(ctx):
         """
 INCLUDES = {includes}
 EXCLUDES = {excludes}
+PERSISTENT_WORKER = {persistent_worker}
 """.format(
             includes = _scope_list_repr(ctx.attr.includes),
             excludes = _scope_list_repr(ctx.attr.excludes),
+            persistent_worker = repr(ctx.attr.persistent_worker),
         ),
         executable = False,
     )
@@ -59,14 +61,17 @@ go_register_nogo = repository_rule(
         # WORKSPACE, for backwards compatibility.
         "includes": attr.string_list(default = ["all"]),
         "excludes": attr.string_list(),
+        # Whether nogo runs as a persistent worker, see go/nogo.rst.
+        "persistent_worker": attr.bool(),
     },
 )
 
-def go_register_nogo_wrapper(nogo, includes = NOGO_DEFAULT_INCLUDES, excludes = NOGO_DEFAULT_EXCLUDES):
+def go_register_nogo_wrapper(nogo, includes = NOGO_DEFAULT_INCLUDES, excludes = NOGO_DEFAULT_EXCLUDES, persistent_worker = False):
     """See go/nogo.rst"""
     go_register_nogo(
         name = "io_bazel_rules_nogo",
         nogo = nogo,
         includes = includes,
         excludes = excludes,
+        persistent_worker = persistent_worker,
     )
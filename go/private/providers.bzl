@@ -36,6 +36,7 @@ GoSDK = provider(
         "goos": "The host OS the SDK was built for.",
         "goarch": "The host architecture the SDK was built for.",
         "experiments": "Comma-separated Go experiments to enable via GOEXPERIMENT.",
+        "compiler": "The Go compiler of the SDK, either \"gc\" or \"gccgo\".",
         "root_file": "A file in the SDK root directory",
         "libs": ("Depset of pre-compiled .a files for the standard library " +
                  "built for the execution platform."),
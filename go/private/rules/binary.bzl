@@ -153,13 +153,39 @@ def _go_binary_impl(ctx):
     )
     validation_output = archive.data._validation_output
     nogo_fix_output = archive.data._nogo_fix_output
+    nogo_fix_json_output = archive.data._nogo_fix_json_output
+    nogo_fix_dir_output = archive.data._nogo_fix_dir_output
+    nogo_log_output = archive.data._nogo_log_output
+    nogo_inspection_output = archive.data._nogo_inspection_output
+    nogo_diagnostics_output = archive.data._nogo_diagnostics_output
+    nogo_summary_output = archive.data._nogo_summary_output
+    nogo_metrics_output = archive.data._nogo_metrics_output
+
+    # Like the go command, provide a header declaring the exported functions
+    # next to c-archive and c-shared binaries.
+    c_header = None
+    if go.mode.linkmode in (LINKMODE_C_ARCHIVE, LINKMODE_C_SHARED):
+        cgo_exports = archive.cgo_exports.to_list()
+        if cgo_exports:
+            c_header = ctx.actions.declare_file("{}.h".format(name))
+            ctx.actions.symlink(
+                output = c_header,
+                target_file = cgo_exports[0],
+            )
 
     providers = [
         archive,
         OutputGroupInfo(
+            c_header = [c_header] if c_header else [],
             cgo_exports = archive.cgo_exports,
             compilation_outputs = [archive.data.file],
-            nogo_fix = [nogo_fix_output] if nogo_fix_output else [],
+            gc_json_diagnostics = [archive.data._gc_json_diagnostics_output] if archive.data._gc_json_diagnostics_output else [],
+            nogo_diagnostics = [nogo_diagnostics_output] if nogo_diagnostics_output else [],
+            nogo_fix = [nogo_fix_output, nogo_fix_json_output, nogo_fix_dir_output, nogo_log_output] if nogo_fix_output else [],
+            nogo_inspection = [nogo_inspection_output] if nogo_inspection_output else [],
+            nogo_metrics = [nogo_metrics_output] if nogo_metrics_output else [],
+            nogo_validation = [validation_output] if validation_output else [],
+            nogo_validation_summary = [nogo_summary_output] if nogo_summary_output else [],
             _validation = [validation_output] if validation_output else [],
         ),
     ]
@@ -198,14 +224,8 @@ def _go_binary_impl(ctx):
                 "windows": ["-mthreads"],
             }.get(go.mode.goos, ["-pthread"]),
         }
-        cgo_exports = archive.cgo_exports.to_list()
-        if cgo_exports:
-            header = ctx.actions.declare_file("{}.h".format(name))
-            ctx.actions.symlink(
-                output = header,
-                target_file = cgo_exports[0],
-            )
-            cc_import_kwargs["hdrs"] = depset([header])
+        if c_header:
+            cc_import_kwargs["hdrs"] = depset([c_header])
         if go.mode.linkmode == LINKMODE_C_SHARED:
             cc_import_kwargs["dynamic_library"] = executable
         elif go.mode.linkmode == LINKMODE_C_ARCHIVE:
@@ -271,7 +291,9 @@ def _go_binary_kwargs(go_cc_aspects = []):
                 `//go:embed` directives. All files must be in the same logical directory
                 or a subdirectory as source files. All source files containing `//go:embed`
                 directives must be in the same logical directory. It's okay to mix static and
-                generated source files and static and generated embeddable files.
+                generated source files and static and generated embeddable files. Files
+                generated by rules in other packages are embedded at their path relative to
+                the directory of their package.
                 """,
             ),
             "env": attr.string_dict(
@@ -338,13 +360,26 @@ def _go_binary_kwargs(go_cc_aspects = []):
                 """,
             ),
             "copts": attr.string_list(
-                doc = """List of flags to add to the C compilation command.
+                doc = """List of flags to add to the C and Objective-C compilation commands.
                 Subject to ["Make variable"] substitution and [Bourne shell tokenization].
                 Only valid if `cgo` = `True`.
                 """,
             ),
             "cxxopts": attr.string_list(
-                doc = """List of flags to add to the C++ compilation command.
+                doc = """List of flags to add to the C++ and Objective-C++ compilation commands,
+                for example to select the C++ standard.
+                Subject to ["Make variable"] substitution and [Bourne shell tokenization].
+                Only valid if `cgo` = `True`.
+                """,
+            ),
+            "objcopts": attr.string_list(
+                doc = """List of flags to add to the Objective-C compilation command, after `copts`.
+                Subject to ["Make variable"] substitution and [Bourne shell tokenization].
+                Only valid if `cgo` = `True`.
+                """,
+            ),
+            "objcxxopts": attr.string_list(
+                doc = """List of flags to add to the Objective-C++ compilation command, after `cxxopts`.
                 Subject to ["Make variable"] substitution and [Bourne shell tokenization].
                 Only valid if `cgo` = `True`.
                 """,
@@ -428,11 +463,11 @@ def _go_binary_kwargs(go_cc_aspects = []):
                 <br><br>
                 <ul>
                 <li>`auto` (default): Controlled by `//go/config:linkmode`, which defaults to `normal`.</li>
-                <li>`normal`: Builds a normal executable with position-dependent code.</li>
+                <li>`normal`: Builds a normal executable. Like the go command, this builds a position-independent executable on platforms that require it or use it by default, like Android, iOS, macOS and Windows, and position-dependent code elsewhere.</li>
                 <li>`pie`: Builds a position-independent executable.</li>
                 <li>`plugin`: Builds a shared library that can be loaded as a Go plugin. Only supported on platforms that support plugins.</li>
-                <li>`c-shared`: Builds a shared library that can be linked into a C program.</li>
-                <li>`c-archive`: Builds an archive that can be linked into a C program.</li>
+                <li>`c-shared`: Builds a shared library that can be linked into a C program. A header declaring the exported functions is in the `c_header` output group.</li>
+                <li>`c-archive`: Builds an archive that can be linked into a C program. A header declaring the exported functions is in the `c_header` output group.</li>
                 </ul>
                 """,
             ),
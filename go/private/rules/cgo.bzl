@@ -24,7 +24,7 @@ load(
     "extldflags_from_cc_toolchain",
 )
 
-def cgo_configure(go, srcs, cdeps, cppopts, copts, cxxopts, clinkopts):
+def cgo_configure(go, srcs, cdeps, cppopts, copts, cxxopts, objcopts, objcxxopts, clinkopts):
     """cgo_configure returns the inputs and compile / link options
     that are required to build a cgo archive.
 
@@ -35,8 +35,14 @@ def cgo_configure(go, srcs, cdeps, cppopts, copts, cxxopts, clinkopts):
         cdeps: list of Targets for C++ dependencies. Include and link options
             may be added.
         cppopts: list of C preprocessor options for the library.
-        copts: list of C compiler options for the library.
-        cxxopts: list of C++ compiler options for the library.
+        copts: list of C compiler options for the library. These also apply to
+            Objective-C sources.
+        cxxopts: list of C++ compiler options for the library. These also apply
+            to Objective-C++ sources.
+        objcopts: list of Objective-C compiler options for the library, which
+            follow copts.
+        objcxxopts: list of Objective-C++ compiler options for the library,
+            which follow cxxopts.
         clinkopts: list of linker options for the library.
 
     Returns: a struct containing:
@@ -56,8 +62,8 @@ def cgo_configure(go, srcs, cdeps, cppopts, copts, cxxopts, clinkopts):
     cppopts = list(cppopts)
     copts = go.cgo_tools.c_compile_options + copts
     cxxopts = go.cgo_tools.cxx_compile_options + cxxopts
-    objcopts = go.cgo_tools.objc_compile_options + copts
-    objcxxopts = go.cgo_tools.objcxx_compile_options + cxxopts
+    objcopts = go.cgo_tools.objc_compile_options + copts + objcopts
+    objcxxopts = go.cgo_tools.objcxx_compile_options + cxxopts + objcxxopts
     clinkopts = extldflags_from_cc_toolchain(go) + clinkopts
 
     # NOTE(#2545): avoid unnecessary dynamic link
@@ -50,6 +50,13 @@ def _go_library_impl(ctx):
     archive = go.archive(go, go_info)
     validation_output = archive.data._validation_output
     nogo_fix_output = archive.data._nogo_fix_output
+    nogo_fix_json_output = archive.data._nogo_fix_json_output
+    nogo_fix_dir_output = archive.data._nogo_fix_dir_output
+    nogo_log_output = archive.data._nogo_log_output
+    nogo_inspection_output = archive.data._nogo_inspection_output
+    nogo_diagnostics_output = archive.data._nogo_diagnostics_output
+    nogo_summary_output = archive.data._nogo_summary_output
+    nogo_metrics_output = archive.data._nogo_metrics_output
 
     return [
         go_info,
@@ -66,7 +73,13 @@ def _go_library_impl(ctx):
         OutputGroupInfo(
             cgo_exports = archive.cgo_exports,
             compilation_outputs = [archive.data.file],
-            nogo_fix = [nogo_fix_output] if nogo_fix_output else [],
+            gc_json_diagnostics = [archive.data._gc_json_diagnostics_output] if archive.data._gc_json_diagnostics_output else [],
+            nogo_diagnostics = [nogo_diagnostics_output] if nogo_diagnostics_output else [],
+            nogo_fix = [nogo_fix_output, nogo_fix_json_output, nogo_fix_dir_output, nogo_log_output] if nogo_fix_output else [],
+            nogo_inspection = [nogo_inspection_output] if nogo_inspection_output else [],
+            nogo_metrics = [nogo_metrics_output] if nogo_metrics_output else [],
+            nogo_validation = [validation_output] if validation_output else [],
+            nogo_validation_summary = [nogo_summary_output] if nogo_summary_output else [],
             _validation = [validation_output] if validation_output else [],
         ),
     ]
@@ -134,6 +147,8 @@ go_library = rule(
             directives. All files must be in the same logical directory or a subdirectory as source files.
             All source files containing `//go:embed` directives must be in the same logical directory.
             It's okay to mix static and generated source files and static and generated embeddable files.
+            Files generated by rules in other packages are embedded at their path relative to the directory
+            of their package.
             """,
         ),
         "gc_goopts": attr.string_list(
@@ -171,13 +186,25 @@ go_library = rule(
         ),
         "copts": attr.string_list(
             doc = """
-            List of flags to add to the C compilation command.
+            List of flags to add to the C and Objective-C compilation commands.
             Subject to ["Make variable"] substitution and [Bourne shell tokenization]. Only valid if `cgo = True`.
             """,
         ),
         "cxxopts": attr.string_list(
             doc = """
-            List of flags to add to the C++ compilation command.
+            List of flags to add to the C++ and Objective-C++ compilation commands, for example to select the C++ standard.
+            Subject to ["Make variable"] substitution and [Bourne shell tokenization]. Only valid if `cgo = True`.
+            """,
+        ),
+        "objcopts": attr.string_list(
+            doc = """
+            List of flags to add to the Objective-C compilation command, after `copts`.
+            Subject to ["Make variable"] substitution and [Bourne shell tokenization]. Only valid if `cgo = True`.
+            """,
+        ),
+        "objcxxopts": attr.string_list(
+            doc = """
+            List of flags to add to the Objective-C++ compilation command, after `cxxopts`.
             Subject to ["Make variable"] substitution and [Bourne shell tokenization]. Only valid if `cgo = True`.
             """,
         ),
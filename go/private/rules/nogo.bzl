@@ -32,7 +32,7 @@ load(
 )
 
 def _nogo_impl(ctx):
-    if not ctx.attr.deps:
+    if not ctx.attr.deps and not ctx.attr.external_analyzers:
         # If there aren't any analyzers to run, don't generate a binary.
         # go_context will check for this condition.
         return None
@@ -45,6 +45,20 @@ def _nogo_impl(ctx):
     nogo_args.add("-output", nogo_main)
     if ctx.attr.debug:
         nogo_args.add("-debug")
+    nogo_args.add("-patch_root", ctx.attr.patch_root)
+    nogo_args.add("-patch_src_prefix", ctx.attr.patch_src_prefix)
+    nogo_args.add("-patch_dst_prefix", ctx.attr.patch_dst_prefix)
+    nogo_args.add("-patch_strip", str(ctx.attr.patch_strip))
+    nogo_args.add("-fix_format", ctx.attr.fix_format)
+    if ctx.attr.verify_fixes:
+        nogo_args.add("-verify_fixes")
+    nogo_args.add("-fix_context", str(ctx.attr.fix_context))
+    if ctx.attr.diagnostic_patches:
+        nogo_args.add("-diagnostic_patches")
+    nogo_args.add("-fix_compression", ctx.attr.fix_compression)
+    nogo_args.add("-max_fix_file_size", str(ctx.attr.max_fix_file_size))
+    if ctx.attr.source_snippets:
+        nogo_args.add("-source_snippets")
     nogo_inputs = []
     analyzer_archives = [dep[GoArchive] for dep in ctx.attr.deps]
     analyzer_importpaths = [archive.data.importpath for archive in analyzer_archives]
@@ -52,6 +66,24 @@ def _nogo_impl(ctx):
     if ctx.file.config:
         nogo_args.add("-config", ctx.file.config)
         nogo_inputs.append(ctx.file.config)
+    if ctx.file.baseline:
+        nogo_args.add("-baseline", ctx.file.baseline)
+        nogo_inputs.append(ctx.file.baseline)
+
+    # External analyzers are runfiles of nogo rather than compiled into it, so
+    # that changing them doesn't relink nogo.
+    external_analyzers = [analyzer[DefaultInfo].files_to_run.executable for analyzer in ctx.attr.external_analyzers]
+    nogo_args.add_all(
+        [_rlocation_path(ctx, analyzer) for analyzer in external_analyzers],
+        before_each = "-external_analyzer",
+    )
+
+    # The facts of the standard library are computed with the nogo binary built
+    # below and shipped in its runfiles.
+    stdlib_facts = None
+    if ctx.attr.stdlib_facts:
+        stdlib_facts = go.declare_file(go, path = "stdlib_facts.zip")
+        nogo_args.add("-stdlib_facts", _rlocation_path(ctx, stdlib_facts))
     ctx.actions.run(
         inputs = nogo_inputs,
         outputs = [nogo_main],
@@ -65,7 +97,7 @@ def _nogo_impl(ctx):
         go,
         struct(
             embed = [ctx.attr._nogo_srcs],
-            deps = analyzer_archives + [ctx.attr._go_difflib[GoArchive]],
+            deps = analyzer_archives,
         ),
         generated_srcs = [nogo_main],
         name = go.label.name + "~nogo",
@@ -79,12 +111,44 @@ def _nogo_impl(ctx):
         name = ctx.label.name,
         source = nogo_info,
     )
+    if stdlib_facts:
+        _emit_stdlib_facts(go, executable, stdlib_facts)
+        runfiles = runfiles.merge(ctx.runfiles(files = [stdlib_facts]))
+    runfiles = runfiles.merge_all(
+        [ctx.runfiles(files = external_analyzers)] +
+        [analyzer[DefaultInfo].default_runfiles for analyzer in ctx.attr.external_analyzers],
+    )
     return [DefaultInfo(
         files = depset([executable]),
         runfiles = runfiles,
         executable = executable,
     )]
 
+def _emit_stdlib_facts(go, nogo, out):
+    """Runs nogo on the standard library to collect its facts into out."""
+    args = go.builder_args(go, "nogostdlibfacts")
+    args.add("-list_json", go.stdlib._list_json)
+    args.add("-nogo", nogo)
+    args.add("-out", out)
+    go.actions.run(
+        inputs = depset(
+            [go.stdlib._list_json],
+            transitive = [go.sdk.srcs, go.stdlib.libs, go.stdlib.cache_dir],
+        ),
+        tools = [nogo],
+        outputs = [out],
+        mnemonic = "GoNogoStdlibFacts",
+        executable = go.toolchain._builder,
+        arguments = [args],
+        env = go.env,
+        progress_message = "Computing nogo facts of the standard library for %{label}",
+    )
+
+def _rlocation_path(ctx, file):
+    if file.short_path.startswith("../"):
+        return file.short_path[len("../"):]
+    return ctx.workspace_name + "/" + file.short_path
+
 _nogo = rule(
     implementation = _nogo_impl,
     attrs = {
@@ -97,12 +161,57 @@ _nogo = rule(
         "debug": attr.bool(
             default = False,
         ),
+        "patch_root": attr.string(
+            default = "execroot",
+            values = ["execroot", "workspace", "package"],
+        ),
+        "patch_src_prefix": attr.string(
+            default = "a/",
+        ),
+        "patch_dst_prefix": attr.string(
+            default = "b/",
+        ),
+        "patch_strip": attr.int(
+            default = -1,
+        ),
+        "fix_format": attr.string(
+            default = "none",
+            values = ["none", "gofmt", "goimports"],
+        ),
+        "verify_fixes": attr.bool(
+            default = False,
+        ),
+        "fix_context": attr.int(
+            default = 3,
+        ),
+        "diagnostic_patches": attr.bool(
+            default = False,
+        ),
+        "fix_compression": attr.string(
+            default = "none",
+            values = ["none", "gzip"],
+        ),
+        "max_fix_file_size": attr.int(
+            default = 0,
+        ),
+        "source_snippets": attr.bool(
+            default = False,
+        ),
+        "baseline": attr.label(
+            allow_single_file = True,
+        ),
+        "external_analyzers": attr.label_list(
+            cfg = "exec",
+            executable = True,
+        ),
+        "stdlib_facts": attr.bool(
+            default = False,
+        ),
         "_nogo_srcs": attr.label(
             default = "//go/tools/builders:nogo_srcs",
         ),
         "_cgo_context_data": attr.label(default = "//:cgo_context_data_proxy"),
         "_go_config": attr.label(default = "//:go_config"),
-        "_go_difflib": attr.label(default = "@com_github_pmezard_go_difflib//difflib:go_default_library"),
         "_stdlib": attr.label(default = "//:stdlib"),
         "_allowlist_function_transition": attr.label(
             default = "@bazel_tools//tools/allowlists/function_transition_allowlist",
@@ -110,6 +219,8 @@ _nogo = rule(
     },
     toolchains = [GO_TOOLCHAIN],
     cfg = go_tool_transition,
+    # Runnable to explain the analyzers, see nogo_explain.go.
+    executable = True,
 )
 
 def nogo(name, visibility = None, **kwargs):
@@ -0,0 +1,138 @@
+# Copyright 2026 The Bazel Authors. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#    http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+load(
+    "//go/private:providers.bzl",
+    "GoArchive",
+)
+
+def _fix_files(deps):
+    archives = depset(transitive = [dep[GoArchive].transitive for dep in deps])
+    return [
+        archive._nogo_fix_output
+        for archive in archives.to_list()
+        if archive._nogo_fix_output
+    ]
+
+def _apply_providers(ctx, fix_files, files = None):
+    """Returns the providers of an executable that applies fix_files with nogo_apply."""
+
+    # nogo_apply reads the patches relative to the runfiles directory of the
+    # main repository, which is its working directory under bazel run.
+    patch_list = ctx.actions.declare_file(ctx.label.name + "~patches.txt")
+    ctx.actions.write(patch_list, "".join([f.short_path + "\n" for f in fix_files]))
+
+    nogo_apply = ctx.attr._nogo_apply[DefaultInfo]
+    executable = ctx.actions.declare_file(ctx.label.name + "~" + ctx.executable._nogo_apply.basename)
+    ctx.actions.symlink(
+        output = executable,
+        target_file = ctx.executable._nogo_apply,
+        is_executable = True,
+    )
+    runfiles = ctx.runfiles(files = fix_files + [patch_list, ctx.executable._nogo_apply])
+    runfiles = runfiles.merge(nogo_apply.default_runfiles)
+
+    return [
+        DefaultInfo(
+            files = files,
+            executable = executable,
+            runfiles = runfiles,
+        ),
+        RunEnvironmentInfo(environment = {"NOGO_APPLY_PATCH_LIST": patch_list.short_path}),
+    ]
+
+def _nogo_fix_impl(ctx):
+    return _apply_providers(ctx, _fix_files(ctx.attr.deps))
+
+nogo_fix = rule(
+    _nogo_fix_impl,
+    attrs = {
+        "deps": attr.label_list(
+            providers = [GoArchive],
+            doc = """Targets that build Go packages ([go_library], [go_binary], [go_test], and
+            similar rules). The fixes suggested for these packages and all of their transitive
+            dependencies that are analyzed by nogo are applied.
+            """,
+        ),
+        "_nogo_apply": attr.label(
+            default = "//go/tools/builders:nogo_apply",
+            executable = True,
+            cfg = "target",
+        ),
+    },
+    executable = True,
+    doc = """`nogo_fix` applies the fixes suggested by nogo to the sources in the workspace when
+    run with `bazel run`, similar to running Gazelle with `-mode=fix`.
+
+    The fixes of all packages are merged: identical fixes suggested for a library and its test
+    are applied once, and files with conflicting fixes are left untouched and reported.
+    Since the build fails on nogo findings by default, run the target with
+    `--norun_validations`.
+    """,
+)
+
+def _nogo_fix_aggregate_impl(ctx):
+    fix_files = _fix_files(ctx.attr.deps)
+    merged_patch = ctx.actions.declare_file(ctx.label.name + ".patch")
+
+    args = ctx.actions.args()
+    args.add("-o", merged_patch)
+    patch_list = ctx.actions.args()
+    patch_list.add_all(fix_files)
+    patch_list.use_param_file("-patch_list=%s", use_always = True)
+    patch_list.set_param_file_format("multiline")
+    ctx.actions.run(
+        executable = ctx.executable._nogo_aggregate,
+        arguments = [args, patch_list],
+        inputs = fix_files,
+        outputs = [merged_patch],
+        mnemonic = "GoNogoFixAggregate",
+        progress_message = "Merging nogo fixes for %{label}",
+    )
+
+    return _apply_providers(ctx, [merged_patch], files = depset([merged_patch]))
+
+nogo_fix_aggregate = rule(
+    _nogo_fix_aggregate_impl,
+    attrs = {
+        "deps": attr.label_list(
+            providers = [GoArchive],
+            doc = """Targets that build Go packages ([go_library], [go_binary], [go_test], and
+            similar rules). The fixes suggested for these packages and all of their transitive
+            dependencies that are analyzed by nogo are merged.
+            """,
+        ),
+        "_nogo_aggregate": attr.label(
+            default = "//go/tools/builders:nogo_aggregate",
+            executable = True,
+            cfg = "exec",
+        ),
+        "_nogo_apply": attr.label(
+            default = "//go/tools/builders:nogo_apply",
+            executable = True,
+            cfg = "target",
+        ),
+    },
+    executable = True,
+    doc = """`nogo_fix_aggregate` merges the fixes suggested by nogo into a single patch for the
+    whole workspace, `<name>.patch`, which is built by `bazel build` and applied to the sources
+    in the workspace by `bazel run`.
+
+    Identical fixes suggested for different targets are included once. Files with conflicting
+    fixes are left out of the patch and reported when it is built. The fix files are merged
+    without reading the sources, so the `patch_root` of the nogo target must not be `package`.
+    Since the build fails on nogo findings by default, build or run the target with
+    `--norun_validations`.
+    """,
+)
@@ -0,0 +1,158 @@
+# Copyright 2026 The Bazel Authors. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#    http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+load(
+    "//go/private:providers.bzl",
+    "GoArchive",
+)
+
+def _nogo_summary_impl(ctx):
+    archives = depset(transitive = [dep[GoArchive].transitive for dep in ctx.attr.deps])
+
+    inputs = []
+    manifest_entries = []
+    for archive in archives.to_list():
+        # Only packages that are validated by nogo can fail the build, so only
+        # those are part of the summary.
+        if not archive._validation_output:
+            continue
+        inputs.append(archive._nogo_log_output)
+        inputs.append(archive._nogo_fix_output)
+        manifest_entries.append(struct(
+            label = str(archive.label),
+            package = archive.importmap,
+            log = archive._nogo_log_output.path,
+            fix = archive._nogo_fix_output.path,
+        ))
+
+    manifest_file = ctx.actions.declare_file(ctx.label.name + "~manifest")
+    ctx.actions.write(manifest_file, json.encode(manifest_entries))
+    inputs.append(manifest_file)
+
+    out = ctx.actions.declare_file(ctx.label.name + ".txt")
+    args = ctx.actions.args()
+    args.add("-manifest", manifest_file)
+    args.add("-out", out)
+    ctx.actions.run(
+        outputs = [out],
+        inputs = inputs,
+        mnemonic = "GoNogoSummary",
+        executable = ctx.executable._nogo_summary,
+        arguments = [args],
+        progress_message = "Summarizing nogo findings for %{label}",
+    )
+
+    return [DefaultInfo(files = depset([out]))]
+
+def _nogo_report_impl(ctx):
+    archives = depset(transitive = [dep[GoArchive].transitive for dep in ctx.attr.deps])
+
+    files = []
+    manifest_entries = []
+    for archive in archives.to_list():
+        # Like the summary, the report covers the packages whose findings can
+        # fail the build.
+        if not archive._validation_output:
+            continue
+        files.append(archive._nogo_log_output)
+        files.append(archive._nogo_fix_output)
+
+        # The copies are named after the path of the log, which, unlike the path
+        # of the log itself, doesn't depend on the configuration.
+        name = archive._nogo_log_output.short_path[:-len(".nogo.log")]
+        if name.startswith("../"):
+            name = "external/" + name[len("../"):]
+        manifest_entries.append(struct(
+            name = name,
+            log = archive._nogo_log_output.short_path,
+            fix = archive._nogo_fix_output.short_path,
+        ))
+
+    # nogo_report reads the outputs relative to the runfiles directory of the
+    # main repository, which is its working directory under bazel run.
+    manifest_file = ctx.actions.declare_file(ctx.label.name + "~manifest.json")
+    ctx.actions.write(manifest_file, json.encode(manifest_entries))
+
+    nogo_report = ctx.attr._nogo_report[DefaultInfo]
+    executable = ctx.actions.declare_file(ctx.label.name + "~" + ctx.executable._nogo_report.basename)
+    ctx.actions.symlink(
+        output = executable,
+        target_file = ctx.executable._nogo_report,
+        is_executable = True,
+    )
+    runfiles = ctx.runfiles(files = files + [manifest_file, ctx.executable._nogo_report])
+    runfiles = runfiles.merge(nogo_report.default_runfiles)
+
+    return [
+        DefaultInfo(
+            executable = executable,
+            runfiles = runfiles,
+        ),
+        RunEnvironmentInfo(environment = {"NOGO_REPORT_MANIFEST": manifest_file.short_path}),
+    ]
+
+nogo_report = rule(
+    _nogo_report_impl,
+    attrs = {
+        "deps": attr.label_list(
+            providers = [GoArchive],
+            doc = """Targets that build Go packages ([go_library], [go_binary], [go_test], and
+            similar rules). The report covers the packages built by these targets and all of
+            their transitive dependencies that are validated by nogo.
+            """,
+        ),
+        "_nogo_report": attr.label(
+            default = "//go/tools/builders:nogo_report",
+            executable = True,
+            cfg = "target",
+        ),
+    },
+    executable = True,
+    doc = """`nogo_report` copies the nogo logs and fix files of many targets to a directory when
+    run with `bazel run`, e.g. `bazel run //:nogo_report -- nogo-report`.
+
+    The paths of the outputs of nogo under `bazel-out` depend on the configuration. The copies
+    are named after the package and the target instead, as `<dir>/<package>/<name>.nogo.log`
+    and `<dir>/<package>/<name>.nogo.patch`, with targets from other repositories under
+    `<dir>/external/<repo>`. Compressed fix files are decompressed. The copies of targets
+    without findings are removed. Relative directories are resolved against the root of the
+    workspace. Since the build fails on nogo findings by default, run the target with
+    `--norun_validations`.
+    """,
+)
+
+nogo_summary = rule(
+    _nogo_summary_impl,
+    attrs = {
+        "deps": attr.label_list(
+            providers = [GoArchive],
+            doc = """Targets that build Go packages ([go_library], [go_binary], [go_test], and
+            similar rules). The summary covers the packages built by these targets and all of
+            their transitive dependencies that are validated by nogo.
+            """,
+        ),
+        "_nogo_summary": attr.label(
+            default = "//go/tools/builders:nogo_summary",
+            executable = True,
+            cfg = "exec",
+        ),
+    },
+    doc = """`nogo_summary` writes the nogo findings and the paths of the suggested fix files
+    of many targets into a single text file.
+
+    The nogo actions that produce findings don't fail, only the separate validation actions do.
+    When building with `--keep_going`, the summary is therefore produced even if some of the
+    validations fail, which makes it a convenient place to collect all findings of a build.
+    """,
+)
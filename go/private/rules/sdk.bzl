@@ -26,6 +26,7 @@ def _go_sdk_impl(ctx):
         goos = ctx.attr.goos,
         goarch = ctx.attr.goarch,
         experiments = ",".join(ctx.attr.experiments),
+        compiler = ctx.attr.compiler,
         root_file = ctx.file.root_file,
         package_list = package_list,
         libs = depset(ctx.files.libs),
@@ -51,6 +52,13 @@ go_sdk = rule(
             mandatory = False,
             doc = "Go experiments to enable via GOEXPERIMENT",
         ),
+        "compiler": attr.string(
+            default = "gc",
+            values = ["gc", "gccgo"],
+            doc = ("The Go compiler of the SDK. For gccgo, bin/gccgo in the " +
+                   "SDK root directory is used to compile and link, and the " +
+                   "standard library is the precompiled libgo."),
+        ),
         "root_file": attr.label(
             mandatory = True,
             allow_single_file = True,
@@ -96,7 +104,7 @@ go_sdk = rule(
         ),
     },
     doc = ("Collects information about a Go SDK. The SDK must have a normal " +
-           "GOROOT directory structure."),
+           "GOROOT directory structure, unless it uses gccgo."),
     provides = [GoSDK],
 )
 
@@ -70,6 +70,11 @@ def _go_test_impl(ctx):
 
     validation_outputs = []
     nogo_fix_outputs = []
+    nogo_inspection_outputs = []
+    nogo_diagnostics_outputs = []
+    nogo_summary_outputs = []
+    nogo_metrics_outputs = []
+    gc_json_diagnostics_outputs = []
 
     # Compile the library to test with internal white box tests
     internal_go_info = new_go_info(
@@ -82,6 +87,19 @@ def _go_test_impl(ctx):
         validation_outputs.append(internal_archive.data._validation_output)
     if internal_archive.data._nogo_fix_output:
         nogo_fix_outputs.append(internal_archive.data._nogo_fix_output)
+        nogo_fix_outputs.append(internal_archive.data._nogo_fix_json_output)
+        nogo_fix_outputs.append(internal_archive.data._nogo_fix_dir_output)
+        nogo_fix_outputs.append(internal_archive.data._nogo_log_output)
+    if internal_archive.data._nogo_inspection_output:
+        nogo_inspection_outputs.append(internal_archive.data._nogo_inspection_output)
+    if internal_archive.data._nogo_diagnostics_output:
+        nogo_diagnostics_outputs.append(internal_archive.data._nogo_diagnostics_output)
+    if internal_archive.data._nogo_summary_output:
+        nogo_summary_outputs.append(internal_archive.data._nogo_summary_output)
+    if internal_archive.data._nogo_metrics_output:
+        nogo_metrics_outputs.append(internal_archive.data._nogo_metrics_output)
+    if internal_archive.data._gc_json_diagnostics_output:
+        gc_json_diagnostics_outputs.append(internal_archive.data._gc_json_diagnostics_output)
     go_srcs = [src for src in internal_go_info.srcs if src.extension == "go"]
 
     # Compile the library with the external black box tests
@@ -104,6 +122,19 @@ def _go_test_impl(ctx):
         validation_outputs.append(external_archive.data._validation_output)
     if external_archive.data._nogo_fix_output:
         nogo_fix_outputs.append(external_archive.data._nogo_fix_output)
+        nogo_fix_outputs.append(external_archive.data._nogo_fix_json_output)
+        nogo_fix_outputs.append(external_archive.data._nogo_fix_dir_output)
+        nogo_fix_outputs.append(external_archive.data._nogo_log_output)
+    if external_archive.data._nogo_inspection_output:
+        nogo_inspection_outputs.append(external_archive.data._nogo_inspection_output)
+    if external_archive.data._nogo_diagnostics_output:
+        nogo_diagnostics_outputs.append(external_archive.data._nogo_diagnostics_output)
+    if external_archive.data._nogo_summary_output:
+        nogo_summary_outputs.append(external_archive.data._nogo_summary_output)
+    if external_archive.data._nogo_metrics_output:
+        nogo_metrics_outputs.append(external_archive.data._nogo_metrics_output)
+    if external_archive.data._gc_json_diagnostics_output:
+        gc_json_diagnostics_outputs.append(external_archive.data._gc_json_diagnostics_output)
 
     # now generate the main function
     repo_relative_rundir = ctx.attr.rundir or ctx.label.package or "."
@@ -212,7 +243,13 @@ def _go_test_impl(ctx):
         ),
         OutputGroupInfo(
             compilation_outputs = [internal_archive.data.file],
+            gc_json_diagnostics = gc_json_diagnostics_outputs,
+            nogo_diagnostics = nogo_diagnostics_outputs,
             nogo_fix = nogo_fix_outputs,
+            nogo_inspection = nogo_inspection_outputs,
+            nogo_metrics = nogo_metrics_outputs,
+            nogo_validation = validation_outputs,
+            nogo_validation_summary = nogo_summary_outputs,
             _validation = validation_outputs,
         ),
         coverage_common.instrumented_files_info(
@@ -271,7 +308,9 @@ _go_test_kwargs = {
             `//go:embed` directives. All files must be in the same logical directory
             or a subdirectory as source files. All source files containing `//go:embed`
             directives must be in the same logical directory. It's okay to mix static and
-            generated source files and static and generated embeddable files.
+            generated source files and static and generated embeddable files. Files
+            generated by rules in other packages are embedded at their path relative to
+            the directory of their package.
             """,
         ),
         "env": attr.string_dict(
@@ -334,7 +373,7 @@ _go_test_kwargs = {
             <br><br>
             <ul>
             <li>`auto` (default): Controlled by `//go/config:linkmode`, which defaults to `normal`.</li>
-            <li>`normal`: Builds a normal executable with position-dependent code.</li>
+            <li>`normal`: Builds a normal executable. Like the go command, this builds a position-independent executable on platforms that require it or use it by default, like Android, iOS, macOS and Windows, and position-dependent code elsewhere.</li>
             <li>`pie`: Builds a position-independent executable.</li>
             <li>`plugin`: Builds a shared library that can be loaded as a Go plugin. Only supported on platforms that support plugins.</li>
             <li>`c-shared`: Builds a shared library that can be linked into a C program.</li>
@@ -365,13 +404,26 @@ _go_test_kwargs = {
             """,
         ),
         "copts": attr.string_list(
-            doc = """List of flags to add to the C compilation command.
+            doc = """List of flags to add to the C and Objective-C compilation commands.
             Subject to ["Make variable"] substitution and [Bourne shell tokenization].
             Only valid if `cgo` = `True`.
             """,
         ),
         "cxxopts": attr.string_list(
-            doc = """List of flags to add to the C++ compilation command.
+            doc = """List of flags to add to the C++ and Objective-C++ compilation commands,
+            for example to select the C++ standard.
+            Subject to ["Make variable"] substitution and [Bourne shell tokenization].
+            Only valid if `cgo` = `True`.
+            """,
+        ),
+        "objcopts": attr.string_list(
+            doc = """List of flags to add to the Objective-C compilation command, after `copts`.
+            Subject to ["Make variable"] substitution and [Bourne shell tokenization].
+            Only valid if `cgo` = `True`.
+            """,
+        ),
+        "objcxxopts": attr.string_list(
+            doc = """List of flags to add to the Objective-C++ compilation command, after `cxxopts`.
             Subject to ["Make variable"] substitution and [Bourne shell tokenization].
             Only valid if `cgo` = `True`.
             """,
@@ -706,6 +758,8 @@ def _recompile_external_deps(go, external_go_info, internal_archive, library_lab
             cppopts = as_list(arc_data._cppopts),
             copts = as_list(arc_data._copts),
             cxxopts = as_list(arc_data._cxxopts),
+            objcopts = as_list(arc_data._objcopts),
+            objcxxopts = as_list(arc_data._objcxxopts),
             clinkopts = as_list(arc_data._clinkopts),
         )
 
@@ -20,8 +20,11 @@
 package main
 
 import (
+	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
 )
 
 func main() {
@@ -33,26 +36,127 @@ func main() {
 		log.Fatal(err)
 	}
 
-	verb := verbFromName(os.Args[0])
-	if verb == "" && len(args) == 0 {
-		log.Fatalf("usage: %s verb options...", os.Args[0])
+	if startupArgs, ok := persistentWorkerArgs(args); ok {
+		if err := serveBuilderWorker(startupArgs); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
-	var rest []string
+	args, err = expandWorkerFlagFile(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := runAction(args, false, os.Stderr); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// workerVerbs are the verbs that can be run by a persistent worker, mapped to
+// whether they can also be run by a multiplex worker, which handles concurrent
+// requests in the same process. The other actions aren't run often enough per
+// build to amortize the startup of one.
+var workerVerbs = map[string]bool{
+	"compilepkg": true,
+	"nogo":       false,
+}
+
+// serveBuilderWorker runs the builder as a persistent worker. Bazel passes the
+// options shared by all actions of the worker, such as the verb, on startup
+// and the remaining ones in each request.
+func serveBuilderWorker(startupArgs []string) error {
+	verb, _ := splitVerb(startupArgs)
+	multiplex := workerVerbs[verb]
+	if verb == "nogo" {
+		// The nogo actions are passed on to a warm nogo, which is stopped
+		// once Bazel shuts the builder down.
+		startNogoWorkers()
+		defer closeNogoWorkers()
+	}
+	if multiplex {
+		// Apply the build tags of the worker before serving, so that the
+		// concurrent requests, which pass them again, only read them.
+		args, _, err := expandParamsFiles(startupArgs)
+		if err != nil {
+			return err
+		}
+		if err := setBuildTags(args); err != nil {
+			return err
+		}
+	}
+	handle := func(reqArgs []string, output io.Writer) int {
+		if !multiplex {
+			log.SetOutput(output)
+			defer log.SetOutput(os.Stderr)
+		}
+		args, _, err := expandParamsFiles(append(startupArgs[:len(startupArgs):len(startupArgs)], reqArgs...))
+		if err == nil {
+			err = runAction(args, true, output)
+		}
+		if err != nil {
+			fmt.Fprintf(output, "%s: %v\n", verb, err)
+			return 1
+		}
+		return 0
+	}
+	if multiplex {
+		return serveMultiplexWorker(os.Stdin, os.Stdout, handle)
+	}
+	return serveWorker(os.Stdin, os.Stdout, handle)
+}
+
+// setBuildTags applies the values of the -tags flags in args.
+func setBuildTags(args []string) error {
+	for i, arg := range args {
+		var value string
+		switch {
+		case arg == "-tags" && i+1 < len(args):
+			value = args[i+1]
+		case strings.HasPrefix(arg, "-tags="):
+			value = strings.TrimPrefix(arg, "-tags=")
+		default:
+			continue
+		}
+		if err := (&tagFlag{}).Set(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitVerb returns the verb of an action, which is either the name of the
+// builder or its first argument, and the remaining arguments.
+func splitVerb(args []string) (string, []string) {
+	if verb := verbFromName(os.Args[0]); verb != "" {
+		return verb, args
+	}
+	if len(args) == 0 {
+		return "", nil
+	}
+	return args[0], args[1:]
+}
+
+// runAction runs the action named by the verb, see splitVerb. The actions that
+// can run concurrently in a multiplex worker write the output of the tools
+// they run to output.
+func runAction(args []string, asWorker bool, output io.Writer) error {
+	verb, rest := splitVerb(args)
 	if verb == "" {
-		verb, rest = args[0], args[1:]
-	} else {
-		rest = args
+		return fmt.Errorf("usage: %s verb options...", os.Args[0])
 	}
 
 	var action func(args []string) error
 	switch verb {
 	case "compilepkg":
-		action = compilePkg
+		action = func(args []string) error {
+			return compilePkgWithOutput(args, output)
+		}
 	case "nogo":
 		action = nogo
 	case "nogovalidation":
 		action = nogoValidation
+	case "nogostdlibfacts":
+		action = nogoStdlibFacts
 	case "filterbuildid":
 		action = filterBuildID
 	case "gentestmain":
@@ -63,16 +167,18 @@ func main() {
 		action = genNogoMain
 	case "stdlib":
 		action = stdlib
+	case "stdlibarchive":
+		action = stdlibArchive
 	case "stdliblist":
 		action = stdliblist
 	case "cc":
 		action = cc
 	default:
-		log.Fatalf("unknown action: %s", verb)
+		return fmt.Errorf("unknown action: %s", verb)
 	}
-	log.SetPrefix(verb + ": ")
-
-	if err := action(rest); err != nil {
-		log.Fatal(err)
+	if _, ok := workerVerbs[verb]; asWorker && !ok {
+		return fmt.Errorf("action %s can't be run by a persistent worker", verb)
 	}
+	log.SetPrefix(verb + ": ")
+	return action(rest)
 }
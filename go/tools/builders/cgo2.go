@@ -94,8 +94,13 @@ func cgo2(goenv *env, goSrcs, cgoSrcs, cSrcs, cxxSrcs, objcSrcs, objcxxSrcs, sSr
 			}
 		}
 	}
+	// Like the go command, link Objective-C code against the Objective-C
+	// runtime.
+	if len(objcSrcs)+len(objcxxSrcs) > 0 {
+		combinedLdFlags = append(combinedLdFlags, "-lobjc")
+	}
 	combinedLdFlags = append(combinedLdFlags, defaultLdFlags()...)
-	os.Setenv("CGO_LDFLAGS", strings.Join(combinedLdFlags, " "))
+	goenv.cmdEnv = append(goenv.cmdEnv, "CGO_LDFLAGS="+strings.Join(combinedLdFlags, " "))
 
 	// If cgo sources are in different directories, gather them into a temporary
 	// directory so we can use -srcdir.
@@ -200,7 +205,7 @@ func cgo2(goenv *env, goSrcs, cgoSrcs, cSrcs, cxxSrcs, objcSrcs, objcxxSrcs, sSr
 	args = append([]string{cc, "-o", mainBin, mainObj}, cObjs...)
 	args = append(args, combinedLdFlags...)
 	var originalErrBuf bytes.Buffer
-	if err := goenv.runCommandToFile(os.Stdout, &originalErrBuf, args); err != nil {
+	if err := goenv.runCommandToFile(goenv.stderr(), &originalErrBuf, args); err != nil {
 		// If linking the binary for cgo fails, this is usually because the
 		// object files reference external symbols that can't be resolved yet.
 		// Since the binary is only produced to have its symbols read by the cgo
@@ -223,11 +228,11 @@ func cgo2(goenv *env, goSrcs, cgoSrcs, cSrcs, cxxSrcs, objcSrcs, objcxxSrcs, sSr
 		// particular compiler/linker pair and would obscure the true reason for
 		// the failure of the original command.
 		if err2 := goenv.runCommandToFile(
-			os.Stdout,
+			goenv.stderr(),
 			ioutil.Discard,
 			append(args, allowUnresolvedSymbolsLdFlag),
 		); err2 != nil {
-			os.Stderr.Write(relativizePaths(originalErrBuf.Bytes()))
+			goenv.stderr().Write(relativizePaths(originalErrBuf.Bytes()))
 			return "", nil, nil, err
 		}
 		// Do not print the original error - rerunning the command with the
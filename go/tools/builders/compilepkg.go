@@ -20,6 +20,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
@@ -28,32 +29,45 @@ import (
 )
 
 func compilePkg(args []string) error {
+	return compilePkgWithOutput(args, os.Stderr)
+}
+
+// compilePkgWithOutput compiles a package like compilePkg and writes the output
+// of the tools it runs, such as compiler errors, to output. It only changes
+// the state of the process in ways that are the same for all packages of a
+// persistent worker, so packages can be compiled concurrently.
+func compilePkgWithOutput(args []string, output io.Writer) error {
 	// Parse arguments.
 	args, _, err := expandParamsFiles(args)
 	if err != nil {
 		return err
 	}
 
-	fs := flag.NewFlagSet("GoCompilePkg", flag.ExitOnError)
+	fs := flag.NewFlagSet("GoCompilePkg", flag.ContinueOnError)
+	fs.SetOutput(output)
 	goenv := envFlags(fs)
-	var unfilteredSrcs, coverSrcs, embedSrcs, embedLookupDirs, embedRoots, recompileInternalDeps multiFlag
+	goenv.output = output
+	var unfilteredSrcs, coverSrcs, embedSrcs, embedLookupDirs, embedRoots, embedPackageDirArgs, recompileInternalDeps multiFlag
 	var deps archiveMultiFlag
 	var importPath, packagePath, packageListPath, coverMode string
-	var outLinkobjPath, outInterfacePath, cgoExportHPath, cgoGoSrcsPath string
+	var outLinkobjPath, outInterfacePath, cgoExportHPath, cgoGoSrcsPath, gcJSONDiagnosticsPath string
 	var testFilter string
 	var gcFlags, asmFlags, cppFlags, cFlags, cxxFlags, objcFlags, objcxxFlags, ldFlags quoteMultiFlag
 	var coverFormat string
 	var pgoprofile string
+	var spectre spectreFlag
 	fs.Var(&unfilteredSrcs, "src", ".go, .c, .cc, .m, .mm, .s, or .S file to be filtered and compiled")
 	fs.Var(&coverSrcs, "cover", ".go file that should be instrumented for coverage (must also be a -src)")
 	fs.Var(&embedSrcs, "embedsrc", "file that may be compiled into the package with a //go:embed directive")
 	fs.Var(&embedLookupDirs, "embedlookupdir", "Root-relative paths to directories relative to which //go:embed directives are resolved")
 	fs.Var(&embedRoots, "embedroot", "Bazel output root under which a file passed via -embedsrc resides")
+	fs.Var(&embedPackageDirArgs, "embedpackagedir", "Directory of the Bazel package that owns a generated file passed via -embedsrc and the file, separated by '='")
 	fs.Var(&deps, "arc", "Import path, package path, and file name of a direct dependency, separated by '='")
 	fs.StringVar(&importPath, "importpath", "", "The import path of the package being compiled. Not passed to the compiler, but may be displayed in debug data.")
 	fs.StringVar(&packagePath, "p", "", "The package path (importmap) of the package being compiled")
 	fs.Var(&gcFlags, "gcflags", "Go compiler flags")
 	fs.Var(&asmFlags, "asmflags", "Go assembler flags")
+	fs.Var(&spectre, "spectre", "Comma-separated list of Spectre mitigations to enable in the compiler and assembler")
 	fs.Var(&cppFlags, "cppflags", "C preprocessor flags")
 	fs.Var(&cFlags, "cflags", "C compiler flags")
 	fs.Var(&cxxFlags, "cxxflags", "C++ compiler flags")
@@ -66,6 +80,7 @@ func compilePkg(args []string) error {
 	fs.StringVar(&outInterfacePath, "o", "", "The export-only output archive required to compile dependent packages")
 	fs.StringVar(&cgoExportHPath, "cgoexport", "", "The _cgo_exports.h file to write")
 	fs.StringVar(&cgoGoSrcsPath, "cgo_go_srcs", "", "The directory to emit cgo-generated Go sources for nogo consumption to")
+	fs.StringVar(&gcJSONDiagnosticsPath, "gc_json_diagnostics", "", "The directory the compiler writes its JSON optimization diagnostics to")
 	fs.StringVar(&testFilter, "testfilter", "off", "Controls test package filtering")
 	fs.StringVar(&coverFormat, "cover_format", "", "Emit source file paths in coverage instrumentation suitable for the specified coverage format")
 	fs.Var(&recompileInternalDeps, "recompile_internal_deps", "The import path of the direct dependencies that needs to be recompiled.")
@@ -79,6 +94,9 @@ func compilePkg(args []string) error {
 	if importPath == "" {
 		importPath = packagePath
 	}
+	if goenv.compiler == "gccgo" && (coverMode != "" || pgoprofile != "" || len(spectre) > 0 || gcJSONDiagnosticsPath != "") {
+		return errors.New("coverage, PGO, Spectre mitigations and JSON diagnostics are not supported by gccgo")
+	}
 	cgoEnabled := os.Getenv("CGO_ENABLED") == "1"
 	cc := os.Getenv("CC")
 	outLinkobjPath = abs(outLinkobjPath)
@@ -88,9 +106,24 @@ func compilePkg(args []string) error {
 	for i := range embedSrcs {
 		embedSrcs[i] = abs(embedSrcs[i])
 	}
+	embedPackageDirs := make(map[string]string, len(embedPackageDirArgs))
+	for _, arg := range embedPackageDirArgs {
+		i := strings.IndexByte(arg, '=')
+		if i < 0 {
+			return fmt.Errorf("-embedpackagedir %q doesn't have the form dir=file", arg)
+		}
+		embedPackageDirs[abs(arg[i+1:])] = abs(arg[:i])
+	}
 	if pgoprofile != "" {
 		pgoprofile = abs(pgoprofile)
 	}
+	gcFlags = append(gcFlags, spectre.gcflags()...)
+	if gcJSONDiagnosticsPath != "" {
+		// The compiler writes a file for each source file with diagnostics
+		// into a subdirectory named after the package path.
+		gcFlags = append(gcFlags, "-json=0,"+abs(gcJSONDiagnosticsPath))
+	}
+	asmFlags = append(asmFlags, spectre.asmflags()...)
 
 	// Filter sources.
 	srcs, err := filterAndSplitFiles(unfilteredSrcs)
@@ -114,6 +147,7 @@ func compilePkg(args []string) error {
 		embedSrcs,
 		embedLookupDirs,
 		embedRoots,
+		embedPackageDirs,
 		cgoEnabled,
 		cc,
 		gcFlags,
@@ -145,6 +179,7 @@ func compileArchive(
 	embedSrcs []string,
 	embedLookupDirs []string,
 	embedRoots []string,
+	embedPackageDirs map[string]string,
 	cgoEnabled bool,
 	cc string,
 	gcFlags []string,
@@ -235,6 +270,9 @@ func compileArchive(
 	// containing Cgo files can also be built with Cgo disabled, and will work if there are build
 	// constraints.
 	compilingWithCgo := haveCgo && cgoEnabled
+	if compilingWithCgo && goenv.compiler == "gccgo" {
+		return errors.New("cgo is not supported by gccgo")
+	}
 
 	// When coverage is set, source files will be modified during instrumentation. We should only run static analysis
 	// over original source files and not the modified ones.
@@ -254,43 +292,82 @@ func compileArchive(
 		if cgoEnabled {
 			combined = append(combined, cgoSrcs...)
 		}
-		for i, origSrc := range combined {
-			if _, ok := relCoverPath[origSrc]; !ok {
-				continue
-			}
+		if coverFormat != "go_cover" && coverFormat != "lcov" {
+			return fmt.Errorf("invalid value for -cover_format: %q", coverFormat)
+		}
 
-			var srcName string
-			switch coverFormat {
-			case "go_cover":
-				srcName = origSrc
-				if importPath != "" {
-					srcName = path.Join(importPath, filepath.Base(origSrc))
+		if useCoverageRedesign() {
+			// Instrument all files of the package with a single invocation of
+			// the cover tool. Coverage profiles report the files under the
+			// import path of the package for go_cover, and under their paths
+			// relative to the exec root for lcov, as below.
+			var indices []int
+			var names []string
+			local := coverFormat == "lcov" || importPath == ""
+			for i, origSrc := range combined {
+				if _, ok := relCoverPath[origSrc]; !ok {
+					continue
+				}
+				indices = append(indices, i)
+				if coverFormat == "lcov" {
+					names = append(names, relCoverPath[origSrc])
+				} else {
+					names = append(names, origSrc)
 				}
-			case "lcov":
-				// Bazel merges lcov reports across languages and thus assumes
-				// that the source file paths are relative to the exec root.
-				srcName = relCoverPath[origSrc]
-			default:
-				return fmt.Errorf("invalid value for -cover_format: %q", coverFormat)
-			}
-
-			stem := filepath.Base(origSrc)
-			if ext := filepath.Ext(stem); ext != "" {
-				stem = stem[:len(stem)-len(ext)]
 			}
-			coverVar := fmt.Sprintf("Cover_%s_%d_%s", sanitizePathForIdentifier(importPath), i, sanitizePathForIdentifier(stem))
-			coverVar = strings.ReplaceAll(coverVar, "_", "Z")
-			coverSrc := filepath.Join(workDir, fmt.Sprintf("cover_%d.go", i))
-			if err := instrumentForCoverage(goenv, origSrc, srcName, coverVar, coverMode, coverSrc); err != nil {
-				return err
+			if len(names) > 0 {
+				coverVar := strings.ReplaceAll("Cover_"+sanitizePathForIdentifier(importPath), "_", "Z")
+				instrumentedSrcs, varsSrc, coveragecfg, err := instrumentPackageForCoverage(goenv, names, local, importPath, packageName, coverVar, coverMode, workDir)
+				if err != nil {
+					return err
+				}
+				for j, i := range indices {
+					if i < len(goSrcs) {
+						goSrcs[i] = instrumentedSrcs[j]
+					} else {
+						cgoSrcs[i-len(goSrcs)] = instrumentedSrcs[j]
+					}
+				}
+				goSrcs = append(goSrcs, varsSrc)
+				gcFlags = append(gcFlags, "-coveragecfg", coveragecfg)
 			}
+		} else {
+			for i, origSrc := range combined {
+				if _, ok := relCoverPath[origSrc]; !ok {
+					continue
+				}
 
-			if i < len(goSrcs) {
-				goSrcs[i] = coverSrc
-				continue
-			}
+				var srcName string
+				switch coverFormat {
+				case "go_cover":
+					srcName = origSrc
+					if importPath != "" {
+						srcName = path.Join(importPath, filepath.Base(origSrc))
+					}
+				case "lcov":
+					// Bazel merges lcov reports across languages and thus assumes
+					// that the source file paths are relative to the exec root.
+					srcName = relCoverPath[origSrc]
+				}
+
+				stem := filepath.Base(origSrc)
+				if ext := filepath.Ext(stem); ext != "" {
+					stem = stem[:len(stem)-len(ext)]
+				}
+				coverVar := fmt.Sprintf("Cover_%s_%d_%s", sanitizePathForIdentifier(importPath), i, sanitizePathForIdentifier(stem))
+				coverVar = strings.ReplaceAll(coverVar, "_", "Z")
+				coverSrc := filepath.Join(workDir, fmt.Sprintf("cover_%d.go", i))
+				if err := instrumentForCoverage(goenv, origSrc, srcName, coverVar, coverMode, coverSrc); err != nil {
+					return err
+				}
 
-			cgoSrcs[i-len(goSrcs)] = coverSrc
+				if i < len(goSrcs) {
+					goSrcs[i] = coverSrc
+					continue
+				}
+
+				cgoSrcs[i-len(goSrcs)] = coverSrc
+			}
 		}
 	}
 
@@ -359,7 +436,7 @@ func compileArchive(
 			}
 		}
 	}
-	embedcfgPath, err := buildEmbedcfgFile(srcs.goSrcs, embedSrcs, embedRootDirs, workDir)
+	embedcfgPath, err := buildEmbedcfgFile(srcs.goSrcs, embedSrcs, embedRootDirs, embedPackageDirs, workDir)
 	if err != nil {
 		return err
 	}
@@ -369,6 +446,14 @@ func compileArchive(
 		}
 	}
 
+	if goenv.compiler == "gccgo" {
+		sysoSrcs := make([]string, len(srcs.sysoSrcs))
+		for i, src := range srcs.sysoSrcs {
+			sysoSrcs[i] = src.filename
+		}
+		return compileGccgoArchive(goenv, goSrcs, sSrcs, hSrcs, sysoSrcs, packagePath, importcfgPath, embedcfgPath, gcFlags, workDir, outLinkObj, outInterfacePath)
+	}
+
 	// If there are Go assembly files and this is go1.12+: generate symbol ABIs.
 	// This excludes Cgo packages: they use the C compiler for assembly.
 	asmHdrPath := ""
@@ -453,18 +538,33 @@ func checkImportsAndBuildCfg(goenv *env, importPath string, srcs archiveSrcs, de
 		if coverMode == "atomic" {
 			imports["sync/atomic"] = nil
 		}
-		const coverdataPath = "github.com/bazelbuild/rules_go/go/tools/coverdata"
-		var coverdata *archive
-		for i := range deps {
-			if deps[i].importPath == coverdataPath {
-				coverdata = &deps[i]
-				break
+		if useCoverageRedesign() {
+			// Instrumented main packages import runtime/coverage, which
+			// writes the coverage data on exit.
+			imports["runtime/coverage"] = nil
+		} else {
+			const coverdataPath = "github.com/bazelbuild/rules_go/go/tools/coverdata"
+			var coverdata *archive
+			for i := range deps {
+				if deps[i].importPath == coverdataPath {
+					coverdata = &deps[i]
+					break
+				}
 			}
+			if coverdata == nil {
+				return "", errors.New("coverage requested but coverdata dependency not provided")
+			}
+			imports[coverdataPath] = coverdata
 		}
-		if coverdata == nil {
-			return "", errors.New("coverage requested but coverdata dependency not provided")
+	}
+
+	if goenv.compiler == "gccgo" {
+		// gccgo finds the standard library in libgo itself.
+		for imp, arc := range imports {
+			if arc == nil {
+				delete(imports, imp)
+			}
 		}
-		imports[coverdataPath] = coverdata
 	}
 
 	// Build an importcfg file for the compiler.
@@ -18,9 +18,122 @@
 // a circular dependency.
 package main
 
+// nogoBaseConfigName is the key of the nogo config that applies to all
+// analyzers.
+const nogoBaseConfigName = "_base"
+
 // The exit codes for nogo binaries.
 const (
 	nogoSuccess int = iota
 	nogoError
 	nogoViolation
+	// nogoWarning means that nogo only reported findings whose severity
+	// doesn't fail the build.
+	nogoWarning
+)
+
+// nogoErrorsHeader starts the message of nogo, after its "nogo: " log prefix,
+// if it exits with nogoViolation.
+const nogoErrorsHeader = "errors found by nogo during build-time code analysis:"
+
+// nogoWarningsHeader starts the message of nogo, after its "nogo: " log
+// prefix, if it exits with nogoWarning. nogovalidation recognizes the logs of
+// such packages by it and prints them without failing the build.
+const nogoWarningsHeader = "warnings found by nogo during build-time code analysis:"
+
+// emptyInspectionXML is the IntelliJ inspection results document for a package
+// without findings. The builder writes it for packages without Go sources.
+const emptyInspectionXML = `<?xml version="1.0" encoding="UTF-8"?>
+<problems></problems>
+`
+
+// The directories the paths in nogo fix files can be relative to. The root is
+// chosen with the patch_root attribute of the nogo rule and compiled into the
+// nogo binary.
+const (
+	patchRootExecroot  = "execroot"
+	patchRootWorkspace = "workspace"
+	patchRootPackage   = "package"
+)
+
+// emptyDiagnosticsJSONFormat is the format of the JSON diagnostics document for
+// a package without findings. Its only verb is the JSON-encoded package path.
+// The builder writes it for packages without Go sources.
+const emptyDiagnosticsJSONFormat = `{
+  "package": %s,
+  "diagnostics": []
+}
+`
+
+// emptyFixFileJSON is the structured JSON fix file of a package without fixes.
+// The builder writes it for packages without Go sources. Keep the version in
+// sync with fixFileVersion.
+const emptyFixFileJSON = `{
+  "version": 1,
+  "files": {}
+}
+`
+
+// The ways of formatting files after applying suggested fixes, selected with
+// the fix_format attribute of the nogo rule and compiled into the nogo binary.
+const (
+	fixFormatNone      = "none"
+	fixFormatGofmt     = "gofmt"
+	fixFormatGoimports = "goimports"
+)
+
+// The compressions of nogo fix files, selected with the fix_compression
+// attribute of the nogo rule and compiled into the nogo binary.
+const (
+	fixCompressionNone = "none"
+	fixCompressionGzip = "gzip"
+)
+
+// The strategies for resolving conflicts between the suggested fixes of
+// different diagnostics, selected with the fix_conflicts key of the base
+// config.
+const (
+	// fixConflictsFirst selects fixes in the order of the diagnostics. Fixes
+	// that overlap with a previously selected fix are dropped.
+	fixConflictsFirst = "first"
+	// fixConflictsPriority is like fixConflictsFirst, but considers the fixes
+	// of analyzers with a higher fix_priority first.
+	fixConflictsPriority = "priority"
+	// fixConflictsPartial is like fixConflictsFirst, but keeps the edits of a
+	// conflicting fix that don't overlap with previously selected fixes.
+	fixConflictsPartial = "partial"
+)
+
+// The severities of findings, selected with the severity key of a config.
+const (
+	// severityError findings fail the build.
+	severityError = "error"
+	// severityWarning findings are printed as warnings without failing the
+	// build.
+	severityWarning = "warning"
+	// severityNote findings are printed as notes without failing the build.
+	severityNote = "note"
+	// severityInfo is accepted as another name of severityNote, as used by
+	// other linters.
+	severityInfo = "info"
+)
+
+// The conditions under which findings fail the build, selected with the
+// fail_on key of the base config.
+const (
+	// failOnFindings fails the build on any reported finding.
+	failOnFindings = "findings"
+	// failOnFixable fails the build on findings only if nogo suggested fixes,
+	// i.e. the fix file of the package is not empty.
+	failOnFixable = "fixable"
+	// failOnUnfixable fails the build on findings only if nogo suggested no
+	// fixes for the package.
+	failOnUnfixable = "unfixable"
+)
+
+// The codecs of the serialized facts in fact files, selected with the
+// fact_compression key of the base config.
+const (
+	factCodecNone = "none"
+	factCodecGzip = "gzip"
 )
@@ -16,14 +16,92 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"go/build"
 	"go/parser"
 	"go/token"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 )
 
+// useCoverageRedesign reports whether packages are instrumented with the
+// coverage redesign of Go 1.20, which writes the coverage data of a program to
+// the directory in GOCOVERDIR. Test binaries convert that data to a profile with
+// internal/coverage/cfile, which exists since Go 1.23, so older SDKs rewrite
+// the sources to register them with coverdata instead.
+func useCoverageRedesign() bool {
+	for _, t := range build.Default.ReleaseTags {
+		if t == "go1.23" {
+			return true
+		}
+	}
+	return false
+}
+
+// coverPkgConfig is the configuration of "go tool cover -pkgcfg", see
+// cmd/internal/cov/covcmd.CoverPkgConfig.
+type coverPkgConfig struct {
+	OutConfig   string
+	PkgPath     string
+	PkgName     string
+	Granularity string
+	ModulePath  string
+	Local       bool
+}
+
+// instrumentPackageForCoverage runs "go tool cover" on the source files of a
+// package to produce coverage-instrumented versions of them, like "go build
+// -cover" does. If local is true, coverage profiles report the files under the
+// paths in srcs, and otherwise under the import path of the package joined
+// with their base names. It returns the paths of the instrumented files, in
+// the order of srcs, an additional source file that declares the coverage
+// variables of the package, and the configuration to pass to the compiler
+// with -coveragecfg.
+func instrumentPackageForCoverage(goenv *env, srcs []string, local bool, importPath, packageName, coverVar, mode, workDir string) (coverSrcs []string, varsSrc, coveragecfg string, err error) {
+	varsSrc = filepath.Join(workDir, "cover_vars.go")
+	outFiles := []string{varsSrc}
+	for i := range srcs {
+		coverSrc := filepath.Join(workDir, fmt.Sprintf("cover_%d.go", i))
+		coverSrcs = append(coverSrcs, coverSrc)
+		outFiles = append(outFiles, coverSrc)
+	}
+	outFileListPath := filepath.Join(workDir, "cover_outfiles.txt")
+	if err := os.WriteFile(outFileListPath, []byte(strings.Join(outFiles, "\n")+"\n"), 0o666); err != nil {
+		return nil, "", "", err
+	}
+
+	coveragecfg = filepath.Join(workDir, "coveragecfg")
+	pkgcfgPath := filepath.Join(workDir, "cover_pkgcfg.json")
+	if err := writeCoverPkgConfig(pkgcfgPath, coverPkgConfig{
+		OutConfig:   coveragecfg,
+		PkgPath:     importPath,
+		PkgName:     packageName,
+		Granularity: "perblock",
+		Local:       local,
+	}); err != nil {
+		return nil, "", "", err
+	}
+
+	goargs := goenv.goTool("cover", "-pkgcfg", pkgcfgPath, "-outfilelist", outFileListPath, "-var", coverVar, "-mode", mode)
+	goargs = append(goargs, srcs...)
+	if err := goenv.runCommand(goargs); err != nil {
+		return nil, "", "", err
+	}
+	return coverSrcs, varsSrc, coveragecfg, nil
+}
+
+func writeCoverPkgConfig(path string, cfg coverPkgConfig) error {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o666)
+}
+
 // instrumentForCoverage runs "go tool cover" on a source file to produce
 // a coverage-instrumented version of the file. It also registers the file
 // with the coverdata package.
@@ -38,10 +38,12 @@ import (
 //
 // All source files listed in goSrcs with //go:embed comments must be in one
 // of the directories in embedRootDirs (not in a subdirectory). Embed patterns
-// are evaluated relative to the source directory. Embed sources (embedSrcs)
-// outside those directories are ignored, since they can't be matched by any
-// valid pattern.
-func buildEmbedcfgFile(goSrcs []fileInfo, embedSrcs, embedRootDirs []string, workDir string) (string, error) {
+// are evaluated relative to the source directory. Generated embed sources
+// (embedSrcs) outside those directories, such as files generated by rules in
+// other packages, are placed at their path relative to the directory of the
+// package that owns them, which embedPackageDirs maps them to. Other embed
+// sources are ignored, since they can't be matched by any valid pattern.
+func buildEmbedcfgFile(goSrcs []fileInfo, embedSrcs, embedRootDirs []string, embedPackageDirs map[string]string, workDir string) (string, error) {
 	// Check whether this package uses embedding and whether the toolchain
 	// supports it (Go 1.16+). With Go 1.15 and lower, we'll try to compile
 	// without an embedcfg file, and the compiler will complain the "embed"
@@ -80,8 +82,8 @@ func buildEmbedcfgFile(goSrcs []fileInfo, embedSrcs, embedRootDirs []string, wor
 	// Build a tree of embeddable files. This includes paths listed with
 	// -embedsrc. If one of those paths is a directory, the tree includes
 	// its files and subdirectories. Paths in the tree are relative to the
-	// path in embedRootDirs that contains them.
-	root, err := buildEmbedTree(embedSrcs, embedRootDirs)
+	// path in embedRootDirs that contains them or else their package directory.
+	root, err := buildEmbedTree(embedSrcs, embedRootDirs, embedPackageDirs)
 	if err != nil {
 		return "", err
 	}
@@ -143,7 +145,8 @@ type embedNode struct {
 // path src, relative to the absolute file path rootDir. If src points to a
 // directory, add recursively inserts nodes for its contents. If a node already
 // exists (for example, if a source file and a generated file have the same
-// name), add leaves the existing node in place.
+// name), add leaves the existing node in place. This includes files in place
+// of parent directories of src, in which case src is not added.
 func (n *embedNode) add(rootDir, src string) error {
 	// Create nodes for parents of src.
 	parent := n
@@ -154,6 +157,8 @@ func (n *embedNode) add(rootDir, src string) error {
 				name:     p,
 				children: make(map[string]*embedNode),
 			}
+		} else if !parent.children[p].isDir() {
+			return nil
 		}
 		parent = parent.children[p]
 	}
@@ -249,8 +254,11 @@ func (n *embedNode) walk(fn func(rel string, n *embedNode) error) error {
 
 // buildEmbedTree constructs a logical directory tree of embeddable files.
 // The tree may contain a mix of static and generated files from multiple
-// root directories. Directory artifacts are recursively expanded.
-func buildEmbedTree(embedSrcs, embedRootDirs []string) (root *embedNode, err error) {
+// root directories. Directory artifacts are recursively expanded. Files that
+// are not in any of the root directories are added relative to their package
+// directory in embedPackageDirs, after the others so that the files in the
+// root directories take precedence.
+func buildEmbedTree(embedSrcs, embedRootDirs []string, embedPackageDirs map[string]string) (root *embedNode, err error) {
 	defer func() {
 		if err != nil {
 			err = fmt.Errorf("building tree of embeddable files in directories %s: %v", strings.Join(embedRootDirs, string(filepath.ListSeparator)), err)
@@ -259,10 +267,11 @@ func buildEmbedTree(embedSrcs, embedRootDirs []string) (root *embedNode, err err
 
 	// Add each path to the tree.
 	root = &embedNode{name: "", children: make(map[string]*embedNode)}
+	var outsideSrcs []string
 	for _, src := range embedSrcs {
 		rootDir := findInRootDirs(src, embedRootDirs)
 		if rootDir == "" {
-			// Embedded path cannot be matched by any valid pattern. Ignore.
+			outsideSrcs = append(outsideSrcs, src)
 			continue
 		}
 		rel := filepath.ToSlash(src[len(rootDir)+1:])
@@ -270,6 +279,17 @@ func buildEmbedTree(embedSrcs, embedRootDirs []string) (root *embedNode, err err
 			return nil, err
 		}
 	}
+	for _, src := range outsideSrcs {
+		packageDir, ok := embedPackageDirs[src]
+		if !ok || !strings.HasPrefix(src, packageDir+string(filepath.Separator)) {
+			// Embedded path cannot be matched by any valid pattern. Ignore.
+			continue
+		}
+		rel := filepath.ToSlash(src[len(packageDir)+1:])
+		if err := root.add(packageDir, rel); err != nil {
+			return nil, err
+		}
+	}
 
 	// Sort children in each directory node.
 	var visit func(*embedNode)
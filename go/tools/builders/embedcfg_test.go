@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBuildEmbedTreePackageDirs(t *testing.T) {
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "bin")
+	lookupDir := filepath.Join(dir, "src", "p")
+	files := map[string]string{
+		// Generated by a rule in the root package.
+		"root": filepath.Join(bin, "root.txt"),
+		// Generated by a rule in //a.
+		"a": filepath.Join(bin, "a", "other", "a.txt"),
+		// Generated by a rule in //a/b, whose directory is nested in the one
+		// of //a.
+		"b": filepath.Join(bin, "a", "b", "b.txt"),
+		// A static file of another package.
+		"static": filepath.Join(dir, "src", "q", "static.txt"),
+		// A file in the directory of the sources.
+		"src": filepath.Join(lookupDir, "src.txt"),
+	}
+	for _, f := range files {
+		if err := os.MkdirAll(filepath.Dir(f), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	packageDirs := map[string]string{
+		files["root"]: bin,
+		files["a"]:    filepath.Join(bin, "a"),
+		files["b"]:    filepath.Join(bin, "a", "b"),
+	}
+	want := map[string]string{
+		"root.txt":    files["root"],
+		"other/a.txt": files["a"],
+		"b.txt":       files["b"],
+		"src.txt":     files["src"],
+	}
+
+	for _, srcs := range [][]string{
+		{files["root"], files["a"], files["b"], files["static"], files["src"]},
+		{files["src"], files["static"], files["b"], files["a"], files["root"]},
+	} {
+		root, err := buildEmbedTree(srcs, []string{lookupDir}, packageDirs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]string)
+		if err := root.walk(func(rel string, n *embedNode) error {
+			if !n.isDir() {
+				got[rel] = n.path
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("with embed sources %q: got %v, want %v", srcs, got, want)
+		}
+	}
+}
@@ -54,6 +54,9 @@ type env struct {
 	// goroot is set as the value of GOROOT if non-empty.
 	goroot string
 
+	// compiler is the Go compiler of the SDK, either "gc" or "gccgo".
+	compiler string
+
 	// installSuffix is the name of the directory below GOROOT/pkg that contains
 	// the .a files for the standard library we should build against.
 	// For example, linux_amd64_race.
@@ -66,6 +69,15 @@ type env struct {
 	workDirPath string
 
 	shouldPreserveWorkDir bool
+
+	// output receives the output of subprocesses instead of os.Stderr if set,
+	// e.g. the response to a request of a persistent worker.
+	output io.Writer
+
+	// cmdEnv holds environment variables set for subprocesses in addition to
+	// the environment of this process, which requests of a multiplex worker
+	// share.
+	cmdEnv []string
 }
 
 // envFlags registers flags common to multiple builders and returns an env
@@ -74,6 +86,7 @@ func envFlags(flags *flag.FlagSet) *env {
 	env := &env{}
 	flags.StringVar(&env.sdk, "sdk", "", "Path to the Go SDK.")
 	flags.StringVar(&env.goroot, "goroot", "", "The value to set for GOROOT.")
+	flags.StringVar(&env.compiler, "compiler", "gc", "The Go compiler of the SDK: gc or gccgo.")
 	flags.Var(&tagFlag{}, "tags", "List of build tags considered true.")
 	flags.StringVar(&env.installSuffix, "installsuffix", "", "Standard library under GOROOT/pkg")
 	flags.BoolVar(&env.verbose, "v", false, "Whether subprocess command lines should be printed")
@@ -87,6 +100,9 @@ func (e *env) checkFlagsAndSetGoroot() error {
 	if e.sdk == "" {
 		return errors.New("-sdk was not set")
 	}
+	if e.compiler != "gc" && e.compiler != "gccgo" {
+		return fmt.Errorf("-compiler must be gc or gccgo, got %q", e.compiler)
+	}
 	if e.goroot != "" {
 		err := os.Setenv("GOROOT", e.goroot)
 		if err != nil {
@@ -140,24 +156,48 @@ func (e *env) goCmd(cmd string, args ...string) []string {
 	return append([]string{exe, cmd}, args...)
 }
 
-// runCommand executes a subprocess that inherits stdout, stderr, and the
-// environment from this process.
-func (e *env) runCommand(args []string) error {
+// gccgoCmd returns a slice containing the path to the gccgo executable of an
+// SDK using the gccgo compiler and additional arguments.
+func (e *env) gccgoCmd(args ...string) []string {
+	return append([]string{filepath.Join(e.sdk, "bin", "gccgo")}, args...)
+}
+
+// stderr returns the writer that receives the output of subprocesses.
+func (e *env) stderr() io.Writer {
+	if e.output != nil {
+		return e.output
+	}
+	return os.Stderr
+}
+
+// command returns a command running args with the environment of this
+// process and cmdEnv.
+func (e *env) command(args []string) *exec.Cmd {
 	cmd := exec.Command(args[0], args[1:]...)
+	if len(e.cmdEnv) > 0 {
+		cmd.Env = append(os.Environ(), e.cmdEnv...)
+	}
+	return cmd
+}
+
+// runCommand executes a subprocess that inherits the environment from this
+// process and writes its stdout and stderr to the output of e.
+func (e *env) runCommand(args []string) error {
+	cmd := e.command(args)
 	// Redirecting stdout to stderr. This mirrors behavior in the go command:
 	// https://go.googlesource.com/go/+/refs/tags/go1.15.2/src/cmd/go/internal/work/exec.go#1958
 	buf := &bytes.Buffer{}
 	cmd.Stdout = buf
 	cmd.Stderr = buf
 	err := runAndLogCommand(cmd, e.verbose)
-	os.Stderr.Write(relativizePaths(buf.Bytes()))
+	e.stderr().Write(relativizePaths(buf.Bytes()))
 	return err
 }
 
 // runCommandToFile executes a subprocess and writes stdout/stderr to the given
 // writers.
 func (e *env) runCommandToFile(out, err io.Writer, args []string) error {
-	cmd := exec.Command(args[0], args[1:]...)
+	cmd := e.command(args)
 	cmd.Stdout = out
 	cmd.Stderr = err
 	return runAndLogCommand(cmd, e.verbose)
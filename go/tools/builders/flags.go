@@ -121,7 +121,9 @@ func splitQuoted(s string) (r []string, err error) {
 }
 
 // tagFlag adds tags to the build.Default context. Tags are expected to be
-// formatted as a comma-separated list.
+// formatted as a comma-separated list. Tags that are already set are not added
+// again, so that the requests of a persistent worker, which all pass the tags
+// of the worker, don't change the context once it has been set up.
 type tagFlag struct{}
 
 func (f *tagFlag) String() string {
@@ -129,7 +131,74 @@ func (f *tagFlag) String() string {
 }
 
 func (f *tagFlag) Set(opt string) error {
-	tags := strings.Split(opt, ",")
-	build.Default.BuildTags = append(build.Default.BuildTags, tags...)
+	set := make(map[string]bool)
+	for _, tag := range build.Default.BuildTags {
+		set[tag] = true
+	}
+	for _, tag := range strings.Split(opt, ",") {
+		if !set[tag] {
+			set[tag] = true
+			build.Default.BuildTags = append(build.Default.BuildTags, tag)
+		}
+	}
 	return nil
 }
+
+// spectreFlag collects the Spectre mitigations to enable, formatted as a
+// comma-separated list of "all", "index", and "ret" like the -spectre flag of
+// the compiler.
+type spectreFlag []string
+
+func (f *spectreFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(*f, ",")
+}
+
+func (f *spectreFlag) Set(opt string) error {
+	for _, m := range strings.Split(opt, ",") {
+		switch m {
+		case "":
+			continue
+		case "all", "index", "ret":
+		default:
+			return fmt.Errorf("unknown Spectre mitigation %q: must be one of all, index, ret", m)
+		}
+		if !f.has(m) {
+			*f = append(*f, m)
+		}
+	}
+	return nil
+}
+
+func (f spectreFlag) has(m string) bool {
+	for _, s := range f {
+		if s == m {
+			return true
+		}
+	}
+	return false
+}
+
+// gcflags returns the compiler flags that enable the mitigations.
+func (f spectreFlag) gcflags() []string {
+	if len(f) == 0 {
+		return nil
+	}
+	return []string{"-spectre=" + strings.Join(f, ",")}
+}
+
+// asmflags returns the assembler flags that enable the mitigations. The
+// assembler only implements "ret" and only accepts a single value, so "index"
+// is dropped.
+func (f spectreFlag) asmflags() []string {
+	switch {
+	case f.has("all"):
+		return []string{"-spectre=all"}
+	case f.has("ret"):
+		return []string{"-spectre=ret"}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSpectreFlag(t *testing.T) {
+	for _, tc := range []struct {
+		values            []string
+		gcflags, asmflags []string
+	}{
+		{
+			values: nil,
+		}, {
+			values:  []string{"index"},
+			gcflags: []string{"-spectre=index"},
+		}, {
+			values:   []string{"ret"},
+			gcflags:  []string{"-spectre=ret"},
+			asmflags: []string{"-spectre=ret"},
+		}, {
+			values:   []string{"index,ret", "index"},
+			gcflags:  []string{"-spectre=index,ret"},
+			asmflags: []string{"-spectre=ret"},
+		}, {
+			values:   []string{"ret,all"},
+			gcflags:  []string{"-spectre=ret,all"},
+			asmflags: []string{"-spectre=all"},
+		},
+	} {
+		var f spectreFlag
+		for _, v := range tc.values {
+			if err := f.Set(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := f.gcflags(); !reflect.DeepEqual(got, tc.gcflags) {
+			t.Errorf("%q: got compiler flags %q, want %q", tc.values, got, tc.gcflags)
+		}
+		if got := f.asmflags(); !reflect.DeepEqual(got, tc.asmflags) {
+			t.Errorf("%q: got assembler flags %q, want %q", tc.values, got, tc.asmflags)
+		}
+	}
+
+	var f spectreFlag
+	if err := f.Set("index,load"); err == nil {
+		t.Error("setting an unknown mitigation succeeded")
+	}
+}
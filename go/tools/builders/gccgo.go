@@ -0,0 +1,224 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This file implements the builders for SDKs that use gccgo instead of gc.
+// gccgo has no separate tools for compiling, assembling, packing and linking,
+// and the standard library is precompiled into libgo, which it links
+// automatically. The builders run gccgo and ar directly and translate the
+// flags the rules pass for the gc tools.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/build"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// gccgoCompileFlags translates flags for "go tool compile" into flags for
+// gccgo. Flags without a gc spelling, such as those of gc_goopts meant for
+// gccgo, are passed through.
+func gccgoCompileFlags(gcFlags []string) ([]string, error) {
+	var flags []string
+	for i := 0; i < len(gcFlags); i++ {
+		flag := gcFlags[i]
+		switch {
+		case flag == "-N":
+			flags = append(flags, "-O0")
+		case flag == "-l":
+			flags = append(flags, "-fno-inline")
+		case flag == "-shared" || flag == "-dynlink":
+			flags = append(flags, "-fPIC")
+		case flag == "-trimpath" && i+1 < len(gcFlags):
+			i++
+			flags = append(flags, gccgoTrimpathFlags(gcFlags[i])...)
+		case strings.HasPrefix(flag, "-trimpath="):
+			flags = append(flags, gccgoTrimpathFlags(strings.TrimPrefix(flag, "-trimpath="))...)
+		case flag == "-race" || flag == "-msan" || strings.HasPrefix(flag, "-d="):
+			return nil, fmt.Errorf("compiler flag %s is not supported by gccgo", flag)
+		default:
+			flags = append(flags, flag)
+		}
+	}
+	return flags, nil
+}
+
+// gccgoTrimpathFlags translates the value of the -trimpath flag of the
+// compiler, a semicolon-separated list of prefixes optionally followed by
+// "=>" and a replacement, into flags that rewrite the source file paths in
+// the debug information gccgo emits.
+func gccgoTrimpathFlags(trimpath string) []string {
+	var flags []string
+	for _, rewrite := range strings.Split(trimpath, ";") {
+		if rewrite == "" {
+			continue
+		}
+		prefix, replacement := rewrite, ""
+		if i := strings.Index(rewrite, "=>"); i >= 0 {
+			prefix, replacement = rewrite[:i], rewrite[i+len("=>"):]
+		}
+		prefix = abs(prefix)
+		if replacement == "" {
+			// Like the compiler, remove the separator after the prefix too.
+			prefix += string(filepath.Separator)
+		}
+		flags = append(flags, "-fdebug-prefix-map="+prefix+"="+replacement)
+	}
+	return flags
+}
+
+// gccgoLinkFlags translates flags for "go tool link" into flags for gccgo.
+// gccgo always links with the C toolchain it was configured with, so the
+// flags selecting the external linker are dropped, and -extldflags are passed
+// to gccgo directly.
+func gccgoLinkFlags(toolArgs []string) ([]string, error) {
+	var flags []string
+	for i := 0; i < len(toolArgs); i++ {
+		arg := toolArgs[i]
+		switch {
+		case arg == "-extldflags" && i+1 < len(toolArgs):
+			i++
+			extldflags, err := splitQuoted(toolArgs[i])
+			if err != nil {
+				return nil, err
+			}
+			flags = append(flags, extldflags...)
+		case arg == "-extld" || arg == "-extar" || arg == "-linkmode" || arg == "-pluginpath":
+			i++
+		case arg == "-w" || strings.HasPrefix(arg, "-buildid="):
+		case arg == "-race" || arg == "-msan" || arg == "-X" || strings.HasPrefix(arg, "-X="):
+			return nil, fmt.Errorf("linker flag %s is not supported by gccgo", arg)
+		default:
+			flags = append(flags, arg)
+		}
+	}
+	return flags, nil
+}
+
+// compileGccgoArchive compiles the Go files of a package with gccgo,
+// assembles its .s files and packs the objects into outLinkObj. gccgo reads
+// export data from the objects of archives, so outInterfacePath is an archive
+// with just the object of the Go files.
+func compileGccgoArchive(goenv *env, goSrcs, sSrcs, hSrcs, sysoSrcs []string, packagePath, importcfgPath, embedcfgPath string, gcFlags []string, workDir, outLinkObj, outInterfacePath string) error {
+	flags, err := gccgoCompileFlags(gcFlags)
+	if err != nil {
+		return err
+	}
+	goObj := filepath.Join(workDir, "_go_.o")
+	args := goenv.gccgoCmd("-c", "-g", "-fgo-pkgpath="+packagePath, "-fgo-importcfg="+importcfgPath)
+	if embedcfgPath != "" {
+		args = append(args, "-fgo-embedcfg="+embedcfgPath)
+	}
+	args = append(args, flags...)
+	args = append(args, "-o", goObj)
+	args = append(args, goSrcs...)
+	if err := goenv.runCommand(args); err != nil {
+		return err
+	}
+
+	// Like the go command, assemble .s files with gccgo, which runs the C
+	// preprocessor on them first.
+	objFiles := []string{goObj}
+	includeSet := map[string]bool{workDir: true}
+	includes := []string{workDir}
+	for _, hdr := range hSrcs {
+		if dir := filepath.Dir(hdr); !includeSet[dir] {
+			includeSet[dir] = true
+			includes = append(includes, dir)
+		}
+	}
+	for i, sSrc := range sSrcs {
+		obj := filepath.Join(workDir, fmt.Sprintf("s%d.o", i))
+		args := goenv.gccgoCmd("-xassembler-with-cpp", "-c", "-D", "GOOS_"+build.Default.GOOS, "-D", "GOARCH_"+build.Default.GOARCH)
+		for _, inc := range includes {
+			args = append(args, "-I", inc)
+		}
+		args = append(args, "-o", obj, sSrc)
+		if err := goenv.runCommand(args); err != nil {
+			return err
+		}
+		objFiles = append(objFiles, obj)
+	}
+	objFiles = append(objFiles, sysoSrcs...)
+
+	if err := gccgoPack(goenv, outLinkObj, objFiles); err != nil {
+		return err
+	}
+	return gccgoPack(goenv, outInterfacePath, []string{goObj})
+}
+
+// gccgoPack writes an archive containing objFiles to outPath with the ar tool
+// in $AR, like the go command does for gccgo.
+func gccgoPack(goenv *env, outPath string, objFiles []string) error {
+	if err := os.Remove(outPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	ar := os.Getenv("AR")
+	if ar == "" {
+		ar = "ar"
+	}
+	// D makes the archive deterministic.
+	args := append([]string{ar, "rcD", abs(outPath)}, objFiles...)
+	return goenv.runCommand(args)
+}
+
+// linkGccgo links the main archive and its transitive dependencies in
+// archives into an executable with gccgo.
+func linkGccgo(goenv *env, main string, archives []archive, buildmode, outFile string, toolArgs []string) error {
+	flags, err := gccgoLinkFlags(toolArgs)
+	if err != nil {
+		return err
+	}
+	args := goenv.gccgoCmd("-o", outFile)
+	switch buildmode {
+	case "":
+	case "pie":
+		args = append(args, "-pie")
+	default:
+		return fmt.Errorf("-buildmode=%s is not supported by gccgo", buildmode)
+	}
+	args = append(args, main)
+	// The archives are passed in no particular order, so let the linker search
+	// them repeatedly. The macOS linker always does.
+	if runtime.GOOS != "darwin" {
+		args = append(args, "-Wl,-(")
+	}
+	for _, arc := range archives {
+		args = append(args, arc.file)
+	}
+	if runtime.GOOS != "darwin" {
+		args = append(args, "-Wl,-)")
+	}
+	args = append(args, flags...)
+	return goenv.runCommand(args)
+}
+
+// stdlibGccgo prepares the output of the stdlib action for an SDK using
+// gccgo. The standard library is precompiled into libgo, so there is nothing
+// to build, but it can't be built in a different configuration either.
+func stdlibGccgo(output string, race, msan bool, gcflags, packageGcflags, spectre []string, pgoprofile string) error {
+	switch {
+	case race:
+		return errors.New("race instrumentation is not supported by gccgo")
+	case msan:
+		return errors.New("msan instrumentation is not supported by gccgo")
+	case len(gcflags) > 0 || len(packageGcflags) > 0 || len(spectre) > 0 || pgoprofile != "":
+		return errors.New("the standard library of gccgo is precompiled in libgo and can't be compiled with different flags")
+	}
+	return os.MkdirAll(filepath.Join(output, "pkg"), 0o755)
+}
@@ -0,0 +1,93 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGccgoCompileFlags(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		desc    string
+		gcFlags []string
+		want    []string
+	}{
+		{
+			desc:    "debug",
+			gcFlags: []string{"-N", "-l"},
+			want:    []string{"-O0", "-fno-inline"},
+		},
+		{
+			desc:    "link mode",
+			gcFlags: []string{"-shared"},
+			want:    []string{"-fPIC"},
+		},
+		{
+			desc:    "trimpath",
+			gcFlags: []string{"-trimpath=.;/src=>example.com/m"},
+			want:    []string{"-fdebug-prefix-map=" + wd + "/=", "-fdebug-prefix-map=/src=example.com/m"},
+		},
+		{
+			desc:    "separate trimpath value",
+			gcFlags: []string{"-trimpath", "sub"},
+			want:    []string{"-fdebug-prefix-map=" + filepath.Join(wd, "sub") + "/="},
+		},
+		{
+			desc:    "passed through",
+			gcFlags: []string{"-O2", "-fgo-debug-escape=1"},
+			want:    []string{"-O2", "-fgo-debug-escape=1"},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := gccgoCompileFlags(tc.gcFlags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGccgoCompileFlagsUnsupported(t *testing.T) {
+	for _, flag := range []string{"-race", "-msan", "-d=ssa/check_bce/debug=1"} {
+		if _, err := gccgoCompileFlags([]string{flag}); err == nil {
+			t.Errorf("translating %s succeeded", flag)
+		}
+	}
+}
+
+func TestGccgoLinkFlags(t *testing.T) {
+	got, err := gccgoLinkFlags([]string{
+		"-extar", "ar",
+		"-extld", "cc",
+		"-linkmode", "external",
+		"-buildid=redacted",
+		"-s", "-w",
+		"-static-libgo",
+		"-extldflags", "-Wl,-z,relro '-Wl,-rpath,$ORIGIN/a b'",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"-s", "-static-libgo", "-Wl,-z,relro", "-Wl,-rpath,$ORIGIN/a b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestGccgoLinkFlagsUnsupported(t *testing.T) {
+	for _, flag := range []string{"-race", "-msan", "-X=runtime.godebugDefault=panicnil=1"} {
+		if _, err := gccgoLinkFlags([]string{flag}); err == nil {
+			t.Errorf("translating %s succeeded", flag)
+		}
+	}
+}
@@ -25,9 +25,13 @@ import (
 	"io/ioutil"
 	"math"
 	"os"
+	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"text/template"
+	"time"
 )
 
 const nogoMainTpl = `
@@ -54,6 +58,45 @@ var analyzers = []*analysis.Analyzer{
 var configs = map[string]config{
 {{- range $name, $config := .Configs}}
 	{{printf "%q" $name}}: config{
+		{{- if $config.Remediation }}
+		remediation: {{printf "%q" $config.Remediation}},
+		{{- end -}}
+		{{- if $config.Fixes }}
+		fixes: newBool({{ $config.Fixes }}),
+		{{- end -}}
+		{{- if $config.Diagnostics }}
+		diagnostics: newBool({{ $config.Diagnostics }}),
+		{{- end -}}
+		{{- if $config.Tests }}
+		tests: newBool({{ $config.Tests }}),
+		{{- end -}}
+		{{- if $config.FixTests }}
+		fixTests: newBool({{ $config.FixTests }}),
+		{{- end -}}
+		{{- if $config.FixConflicts }}
+		fixConflicts: {{printf "%q" $config.FixConflicts}},
+		{{- end -}}
+		{{- if $config.FixPriority }}
+		fixPriority: {{ $config.FixPriority }},
+		{{- end -}}
+		{{- if $config.FailOn }}
+		failOn: {{printf "%q" $config.FailOn}},
+		{{- end -}}
+		{{- if $config.Severity }}
+		severity: {{printf "%q" $config.Severity}},
+		{{- end -}}
+		{{- if $config.FactCompression }}
+		factCompression: {{printf "%q" $config.FactCompression}},
+		{{- end -}}
+		{{- if $config.Timeout }}
+		timeout: {{printf "%d" $config.TimeoutDuration}}, // {{$config.Timeout}}
+		{{- end -}}
+		{{- if $config.ReportErrors }}
+		reportErrors: newBool({{ $config.ReportErrors }}),
+		{{- end -}}
+		{{- if $config.MaxFindings }}
+		maxFindings: {{ $config.MaxFindings }},
+		{{- end -}}
 		{{- if $config.AnalyzerFlags }}
 		analyzerFlags: map[string]string {
 			{{- range $flagKey, $flagValue := $config.AnalyzerFlags}}
@@ -80,27 +123,135 @@ var configs = map[string]config{
 			{{printf "regexp.MustCompile(%q)" $path}},
 			{{- end}}
 		},
+		{{- end -}}
+		{{- if $config.FixOnlyFiles}}
+		fixOnlyFiles: []*regexp.Regexp{
+			{{- range $path, $comment := $config.FixOnlyFiles}}
+			{{- if $comment}}
+			// {{$comment}}
+			{{end -}}
+			{{printf "regexp.MustCompile(%q)" $path}},
+			{{- end}}
+		},
+		{{- end -}}
+		{{- if $config.FixExcludeFiles}}
+		fixExcludeFiles: []*regexp.Regexp{
+			{{- range $path, $comment := $config.FixExcludeFiles}}
+			{{- if $comment}}
+			// {{$comment}}
+			{{end -}}
+			{{printf "regexp.MustCompile(%q)" $path}},
+			{{- end}}
+		},
 		{{- end}}
 	},
 {{- end}}
 }
 
 const debugMode = {{ .Debug }}
+
+const patchRoot = {{ printf "%q" .PatchRoot }}
+
+const patchSrcPrefix = {{ printf "%q" .PatchSrcPrefix }}
+
+const patchDstPrefix = {{ printf "%q" .PatchDstPrefix }}
+
+const patchStrip = {{ .PatchStrip }}
+
+const fixFormat = {{ printf "%q" .FixFormat }}
+
+const verifyFixes = {{ .VerifyFixes }}
+
+const fixContext = {{ .FixContext }}
+
+const diagnosticPatches = {{ .DiagnosticPatches }}
+
+const fixCompression = {{ printf "%q" .FixCompression }}
+
+const maxFixFileSize = {{ .MaxFixFileSize }}
+
+const sourceSnippets = {{ .SourceSnippets }}
+
+// externalAnalyzers are the runfiles paths of the external analyzers.
+var externalAnalyzers = []string{
+{{- range .ExternalAnalyzers}}
+	{{printf "%q" .}},
+{{- end}}
+}
+
+// stdlibFacts is the runfiles path of the archive with the facts of the
+// standard library, or empty if they are not computed.
+const stdlibFacts = {{printf "%q" .StdlibFacts}}
+
+// configProblems are the problems with the config that don't fail the build,
+// such as unknown keys, which are ignored. They are reported by the
+// check_config command.
+var configProblems = []string{
+{{- range .ConfigProblems}}
+	{{printf "%q" .}},
+{{- end}}
+}
+
+// baseline lists the known findings that are not reported.
+var baseline = []baselineFinding{
+{{- range .Baseline}}
+	{File: {{printf "%q" .File}}, Analyzer: {{printf "%q" .Analyzer}}, Message: {{printf "%q" .Message}}, Count: {{.Count}}},
+{{- end}}
+}
 `
 
 func genNogoMain(args []string) error {
 	analyzerImportPaths := multiFlag{}
+	externalAnalyzers := multiFlag{}
 	flags := flag.NewFlagSet("generate_nogo_main", flag.ExitOnError)
 	out := flags.String("output", "", "output file to write (defaults to stdout)")
 	flags.Var(&analyzerImportPaths, "analyzer_importpath", "import path of an analyzer library")
+	flags.Var(&externalAnalyzers, "external_analyzer", "runfiles path of an external analyzer executable")
+	stdlibFacts := flags.String("stdlib_facts", "", "runfiles path of the archive with the facts of the standard library")
 	configFile := flags.String("config", "", "nogo config file")
 	debug := flags.Bool("debug", false, "enable debug mode")
+	patchRoot := flags.String("patch_root", patchRootExecroot, "directory the paths in fix files are relative to: execroot, workspace or package")
+	patchSrcPrefix := flags.String("patch_src_prefix", "a/", "prefix of the old file names in fix files")
+	patchDstPrefix := flags.String("patch_dst_prefix", "b/", "prefix of the new file names in fix files")
+	patchStrip := flags.Int("patch_strip", -1, "number of leading path components to strip from the file names in fix files to apply them (default: the number of directories in -patch_src_prefix)")
+	fixFormat := flags.String("fix_format", fixFormatNone, "how to format files after applying fixes: none, gofmt or goimports")
+	verifyFixes := flags.Bool("verify_fixes", false, "analyze packages again with the suggested fixes applied to check them")
+	fixContext := flags.Int("fix_context", 3, "number of context lines around the changes in fix files")
+	diagnosticPatches := flags.Bool("diagnostic_patches", false, "write a patch with the fix of each diagnostic")
+	fixCompression := flags.String("fix_compression", fixCompressionNone, "how to compress the fix files: none or gzip")
+	maxFixFileSize := flags.Int64("max_fix_file_size", 0, "size in bytes above which the fixes of further files are left out of the fix file, or 0 for no limit")
+	sourceSnippets := flags.Bool("source_snippets", false, "print the source lines of each finding in the log")
+	baselinePath := flags.String("baseline", "", "baseline file of known findings that are not reported")
 	if err := flags.Parse(args); err != nil {
 		return err
 	}
 	if *out == "" {
 		return errors.New("must provide output file")
 	}
+	switch *patchRoot {
+	case patchRootExecroot, patchRootWorkspace, patchRootPackage:
+	default:
+		return fmt.Errorf("invalid patch root %q", *patchRoot)
+	}
+	if *patchStrip < 0 {
+		*patchStrip = strings.Count(*patchSrcPrefix, "/")
+	}
+	switch *fixFormat {
+	case fixFormatNone, fixFormatGofmt, fixFormatGoimports:
+	default:
+		return fmt.Errorf("invalid fix format %q", *fixFormat)
+	}
+	if *fixContext < 0 {
+		return fmt.Errorf("invalid fix context %d, must not be negative", *fixContext)
+	}
+	switch *fixCompression {
+	case fixCompressionNone, fixCompressionGzip:
+	default:
+		return fmt.Errorf("invalid fix compression %q", *fixCompression)
+	}
+	if *maxFixFileSize < 0 {
+		return fmt.Errorf("invalid max fix file size %d, must not be negative", *maxFixFileSize)
+	}
 
 	outFile := os.Stdout
 	var cErr error
@@ -118,6 +269,14 @@ func genNogoMain(args []string) error {
 	if err != nil {
 		return err
 	}
+	configProblems, err := unknownConfigKeys(*configFile)
+	if err != nil {
+		return err
+	}
+	baseline, err := buildBaseline(*baselinePath)
+	if err != nil {
+		return err
+	}
 
 	type Import struct {
 		Path, Name string
@@ -135,17 +294,47 @@ func genNogoMain(args []string) error {
 		suffix++
 	}
 	data := struct {
-		Imports    []Import
-		Configs    Configs
-		NeedRegexp bool
-		Debug      bool
+		Imports           []Import
+		Configs           Configs
+		NeedRegexp        bool
+		Debug             bool
+		PatchRoot         string
+		PatchSrcPrefix    string
+		PatchDstPrefix    string
+		PatchStrip        int
+		FixFormat         string
+		VerifyFixes       bool
+		FixContext        int
+		DiagnosticPatches bool
+		FixCompression    string
+		MaxFixFileSize    int64
+		SourceSnippets    bool
+		Baseline          []baselineFinding
+		ExternalAnalyzers []string
+		StdlibFacts       string
+		ConfigProblems    []string
 	}{
-		Imports: imports,
-		Configs: config,
-		Debug:   *debug,
+		Imports:           imports,
+		Configs:           config,
+		Debug:             *debug,
+		PatchRoot:         *patchRoot,
+		PatchSrcPrefix:    *patchSrcPrefix,
+		PatchDstPrefix:    *patchDstPrefix,
+		PatchStrip:        *patchStrip,
+		FixFormat:         *fixFormat,
+		VerifyFixes:       *verifyFixes,
+		FixContext:        *fixContext,
+		DiagnosticPatches: *diagnosticPatches,
+		FixCompression:    *fixCompression,
+		MaxFixFileSize:    *maxFixFileSize,
+		SourceSnippets:    *sourceSnippets,
+		Baseline:          baseline.Findings,
+		ExternalAnalyzers: externalAnalyzers,
+		StdlibFacts:       *stdlibFacts,
+		ConfigProblems:    configProblems,
 	}
 	for _, c := range config {
-		if len(c.OnlyFiles) > 0 || len(c.ExcludeFiles) > 0 {
+		if len(c.OnlyFiles) > 0 || len(c.ExcludeFiles) > 0 || len(c.FixOnlyFiles) > 0 || len(c.FixExcludeFiles) > 0 {
 			data.NeedRegexp = true
 			break
 		}
@@ -181,21 +370,175 @@ func buildConfig(path string) (Configs, error) {
 				return Configs{}, fmt.Errorf("invalid pattern for analysis %q: %v", name, err)
 			}
 		}
+		for pattern := range config.FixOnlyFiles {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return Configs{}, fmt.Errorf("invalid pattern for analysis %q: %v", name, err)
+			}
+		}
+		for pattern := range config.FixExcludeFiles {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return Configs{}, fmt.Errorf("invalid pattern for analysis %q: %v", name, err)
+			}
+		}
+		if len(config.AnalyzerFlags) > 0 && name == nogoBaseConfigName {
+			return Configs{}, fmt.Errorf("analyzer_flags can't be set in %q since each analyzer has its own flags", nogoBaseConfigName)
+		}
+		switch config.FixConflicts {
+		case "", fixConflictsFirst, fixConflictsPriority, fixConflictsPartial:
+		default:
+			return Configs{}, fmt.Errorf("invalid fix_conflicts for analysis %q: %q, must be %q, %q or %q",
+				name, config.FixConflicts, fixConflictsFirst, fixConflictsPriority, fixConflictsPartial)
+		}
+		if config.FixConflicts != "" && name != nogoBaseConfigName {
+			return Configs{}, fmt.Errorf("fix_conflicts can only be set in %q, not for analysis %q", nogoBaseConfigName, name)
+		}
+		switch config.FailOn {
+		case "", failOnFindings, failOnFixable, failOnUnfixable:
+		default:
+			return Configs{}, fmt.Errorf("invalid fail_on for analysis %q: %q, must be %q, %q or %q",
+				name, config.FailOn, failOnFindings, failOnFixable, failOnUnfixable)
+		}
+		if config.FailOn != "" && name != nogoBaseConfigName {
+			return Configs{}, fmt.Errorf("fail_on can only be set in %q, not for analysis %q", nogoBaseConfigName, name)
+		}
+		switch config.Severity {
+		case "", severityError, severityWarning, severityNote:
+		case severityInfo:
+			config.Severity = severityNote
+		default:
+			return Configs{}, fmt.Errorf("invalid severity for analysis %q: %q, must be %q, %q or %q",
+				name, config.Severity, severityError, severityWarning, severityNote)
+		}
+		switch config.FactCompression {
+		case "", factCodecNone, factCodecGzip:
+		default:
+			return Configs{}, fmt.Errorf("invalid fact_compression for analysis %q: %q, must be %q or %q",
+				name, config.FactCompression, factCodecNone, factCodecGzip)
+		}
+		if config.FactCompression != "" && name != nogoBaseConfigName {
+			return Configs{}, fmt.Errorf("fact_compression can only be set in %q, not for analysis %q", nogoBaseConfigName, name)
+		}
+		if config.MaxFindings < 0 {
+			return Configs{}, fmt.Errorf("invalid max_findings for analysis %q: %d, must not be negative", name, config.MaxFindings)
+		}
+		if config.MaxFindings != 0 && name != nogoBaseConfigName {
+			return Configs{}, fmt.Errorf("max_findings can only be set in %q, not for analysis %q", nogoBaseConfigName, name)
+		}
+		var timeout time.Duration
+		if config.Timeout != "" {
+			if timeout, err = time.ParseDuration(config.Timeout); err != nil || timeout <= 0 {
+				return Configs{}, fmt.Errorf("invalid timeout for analysis %q: %q, must be a positive duration such as \"30s\"", name, config.Timeout)
+			}
+		}
 		configs[name] = Config{
 			// Description is currently unused.
-			OnlyFiles:     config.OnlyFiles,
-			ExcludeFiles:  config.ExcludeFiles,
-			AnalyzerFlags: config.AnalyzerFlags,
+			OnlyFiles:       config.OnlyFiles,
+			ExcludeFiles:    config.ExcludeFiles,
+			AnalyzerFlags:   config.AnalyzerFlags,
+			Remediation:     config.Remediation,
+			Fixes:           config.Fixes,
+			FixOnlyFiles:    config.FixOnlyFiles,
+			FixExcludeFiles: config.FixExcludeFiles,
+			Diagnostics:     config.Diagnostics,
+			Tests:           config.Tests,
+			FixTests:        config.FixTests,
+			FixConflicts:    config.FixConflicts,
+			FixPriority:     config.FixPriority,
+			FailOn:          config.FailOn,
+			Severity:        config.Severity,
+			FactCompression: config.FactCompression,
+			Timeout:         config.Timeout,
+			TimeoutDuration: timeout,
+			MaxFindings:     config.MaxFindings,
+			ReportErrors:    config.ReportErrors,
 		}
 	}
 	return configs, nil
 }
 
+// unknownConfigKeys returns a problem for each key of the analyzers in the
+// config file that isn't a field of Config, e.g. a misspelled one. Like the
+// JSON decoder, which ignores them, it matches keys case-insensitively.
+func unknownConfigKeys(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+	var raw map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config file: %v", err)
+	}
+	var known []string
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = t.Field(i).Name
+		}
+		known = append(known, name)
+	}
+	var problems []string
+	for name, config := range raw {
+		for key := range config {
+			isKnown := false
+			for _, k := range known {
+				if strings.EqualFold(key, k) {
+					isKnown = true
+					break
+				}
+			}
+			if !isKnown {
+				problems = append(problems, fmt.Sprintf("%s: unknown key %q, which is ignored", name, key))
+			}
+		}
+	}
+	sort.Strings(problems)
+	return problems, nil
+}
+
+func buildBaseline(path string) (baselineFile, error) {
+	if path == "" {
+		return baselineFile{}, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return baselineFile{}, fmt.Errorf("failed to read baseline file: %v", err)
+	}
+	baseline, err := parseBaselineFile(b)
+	if err != nil {
+		return baselineFile{}, fmt.Errorf("invalid baseline file %s: %v", path, err)
+	}
+	return baseline, nil
+}
+
 type Configs map[string]Config
 
 type Config struct {
-	Description   string
-	OnlyFiles     map[string]string `json:"only_files"`
-	ExcludeFiles  map[string]string `json:"exclude_files"`
-	AnalyzerFlags map[string]string `json:"analyzer_flags"`
+	Description     string
+	OnlyFiles       map[string]string `json:"only_files"`
+	ExcludeFiles    map[string]string `json:"exclude_files"`
+	AnalyzerFlags   map[string]string `json:"analyzer_flags"`
+	Remediation     string            `json:"remediation"`
+	Fixes           *bool             `json:"fixes"`
+	FixOnlyFiles    map[string]string `json:"fix_only_files"`
+	FixExcludeFiles map[string]string `json:"fix_exclude_files"`
+	Diagnostics     *bool             `json:"diagnostics"`
+	Tests           *bool             `json:"tests"`
+	FixTests        *bool             `json:"fix_tests"`
+	FixConflicts    string            `json:"fix_conflicts"`
+	FixPriority     int               `json:"fix_priority"`
+	FailOn          string            `json:"fail_on"`
+	Severity        string            `json:"severity"`
+	FactCompression string            `json:"fact_compression"`
+	MaxFindings     int               `json:"max_findings"`
+	Timeout         string            `json:"timeout"`
+	ReportErrors    *bool             `json:"report_errors"`
+	// TimeoutDuration is the parsed Timeout.
+	TimeoutDuration time.Duration `json:"-"`
 }
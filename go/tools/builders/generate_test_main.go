@@ -107,8 +107,12 @@ import (
 	"testing/internal/testdeps"
 
 {{if ne .CoverMode ""}}
+{{if .Version "go1.23"}}
+	"internal/coverage/cfile"
+{{else}}
 	"github.com/bazelbuild/rules_go/go/tools/coverdata"
 {{end}}
+{{end}}
 
 {{range $p := .Imports}}
 	{{$p.Name}} "{{$p.Path}}"
@@ -141,6 +145,17 @@ var examples = []testing.InternalExample{
 {{end}}
 }
 
+{{if and (ne .CoverMode "") (.Version "go1.23")}}
+func init() {
+	// The packages under test are instrumented with the coverage redesign,
+	// see useCoverageRedesign in the builder.
+	testdeps.CoverMode = "{{ .CoverMode }}"
+	testdeps.CoverSnapshotFunc = cfile.Snapshot
+	testdeps.CoverProcessTestDirFunc = cfile.ProcessCoverTestDir
+	testdeps.CoverMarkProfileEmittedFunc = cfile.MarkProfileEmitted
+}
+{{end}}
+
 func testsInShard() []testing.InternalTest {
 	totalShards, err := strconv.Atoi(os.Getenv("TEST_TOTAL_SHARDS"))
 	if err != nil || totalShards <= 1 {
@@ -219,6 +234,22 @@ func main() {
 	panicOnExit0Flag.Set("true")
 {{end}}
 {{if ne .CoverMode ""}}
+  {{if .Version "go1.23"}}
+	if coverageDat, ok := os.LookupEnv("COVERAGE_OUTPUT_FILE"); ok {
+		// The test writes its coverage data to this directory, along with
+		// the programs it starts, and converts it to a profile once done.
+		coverDir, err := bzltestutil.CoverDir()
+		if err != nil {
+			log.Fatalf("Failed to create the coverage data directory: %v", err)
+		}
+		flag.Lookup("test.gocoverdir").Value.Set(coverDir)
+		{{if eq .CoverFormat "lcov"}}
+		flag.Lookup("test.coverprofile").Value.Set(coverageDat+".cover")
+		{{else}}
+		flag.Lookup("test.coverprofile").Value.Set(coverageDat)
+		{{end}}
+	}
+  {{else}}
 	if len(coverdata.Counters) > 0 {
 		testing.RegisterCover(testing.Cover{
 			Mode: "{{ .CoverMode }}",
@@ -234,7 +265,8 @@ func main() {
 			{{end}}
 		}
 	}
-	{{end}}
+  {{end}}
+{{end}}
 
 	testTimeout := os.Getenv("TEST_TIMEOUT")
 	if testTimeout != "" {
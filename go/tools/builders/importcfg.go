@@ -19,12 +19,14 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
 type archive struct {
@@ -38,28 +40,10 @@ type archive struct {
 // a map from source import paths to elements of archives or to nil
 // for standard library packages.
 func checkImports(files []fileInfo, archives []archive, stdPackageListPath string, importPath string, recompileInternalDeps []string) (map[string]*archive, error) {
-	// Read the standard package list.
-	packagesTxt, err := ioutil.ReadFile(stdPackageListPath)
+	stdPkgs, err := readStdPackageList(stdPackageListPath)
 	if err != nil {
 		return nil, err
 	}
-	stdPkgs := make(map[string]bool)
-	for len(packagesTxt) > 0 {
-		n := bytes.IndexByte(packagesTxt, '\n')
-		var line string
-		if n < 0 {
-			line = string(packagesTxt)
-			packagesTxt = nil
-		} else {
-			line = string(packagesTxt[:n])
-			packagesTxt = packagesTxt[n+1:]
-		}
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-		stdPkgs[line] = true
-	}
 
 	// Index the archives.
 	importToArchive := make(map[string]*archive)
@@ -107,63 +91,104 @@ func checkImports(files []fileInfo, archives []archive, stdPackageListPath strin
 	return imports, nil
 }
 
+// stdPackageLists caches the parsed standard package lists by path, so that a
+// persistent worker only parses the list of its SDK once.
+var stdPackageLists = struct {
+	sync.Mutex
+	lists map[string]stdPackageList
+}{lists: make(map[string]stdPackageList)}
+
+type stdPackageList struct {
+	modTime  time.Time
+	size     int64
+	packages map[string]bool
+}
+
+// readStdPackageList returns the set of packages listed in the file at path,
+// one per line. The set must not be modified.
+func readStdPackageList(path string) (map[string]bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	stdPackageLists.Lock()
+	cached, ok := stdPackageLists.lists[path]
+	stdPackageLists.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.packages, nil
+	}
+
+	packagesTxt, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	stdPkgs := make(map[string]bool)
+	for len(packagesTxt) > 0 {
+		n := bytes.IndexByte(packagesTxt, '\n')
+		var line string
+		if n < 0 {
+			line = string(packagesTxt)
+			packagesTxt = nil
+		} else {
+			line = string(packagesTxt[:n])
+			packagesTxt = packagesTxt[n+1:]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		stdPkgs[line] = true
+	}
+	stdPackageLists.Lock()
+	stdPackageLists.lists[path] = stdPackageList{modTime: info.ModTime(), size: info.Size(), packages: stdPkgs}
+	stdPackageLists.Unlock()
+	return stdPkgs, nil
+}
+
 // buildImportcfgFileForCompile writes an importcfg file to be consumed by the
 // compiler. The file is constructed from direct dependencies and std imports.
 // The caller is responsible for deleting the importcfg file.
 func buildImportcfgFileForCompile(imports map[string]*archive, installSuffix, dir string) (string, error) {
-	buf := &bytes.Buffer{}
 	goroot, ok := os.LookupEnv("GOROOT")
 	if !ok {
 		return "", errors.New("GOROOT not set")
 	}
-	goroot = abs(goroot)
 
-	sortedImports := make([]string, 0, len(imports))
-	for imp := range imports {
-		sortedImports = append(sortedImports, imp)
-	}
-	sort.Strings(sortedImports)
-
-	for _, imp := range sortedImports {
-		if arc := imports[imp]; arc == nil {
+	importMap := make(map[string]string)
+	packageFiles := make(map[string]string)
+	for imp, arc := range imports {
+		if arc == nil {
 			// std package
-			path := filepath.Join(goroot, "pkg", installSuffix, filepath.FromSlash(imp))
-			fmt.Fprintf(buf, "packagefile %s=%s.a\n", imp, path)
+			packageFiles[imp] = filepath.Join(goroot, "pkg", installSuffix, filepath.FromSlash(imp)) + ".a"
 		} else {
 			if imp != arc.packagePath {
-				fmt.Fprintf(buf, "importmap %s=%s\n", imp, arc.packagePath)
+				importMap[imp] = arc.packagePath
 			}
-			fmt.Fprintf(buf, "packagefile %s=%s\n", arc.packagePath, arc.file)
+			packageFiles[arc.packagePath] = arc.file
 		}
 	}
 
-	f, err := ioutil.TempFile(dir, "importcfg")
-	if err != nil {
-		return "", err
-	}
-	filename := f.Name()
-	if _, err := io.Copy(f, buf); err != nil {
-		f.Close()
-		os.Remove(filename)
-		return "", err
-	}
-	if err := f.Close(); err != nil {
-		os.Remove(filename)
+	filename := filepath.Join(dir, "importcfg")
+	if err := writeImportcfg(filename, importMap, packageFiles); err != nil {
 		return "", err
 	}
 	return filename, nil
 }
 
-func buildImportcfgFileForLink(archives []archive, stdPackageListPath, installSuffix, dir string) (string, error) {
-	buf := &bytes.Buffer{}
+// buildImportcfgFileForLink writes an importcfg file to be consumed by the
+// linker at path. The file is constructed from all standard packages and the
+// transitive dependencies in archives. The caller is responsible for deleting
+// the importcfg file.
+func buildImportcfgFileForLink(archives []archive, stdPackageListPath, installSuffix, path string) error {
 	goroot, ok := os.LookupEnv("GOROOT")
 	if !ok {
-		return "", errors.New("GOROOT not set")
+		return errors.New("GOROOT not set")
 	}
-	prefix := abs(filepath.Join(goroot, "pkg", installSuffix))
+	prefix := filepath.Join(goroot, "pkg", installSuffix)
+	packageFiles := make(map[string]string)
 	stdPackageListFile, err := os.Open(stdPackageListPath)
 	if err != nil {
-		return "", err
+		return err
 	}
 	defer stdPackageListFile.Close()
 	scanner := bufio.NewScanner(stdPackageListFile)
@@ -172,15 +197,15 @@ func buildImportcfgFileForLink(archives []archive, stdPackageListPath, installSu
 		if line == "" {
 			continue
 		}
-		fmt.Fprintf(buf, "packagefile %s=%s.a\n", line, filepath.Join(prefix, filepath.FromSlash(line)))
+		packageFiles[line] = filepath.Join(prefix, filepath.FromSlash(line)) + ".a"
 	}
 	if err := scanner.Err(); err != nil {
-		return "", err
+		return err
 	}
 	depsSeen := map[string]string{}
 	for _, arc := range archives {
 		if prevLabel, ok := depsSeen[arc.packagePath]; ok {
-			return "", fmt.Errorf(`
+			return fmt.Errorf(`
 package conflict error: %s: multiple copies of package passed to linker:
     %s
     %s
@@ -194,23 +219,57 @@ package with this path is linked.`,
 		// The parsing is incorrect because arrchiveMultiFlag assuming the formatting from
 		// `compilepkg.bzl` but `_format_archive` in `link.bzl` formats differently.
 		depsSeen[arc.packagePath] = arc.importPath
-		fmt.Fprintf(buf, "packagefile %s=%s\n", arc.packagePath, arc.file)
+		packageFiles[arc.packagePath] = arc.file
 	}
-	f, err := ioutil.TempFile(dir, "importcfg")
-	if err != nil {
-		return "", err
+	return writeImportcfg(path, nil, packageFiles)
+}
+
+// writeImportcfg writes an importcfg file with the given importmap and
+// packagefile directives to path. The file is canonical: directives are
+// sorted, and files below the working directory, which is the execroot, are
+// referred to by relative paths. Equal dependencies thus produce identical
+// files, no matter the order they were passed in, the sandbox the action runs
+// in or the name of the work directory.
+func writeImportcfg(path string, importMap, packageFiles map[string]string) error {
+	imps := make([]string, 0, len(importMap))
+	for imp := range importMap {
+		imps = append(imps, imp)
 	}
-	filename := f.Name()
-	if _, err := io.Copy(f, buf); err != nil {
-		f.Close()
-		os.Remove(filename)
-		return "", err
+	sort.Strings(imps)
+	pkgs := make([]string, 0, len(packageFiles))
+	for pkg := range packageFiles {
+		pkgs = append(pkgs, pkg)
 	}
-	if err := f.Close(); err != nil {
-		os.Remove(filename)
-		return "", err
+	sort.Strings(pkgs)
+
+	buf := &bytes.Buffer{}
+	for _, imp := range imps {
+		fmt.Fprintf(buf, "importmap %s=%s\n", imp, importMap[imp])
 	}
-	return filename, nil
+	for _, pkg := range pkgs {
+		fmt.Fprintf(buf, "packagefile %s=%s\n", pkg, importcfgFilePath(packageFiles[pkg]))
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o666)
+}
+
+// importcfgFilePath returns the path of a package file as it is written to an
+// importcfg file: cleaned, and relative to the working directory if the file
+// is below it. Windows can't open long relative paths, so absolute paths are
+// kept there.
+func importcfgFilePath(path string) string {
+	path = abs(path)
+	if runtime.GOOS == "windows" {
+		return path
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(wd, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return rel
 }
 
 type depsError struct {
@@ -0,0 +1,86 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuildImportcfgFileForLinkIsCanonical(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	t.Setenv("GOROOT", filepath.Join(wd, "goroot"))
+	packageList := filepath.Join(dir, "packages.txt")
+	if err := os.WriteFile(packageList, []byte("runtime\nfmt\n\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	archives := []archive{
+		{importPath: "example.com/b", packagePath: "example.com/b", file: filepath.Join(wd, "bazel-out", "b.a")},
+		{importPath: "example.com/a", packagePath: "example.com/a", file: filepath.Join(wd, "bazel-out", ".", "a.a")},
+		{importPath: "example.com/c", packagePath: "example.com/c", file: filepath.Join(dir, "c.a")},
+	}
+	reversed := []archive{archives[2], archives[1], archives[0]}
+
+	var got []string
+	for i, arcs := range [][]archive{archives, reversed} {
+		path := filepath.Join(dir, "importcfg")
+		if err := buildImportcfgFileForLink(arcs, packageList, "linux_amd64", path); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(data))
+		if i > 0 && got[i] != got[0] {
+			t.Errorf("importcfg depends on the order of archives:\n%s\nvs.\n%s", got[0], got[i])
+		}
+	}
+
+	want := "packagefile example.com/a=" + filepath.Join("bazel-out", "a.a") + "\n" +
+		"packagefile example.com/b=" + filepath.Join("bazel-out", "b.a") + "\n" +
+		"packagefile example.com/c=" + filepath.Join(dir, "c.a") + "\n" +
+		"packagefile fmt=" + filepath.Join("goroot", "pkg", "linux_amd64", "fmt.a") + "\n" +
+		"packagefile runtime=" + filepath.Join("goroot", "pkg", "linux_amd64", "runtime.a") + "\n"
+	if got[0] != want {
+		t.Errorf("got importcfg:\n%s\nwant:\n%s", got[0], want)
+	}
+}
+
+func TestBuildImportcfgFileForCompileIsCanonical(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GOROOT", filepath.Join(wd, "goroot"))
+	dep := &archive{importPath: "example.com/dep", packagePath: "example.com/dep_internal", file: filepath.Join(wd, "dep.a")}
+	imports := map[string]*archive{
+		"fmt":                 nil,
+		"example.com/dep":     dep,
+		"example.com/dep/old": dep,
+	}
+	dir := t.TempDir()
+	path, err := buildImportcfgFileForCompile(imports, "linux_amd64", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "importcfg"); path != want {
+		t.Errorf("got importcfg at %s, want %s", path, want)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "importmap example.com/dep=example.com/dep_internal\n" +
+		"importmap example.com/dep/old=example.com/dep_internal\n" +
+		"packagefile example.com/dep_internal=dep.a\n" +
+		"packagefile fmt=" + filepath.Join("goroot", "pkg", "linux_amd64", "fmt.a") + "\n"
+	if got := string(data); got != want {
+		t.Errorf("got importcfg:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -23,7 +23,6 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
-	"path/filepath"
 	"regexp"
 	"runtime"
 	"strconv"
@@ -67,6 +66,13 @@ func link(args []string) error {
 	}
 	*main = abs(*main)
 
+	if goenv.compiler == "gccgo" {
+		if len(xdefs) > 0 {
+			return fmt.Errorf("-X is not supported by gccgo")
+		}
+		return linkGccgo(goenv, *main, archives, *buildmode, *outFile, toolArgs)
+	}
+
 	// If we were given any stamp value files, read and parse them
 	stampMap := map[string]string{}
 	for _, stampfile := range stamps {
@@ -91,8 +97,8 @@ func link(args []string) error {
 	}
 
 	// Build an importcfg file.
-	importcfgName, err := buildImportcfgFileForLink(archives, *packageList, goenv.installSuffix, filepath.Dir(*outFile))
-	if err != nil {
+	importcfgName := *outFile + ".importcfg"
+	if err := buildImportcfgFileForLink(archives, *packageList, goenv.installSuffix, importcfgName); err != nil {
 		return err
 	}
 	if !goenv.shouldPreserveWorkDir {
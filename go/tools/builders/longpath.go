@@ -0,0 +1,56 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This file is shared between the builder, the nogo binary and nogo_apply.
+
+package main
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// windowsMaxDirPath is the longest directory path Windows accepts without the
+// extended-length prefix. It is MAX_PATH minus the 8.3 file name that must
+// still fit into a directory.
+const windowsMaxDirPath = 248
+
+// longPath returns a form of path that can be passed to the os package even
+// if it exceeds MAX_PATH on Windows, where paths below the execroot easily do.
+// On other platforms, path is returned unchanged.
+func longPath(path string) string {
+	if runtime.GOOS != "windows" {
+		return path
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return windowsLongPath(path)
+}
+
+// windowsLongPath adds the extended-length prefix to a clean, absolute Windows
+// path if it is too long to be used without it.
+func windowsLongPath(path string) string {
+	if len(path) < windowsMaxDirPath || strings.HasPrefix(path, `\\?\`) || strings.HasPrefix(path, `\\.\`) {
+		return path
+	}
+	// Extended-length paths are not normalized by Windows, so they must not
+	// contain forward slashes.
+	path = strings.ReplaceAll(path, "/", `\`)
+	if strings.HasPrefix(path, `\\`) {
+		return `\\?\UNC\` + path[len(`\\`):]
+	}
+	return `\\?\` + path
+}
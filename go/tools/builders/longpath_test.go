@@ -0,0 +1,24 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWindowsLongPath(t *testing.T) {
+	long := strings.Repeat("d", windowsMaxDirPath)
+	tests := []struct {
+		path, want string
+	}{
+		{`C:\short\file.go`, `C:\short\file.go`},
+		{`C:\` + long + `\file.go`, `\\?\C:\` + long + `\file.go`},
+		{`C:/` + long + `/file.go`, `\\?\C:\` + long + `\file.go`},
+		{`\\server\share\` + long, `\\?\UNC\server\share\` + long},
+		{`\\?\C:\` + long, `\\?\C:\` + long},
+	}
+	for _, tt := range tests {
+		if got := windowsLongPath(tt.path); got != tt.want {
+			t.Errorf("windowsLongPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -1,16 +1,24 @@
 package main
 
 import (
-	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"log"
+	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 )
 
+// nogoProfileDirEnv names an absolute directory that nogo writes a CPU
+// profile, a heap profile and an execution trace of each run to. It is set with
+// --action_env to find the analyzers that dominate the build time.
+const nogoProfileDirEnv = "NOGO_PROFILE_DIR"
+
 func nogo(args []string) error {
 	// Parse arguments.
 	args, _, err := expandParamsFiles(args)
@@ -18,13 +26,17 @@ func nogo(args []string) error {
 		return err
 	}
 
-	fs := flag.NewFlagSet("GoNogo", flag.ExitOnError)
+	// Invalid flags must not exit the builder if it runs as a persistent
+	// worker.
+	fs := flag.NewFlagSet("GoNogo", flag.ContinueOnError)
 	goenv := envFlags(fs)
 	var unfilteredSrcs, ignoreSrcs, recompileInternalDeps multiFlag
 	var deps, facts archiveMultiFlag
 	var importPath, packagePath, nogoPath, packageListPath string
 	var testFilter string
-	var outFactsPath, outLogPath, outFixPath string
+	var outFactsPath, outLogPath, outFixPath, outFixJSONPath, outFixDir, outInspectionPath, outDiagnosticsPath, outMetricsPath string
+	var metricsAllocs bool
+	var workspaceRoot, packageDir, label string
 	var coverMode string
 	fs.Var(&unfilteredSrcs, "src", ".go, .c, .cc, .m, .mm, .s, or .S file to be filtered and checked")
 	fs.Var(&ignoreSrcs, "ignore_src", ".go, .c, .cc, .m, .mm, .s, or .S file to be filtered and checked, but with its diagnostics ignored")
@@ -40,6 +52,15 @@ func nogo(args []string) error {
 	fs.StringVar(&outFactsPath, "out_facts", "", "The file to emit serialized nogo facts to")
 	fs.StringVar(&outLogPath, "out_log", "", "The file to emit nogo logs into")
 	fs.StringVar(&outFixPath, "out_fix", "", "The path of the file that stores the nogo fixes")
+	fs.StringVar(&outFixJSONPath, "out_fix_json", "", "The path of the file that stores the nogo fixes as JSON")
+	fs.StringVar(&outFixDir, "out_fix_dir", "", "The directory that stores a patch with the nogo fixes of each analyzer")
+	fs.StringVar(&outInspectionPath, "out_inspection", "", "The file to emit nogo diagnostics into in the IntelliJ inspection results format")
+	fs.StringVar(&outDiagnosticsPath, "out_diagnostics", "", "The file to emit nogo diagnostics into as JSON")
+	fs.StringVar(&outMetricsPath, "out_metrics", "", "The file to emit the wall time of each analyzer into as JSON")
+	fs.BoolVar(&metricsAllocs, "metrics_allocs", false, "Whether to also emit the bytes allocated by each analyzer into the file passed with -out_metrics")
+	fs.StringVar(&workspaceRoot, "workspace_root", "", "The execroot-relative path of the root of the repository containing the package")
+	fs.StringVar(&packageDir, "package_dir", "", "The execroot-relative path of the Bazel package containing the package")
+	fs.StringVar(&label, "label", "", "The label of the target being analyzed")
 
 	if err := fs.Parse(args); err != nil {
 		return err
@@ -84,10 +105,10 @@ func nogo(args []string) error {
 		return err
 	}
 
-	return runNogo(workDir, nogoPath, goSrcs, ignoreSrcs, facts, importPath, importcfgPath, outFactsPath, outLogPath, outFixPath)
+	return runNogo(workDir, nogoPath, goSrcs, ignoreSrcs, facts, importPath, importcfgPath, outFactsPath, outLogPath, outFixPath, outFixJSONPath, outFixDir, outInspectionPath, outDiagnosticsPath, outMetricsPath, metricsAllocs, workspaceRoot, packageDir, label)
 }
 
-func runNogo(workDir string, nogoPath string, srcs, ignores []string, facts []archive, packagePath, importcfgPath, outFactsPath, outLogPath, outFixPath string) error {
+func runNogo(workDir string, nogoPath string, srcs, ignores []string, facts []archive, packagePath, importcfgPath, outFactsPath, outLogPath, outFixPath, outFixJSONPath, outFixDir, outInspectionPath, outDiagnosticsPath, outMetricsPath string, metricsAllocs bool, workspaceRoot, packageDir, label string) error {
 	if len(srcs) == 0 {
 		// emit_compilepkg expects a nogo facts file, even if it's empty.
 		// We also need to write the validation output log.
@@ -99,15 +120,64 @@ func runNogo(workDir string, nogoPath string, srcs, ignores []string, facts []ar
 		if err != nil {
 			return fmt.Errorf("error writing empty nogo log file: %v", err)
 		}
-		err = os.WriteFile(outFixPath, nil, 0o666)
+		err = os.WriteFile(longPath(outFixPath), nil, 0o666)
 		if err != nil {
 			return fmt.Errorf("error writing empty nogo fix file: %v", err)
 		}
+		if outFixJSONPath != "" {
+			err = os.WriteFile(longPath(outFixJSONPath), []byte(emptyFixFileJSON), 0o666)
+			if err != nil {
+				return fmt.Errorf("error writing empty nogo JSON fix file: %v", err)
+			}
+		}
+		if outFixDir != "" {
+			err = os.MkdirAll(longPath(outFixDir), 0o777)
+			if err != nil {
+				return fmt.Errorf("error creating empty nogo fix directory: %v", err)
+			}
+		}
+		if outInspectionPath != "" {
+			err = os.WriteFile(longPath(outInspectionPath), []byte(emptyInspectionXML), 0o666)
+			if err != nil {
+				return fmt.Errorf("error writing empty nogo inspection file: %v", err)
+			}
+		}
+		if outDiagnosticsPath != "" {
+			quotedPackagePath, err := json.Marshal(packagePath)
+			if err != nil {
+				return err
+			}
+			err = os.WriteFile(longPath(outDiagnosticsPath), []byte(fmt.Sprintf(emptyDiagnosticsJSONFormat, quotedPackagePath)), 0o666)
+			if err != nil {
+				return fmt.Errorf("error writing empty nogo diagnostics file: %v", err)
+			}
+		}
+		if outMetricsPath != "" {
+			if err := (&metricsRecorder{}).write(outMetricsPath, packagePath); err != nil {
+				return fmt.Errorf("error writing empty nogo metrics file: %v", err)
+			}
+		}
 		return nil
 	}
 	args := []string{nogoPath}
 	args = append(args, "-p", packagePath)
 	args = append(args, "-fix", outFixPath)
+	if outFixJSONPath != "" {
+		args = append(args, "-fix_json", outFixJSONPath)
+	}
+	if outFixDir != "" {
+		args = append(args, "-fix_dir", outFixDir)
+	}
+	args = append(args, "-workspace_root", workspaceRoot, "-package_dir", packageDir)
+	if label != "" {
+		args = append(args, "-label", label)
+	}
+	if outInspectionPath != "" {
+		args = append(args, "-inspection_xml", outInspectionPath)
+	}
+	if outDiagnosticsPath != "" {
+		args = append(args, "-diagnostics_json", outDiagnosticsPath)
+	}
 	args = append(args, "-importcfg", importcfgPath)
 	for _, fact := range facts {
 		args = append(args, "-fact", fmt.Sprintf("%s=%s", fact.importPath, fact.file))
@@ -116,6 +186,20 @@ func runNogo(workDir string, nogoPath string, srcs, ignores []string, facts []ar
 	for _, ignore := range ignores {
 		args = append(args, "-ignore", ignore)
 	}
+	profileDir := os.Getenv(nogoProfileDirEnv)
+	if profileDir != "" {
+		profileArgs, err := nogoProfileArgs(profileDir, packagePath, outFactsPath)
+		if err != nil {
+			return err
+		}
+		args = append(args, profileArgs...)
+	}
+	if outMetricsPath != "" {
+		args = append(args, "-metrics", outMetricsPath)
+		if metricsAllocs {
+			args = append(args, "-metrics_allocs")
+		}
+	}
 	args = append(args, srcs...)
 
 	paramsFile := filepath.Join(workDir, "nogo.param")
@@ -123,9 +207,6 @@ func runNogo(workDir string, nogoPath string, srcs, ignores []string, facts []ar
 		return fmt.Errorf("error writing nogo params file: %v", err)
 	}
 
-	cmd := exec.Command(args[0], "-param="+paramsFile)
-	out := &bytes.Buffer{}
-	cmd.Stdout, cmd.Stderr = out, out
 	// Always create this file as a Bazel-declared output, but keep it empty
 	// if nogo finds no issues.
 	outLog, err := os.Create(outLogPath)
@@ -133,26 +214,52 @@ func runNogo(workDir string, nogoPath string, srcs, ignores []string, facts []ar
 		return fmt.Errorf("error creating nogo log file: %v", err)
 	}
 	defer outLog.Close()
-	err = cmd.Run()
-	if err == nil {
-		return nil
+	exitCode, out, err := execNogo(args[0], paramsFile)
+	if profileDir != "" {
+		log.Printf("profiles of %s written to %s", packagePath, filepath.Join(profileDir, nogoProfileName(packagePath, outFactsPath))+".*")
 	}
-	if exitErr, ok := err.(*exec.ExitError); ok {
-		if !exitErr.Exited() {
-			cmdLine := strings.Join(args, " ")
-			return fmt.Errorf("nogo command '%s' exited unexpectedly: %s", cmdLine, exitErr.String())
-		}
-		prettyOut := relativizePaths(out.Bytes())
-		if exitErr.ExitCode() != nogoViolation {
-			return errors.New(string(prettyOut))
-		}
-		// Do not fail the action if nogo has findings so that facts are
-		// still available for downstream targets.
-		_, err := outLog.Write(prettyOut)
-		if err != nil {
-			return fmt.Errorf("error writing nogo log file: %v", err)
-		}
+	if err != nil {
+		cmdLine := strings.Join(args, " ")
+		return fmt.Errorf("nogo command '%s' exited unexpectedly: %v", cmdLine, err)
+	}
+	if exitCode == nogoSuccess {
 		return nil
 	}
-	return err
+	prettyOut := relativizePaths(out)
+	if exitCode != nogoViolation && exitCode != nogoWarning {
+		return errors.New(string(prettyOut))
+	}
+	// Do not fail the action if nogo has findings so that facts are
+	// still available for downstream targets. nogovalidation decides
+	// whether they fail the build.
+	if _, err := outLog.Write(prettyOut); err != nil {
+		return fmt.Errorf("error writing nogo log file: %v", err)
+	}
+	return nil
+}
+
+// nogoProfileArgs returns the nogo flags that write the profiles of the nogo
+// run into dir.
+func nogoProfileArgs(dir, packagePath, outFactsPath string) ([]string, error) {
+	if !filepath.IsAbs(dir) {
+		return nil, fmt.Errorf("%s must be an absolute path, got %q", nogoProfileDirEnv, dir)
+	}
+	if err := os.MkdirAll(dir, 0o777); err != nil {
+		return nil, fmt.Errorf("error creating nogo profile directory: %v", err)
+	}
+	prefix := filepath.Join(dir, nogoProfileName(packagePath, outFactsPath))
+	return []string{
+		"-cpuprofile", prefix + ".cpu.pprof",
+		"-memprofile", prefix + ".mem.pprof",
+		"-trace", prefix + ".trace",
+	}, nil
+}
+
+// nogoProfileName returns the base name of the profiles of a nogo run. The
+// package path is followed by a hash of the facts file, which distinguishes
+// the runs on the library and the test variant of a package and on different
+// configurations.
+func nogoProfileName(packagePath, outFactsPath string) string {
+	sum := sha256.Sum256([]byte(outFactsPath))
+	return url.PathEscape(packagePath) + "." + hex.EncodeToString(sum[:4])
 }
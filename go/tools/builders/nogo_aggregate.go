@@ -0,0 +1,109 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// nogo_aggregate merges the fix files produced by nogo for many targets into a
+// single patch for the whole workspace.
+//
+// Usage: nogo_aggregate -o out.patch [-patch_list file] patch...
+//
+// It runs as a build action of the nogo_fix_aggregate rule and therefore
+// doesn't read the patched sources: hunks for the same file are merged based on
+// the lines they contain. Identical changes are included once. Files with
+// conflicting changes are left out of the merged patch and reported.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("nogo_aggregate: ")
+	if err := runAggregate(os.Args[1:], os.Stderr); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// aggregateContext is the number of context lines around the merged changes,
+// matching the fix files written by nogo.
+const aggregateContext = 3
+
+func runAggregate(args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet("nogo_aggregate", flag.ExitOnError)
+	out := fs.String("o", "", "The file to write the merged patch to")
+	patchList := fs.String("patch_list", "", "A file listing the patch files to merge, one per line")
+	strip := fs.Int("p", -1, "Number of leading path components to strip from file names in the patches (default: the level recorded in each patch by nogo, or 1)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return fmt.Errorf("-o is required")
+	}
+	patchFiles := fs.Args()
+	if *patchList != "" {
+		listed, err := readPatchList(*patchList)
+		if err != nil {
+			return err
+		}
+		patchFiles = append(patchFiles, listed...)
+	}
+
+	readFile := func(name string) ([]byte, error) {
+		return nil, fmt.Errorf("legacy fix files can't be merged, they need the content of %s", name)
+	}
+	files, err := collectFileEdits(patchFiles, "", readFile, *strip)
+	if err != nil {
+		return err
+	}
+	var merged bytes.Buffer
+	skipped := 0
+	for _, fe := range files {
+		hunks, _, err := mergeHunks(fe.hunks, aggregateContext)
+		if err != nil {
+			skipped++
+			fmt.Fprintf(stderr, "%s: left out of the merged patch: %v\n", fe.path, err)
+			continue
+		}
+		if len(hunks) == 0 {
+			continue
+		}
+		oldName, newName := fe.patchNames()
+		if err := writeFilePatch(&merged, filePatch{oldName: oldName, newName: newName, hunks: hunks, digest: commonDigest(fe.hunks)}); err != nil {
+			return err
+		}
+	}
+	if skipped > 0 {
+		fmt.Fprintf(stderr, "%d of %d file(s) have conflicting fixes and must be fixed manually\n", skipped, len(files))
+	}
+	return os.WriteFile(longPath(*out), merged.Bytes(), 0o666)
+}
+
+// commonDigest returns the content digest that all hunks were generated
+// against, or "" if it is unknown for any of them or they disagree.
+func commonDigest(hunks []sourcedHunk) string {
+	if len(hunks) == 0 {
+		return ""
+	}
+	for _, h := range hunks[1:] {
+		if h.digest != hunks[0].digest {
+			return ""
+		}
+	}
+	return hunks[0].digest
+}
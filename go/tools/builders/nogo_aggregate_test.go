@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const aggregateTestSource = `package main
+
+import "fmt"
+
+func Hello() {
+	fmt.Println("hello")
+}
+
+func Bye() {
+	fmt.Println("bye")
+}
+
+var x = 10
+`
+
+func TestMergeHunks(t *testing.T) {
+	// The library and the test variant of a package suggest the same fix for
+	// Hello and the test variant suggests another one for x.
+	lib, err := parsePatch([]byte(`--- a/pkg/file.go
++++ b/pkg/file.go
+@@ -4,4 +4,4 @@
+ 
+ func Hello() {
+-	fmt.Println("hello")
++	fmt.Println("Hello")
+ }
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test, err := parsePatch([]byte(`--- a/pkg/file.go
++++ b/pkg/file.go
+@@ -4,4 +4,4 @@
+ 
+ func Hello() {
+-	fmt.Println("hello")
++	fmt.Println("Hello")
+ }
+@@ -11,3 +11,4 @@
+ }
+ 
++// x is ten.
+ var x = 10
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hunks []sourcedHunk
+	for _, h := range lib[0].hunks {
+		hunks = append(hunks, sourcedHunk{patchHunk: h, source: "lib.patch"})
+	}
+	for _, h := range test[0].hunks {
+		hunks = append(hunks, sourcedHunk{patchHunk: h, source: "test.patch"})
+	}
+
+	merged, numEdits, err := mergeHunks(hunks, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if numEdits != 2 {
+		t.Errorf("got %d edits, want 2", numEdits)
+	}
+	var buf bytes.Buffer
+	if err := writeFilePatch(&buf, filePatch{oldName: "a/pkg/file.go", newName: "b/pkg/file.go", hunks: merged}); err != nil {
+		t.Fatal(err)
+	}
+	expected := `--- a/pkg/file.go
++++ b/pkg/file.go
+@@ -4,4 +4,4 @@
+ 
+ func Hello() {
+-	fmt.Println("hello")
++	fmt.Println("Hello")
+ }
+@@ -11,3 +11,4 @@
+ }
+ 
++// x is ten.
+ var x = 10
+`
+	if got := buf.String(); got != expected {
+		t.Errorf("unexpected patch:\n\tgot:\n%s\n\twant:\n%s", got, expected)
+	}
+
+	// The merged patch applies to the original file.
+	patches, err := parsePatch(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := splitLines([]byte(aggregateTestSource))
+	var edits []lineEdit
+	for _, h := range patches[0].hunks {
+		e, err := h.lineEdits(lines, "merged.patch")
+		if err != nil {
+			t.Fatalf("merged patch doesn't apply: %v", err)
+		}
+		edits = append(edits, e...)
+	}
+	got := string(applyLineEdits(lines, edits))
+	want := strings.Replace(strings.Replace(aggregateTestSource, `"hello"`, `"Hello"`, 1), "var x", "// x is ten.\nvar x", 1)
+	if got != want {
+		t.Errorf("unexpected content:\n\tgot:\n%s\n\twant:\n%s", got, want)
+	}
+}
+
+func TestMergeHunks_Inconsistent(t *testing.T) {
+	hunks := []sourcedHunk{
+		{source: "1.patch", patchHunk: patchHunk{oldStart: 1, oldLines: 1, newStart: 1, newLines: 1, lines: []patchLine{{'-', "a\n"}, {'+', "b\n"}}}},
+		{source: "2.patch", patchHunk: patchHunk{oldStart: 1, oldLines: 2, newStart: 1, newLines: 2, lines: []patchLine{{' ', "x\n"}, {'-', "y\n"}, {'+', "z\n"}}}},
+	}
+	_, _, err := mergeHunks(hunks, 3)
+	if err == nil || !strings.Contains(err.Error(), "not generated from the same version") {
+		t.Errorf("expected an error about different versions, got: %v", err)
+	}
+}
+
+func TestEditHunks(t *testing.T) {
+	lines := []string{"1\n", "2\n", "3\n", "4\n", "5\n", "6\n", "7\n", "8\n", "9\n", "10\n"}
+	all := func(int) bool { return true }
+	edits := []lineEdit{
+		{start: 1, old: []string{"2\n"}},
+		{start: 4, new: []string{"4.5\n"}},
+		{start: 9, old: []string{"10\n"}, new: []string{"ten"}},
+	}
+	got := editHunks(lines, all, edits, 1)
+	expected := []patchHunk{
+		{
+			oldStart: 1, oldLines: 5, newStart: 1, newLines: 5,
+			lines: []patchLine{{' ', "1\n"}, {'-', "2\n"}, {' ', "3\n"}, {' ', "4\n"}, {'+', "4.5\n"}, {' ', "5\n"}},
+		},
+		{
+			oldStart: 9, oldLines: 2, newStart: 9, newLines: 2,
+			lines: []patchLine{{' ', "9\n"}, {'-', "10\n"}, {'+', "ten"}},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected hunks:\n\tgot:\t%v\n\twant:\t%v", got, expected)
+	}
+}
+
+func TestRunAggregate(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	// The digests of the files are kept if all patches of a file agree on it.
+	fix1 := writeFile("1.patch", "# nogo: sha256=b1\n--- a/b.go\n+++ b/b.go\n@@ -1 +1 @@\n-package b\n+package bb\n# nogo: sha256=a1\n--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-package a\n+package aa\n")
+	fix2 := writeFile("2.patch", "# nogo: sha256=a2\n--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-package a\n+package aa\n--- a/c.go\n+++ b/c.go\n@@ -1 +1 @@\n-package c\n+package c1\n")
+	// Conflicts with the fix for c.go in 2.patch.
+	fix3 := writeFile("3.patch", "--- a/c.go\n+++ b/c.go\n@@ -1 +1 @@\n-package c\n+package c2\n")
+	// Created and deleted files keep /dev/null as their old or new name.
+	fix4 := writeFile("4.patch", "--- /dev/null\n+++ b/d.go\n@@ -0,0 +1 @@\n+package d\n--- a/e.go\n+++ /dev/null\n@@ -1 +0,0 @@\n-package e\n")
+	// Packages without fixes have empty fix files.
+	empty := writeFile("empty.patch", "")
+	list := writeFile("patches.txt", fix2+"\n"+fix3+"\n"+fix4+"\n"+empty+"\n")
+	out := filepath.Join(dir, "merged.patch")
+
+	var stderr bytes.Buffer
+	if err := runAggregate([]string{"-o", out, "-patch_list", list, fix1}, &stderr); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-package a\n+package aa\n# nogo: sha256=b1\n--- a/b.go\n+++ b/b.go\n@@ -1 +1 @@\n-package b\n+package bb\n" +
+		"--- /dev/null\n+++ b/d.go\n@@ -0,0 +1 @@\n+package d\n--- a/e.go\n+++ /dev/null\n@@ -1 +0,0 @@\n-package e\n"
+	if string(got) != expected {
+		t.Errorf("unexpected patch:\n\tgot:\n%s\n\twant:\n%s", got, expected)
+	}
+	if !strings.Contains(stderr.String(), "c.go: left out of the merged patch: conflicting changes") {
+		t.Errorf("expected the conflict to be reported, got:\n%s", stderr.String())
+	}
+}
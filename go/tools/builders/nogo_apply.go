@@ -0,0 +1,439 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// nogo_apply applies the fix files produced by nogo to the workspace.
+//
+// Usage: bazel run @io_bazel_rules_go//go/tools/builders:nogo_apply -- [-p N] patch...
+//
+// Without arguments, the patches listed in the file named by the
+// NOGO_APPLY_PATCH_LIST environment variable are applied. This is how the
+// nogo_fix rule runs nogo_apply.
+//
+// Patches from different files that touch the same source file are merged:
+// identical changes, such as the ones suggested for both the library and the
+// test variant of a package, are applied once and conflicting changes are
+// reported without modifying the file. Files are patched concurrently.
+//
+// Like patch, nogo_apply also applies patches generated from an older version
+// of a file: hunks whose lines moved are applied where their context now
+// matches, and with -fuzz N, up to N lines of context at the start and end of
+// a hunk may differ. Files with hunks that don't match anymore are left
+// untouched unless -partial is given, which applies the other hunks. The
+// rejected hunks are reported either way.
+//
+// Patches whose old file is /dev/null create the file, and patches whose new
+// file is /dev/null delete it.
+//
+// Patches written by nogo record the SHA-256 digest of each file they were
+// generated against in a "# nogo: sha256=..." metadata line. Files that
+// changed since are reported, and with -strict, they are not patched.
+//
+// With -check, nogo_apply only verifies that all hunks still apply, e.g. in CI
+// before fixes are applied automatically: it reports the hunks that don't and
+// exits with an error if there are any, but doesn't modify any file.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("nogo_apply: ")
+	if err := runApply(os.Args[1:], os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// An applyResult is the outcome of patching a single source file.
+type applyResult struct {
+	path       string
+	numEdits   int
+	numSources int
+	// notes describe the hunks that were applied at another line or with
+	// fuzz and the hunks that were rejected.
+	notes    []string
+	rejected int
+	err      error
+}
+
+// An applyMode controls how hunks that don't match the file are handled.
+type applyMode struct {
+	// fuzz is the number of context lines at the start and end of a hunk
+	// that may differ from the file.
+	fuzz int
+	// partial applies the hunks that match the file even if others don't.
+	partial bool
+	// check only verifies that the hunks apply without modifying the file.
+	check bool
+	// strict rejects the hunks of patches generated from another version of
+	// the file, according to their digest, even if they match.
+	strict bool
+}
+
+// patchListEnv names a file listing the patch files to apply, one per line,
+// relative to the current directory.
+const patchListEnv = "NOGO_APPLY_PATCH_LIST"
+
+func runApply(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("nogo_apply", flag.ExitOnError)
+	strip := fs.Int("p", -1, "Number of leading path components to strip from file names in the patches (default: the level recorded in each patch by nogo, or 1)")
+	root := fs.String("root", "", "Directory the patched paths are relative to (default: the workspace root under bazel run, else the current directory)")
+	jobs := fs.Int("j", runtime.GOMAXPROCS(0), "Number of files to patch concurrently")
+	fuzz := fs.Int("fuzz", 0, "Number of context lines at the start and end of a hunk that may differ from the file")
+	partial := fs.Bool("partial", false, "Patch files even if some of their hunks don't match, reporting the rejected hunks")
+	check := fs.Bool("check", false, "Only check that the patches apply, without modifying any file")
+	strict := fs.Bool("strict", false, "Don't patch files that changed since the patches were generated, according to their digests")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	patchFiles := fs.Args()
+	// Relative patch paths are given relative to the directory bazel run was
+	// invoked from.
+	cwd := os.Getenv("BUILD_WORKING_DIRECTORY")
+	if listFile := os.Getenv(patchListEnv); len(patchFiles) == 0 && listFile != "" {
+		var err error
+		if patchFiles, err = readPatchList(listFile); err != nil {
+			return err
+		}
+		if len(patchFiles) == 0 {
+			return fmt.Errorf("%s lists no patch files", listFile)
+		}
+	}
+	if len(patchFiles) == 0 {
+		return fmt.Errorf("no patch files given")
+	}
+	if *root == "" {
+		*root = os.Getenv("BUILD_WORKSPACE_DIRECTORY")
+	}
+
+	readFile := func(name string) ([]byte, error) {
+		return os.ReadFile(longPath(filepath.Join(*root, filepath.FromSlash(name))))
+	}
+	files, err := collectFileEdits(patchFiles, cwd, readFile, *strip)
+	if err != nil {
+		return err
+	}
+	files = mergeCaseCollisions(*root, files, stdout)
+	if len(files) == 0 {
+		fmt.Fprintln(stdout, "nogo_apply: no fixes to apply")
+		return nil
+	}
+	results := applyFileEdits(*root, files, *jobs, applyMode{fuzz: *fuzz, partial: *partial, check: *check, strict: *strict})
+
+	failed := 0
+	for _, r := range results {
+		switch {
+		case r.err != nil && *check:
+			failed++
+			fmt.Fprintf(stdout, "%s: does not apply: %v\n", r.path, r.err)
+		case r.err != nil:
+			failed++
+			fmt.Fprintf(stdout, "%s: not patched: %v\n", r.path, r.err)
+		case r.rejected > 0 && *check:
+			failed++
+			fmt.Fprintf(stdout, "%s: %d change(s) from %d patch file(s) apply, rejected %d hunk(s)\n", r.path, r.numEdits, r.numSources, r.rejected)
+		case r.rejected > 0:
+			failed++
+			fmt.Fprintf(stdout, "%s: applied %d change(s) from %d patch file(s), rejected %d hunk(s)\n", r.path, r.numEdits, r.numSources, r.rejected)
+		case *check:
+			fmt.Fprintf(stdout, "%s: %d change(s) from %d patch file(s) apply\n", r.path, r.numEdits, r.numSources)
+		default:
+			fmt.Fprintf(stdout, "%s: applied %d change(s) from %d patch file(s)\n", r.path, r.numEdits, r.numSources)
+		}
+		for _, note := range r.notes {
+			fmt.Fprintf(stdout, "    %s\n", note)
+		}
+	}
+	if failed > 0 && *check {
+		return fmt.Errorf("the patches don't apply to %d of %d file(s)", failed, len(results))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d file(s) could not be patched", failed, len(results))
+	}
+	return nil
+}
+
+// mergeCaseCollisions merges the edits for paths that differ only in case but
+// refer to the same file, as they do on case-insensitive file systems, e.g.
+// when the patches were generated from checkouts with different casing.
+// Patching such a file under both names would lose one set of edits. The
+// merged edits use the casing of the file on disk.
+func mergeCaseCollisions(root string, files []*fileEdits, warn io.Writer) []*fileEdits {
+	byFoldedPath := make(map[string][]*fileEdits)
+	for _, fe := range files {
+		folded := strings.ToLower(fe.path)
+		byFoldedPath[folded] = append(byFoldedPath[folded], fe)
+	}
+
+	var merged []*fileEdits
+	for _, fe := range files {
+		group := byFoldedPath[strings.ToLower(fe.path)]
+		if group == nil {
+			// Already merged into another entry.
+			continue
+		}
+		delete(byFoldedPath, strings.ToLower(fe.path))
+		if len(group) == 1 {
+			merged = append(merged, fe)
+			continue
+		}
+		// Only paths that refer to the same file are merged. On case-sensitive
+		// file systems, the paths may well be distinct files.
+		for len(group) > 0 {
+			first := group[0]
+			same := []*fileEdits{first}
+			var rest []*fileEdits
+			firstInfo, err := os.Stat(longPath(filepath.Join(root, first.path)))
+			for _, other := range group[1:] {
+				otherInfo, otherErr := os.Stat(longPath(filepath.Join(root, other.path)))
+				if err == nil && otherErr == nil && os.SameFile(firstInfo, otherInfo) {
+					same = append(same, other)
+				} else {
+					rest = append(rest, other)
+				}
+			}
+			group = rest
+			if len(same) == 1 {
+				merged = append(merged, first)
+				continue
+			}
+
+			canonical, err := canonicalPath(root, first.path)
+			if err != nil {
+				canonical = first.path
+			}
+			combined := &fileEdits{path: canonical}
+			var names []string
+			for _, fe := range same {
+				names = append(names, fe.path)
+				for _, source := range fe.sources {
+					if !containsString(combined.sources, source) {
+						combined.sources = append(combined.sources, source)
+					}
+				}
+				combined.hunks = append(combined.hunks, fe.hunks...)
+				combined.created = combined.created || fe.created
+				combined.deleted = combined.deleted || fe.deleted
+			}
+			fmt.Fprintf(warn, "warning: %s refer to the same file, patching it as %s\n", strings.Join(names, ", "), canonical)
+			merged = append(merged, combined)
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].path < merged[j].path
+	})
+	return merged
+}
+
+// canonicalPath returns the path relative to root with the casing of the
+// directory entries on disk, matching each component case-insensitively if
+// there is no exact match.
+func canonicalPath(root, path string) (string, error) {
+	dir := root
+	if dir == "" {
+		dir = "."
+	}
+	var canonical []string
+	for _, name := range strings.Split(filepath.ToSlash(path), "/") {
+		entries, err := os.ReadDir(longPath(dir))
+		if err != nil {
+			return "", err
+		}
+		match := ""
+		for _, e := range entries {
+			if e.Name() == name {
+				match = name
+				break
+			}
+			if match == "" && strings.EqualFold(e.Name(), name) {
+				match = e.Name()
+			}
+		}
+		if match == "" {
+			return "", fmt.Errorf("%s not found in %s", name, dir)
+		}
+		canonical = append(canonical, match)
+		dir = filepath.Join(dir, match)
+	}
+	return filepath.Join(canonical...), nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// applyFileEdits patches the files concurrently, with at most jobs files in
+// flight. The results are returned in the order of files.
+func applyFileEdits(root string, files []*fileEdits, jobs int, mode applyMode) []applyResult {
+	if jobs < 1 {
+		jobs = 1
+	}
+	results := make([]applyResult, len(files))
+	sem := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	for i, fe := range files {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, fe *fileEdits) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			r := applyToFile(longPath(filepath.Join(root, fe.path)), fe, mode)
+			r.path, r.numSources = fe.path, len(fe.sources)
+			results[i] = r
+		}(i, fe)
+	}
+	wg.Wait()
+	return results
+}
+
+// staleSources returns the patch files of the hunks whose digest, if known,
+// doesn't match content, in the order of the hunks.
+func staleSources(content []byte, hunks []sourcedHunk) []string {
+	var stale []string
+	digest := ""
+	for _, h := range hunks {
+		if h.digest == "" || containsString(stale, h.source) {
+			continue
+		}
+		if digest == "" {
+			digest = contentDigest(content)
+		}
+		if h.digest != digest {
+			stale = append(stale, h.source)
+		}
+	}
+	return stale
+}
+
+// applyToFile applies all hunks to the file, or none of them if any hunk
+// conflicts with another hunk or, unless mode.partial is set, doesn't match
+// the file. Hunks are located in the file with patchHunk.locate.
+//
+// If fe.created is set, the file is created if it doesn't exist, and if
+// fe.deleted is set, the file is removed if the hunks remove all of its lines.
+// With mode.strict, no hunk is applied if any of them comes from a patch
+// generated from another version of the file.
+func applyToFile(path string, fe *fileEdits, mode applyMode) applyResult {
+	hunks := fe.hunks
+	perm := os.FileMode(0o644)
+	var content []byte
+	info, err := os.Stat(path)
+	switch {
+	case os.IsNotExist(err) && fe.created:
+		if mode.check {
+			break
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return applyResult{err: err}
+		}
+	case err != nil:
+		return applyResult{err: err}
+	case fe.created && info.Size() > 0:
+		return applyResult{err: fmt.Errorf("the patch creates the file, but it already exists")}
+	default:
+		perm = info.Mode().Perm()
+		if content, err = os.ReadFile(path); err != nil {
+			return applyResult{err: err}
+		}
+	}
+	lines := splitLines(content)
+
+	var r applyResult
+	if stale := staleSources(content, hunks); len(stale) > 0 {
+		if mode.strict {
+			return applyResult{err: fmt.Errorf("the file changed since %s was generated", strings.Join(stale, ", "))}
+		}
+		for _, source := range stale {
+			r.notes = append(r.notes, fmt.Sprintf("%s: generated from another version of the file", source))
+		}
+	}
+	var edits []lineEdit
+	for _, h := range hunks {
+		located, fuzz, ok := h.locate(lines, mode.fuzz)
+		if !ok {
+			_, err := h.lineEdits(lines, h.source)
+			r.rejected++
+			r.notes = append(r.notes, fmt.Sprintf("%s: rejected: %v", h.source, err))
+			continue
+		}
+		e, err := located.lineEdits(lines, h.source)
+		if err != nil {
+			return applyResult{err: fmt.Errorf("%s: %v", h.source, err)}
+		}
+		if offset := located.startIndex() - h.withoutContext(fuzz).startIndex(); offset != 0 || fuzz > 0 {
+			r.notes = append(r.notes, fmt.Sprintf("%s: hunk @@ -%d,%d @@ applied at line %d (offset %d lines, fuzz %d)", h.source, h.oldStart, h.oldLines, located.oldStart, offset, fuzz))
+		}
+		edits = append(edits, e...)
+	}
+	if r.rejected > 0 && !mode.partial {
+		r.err = fmt.Errorf("%d of %d hunk(s) don't match the file", r.rejected, len(hunks))
+		return r
+	}
+	edits, err = mergeLineEdits(edits)
+	if err != nil {
+		return applyResult{err: err}
+	}
+	r.numEdits = len(edits)
+	if len(edits) == 0 || mode.check {
+		return r
+	}
+	patched := applyLineEdits(lines, edits)
+	if fe.deleted && len(patched) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return applyResult{err: err}
+		}
+		r.notes = append(r.notes, "removed the file")
+		return r
+	}
+
+	// Write to a temporary file first so that the source file is never left
+	// partially written.
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".nogo_apply")
+	if err != nil {
+		return applyResult{err: err}
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(patched); err != nil {
+		tmp.Close()
+		return applyResult{err: err}
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return applyResult{err: err}
+	}
+	if err := tmp.Close(); err != nil {
+		return applyResult{err: err}
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return applyResult{err: err}
+	}
+	return r
+}
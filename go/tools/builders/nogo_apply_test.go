@@ -0,0 +1,937 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const applyTestSource = `package main
+
+func Hello() {}
+
+var x = 10
+`
+
+func TestParsePatch(t *testing.T) {
+	patch := `# pkg/file.go:2:1: remove empty line (analyzer)
+--- a/pkg/file.go
++++ b/pkg/file.go
+@@ -1,3 +1,3 @@
+ package main
+-
++// comment
+ func Hello() {}
+@@ -5 +5 @@
+-var x = 10
++var x = 11
+\ No newline at end of file
+`
+	patches, err := parsePatch([]byte(patch))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []filePatch{{
+		oldName: "a/pkg/file.go",
+		newName: "b/pkg/file.go",
+		hunks: []patchHunk{
+			{
+				oldStart: 1, oldLines: 3, newStart: 1, newLines: 3,
+				lines: []patchLine{
+					{' ', "package main\n"},
+					{'-', "\n"},
+					{'+', "// comment\n"},
+					{' ', "func Hello() {}\n"},
+				},
+			},
+			{
+				oldStart: 5, oldLines: 1, newStart: 5, newLines: 1,
+				lines: []patchLine{
+					{'-', "var x = 10\n"},
+					{'+', "var x = 11"},
+				},
+			},
+		},
+	}}
+	if !reflect.DeepEqual(patches, expected) {
+		t.Errorf("unexpected patches:\n\tgot:\t%v\n\twant:\t%v", patches, expected)
+	}
+}
+
+func TestParsePatch_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		patch       string
+		expectedErr string
+	}{
+		{
+			name:        "hunk without file",
+			patch:       "@@ -1 +1 @@\n-a\n+b\n",
+			expectedErr: "hunk outside of a file section",
+		},
+		{
+			name:        "truncated hunk",
+			patch:       "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n-a\n+b\n",
+			expectedErr: "hunk is shorter than its header says",
+		},
+		{
+			name:        "bad header",
+			patch:       "--- a/f\n+++ b/f\n@@ -x +1 @@\n",
+			expectedErr: "invalid hunk header",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parsePatch([]byte(tt.patch))
+			if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestParsePatchEdits(t *testing.T) {
+	patch := `# pkg/file.go:3:6: rename Hello (analyzer)
+--- a/pkg/file.go
++++ b/pkg/file.go
+@@ -2,3 +2,3 @@
+ 
+-func Hello() {}
++func Hi() {}
+ 
+@@ -5 +5,2 @@
+-var x = 10
++var x = 11
++var y = 12
+--- /dev/null
++++ b/pkg/new.go
+@@ -0,0 +1 @@
++package main
+--- a/pkg/old.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package main
+`
+	files := map[string]string{
+		filepath.FromSlash("pkg/file.go"): applyTestSource,
+		filepath.FromSlash("pkg/old.go"):  "package main\n",
+	}
+	readFile := func(name string) ([]byte, error) {
+		content, ok := files[name]
+		if !ok {
+			t.Fatalf("unexpected file %q", name)
+		}
+		return []byte(content), nil
+	}
+	got, err := parsePatchEdits([]byte(patch), readFile, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string][]offsetEdit{
+		filepath.FromSlash("pkg/file.go"): {
+			{New: "func Hi() {}\n", Start: 14, End: 30},
+			{New: "var x = 11\nvar y = 12\n", Start: 31, End: 42},
+		},
+		filepath.FromSlash("pkg/new.go"): {{New: "package main\n"}},
+		filepath.FromSlash("pkg/old.go"): {{Start: 0, End: 13}},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected edits:\n\tgot:\t%v\n\twant:\t%v", got, expected)
+	}
+
+	files[filepath.FromSlash("pkg/file.go")] = strings.Replace(applyTestSource, "Hello", "Bye", 1)
+	if _, err := parsePatchEdits([]byte(patch), readFile, 1); err == nil || !strings.Contains(err.Error(), "does not match line 3") {
+		t.Errorf("expected an error for a file that doesn't match the patch, got %v", err)
+	}
+}
+
+func TestMergeLineEdits(t *testing.T) {
+	edits := []lineEdit{
+		{start: 4, old: []string{"d\n"}, new: []string{"D\n"}, source: "2.patch"},
+		{start: 0, old: []string{"a\n"}, new: []string{"A\n"}, source: "1.patch"},
+		// A duplicate of the first edit from a different patch file.
+		{start: 4, old: []string{"d\n"}, new: []string{"D\n"}, source: "1.patch"},
+	}
+	merged, err := mergeLineEdits(edits)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []lineEdit{
+		{start: 0, old: []string{"a\n"}, new: []string{"A\n"}, source: "1.patch"},
+		{start: 4, old: []string{"d\n"}, new: []string{"D\n"}, source: "2.patch"},
+	}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("unexpected edits:\n\tgot:\t%v\n\twant:\t%v", merged, expected)
+	}
+
+	conflicting := append(edits, lineEdit{start: 4, old: []string{"d\n"}, new: []string{"X\n"}, source: "3.patch"})
+	_, err = mergeLineEdits(conflicting)
+	if err == nil || !strings.Contains(err.Error(), "conflicting changes to lines 5-5") {
+		t.Errorf("expected conflict, got: %v", err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "pkg"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile := func(name, content string) string {
+		path := filepath.Join(root, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	source := writeFile("pkg/file.go", applyTestSource)
+	other := writeFile("pkg/other.go", "package main\n")
+
+	// The library and the test variant of a package suggest the same fix and
+	// the test variant suggests an additional one with overlapping context.
+	libPatch := writeFile("lib.patch", `--- a/pkg/file.go
++++ b/pkg/file.go
+@@ -2,3 +2,3 @@
+
+-func Hello() {}
++func Hello() { println("hello") }
+
+`)
+	testPatch := writeFile("test.patch", `--- a/pkg/file.go
++++ b/pkg/file.go
+@@ -2,5 +2,5 @@
+
+-func Hello() {}
++func Hello() { println("hello") }
+
+-var x = 10
++var x = 11
+
+`)
+	// Patches for other files are applied independently.
+	otherPatch := writeFile("other.patch", `--- a/pkg/other.go
++++ b/pkg/other.go
+@@ -1 +1 @@
+-package main
++package other
+`)
+
+	var stdout bytes.Buffer
+	if err := runApply([]string{"-root", root, libPatch, testPatch, otherPatch}, &stdout); err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, stdout.String())
+	}
+	got, err := os.ReadFile(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Replace(strings.Replace(applyTestSource, "func Hello() {}", `func Hello() { println("hello") }`, 1), "10", "11", 1)
+	if string(got) != expected {
+		t.Errorf("unexpected content:\n\tgot:\n%s\n\twant:\n%s", got, expected)
+	}
+	if got, err := os.ReadFile(other); err != nil || string(got) != "package other\n" {
+		t.Errorf("unexpected content of other.go: %q, %v", got, err)
+	}
+	if !strings.Contains(stdout.String(), "file.go: applied 2 change(s) from 2 patch file(s)") {
+		t.Errorf("unexpected output:\n%s", stdout.String())
+	}
+
+	// Applying a conflicting patch fails and leaves the file untouched.
+	before, err := os.ReadFile(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conflict1 := writeFile("conflict1.patch", "--- a/pkg/file.go\n+++ b/pkg/file.go\n@@ -5 +5 @@\n-var x = 11\n+var x = 12\n")
+	conflict2 := writeFile("conflict2.patch", "--- a/pkg/file.go\n+++ b/pkg/file.go\n@@ -5 +5 @@\n-var x = 11\n+var x = 13\n")
+	stdout.Reset()
+	if err := runApply([]string{"-root", root, conflict1, conflict2}, &stdout); err == nil {
+		t.Fatalf("expected error, got output:\n%s", stdout.String())
+	}
+	if !strings.Contains(stdout.String(), "conflicting changes to lines 5-5") {
+		t.Errorf("unexpected output:\n%s", stdout.String())
+	}
+	after, err := os.ReadFile(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Errorf("file was modified despite the conflict:\n%s", after)
+	}
+}
+
+func TestParseFixFile_Legacy(t *testing.T) {
+	// Offsets into applyTestSource: "func Hello() {}" starts at 14 and the
+	// "10" of "var x = 10" at 39.
+	fix := `{"analyzer_file_to_edits": {
+		"b": {"pkg/file.go": [{"new": "11", "start": 39, "end": 41}]},
+		"a": {"pkg/file.go": [
+			{"new": "// Hello says hello.\n", "start": 14, "end": 14},
+			{"new": "Hi", "start": 19, "end": 24}
+		]}
+	}}`
+	readFile := func(name string) ([]byte, error) {
+		if name != "pkg/file.go" {
+			t.Fatalf("unexpected file %q", name)
+		}
+		return []byte(applyTestSource), nil
+	}
+	patches, err := parseFixFile([]byte(fix), readFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []filePatch{{
+		oldName: "a/pkg/file.go",
+		newName: "b/pkg/file.go",
+		hunks: []patchHunk{
+			{
+				oldStart: 3, oldLines: 1, newStart: 3, newLines: 2,
+				lines: []patchLine{
+					{'-', "func Hello() {}\n"},
+					{'+', "// Hello says hello.\n"},
+					{'+', "func Hi() {}\n"},
+				},
+			},
+			{
+				oldStart: 5, oldLines: 1, newStart: 5, newLines: 1,
+				lines: []patchLine{
+					{'-', "var x = 10\n"},
+					{'+', "var x = 11\n"},
+				},
+			},
+		},
+	}}
+	if !reflect.DeepEqual(patches, expected) {
+		t.Errorf("unexpected patches:\n\tgot:\t%v\n\twant:\t%v", patches, expected)
+	}
+
+	if _, err := parseFixFile([]byte(`{"analyzer_file_to_edits": {"a": {"pkg/file.go": [{"start": 10, "end": 100}]}}}`), readFile); err == nil {
+		t.Error("expected error for an edit past the end of the file")
+	}
+}
+
+// TestOffsetEditHunks_Overlap checks that the edits of a fix file overlap
+// under the same rules as in nogo's validate.
+func TestOffsetEditHunks_Overlap(t *testing.T) {
+	rename := offsetEdit{New: "Hi", Start: 19, End: 24}
+	hunks, err := offsetEditHunks([]byte(applyTestSource), []offsetEdit{rename})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Identical edits, e.g. from the fixes of two diagnostics, are applied once.
+	if got, err := offsetEditHunks([]byte(applyTestSource), []offsetEdit{rename, rename}); err != nil || !reflect.DeepEqual(got, hunks) {
+		t.Errorf("identical edits: got %v, %v, want %v", got, err, hunks)
+	}
+	// Insertions at the same offset are applied in order.
+	got, err := offsetEditHunks([]byte(applyTestSource), []offsetEdit{{New: "a", Start: 19, End: 19}, {New: "b", Start: 19, End: 19}})
+	if err != nil || len(got) != 1 || got[0].lines[1].text != "func abHello() {}\n" {
+		t.Errorf("insertions at the same offset: got %v, %v", got, err)
+	}
+	if _, err := offsetEditHunks([]byte(applyTestSource), []offsetEdit{rename, {New: "Bye", Start: 19, End: 24}}); err == nil {
+		t.Error("expected an error for overlapping edits")
+	}
+}
+
+func TestParseFixFile_Structured(t *testing.T) {
+	// Offsets into applyTestSource as in TestParseFixFile_Legacy.
+	fix := `{
+		"version": 1,
+		"files": {"pkg/file.go": {
+			"b": [{"new": "11", "start": 39, "end": 41}],
+			"a": [{"new": "Hi", "start": 19, "end": 24}]
+		}}
+	}`
+	readFile := func(name string) ([]byte, error) {
+		if name != "pkg/file.go" {
+			t.Fatalf("unexpected file %q", name)
+		}
+		return []byte(applyTestSource), nil
+	}
+	patches, err := parseFixFile([]byte(fix), readFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []filePatch{{
+		oldName: "a/pkg/file.go",
+		newName: "b/pkg/file.go",
+		hunks: []patchHunk{
+			{
+				oldStart: 3, oldLines: 1, newStart: 3, newLines: 1,
+				lines: []patchLine{
+					{'-', "func Hello() {}\n"},
+					{'+', "func Hi() {}\n"},
+				},
+			},
+			{
+				oldStart: 5, oldLines: 1, newStart: 5, newLines: 1,
+				lines: []patchLine{
+					{'-', "var x = 10\n"},
+					{'+', "var x = 11\n"},
+				},
+			},
+		},
+	}}
+	if !reflect.DeepEqual(patches, expected) {
+		t.Errorf("unexpected patches:\n\tgot:\t%v\n\twant:\t%v", patches, expected)
+	}
+
+	if patches, err := parseFixFile([]byte(`{"version": 1, "files": {}}`), readFile); err != nil || len(patches) != 0 {
+		t.Errorf("empty fix file: got %v, %v", patches, err)
+	}
+	if _, err := parseFixFile([]byte(`{"version": 2, "files": {}}`), readFile); err == nil {
+		t.Error("expected error for an unsupported version")
+	}
+}
+
+func TestFixFileDigest(t *testing.T) {
+	fix := fixFile{Version: 1, Files: map[string]map[string][]offsetEdit{
+		"a.go": {
+			"a": {{New: "x", Start: 1, End: 2}, {New: "y", Start: 5, End: 5}},
+			"b": {{New: "", Start: 7, End: 9}},
+		},
+		"b.go": {"a": {{New: "z", Start: 0, End: 1}}},
+	}}
+	// The same edits in another order, with an empty file and analyzer and a
+	// different version.
+	reordered := fixFile{Version: 2, Files: map[string]map[string][]offsetEdit{
+		"b.go": {"a": {{New: "z", Start: 0, End: 1}}},
+		"a.go": {
+			"b": {{New: "", Start: 7, End: 9}},
+			"a": {{New: "y", Start: 5, End: 5}, {New: "x", Start: 1, End: 2}},
+			"c": nil,
+		},
+		"c.go": {},
+	}}
+	if fix.digest() != reordered.digest() {
+		t.Errorf("got different digests %s and %s for the same edits", fix.digest(), reordered.digest())
+	}
+
+	for _, changed := range []map[string]map[string][]offsetEdit{
+		{"a.go": fix.Files["a.go"]},
+		{"a.go": fix.Files["a.go"], "c.go": fix.Files["b.go"]},
+		{"a.go": {"a": fix.Files["a.go"]["a"], "c": fix.Files["a.go"]["b"]}, "b.go": fix.Files["b.go"]},
+		{"a.go": fix.Files["a.go"], "b.go": {"a": {{New: "zz", Start: 0, End: 1}}}},
+		{"a.go": fix.Files["a.go"], "b.go": {"a": {{New: "z", Start: 0, End: 2}}}},
+	} {
+		if digest := (fixFile{Version: 1, Files: changed}).digest(); digest == fix.digest() {
+			t.Errorf("got the same digest for the edits %v", changed)
+		}
+	}
+}
+
+func TestCollectFileEdits_DuplicateFixFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	// The fix files of the library and of the test variant of a package list
+	// the same edits.
+	lib := writeFile("lib.json", `{"version": 1, "files": {"pkg/file.go": {"a": [{"new": "Hi", "start": 19, "end": 24}], "b": [{"new": "11", "start": 39, "end": 41}]}}}`)
+	test := writeFile("test.json", `{
+		"version": 1,
+		"files": {"pkg/file.go": {
+			"b": [{"new": "11", "start": 39, "end": 41}],
+			"a": [{"new": "Hi", "start": 19, "end": 24}]
+		}}
+	}`)
+	other := writeFile("other.json", `{"version": 1, "files": {"pkg/file.go": {"a": [{"new": "Hi", "start": 19, "end": 24}]}}}`)
+	reads := 0
+	readFile := func(name string) ([]byte, error) {
+		reads++
+		return []byte(applyTestSource), nil
+	}
+	files, err := collectFileEdits([]string{lib, test, other}, "", readFile, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("got edits for %d files, want 1", len(files))
+	}
+	if want := []string{lib, other}; !reflect.DeepEqual(files[0].sources, want) {
+		t.Errorf("got sources %q, want %q", files[0].sources, want)
+	}
+	if len(files[0].hunks) != 3 || reads != 2 {
+		t.Errorf("got %d hunks from %d reads, want 3 hunks from 2 reads", len(files[0].hunks), reads)
+	}
+}
+
+func TestCollectFileEdits_RecordedStrip(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	hunk := "@@ -5 +5 @@\n-var x = 10\n+var x = 11\n"
+	// A patch written without prefixes records that it applies with -p0.
+	unprefixed := writeFile("unprefixed.patch", "# nogo: strip=0\n--- pkg/file.go\n+++ pkg/file.go\n"+hunk)
+	prefixed := writeFile("prefixed.patch", "--- a/pkg/other.go\n+++ b/pkg/other.go\n"+hunk)
+
+	for _, tt := range []struct {
+		strip int
+		want  []string
+	}{
+		{-1, []string{"pkg/file.go", "pkg/other.go"}},
+		{0, []string{"b/pkg/other.go", "pkg/file.go"}},
+	} {
+		files, err := collectFileEdits([]string{unprefixed, prefixed}, "", nil, tt.strip)
+		if err != nil {
+			t.Fatalf("strip %d: unexpected error: %v", tt.strip, err)
+		}
+		var got []string
+		for _, fe := range files {
+			got = append(got, fe.path)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("strip %d: got files %q, want %q", tt.strip, got, tt.want)
+		}
+	}
+}
+
+func TestCollectFileEdits_Compressed(t *testing.T) {
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	if _, err := io.WriteString(w, "--- a/pkg/file.go\n+++ b/pkg/file.go\n@@ -5 +5 @@\n-var x = 10\n+var x = 11\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	patchFile := filepath.Join(t.TempDir(), "pkg.nogo.patch")
+	if err := os.WriteFile(patchFile, compressed.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	files, err := collectFileEdits([]string{patchFile}, "", nil, -1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 1 || files[0].path != "pkg/file.go" || len(files[0].hunks) != 1 {
+		t.Errorf("got %+v, want a hunk for pkg/file.go", files)
+	}
+}
+
+func TestRecordedStrip(t *testing.T) {
+	for _, tt := range []struct {
+		patch string
+		strip int
+		ok    bool
+	}{
+		{"# nogo: strip=0\n--- pkg/file.go\n", 0, true},
+		{"# pkg/file.go:1:1: message (analyzer)\n# nogo: target=//pkg strip=2\n--- x/y/pkg/file.go\n", 2, true},
+		{"--- a/pkg/file.go\n", 0, false},
+		// Only the metadata before the first file section counts.
+		{"--- a/pkg/file.go\n+++ b/pkg/file.go\n# nogo: strip=0\n", 0, false},
+		{"# nogo: strip=-1\n", 0, false},
+	} {
+		strip, ok := recordedStrip([]byte(tt.patch))
+		if strip != tt.strip || ok != tt.ok {
+			t.Errorf("recordedStrip(%q) = %d, %v, want %d, %v", tt.patch, strip, ok, tt.strip, tt.ok)
+		}
+	}
+}
+
+func TestMergeCaseCollisions(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "pkg"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "pkg", "file.go"), []byte(applyTestSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// A hard link stands in for the second name of the file on a
+	// case-insensitive file system.
+	if err := os.Link(filepath.Join(root, "pkg", "file.go"), filepath.Join(root, "pkg", "FILE.go")); err != nil {
+		t.Skipf("hard links are not supported: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "pkg", "File.go"), []byte(applyTestSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	files := []*fileEdits{
+		{path: filepath.Join("pkg", "FILE.go"), sources: []string{"1.patch"}, hunks: []sourcedHunk{{source: "1.patch"}}},
+		{path: filepath.Join("pkg", "File.go"), sources: []string{"1.patch"}, hunks: []sourcedHunk{{source: "1.patch"}}},
+		{path: filepath.Join("pkg", "file.go"), sources: []string{"2.patch"}, hunks: []sourcedHunk{{source: "2.patch"}}},
+	}
+	var warnings bytes.Buffer
+	merged := mergeCaseCollisions(root, files, &warnings)
+	var paths []string
+	for _, fe := range merged {
+		paths = append(paths, fmt.Sprintf("%s %v %d", filepath.ToSlash(fe.path), fe.sources, len(fe.hunks)))
+	}
+	// pkg/File.go is a distinct file and is patched on its own.
+	expected := []string{"pkg/FILE.go [1.patch 2.patch] 2", "pkg/File.go [1.patch] 1"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("unexpected files:\n\tgot:\t%v\n\twant:\t%v", paths, expected)
+	}
+	if !strings.Contains(warnings.String(), "refer to the same file") {
+		t.Errorf("expected a warning, got: %q", warnings.String())
+	}
+}
+
+func TestCanonicalPath(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "Pkg"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "Pkg", "File.go"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := canonicalPath(root, "pkg/file.GO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join("Pkg", "File.go"); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, err := canonicalPath(root, "pkg/other.go"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestApply_PatchList(t *testing.T) {
+	root := t.TempDir()
+	source := filepath.Join(root, "file.go")
+	if err := os.WriteFile(source, []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// The list and the patches are relative to the current directory, which is
+	// the runfiles directory under bazel run.
+	runfiles := t.TempDir()
+	if err := os.WriteFile(filepath.Join(runfiles, "fix.patch"), []byte("--- a/file.go\n+++ b/file.go\n@@ -1 +1 @@\n-package main\n+package fixed\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Empty fix files of packages without fixes are listed as well.
+	if err := os.WriteFile(filepath.Join(runfiles, "empty.patch"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(runfiles, "patches.txt"), []byte("fix.patch\nempty.patch\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(runfiles); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	t.Setenv(patchListEnv, "patches.txt")
+	t.Setenv("BUILD_WORKSPACE_DIRECTORY", root)
+	t.Setenv("BUILD_WORKING_DIRECTORY", filepath.Join(root, "elsewhere"))
+
+	var stdout bytes.Buffer
+	if err := runApply(nil, &stdout); err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, stdout.String())
+	}
+	if got, err := os.ReadFile(source); err != nil || string(got) != "package fixed\n" {
+		t.Errorf("unexpected content: %q, %v", got, err)
+	}
+}
+
+func TestLocateHunk(t *testing.T) {
+	patches, err := parsePatch([]byte(`--- a/file.go
++++ b/file.go
+@@ -2,3 +2,3 @@
+ a
+-b
++B
+ c
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := patches[0].hunks[0]
+	for _, tt := range []struct {
+		desc       string
+		file       string
+		fuzz       int
+		wantStart  int
+		wantFuzz   int
+		wantLocate bool
+	}{
+		{desc: "unchanged", file: "x\na\nb\nc\n", wantStart: 2, wantLocate: true},
+		{desc: "lines added before", file: "x\ny\nz\na\nb\nc\n", wantStart: 4, wantLocate: true},
+		{desc: "lines removed before", file: "a\nb\nc\n", wantStart: 1, wantLocate: true},
+		{desc: "nearest match", file: "a\nb\nc\nx\na\nb\nc\n", wantStart: 1, wantLocate: true},
+		{desc: "changed context", file: "x\nA\nb\nc\n"},
+		{desc: "changed context with fuzz", file: "x\nA\nb\nc\n", fuzz: 1, wantStart: 3, wantFuzz: 1, wantLocate: true},
+		{desc: "changed line", file: "x\na\nbb\nc\n", fuzz: 2},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			located, fuzz, ok := h.locate(splitLines([]byte(tt.file)), tt.fuzz)
+			if ok != tt.wantLocate {
+				t.Fatalf("got located %v, want %v", ok, tt.wantLocate)
+			}
+			if ok && (located.oldStart != tt.wantStart || fuzz != tt.wantFuzz) {
+				t.Errorf("got hunk at line %d with fuzz %d, want line %d with fuzz %d", located.oldStart, fuzz, tt.wantStart, tt.wantFuzz)
+			}
+		})
+	}
+}
+
+func TestApply_Stale(t *testing.T) {
+	root := t.TempDir()
+	source := filepath.Join(root, "file.go")
+	// The patch was generated before the import was added and the var
+	// declaration changed.
+	const content = "package main\n\nimport \"fmt\"\n\nfunc Hello() {}\n\nvar x = 10\n"
+	patch := filepath.Join(root, "fix.patch")
+	if err := os.WriteFile(patch, []byte(`--- a/file.go
++++ b/file.go
+@@ -2,3 +2,3 @@
+ 
+-func Hello() {}
++func Hello() { println("hello") }
+ 
+@@ -5 +5 @@
+-var x = 1
++var x = 2
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		desc    string
+		args    []string
+		want    string
+		wantErr bool
+		output  []string
+	}{
+		{
+			desc:    "all hunks",
+			wantErr: true,
+			want:    content,
+			output: []string{
+				"file.go: not patched: 1 of 2 hunk(s) don't match the file",
+				"fix.patch: hunk @@ -2,3 @@ applied at line 4 (offset 2 lines, fuzz 0)",
+				`fix.patch: rejected: hunk @@ -5,1 @@ does not match line 5: expected "var x = 1\n", found "func Hello() {}\n"`,
+			},
+		},
+		{
+			desc:    "partial",
+			args:    []string{"-partial"},
+			wantErr: true,
+			want:    strings.Replace(content, "func Hello() {}", `func Hello() { println("hello") }`, 1),
+			output:  []string{"file.go: applied 1 change(s) from 1 patch file(s), rejected 1 hunk(s)"},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if err := os.WriteFile(source, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			var stdout bytes.Buffer
+			err := runApply(append(append([]string{"-root", root}, tt.args...), patch), &stdout)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v\n%s", err, tt.wantErr, stdout.String())
+			}
+			for _, want := range tt.output {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("output doesn't contain %q:\n%s", want, stdout.String())
+				}
+			}
+			if got, err := os.ReadFile(source); err != nil || string(got) != tt.want {
+				t.Errorf("got content %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply_CreateAndDelete(t *testing.T) {
+	root := t.TempDir()
+	old := filepath.Join(root, "old.go")
+	if err := os.WriteFile(old, []byte("package main\n\nvar x = 10\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	patch := filepath.Join(root, "fix.patch")
+	if err := os.WriteFile(patch, []byte(`--- /dev/null
++++ b/sub/new.go
+@@ -0,0 +1,2 @@
++package sub
++var y = 20
+--- a/old.go
++++ /dev/null
+@@ -1,3 +0,0 @@
+-package main
+-
+-var x = 10
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Fix files create a file with an insertion at its start.
+	fix := filepath.Join(root, "fix.json")
+	if err := os.WriteFile(fix, []byte(`{"version": 1, "files": {"gen.go": {"a": [{"new": "package main\n", "start": 0, "end": 0}]}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	if err := runApply([]string{"-root", root, patch, fix}, &stdout); err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, stdout.String())
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("old.go was not deleted: %v", err)
+	}
+	for name, want := range map[string]string{
+		"sub/new.go": "package sub\nvar y = 20\n",
+		"gen.go":     "package main\n",
+	} {
+		if got, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(name))); err != nil || string(got) != want {
+			t.Errorf("%s: got content %q, %v, want %q", name, got, err, want)
+		}
+	}
+
+	// A patch doesn't create a file that exists.
+	if err := os.WriteFile(patch, []byte("--- /dev/null\n+++ b/gen.go\n@@ -0,0 +1 @@\n+package gen\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stdout.Reset()
+	if err := runApply([]string{"-root", root, patch}, &stdout); err == nil {
+		t.Errorf("expected an error for an existing file\n%s", stdout.String())
+	}
+	if !strings.Contains(stdout.String(), "gen.go: not patched: the patch creates the file, but it already exists") {
+		t.Errorf("unexpected output:\n%s", stdout.String())
+	}
+}
+
+func TestApply_Check(t *testing.T) {
+	root := t.TempDir()
+	source := filepath.Join(root, "file.go")
+	if err := os.WriteFile(source, []byte(applyTestSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	writePatch := func(content string) string {
+		patch := filepath.Join(root, "fix.patch")
+		if err := os.WriteFile(patch, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return patch
+	}
+
+	var stdout bytes.Buffer
+	patch := writePatch("--- a/file.go\n+++ b/file.go\n@@ -3 +3 @@\n-func Hello() {}\n+func Hi() {}\n--- /dev/null\n+++ b/new.go\n@@ -0,0 +1 @@\n+package main\n")
+	if err := runApply([]string{"-root", root, "-check", patch}, &stdout); err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, stdout.String())
+	}
+	for _, want := range []string{"file.go: 1 change(s) from 1 patch file(s) apply", "new.go: 1 change(s) from 1 patch file(s) apply"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("output doesn't contain %q:\n%s", want, stdout.String())
+		}
+	}
+
+	stdout.Reset()
+	patch = writePatch("--- a/file.go\n+++ b/file.go\n@@ -3 +3 @@\n-func Hello() {}\n+func Hi() {}\n@@ -5 +5 @@\n-var x = 1\n+var x = 2\n")
+	if err := runApply([]string{"-root", root, "-check", "-partial", patch}, &stdout); err == nil {
+		t.Errorf("expected an error for a stale hunk\n%s", stdout.String())
+	}
+	for _, want := range []string{
+		"file.go: 1 change(s) from 1 patch file(s) apply, rejected 1 hunk(s)",
+		`fix.patch: rejected: hunk @@ -5,1 @@ does not match line 5: expected "var x = 1\n", found "var x = 10\n"`,
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("output doesn't contain %q:\n%s", want, stdout.String())
+		}
+	}
+
+	stdout.Reset()
+	if err := runApply([]string{"-root", root, "-check", patch}, &stdout); err == nil || !strings.Contains(err.Error(), "the patches don't apply to 1 of 1 file(s)") {
+		t.Errorf("got error %v, want the stale file to be reported\n%s", err, stdout.String())
+	}
+	if !strings.Contains(stdout.String(), "file.go: does not apply: 1 of 2 hunk(s) don't match the file") {
+		t.Errorf("unexpected output:\n%s", stdout.String())
+	}
+
+	if got, err := os.ReadFile(source); err != nil || string(got) != applyTestSource {
+		t.Errorf("got content %q, %v, want the file to be unchanged", got, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "new.go")); !os.IsNotExist(err) {
+		t.Errorf("new.go was created: %v", err)
+	}
+}
+
+func TestPatchMetadata(t *testing.T) {
+	for _, tt := range []struct {
+		keyValues []string
+		want      string
+	}{
+		{[]string{"target", "//pkg:lib"}, `# nogo: target=//pkg:lib`},
+		{[]string{"analyzer", "printf", "message", `call has "arguments" = 2`}, `# nogo: analyzer=printf message="call has \"arguments\" = 2"`},
+		{[]string{"message", ""}, `# nogo: message=""`},
+		{[]string{"message", "invalid \xff"}, `# nogo: message="invalid \xff"`},
+	} {
+		line := formatPatchMetadata(tt.keyValues...)
+		if line != tt.want {
+			t.Errorf("formatPatchMetadata(%q) = %s, want %s", tt.keyValues, line, tt.want)
+		}
+		want := make(map[string]string)
+		for i := 0; i < len(tt.keyValues); i += 2 {
+			want[tt.keyValues[i]] = tt.keyValues[i+1]
+		}
+		if got, ok := parsePatchMetadata(line + "\n"); !ok || !reflect.DeepEqual(got, want) {
+			t.Errorf("parsePatchMetadata(%s) = %q, %v, want %q", line, got, ok, want)
+		}
+	}
+	for _, line := range []string{
+		"# pkg/file.go:2:1: message (analyzer)",
+		"# nogo: message",
+		`# nogo: message="unterminated`,
+		`# nogo: message="a"b`,
+	} {
+		if got, ok := parsePatchMetadata(line); ok {
+			t.Errorf("parsePatchMetadata(%s) = %q, want an error", line, got)
+		}
+	}
+}
+
+func TestApply_Digest(t *testing.T) {
+	root := t.TempDir()
+	source := filepath.Join(root, "file.go")
+	patch := filepath.Join(root, "fix.patch")
+	diff := "--- a/file.go\n+++ b/file.go\n@@ -3 +3 @@\n-func Hello() {}\n+func Hi() {}\n"
+	write := func(name, content string) {
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The patch was generated from the current content.
+	write(source, applyTestSource)
+	write(patch, "# nogo: target=//:lib\n"+formatPatchMetadata(metadataSHA256, contentDigest([]byte(applyTestSource)))+"\n"+diff)
+	var stdout bytes.Buffer
+	if err := runApply([]string{"-root", root, "-strict", patch}, &stdout); err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, stdout.String())
+	}
+	if strings.Contains(stdout.String(), "another version") {
+		t.Errorf("unexpected output:\n%s", stdout.String())
+	}
+
+	// The patch was generated from another version of the file, but still
+	// applies.
+	changed := strings.Replace(applyTestSource, "var x = 10", "var x = 11", 1)
+	write(source, changed)
+	write(patch, formatPatchMetadata(metadataSHA256, contentDigest([]byte(applyTestSource)))+"\n"+diff)
+	stdout.Reset()
+	if err := runApply([]string{"-root", root, "-check", patch}, &stdout); err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, stdout.String())
+	}
+	if want := "fix.patch: generated from another version of the file"; !strings.Contains(stdout.String(), want) {
+		t.Errorf("output doesn't contain %q:\n%s", want, stdout.String())
+	}
+
+	stdout.Reset()
+	if err := runApply([]string{"-root", root, "-strict", patch}, &stdout); err == nil {
+		t.Errorf("expected an error for a changed file\n%s", stdout.String())
+	}
+	if want := "file.go: not patched: the file changed since " + patch + " was generated"; !strings.Contains(stdout.String(), want) {
+		t.Errorf("output doesn't contain %q:\n%s", want, stdout.String())
+	}
+	if got, err := os.ReadFile(source); err != nil || string(got) != changed {
+		t.Errorf("got content %q, %v, want the file to be unchanged", got, err)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// nogo_baseline writes a baseline file of the current nogo findings. The
+// findings in the baseline set on the nogo rule are not reported, so that only
+// new findings fail the build.
+//
+// Usage: bazel run @io_bazel_rules_go//go/tools/builders:nogo_baseline -- baseline.json findings...
+//
+// findings are inspection results files written by nogo (see the
+// nogo_inspection output group) or directories that are searched for them.
+// Since nogo records the findings in the baseline in these files, the baseline
+// can be regenerated at any time, e.g. to drop the findings that have been
+// fixed.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("nogo_baseline: ")
+	if err := runBaseline(os.Args[1:], os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func runBaseline(args []string, stdout io.Writer) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: nogo_baseline baseline.json findings...")
+	}
+	// Relative paths are given relative to the directory bazel run was invoked
+	// from.
+	cwd := os.Getenv("BUILD_WORKING_DIRECTORY")
+	paths := make([]string, len(args))
+	for i, path := range args {
+		if cwd != "" && !filepath.IsAbs(path) {
+			path = filepath.Join(cwd, path)
+		}
+		paths[i] = path
+	}
+
+	var findings [][]inspectionProblem
+	for _, path := range paths[1:] {
+		files, err := loadFindingFiles(path)
+		if err != nil {
+			return err
+		}
+		findings = append(findings, files...)
+	}
+	baseline := newBaseline(findings)
+	data, err := json.MarshalIndent(baseline, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(paths[0], append(data, '\n'), 0o666); err != nil {
+		return err
+	}
+	total := 0
+	for _, f := range baseline.Findings {
+		total += f.Count
+	}
+	fmt.Fprintf(stdout, "wrote %d finding(s) to %s\n", total, args[0])
+	return nil
+}
+
+// newBaseline returns the baseline of the findings of each compiled package,
+// sorted by file, analyzer and message. Packages compiled both as a library
+// and as part of a test report the same findings twice, so the count of each
+// baseline finding is the highest count in any single compiled package.
+func newBaseline(packages [][]inspectionProblem) baselineFile {
+	counts := make(map[baselineKey]int)
+	for _, problems := range packages {
+		pkgCounts := make(map[baselineKey]int)
+		for _, p := range problems {
+			pkgCounts[baselineKey{strings.TrimPrefix(p.File, inspectionProjectDir), p.ProblemClass.Name, p.Description}]++
+		}
+		for k, n := range pkgCounts {
+			if n > counts[k] {
+				counts[k] = n
+			}
+		}
+	}
+
+	baseline := baselineFile{Version: baselineFileVersion, Findings: []baselineFinding{}}
+	for k, n := range counts {
+		baseline.Findings = append(baseline.Findings, baselineFinding{File: k.file, Analyzer: k.analyzer, Message: k.message, Count: n})
+	}
+	sort.Slice(baseline.Findings, func(i, j int) bool {
+		a, b := baseline.Findings[i], baseline.Findings[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Analyzer != b.Analyzer {
+			return a.Analyzer < b.Analyzer
+		}
+		return a.Message < b.Message
+	})
+	return baseline
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRunBaseline(t *testing.T) {
+	dir := t.TempDir()
+	problem := func(pkg, file string, line int, analyzer, message string) inspectionProblem {
+		return inspectionProblem{
+			File:         inspectionProjectDir + file,
+			Line:         line,
+			Package:      pkg,
+			ProblemClass: inspectionProblemClass{ID: analyzer, Name: analyzer},
+			Description:  message,
+		}
+	}
+	writeXML := func(path string, problems ...inspectionProblem) {
+		data, err := xml.Marshal(inspectionProblems{Problems: problems})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, path), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeXML("lib.nogo.xml",
+		problem("example.com/a", "a/a.go", 10, "printf", "bad format"),
+		problem("example.com/a", "a/a.go", 20, "printf", "bad format"),
+		problem("example.com/a", "a/a.go", 5, "nilness", "nil dereference"),
+	)
+	// The test reports the findings of the library again.
+	writeXML("test.nogo.xml",
+		problem("example.com/a", "a/a.go", 10, "printf", "bad format"),
+		problem("example.com/a", "a/a.go", 20, "printf", "bad format"),
+		problem("example.com/a", "a/a.go", 5, "nilness", "nil dereference"),
+		problem("example.com/a", "a/a_test.go", 3, "printf", "bad format"),
+	)
+
+	out := filepath.Join(dir, "baseline.json")
+	var stdout bytes.Buffer
+	if err := runBaseline([]string{out, dir}, &stdout); err != nil {
+		t.Fatal(err)
+	}
+	if want := "wrote 4 finding(s) to " + out + "\n"; stdout.String() != want {
+		t.Errorf("got output %q, want %q", stdout.String(), want)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseline, err := parseBaselineFile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := baselineFile{
+		Version: baselineFileVersion,
+		Findings: []baselineFinding{
+			{File: "a/a.go", Analyzer: "nilness", Message: "nil dereference", Count: 1},
+			{File: "a/a.go", Analyzer: "printf", Message: "bad format", Count: 2},
+			{File: "a/a_test.go", Analyzer: "printf", Message: "bad format", Count: 1},
+		},
+	}
+	if !reflect.DeepEqual(baseline, want) {
+		t.Errorf("got %+v, want %+v", baseline, want)
+	}
+}
+
+func TestBaselineMatcher(t *testing.T) {
+	m := newBaselineMatcher([]baselineFinding{
+		{File: "a/a.go", Analyzer: "printf", Message: "bad format", Count: 2},
+	})
+	for i, want := range []bool{true, true, false} {
+		if got := m.match("a/a.go", "printf", "bad format"); got != want {
+			t.Errorf("match %d: got %v, want %v", i, got, want)
+		}
+	}
+	if m.match("a/b.go", "printf", "bad format") {
+		t.Errorf("matched a finding in another file")
+	}
+}
+
+func TestParseBaselineFile(t *testing.T) {
+	for _, tt := range []struct {
+		desc, content string
+	}{
+		{"unknown version", `{"version": 2, "findings": []}`},
+		{"no file", `{"version": 1, "findings": [{"analyzer": "printf", "message": "m", "count": 1}]}`},
+		{"no count", `{"version": 1, "findings": [{"file": "a.go", "analyzer": "printf", "message": "m"}]}`},
+	} {
+		if _, err := parseBaselineFile([]byte(tt.content)); err == nil {
+			t.Errorf("%s: expected an error", tt.desc)
+		}
+	}
+}
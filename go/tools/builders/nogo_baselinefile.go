@@ -0,0 +1,91 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This file defines the baseline file of known nogo findings. It is shared by
+// the builder, which embeds the baseline into nogo, nogo, which doesn't report
+// the findings in it, and nogo_baseline, which regenerates it.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// baselineFileVersion is the version of the baseline file format.
+const baselineFileVersion = 1
+
+// A baselineFile lists the known findings that nogo doesn't report.
+type baselineFile struct {
+	Version  int               `json:"version"`
+	Findings []baselineFinding `json:"findings"`
+}
+
+// A baselineFinding matches findings by file, analyzer and message, but not by
+// line, so that findings moved by unrelated edits still match. Up to Count
+// findings are matched, the first ones by position.
+type baselineFinding struct {
+	// File is relative to the execroot, like the paths in the nogo log.
+	File     string `json:"file"`
+	Analyzer string `json:"analyzer"`
+	Message  string `json:"message"`
+	Count    int    `json:"count"`
+}
+
+type baselineKey struct {
+	file, analyzer, message string
+}
+
+// parseBaselineFile parses and validates the content of a baseline file.
+func parseBaselineFile(data []byte) (baselineFile, error) {
+	var baseline baselineFile
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return baselineFile{}, err
+	}
+	if baseline.Version != baselineFileVersion {
+		return baselineFile{}, fmt.Errorf("unsupported baseline file version %d, want %d", baseline.Version, baselineFileVersion)
+	}
+	for _, f := range baseline.Findings {
+		if f.File == "" || f.Analyzer == "" {
+			return baselineFile{}, fmt.Errorf("baseline finding %q has no file or analyzer", f.Message)
+		}
+		if f.Count < 1 {
+			return baselineFile{}, fmt.Errorf("baseline finding %q in %s has count %d, must be positive", f.Message, f.File, f.Count)
+		}
+	}
+	return baseline, nil
+}
+
+// A baselineMatcher matches findings against a baseline, each baseline
+// finding as many times as its count.
+type baselineMatcher map[baselineKey]int
+
+func newBaselineMatcher(findings []baselineFinding) baselineMatcher {
+	m := make(baselineMatcher)
+	for _, f := range findings {
+		m[baselineKey{f.File, f.Analyzer, f.Message}] += f.Count
+	}
+	return m
+}
+
+// match reports whether a finding is in the baseline and consumes one
+// occurrence of its baseline finding if so.
+func (m baselineMatcher) match(file, analyzer, message string) bool {
+	k := baselineKey{file, analyzer, message}
+	if m[k] == 0 {
+		return false
+	}
+	m[k]--
+	return true
+}
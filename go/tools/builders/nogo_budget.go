@@ -0,0 +1,161 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// nogo_budget checks the number of nogo findings against a checked-in budget
+// of allowed findings per analyzer and per package.
+//
+// Usage: bazel run @io_bazel_rules_go//go/tools/builders:nogo_budget -- [-update] budget.json findings...
+//
+// findings are inspection results files written by nogo (see the
+// nogo_inspection output group) or directories that are searched for them.
+// nogo_budget exits with a non-zero status if any count exceeds its budget.
+// With -update, budgets are lowered to the current counts instead, so that the
+// budget ratchets down as findings are fixed. Budgets are never raised.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// A findingBudget is the content of a budget file. Analyzers and packages
+// without an entry are not limited.
+type findingBudget struct {
+	Analyzers map[string]int `json:"analyzers,omitempty"`
+	Packages  map[string]int `json:"packages,omitempty"`
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("nogo_budget: ")
+	if err := runBudget(os.Args[1:], os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func runBudget(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("nogo_budget", flag.ExitOnError)
+	update := fs.Bool("update", false, "Lower the budgets to the current counts instead of checking them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		return fmt.Errorf("usage: nogo_budget [-update] budget.json findings...")
+	}
+	// Relative paths are given relative to the directory bazel run was invoked
+	// from.
+	cwd := os.Getenv("BUILD_WORKING_DIRECTORY")
+	paths := fs.Args()
+	for i, path := range paths {
+		if cwd != "" && !filepath.IsAbs(path) {
+			paths[i] = filepath.Join(cwd, path)
+		}
+	}
+
+	budgetPath := paths[0]
+	data, err := os.ReadFile(budgetPath)
+	if err != nil {
+		return err
+	}
+	var budget findingBudget
+	if err := json.Unmarshal(data, &budget); err != nil {
+		return fmt.Errorf("parsing %s: %v", budgetPath, err)
+	}
+	var findings []inspectionProblem
+	for _, path := range paths[1:] {
+		problems, err := loadFindings(path)
+		if err != nil {
+			return err
+		}
+		findings = append(findings, problems...)
+	}
+	analyzerCounts, packageCounts := countFindings(findings)
+
+	if *update {
+		lowered := lowerBudget(budget.Analyzers, analyzerCounts) + lowerBudget(budget.Packages, packageCounts)
+		out, err := json.MarshalIndent(budget, "", "  ")
+		if err != nil {
+			return err
+		}
+		out = append(out, '\n')
+		if !bytes.Equal(out, data) {
+			if err := os.WriteFile(budgetPath, out, 0o666); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(stdout, "lowered %d budget(s)\n", lowered)
+		return nil
+	}
+
+	exceeded := checkBudget(stdout, "analyzer", budget.Analyzers, analyzerCounts) +
+		checkBudget(stdout, "package", budget.Packages, packageCounts)
+	if exceeded > 0 {
+		return fmt.Errorf("%d budget(s) exceeded", exceeded)
+	}
+	return nil
+}
+
+// countFindings returns the number of findings per analyzer and per package.
+// Packages compiled both as a library and as part of a test report the same
+// findings twice, which is fine since budgets are only compared against counts
+// computed the same way.
+func countFindings(findings []inspectionProblem) (analyzers, packages map[string]int) {
+	analyzers, packages = make(map[string]int), make(map[string]int)
+	for _, f := range findings {
+		analyzers[f.ProblemClass.Name]++
+		packages[f.Package]++
+	}
+	return analyzers, packages
+}
+
+// checkBudget prints the budgets exceeded by counts and returns their number.
+func checkBudget(w io.Writer, kind string, budget, counts map[string]int) int {
+	exceeded := 0
+	for _, name := range sortedKeys(budget) {
+		if counts[name] > budget[name] {
+			fmt.Fprintf(w, "%s %s: %d finding(s), budget is %d\n", kind, name, counts[name], budget[name])
+			exceeded++
+		}
+	}
+	return exceeded
+}
+
+// lowerBudget lowers the budgets to counts and returns the number of budgets
+// that changed.
+func lowerBudget(budget, counts map[string]int) int {
+	lowered := 0
+	for name, max := range budget {
+		if counts[name] < max {
+			budget[name] = counts[name]
+			lowered++
+		}
+	}
+	return lowered
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunBudget(t *testing.T) {
+	dir := t.TempDir()
+	problem := func(pkg, analyzer string) inspectionProblem {
+		return inspectionProblem{
+			File:         inspectionProjectDir + pkg + "/file.go",
+			Package:      pkg,
+			ProblemClass: inspectionProblemClass{ID: analyzer, Name: analyzer},
+		}
+	}
+	data, err := xml.Marshal(inspectionProblems{Problems: []inspectionProblem{
+		problem("example.com/a", "printf"),
+		problem("example.com/a", "printf"),
+		problem("example.com/b", "printf"),
+		problem("example.com/b", "nilness"),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings := filepath.Join(dir, "lib.nogo.xml")
+	if err := os.WriteFile(findings, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	budget := filepath.Join(dir, "budget.json")
+	writeBudget := func(content string) {
+		if err := os.WriteFile(budget, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeBudget(`{"analyzers": {"printf": 3, "nilness": 0}, "packages": {"example.com/a": 2}}`)
+	var stdout bytes.Buffer
+	if err := runBudget([]string{budget, findings}, &stdout); err == nil {
+		t.Fatalf("expected the nilness budget to be exceeded")
+	}
+	if want := "analyzer nilness: 1 finding(s), budget is 0\n"; stdout.String() != want {
+		t.Errorf("unexpected output:\n\tgot:\n%s\n\twant:\n%s", stdout.String(), want)
+	}
+
+	writeBudget(`{"analyzers": {"printf": 5, "nilness": 1}, "packages": {"example.com/a": 2}}`)
+	stdout.Reset()
+	if err := runBudget([]string{budget, findings}, &stdout); err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, stdout.String())
+	}
+
+	// Updating lowers the printf budget, but never raises a budget.
+	writeBudget(`{"analyzers": {"printf": 5, "nilness": 0}, "packages": {"example.com/a": 2}}`)
+	stdout.Reset()
+	if err := runBudget([]string{"-update", budget, findings}, &stdout); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := os.ReadFile(budget)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "analyzers": {
+    "nilness": 0,
+    "printf": 3
+  },
+  "packages": {
+    "example.com/a": 2
+  }
+}
+`
+	if string(got) != want {
+		t.Errorf("unexpected budget:\n\tgot:\n%s\n\twant:\n%s", got, want)
+	}
+	if !strings.Contains(stdout.String(), "lowered 1 budget(s)") {
+		t.Errorf("unexpected output: %s", stdout.String())
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"regexp"
+	"time"
+)
+
+// config determines which source files an analyzer will emit diagnostics for.
+// config values are generated in another file that is compiled with
+// nogo_main.go by the nogo rule.
+type config struct {
+	// onlyFiles is a list of regular expressions that match files an analyzer
+	// will emit diagnostics for. When empty, the analyzer will emit diagnostics
+	// for all files.
+	onlyFiles []*regexp.Regexp
+
+	// excludeFiles is a list of regular expressions that match files that an
+	// analyzer will not emit diagnostics for.
+	excludeFiles []*regexp.Regexp
+
+	// analyzerFlags is a map of flag names to flag values which will be passed
+	// to Analyzer.Flags. Note that no leading '-' should be present in a flag
+	// name
+	analyzerFlags map[string]string
+
+	// remediation is a note printed below each diagnostic of the analyzer,
+	// e.g. a link to internal documentation or the team owning the check.
+	remediation string
+
+	// fixes controls whether the suggested fixes of the analyzer are written
+	// to the fix file. nil means the value of the base config, which defaults
+	// to true.
+	fixes *bool
+
+	// fixOnlyFiles is a list of regular expressions that match the files the
+	// suggested fixes of the analyzer may edit. Fixes editing other files are
+	// dropped. Fixes are also limited to the files matched by onlyFiles and
+	// not matched by excludeFiles.
+	fixOnlyFiles []*regexp.Regexp
+
+	// fixExcludeFiles is a list of regular expressions that match files, such
+	// as generated files, that the suggested fixes of the analyzer must not
+	// edit. Fixes editing such a file are dropped.
+	fixExcludeFiles []*regexp.Regexp
+
+	// diagnostics controls whether the diagnostics of the analyzer are
+	// reported. Unreported diagnostics don't fail the build, but their fixes
+	// are still written to the fix file. nil means the value of the base
+	// config, which defaults to true.
+	diagnostics *bool
+
+	// tests controls whether the analyzer emits diagnostics for test files.
+	// Its suggested fixes don't edit test files either if it is false. nil
+	// means the value of the base config, which defaults to true.
+	tests *bool
+
+	// fixTests controls whether the suggested fixes of the analyzer may edit
+	// test files. Fixes editing a test file are dropped if it is false. nil
+	// means the value of the base config, which defaults to true.
+	fixTests *bool
+
+	// fixConflicts is the strategy for resolving conflicts between suggested
+	// fixes, one of the fixConflicts constants. It is only set in the base
+	// config.
+	fixConflicts string
+
+	// fixPriority orders the fixes of the analyzer relative to the fixes of
+	// other analyzers if fixConflicts is fixConflictsPriority.
+	fixPriority int
+
+	// failOn is the condition under which findings fail the build, one of
+	// the failOn constants. It is only set in the base config.
+	failOn string
+
+	// severity is the severity of the findings of the analyzer, one of the
+	// severity constants. Empty means the value of the base config, which
+	// defaults to severityError.
+	severity string
+
+	// factCompression is the codec the facts of the package are written
+	// with, one of the factCodec constants. It is only set in the base config
+	// and defaults to factCodecNone.
+	factCompression string
+
+	// timeout is the time the analyzer may run on a package before it is
+	// reported as a finding. Zero means the value of the base config, which
+	// defaults to no timeout.
+	timeout time.Duration
+
+	// reportErrors controls whether an error returned by the analyzer is
+	// reported as a finding of the analyzer instead of failing the nogo
+	// action, which keeps a broken analyzer from failing the build when
+	// validations are disabled. nil means the value of the base config,
+	// which defaults to false.
+	reportErrors *bool
+
+	// maxFindings is the number of findings of a package after which the
+	// others are suppressed, which keeps the log and the fix files of
+	// packages with many findings bounded. It is only set in the base config
+	// and zero means no limit.
+	maxFindings int
+}
+
+// newBool is used by the generated configs to set optional booleans.
+func newBool(b bool) *bool {
+	return &b
+}
+
+// analyzerConfig returns the config of the named analyzer, in which the fields
+// that it doesn't set have the value of the base config. The fields that are
+// only set in the base config are copied from it.
+func analyzerConfig(configs map[string]config, analyzerName string) config {
+	// Use the base config if it exists.
+	merged := configs[nogoBaseConfigName]
+	if analyzerName == nogoBaseConfigName {
+		return merged
+	}
+	c := configs[analyzerName]
+	// Overwrite the base config with the fields set in the config of the
+	// analyzer.
+	if c.analyzerFlags != nil {
+		merged.analyzerFlags = c.analyzerFlags
+	}
+	if c.onlyFiles != nil {
+		merged.onlyFiles = c.onlyFiles
+	}
+	if c.excludeFiles != nil {
+		merged.excludeFiles = c.excludeFiles
+	}
+	if c.remediation != "" {
+		merged.remediation = c.remediation
+	}
+	if c.fixes != nil {
+		merged.fixes = c.fixes
+	}
+	if c.fixOnlyFiles != nil {
+		merged.fixOnlyFiles = c.fixOnlyFiles
+	}
+	if c.fixExcludeFiles != nil {
+		merged.fixExcludeFiles = c.fixExcludeFiles
+	}
+	if c.diagnostics != nil {
+		merged.diagnostics = c.diagnostics
+	}
+	if c.tests != nil {
+		merged.tests = c.tests
+	}
+	if c.fixTests != nil {
+		merged.fixTests = c.fixTests
+	}
+	if c.severity != "" {
+		merged.severity = c.severity
+	}
+	if c.timeout != 0 {
+		merged.timeout = c.timeout
+	}
+	if c.reportErrors != nil {
+		merged.reportErrors = c.reportErrors
+	}
+	// The priority of the base config doesn't apply to other analyzers.
+	merged.fixPriority = c.fixPriority
+	return merged
+}
@@ -0,0 +1,269 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// checkConfigCommand is the first argument of nogo that checks its config
+// instead of analyzing a package, e.g. with
+// bazel run //:nogo_actual -- check_config.
+const checkConfigCommand = "check_config"
+
+// checkConfig prints the effective config of each analyzer, followed by the
+// problems with the config: names that are not those of analyzers, analyzer
+// flags that can't be set, settings that have no effect and the problems found
+// when nogo was generated. It returns an error if there are problems, which
+// would otherwise only be noticed when nogo runs or not at all.
+func checkConfig(w io.Writer, analyzers []*analysis.Analyzer, externalNames []string, configs map[string]config, generatedProblems []string) error {
+	byName := make(map[string]*analysis.Analyzer, len(analyzers))
+	names := make([]string, 0, len(analyzers)+len(externalNames))
+	for _, a := range analyzers {
+		byName[a.Name] = a
+		names = append(names, a.Name)
+	}
+	external := make(map[string]bool, len(externalNames))
+	for _, name := range externalNames {
+		external[name] = true
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	configured := make([]string, 0, len(configs))
+	for name := range configs {
+		configured = append(configured, name)
+	}
+	sort.Strings(configured)
+	problems := append([]string{}, generatedProblems...)
+	var warnings []string
+	for _, name := range configured {
+		c := configs[name]
+		switch {
+		case name == nogoBaseConfigName:
+		case byName[name] != nil:
+			problems = append(problems, checkAnalyzerFlags(byName[name], c.analyzerFlags)...)
+		case external[name]:
+			if len(c.analyzerFlags) > 0 {
+				problems = append(problems, fmt.Sprintf("%s: analyzer_flags has no effect on an external analyzer", name))
+			}
+		case len(externalNames) > 0:
+			// External analyzers may report findings under any name.
+			warnings = append(warnings, fmt.Sprintf("%s: not an analyzer of nogo, the config only applies to the findings an external analyzer reports with this name%s", name, suggestName(name, names)))
+		default:
+			problems = append(problems, fmt.Sprintf("%s: unknown analyzer%s", name, suggestName(name, names)))
+		}
+		problems = append(problems, ineffectiveSettings(configs, name)...)
+	}
+
+	base := configs[nogoBaseConfigName]
+	fmt.Fprintf(w, "%s:\n", nogoBaseConfigName)
+	fmt.Fprintf(w, "  fix_conflicts: %s\n", orDefault(base.fixConflicts, fixConflictsFirst))
+	fmt.Fprintf(w, "  fail_on: %s\n", orDefault(base.failOn, failOnFindings))
+	fmt.Fprintf(w, "  fact_compression: %s\n", orDefault(base.factCompression, factCodecNone))
+	if base.maxFindings != 0 {
+		fmt.Fprintf(w, "  max_findings: %d\n", base.maxFindings)
+	}
+	for _, name := range names {
+		writeEffectiveConfig(w, name, analyzerConfig(configs, name))
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(w, "warning: %s\n", warning)
+	}
+	for _, problem := range problems {
+		fmt.Fprintf(w, "error: %s\n", problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d problems in the config", len(problems))
+	}
+	return nil
+}
+
+// checkAnalyzerFlags returns the problems with setting the analyzer flags of
+// the config, which fail nogo when it runs the analyzer.
+func checkAnalyzerFlags(a *analysis.Analyzer, analyzerFlags map[string]string) []string {
+	keys := make([]string, 0, len(analyzerFlags))
+	for key := range analyzerFlags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var flagNames []string
+	a.Flags.VisitAll(func(f *flag.Flag) { flagNames = append(flagNames, f.Name) })
+	var problems []string
+	for _, key := range keys {
+		switch {
+		case strings.HasPrefix(key, "-"):
+			problems = append(problems, fmt.Sprintf("%s: flag should not begin with '-': %s", a.Name, key))
+		case a.Flags.Lookup(key) == nil:
+			problems = append(problems, fmt.Sprintf("%s: unrecognized flag: %s%s", a.Name, key, suggestName(key, flagNames)))
+		default:
+			if err := a.Flags.Set(key, analyzerFlags[key]); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: invalid value for flag: %s=%s: %v", a.Name, key, analyzerFlags[key], err))
+			}
+		}
+	}
+	return problems
+}
+
+// ineffectiveSettings returns a problem for each setting in the config of the
+// analyzer that has no effect because of another one.
+func ineffectiveSettings(configs map[string]config, analyzerName string) []string {
+	c := configs[analyzerName]
+	merged := analyzerConfig(configs, analyzerName)
+	var ineffective []string
+	because := func(key, reason string) {
+		ineffective = append(ineffective, fmt.Sprintf("%s: %s has no effect since %s", analyzerName, key, reason))
+	}
+	if merged.fixes != nil && !*merged.fixes {
+		if c.fixOnlyFiles != nil {
+			because("fix_only_files", "fixes is false")
+		}
+		if c.fixExcludeFiles != nil {
+			because("fix_exclude_files", "fixes is false")
+		}
+		if c.fixTests != nil {
+			because("fix_tests", "fixes is false")
+		}
+	}
+	if c.fixPriority != 0 {
+		if analyzerName == nogoBaseConfigName {
+			because("fix_priority", "it only orders the fixes of the analyzer it is set for")
+		} else if configs[nogoBaseConfigName].fixConflicts != fixConflictsPriority {
+			because("fix_priority", fmt.Sprintf("fix_conflicts is not %q", fixConflictsPriority))
+		}
+	}
+	if merged.tests != nil && !*merged.tests && c.fixTests != nil && *c.fixTests {
+		because("fix_tests", "tests is false")
+	}
+	if merged.diagnostics != nil && !*merged.diagnostics {
+		if c.severity != "" {
+			because("severity", "diagnostics is false")
+		}
+		if c.remediation != "" {
+			because("remediation", "diagnostics is false")
+		}
+	}
+	for _, only := range c.onlyFiles {
+		for _, exclude := range c.excludeFiles {
+			if only.String() == exclude.String() {
+				because(fmt.Sprintf("only_files pattern %q", only.String()), "exclude_files has the same pattern")
+			}
+		}
+	}
+	return ineffective
+}
+
+// writeEffectiveConfig writes the settings of an analyzer, including those
+// that it inherits from the base config and the defaults.
+func writeEffectiveConfig(w io.Writer, name string, c config) {
+	fmt.Fprintf(w, "%s:\n", name)
+	fmt.Fprintf(w, "  diagnostics: %t\n", c.diagnostics == nil || *c.diagnostics)
+	fmt.Fprintf(w, "  severity: %s\n", orDefault(c.severity, severityError))
+	fmt.Fprintf(w, "  tests: %t\n", c.tests == nil || *c.tests)
+	fmt.Fprintf(w, "  fixes: %t\n", c.fixes == nil || *c.fixes)
+	fmt.Fprintf(w, "  fix_tests: %t\n", c.fixTests == nil || *c.fixTests)
+	writePatterns(w, "only_files", c.onlyFiles)
+	writePatterns(w, "exclude_files", c.excludeFiles)
+	writePatterns(w, "fix_only_files", c.fixOnlyFiles)
+	writePatterns(w, "fix_exclude_files", c.fixExcludeFiles)
+	if c.fixPriority != 0 {
+		fmt.Fprintf(w, "  fix_priority: %d\n", c.fixPriority)
+	}
+	if c.timeout != 0 {
+		fmt.Fprintf(w, "  timeout: %s\n", c.timeout)
+	}
+	if c.reportErrors != nil && *c.reportErrors {
+		fmt.Fprintf(w, "  report_errors: true\n")
+	}
+	if len(c.analyzerFlags) > 0 {
+		keys := make([]string, 0, len(c.analyzerFlags))
+		for key := range c.analyzerFlags {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fmt.Fprintf(w, "  analyzer_flags:\n")
+		for _, key := range keys {
+			fmt.Fprintf(w, "    %s=%s\n", key, c.analyzerFlags[key])
+		}
+	}
+	if c.remediation != "" {
+		fmt.Fprintf(w, "  remediation: %q\n", c.remediation)
+	}
+}
+
+func writePatterns(w io.Writer, key string, patterns []*regexp.Regexp) {
+	if len(patterns) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "  %s:\n", key)
+	for _, p := range patterns {
+		fmt.Fprintf(w, "    %q\n", p.String())
+	}
+}
+
+func orDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// suggestName returns a hint naming the candidate closest to a misspelled
+// name, or an empty string if none is close.
+func suggestName(name string, candidates []string) string {
+	best, bestDistance := "", len(name)/3+1
+	for _, candidate := range candidates {
+		if d := editDistance(name, candidate); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %q?", best)
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cur[j] = prev[j-1]
+			if a[i-1] != b[j-1] {
+				cur[j]++
+			}
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+func TestAnalyzerConfig(t *testing.T) {
+	configs := map[string]config{
+		nogoBaseConfigName: {
+			excludeFiles: []*regexp.Regexp{regexp.MustCompile("third_party/")},
+			remediation:  "ask the linter owners",
+			tests:        newBool(false),
+			fixConflicts: fixConflictsPriority,
+			fixPriority:  5,
+			timeout:      time.Minute,
+			reportErrors: newBool(true),
+		},
+		"printf": {
+			excludeFiles: []*regexp.Regexp{},
+			tests:        newBool(true),
+			fixPriority:  2,
+		},
+	}
+	got := analyzerConfig(configs, "printf")
+	want := config{
+		excludeFiles: []*regexp.Regexp{},
+		remediation:  "ask the linter owners",
+		tests:        newBool(true),
+		fixConflicts: fixConflictsPriority,
+		fixPriority:  2,
+		timeout:      time.Minute,
+		reportErrors: newBool(true),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got config %+v for printf, want %+v", got, want)
+	}
+	if got := analyzerConfig(configs, "unconfigured"); got.fixPriority != 0 || len(got.excludeFiles) != 1 || got.remediation != "ask the linter owners" {
+		t.Errorf("got config %+v for an unconfigured analyzer, want the base config without its priority", got)
+	}
+}
+
+func newFlagAnalyzer() *analysis.Analyzer {
+	a := &analysis.Analyzer{Name: "printf"}
+	a.Flags.Init("printf", flag.ContinueOnError)
+	a.Flags.String("funcs", "", "comma-separated list of print function names")
+	a.Flags.Int("depth", 1, "how deep to look")
+	return a
+}
+
+func TestCheckConfig(t *testing.T) {
+	configs := map[string]config{
+		nogoBaseConfigName: {
+			excludeFiles: []*regexp.Regexp{regexp.MustCompile("third_party/")},
+			failOn:       failOnFixable,
+			maxFindings:  100,
+		},
+		"printf": {
+			analyzerFlags: map[string]string{"funcs": "Wrapf"},
+			fixes:         newBool(false),
+			timeout:       30 * time.Second,
+			reportErrors:  newBool(true),
+		},
+		"mylinter": {
+			severity:    severityWarning,
+			remediation: "see go/lint",
+		},
+	}
+	var buf bytes.Buffer
+	if err := checkConfig(&buf, []*analysis.Analyzer{newFlagAnalyzer()}, []string{"mylinter"}, configs, nil); err != nil {
+		t.Fatalf("checkConfig failed on a valid config: %v\n%s", err, buf.String())
+	}
+	want := `_base:
+  fix_conflicts: first
+  fail_on: fixable
+  fact_compression: none
+  max_findings: 100
+mylinter:
+  diagnostics: true
+  severity: warning
+  tests: true
+  fixes: true
+  fix_tests: true
+  exclude_files:
+    "third_party/"
+  remediation: "see go/lint"
+printf:
+  diagnostics: true
+  severity: error
+  tests: true
+  fixes: false
+  fix_tests: true
+  exclude_files:
+    "third_party/"
+  timeout: 30s
+  report_errors: true
+  analyzer_flags:
+    funcs=Wrapf
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCheckConfig_Problems(t *testing.T) {
+	configs := map[string]config{
+		nogoBaseConfigName: {
+			fixPriority: 1,
+		},
+		"pritnf": {},
+		"printf": {
+			analyzerFlags:   map[string]string{"func": "Wrapf", "depth": "deep", "-funcs": "Wrapf"},
+			fixes:           newBool(false),
+			fixExcludeFiles: []*regexp.Regexp{regexp.MustCompile("gen/")},
+			fixPriority:     3,
+			onlyFiles:       []*regexp.Regexp{regexp.MustCompile("src/")},
+			excludeFiles:    []*regexp.Regexp{regexp.MustCompile("src/")},
+		},
+		"unusedwrite": {
+			diagnostics: newBool(false),
+			severity:    severityNote,
+			tests:       newBool(false),
+			fixTests:    newBool(true),
+		},
+	}
+	analyzers := []*analysis.Analyzer{newFlagAnalyzer(), {Name: "unusedwrite"}}
+	generated := []string{`printf: unknown key "exclude_file", which is ignored`}
+	var buf bytes.Buffer
+	err := checkConfig(&buf, analyzers, nil, configs, generated)
+	if err == nil {
+		t.Fatalf("checkConfig succeeded on an invalid config:\n%s", buf.String())
+	}
+	var got []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "error: ") || strings.HasPrefix(line, "warning: ") {
+			got = append(got, line)
+		}
+	}
+	want := []string{
+		`error: printf: unknown key "exclude_file", which is ignored`,
+		`error: _base: fix_priority has no effect since it only orders the fixes of the analyzer it is set for`,
+		`error: printf: flag should not begin with '-': -funcs`,
+		`error: printf: invalid value for flag: depth=deep: parse error`,
+		`error: printf: unrecognized flag: func, did you mean "funcs"?`,
+		`error: printf: fix_exclude_files has no effect since fixes is false`,
+		`error: printf: fix_priority has no effect since fix_conflicts is not "priority"`,
+		`error: printf: only_files pattern "src/" has no effect since exclude_files has the same pattern`,
+		`error: pritnf: unknown analyzer, did you mean "printf"?`,
+		`error: unusedwrite: fix_tests has no effect since tests is false`,
+		`error: unusedwrite: severity has no effect since diagnostics is false`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got problems:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if want := "found 11 problems in the config"; err.Error() != want {
+		t.Errorf("got error %q, want %q", err, want)
+	}
+}
+
+func TestCheckConfig_ExternalNames(t *testing.T) {
+	configs := map[string]config{
+		"SA1019": {},
+		"mylinter": {
+			analyzerFlags: map[string]string{"strict": "true"},
+		},
+	}
+	var buf bytes.Buffer
+	err := checkConfig(&buf, nil, []string{"mylinter"}, configs, nil)
+	if err == nil {
+		t.Fatal("checkConfig accepted analyzer_flags for an external analyzer")
+	}
+	for _, want := range []string{
+		"warning: SA1019: not an analyzer of nogo, the config only applies to the findings an external analyzer reports with this name\n",
+		"error: mylinter: analyzer_flags has no effect on an external analyzer\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output doesn't contain %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestSuggestName(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		want string
+	}{
+		{name: "pritnf", want: `, did you mean "printf"?`},
+		{name: "shadw", want: `, did you mean "shadow"?`},
+		{name: "nilness", want: ""},
+		{name: "x", want: ""},
+	} {
+		if got := suggestName(tt.name, []string{"printf", "shadow", "nilfunc"}); got != tt.want {
+			t.Errorf("suggestName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
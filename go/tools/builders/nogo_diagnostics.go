@@ -0,0 +1,139 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"go/token"
+	"io"
+)
+
+// diagnosticsReport is the machine-readable form of the findings of nogo for
+// a single package. Paths are relative to the execroot.
+type diagnosticsReport struct {
+	Package     string           `json:"package"`
+	Diagnostics []jsonDiagnostic `json:"diagnostics"`
+	// Suppressed is the number of findings left out of Diagnostics since the
+	// package has more than the max_findings of the config.
+	Suppressed int `json:"suppressed,omitempty"`
+}
+
+type jsonDiagnostic struct {
+	Analyzer       string             `json:"analyzer"`
+	Category       string             `json:"category,omitempty"`
+	Severity       string             `json:"severity"`
+	Message        string             `json:"message"`
+	URL            string             `json:"url,omitempty"`
+	*jsonRange                        // nil if the diagnostic has no position
+	SuggestedFixes []jsonSuggestedFix `json:"suggested_fixes,omitempty"`
+	Hints          []string           `json:"hints,omitempty"`
+	Related        []jsonRelatedInfo  `json:"related,omitempty"`
+}
+
+// A jsonRange is a range of source code. Lines and columns are 1-based;
+// columns and offsets are in bytes.
+type jsonRange struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	Offset    int    `json:"offset"`
+	EndLine   int    `json:"end_line"`
+	EndColumn int    `json:"end_column"`
+	EndOffset int    `json:"end_offset"`
+}
+
+type jsonSuggestedFix struct {
+	Message string         `json:"message"`
+	Edits   []jsonTextEdit `json:"edits"`
+}
+
+type jsonTextEdit struct {
+	*jsonRange
+	NewText string `json:"new_text"`
+}
+
+type jsonRelatedInfo struct {
+	*jsonRange
+	Message string `json:"message"`
+}
+
+// newJSONRange converts a range of token positions. It returns nil if pos is
+// not valid. The end is optional.
+func newJSONRange(fset *token.FileSet, pos, end token.Pos) *jsonRange {
+	p := fset.Position(pos)
+	if !p.IsValid() {
+		return nil
+	}
+	r := &jsonRange{
+		File: p.Filename, Line: p.Line, Column: p.Column, Offset: p.Offset,
+		EndLine: p.Line, EndColumn: p.Column, EndOffset: p.Offset,
+	}
+	if e := fset.Position(end); end.IsValid() && e.Filename == p.Filename && e.Offset >= p.Offset {
+		r.EndLine, r.EndColumn, r.EndOffset = e.Line, e.Column, e.Offset
+	}
+	return r
+}
+
+// newDiagnosticsReport converts the diagnostics of a package, including their
+// suggested fixes and related information.
+func newDiagnosticsReport(packagePath string, diagnostics []diagnosticEntry, fset *token.FileSet) diagnosticsReport {
+	report := diagnosticsReport{Package: packagePath, Diagnostics: []jsonDiagnostic{}}
+	for _, d := range diagnostics {
+		jd := jsonDiagnostic{
+			Analyzer:  d.analyzerName,
+			Category:  d.Category,
+			Severity:  d.findingSeverity(),
+			Message:   d.Message,
+			URL:       d.URL,
+			jsonRange: newJSONRange(fset, d.Pos, d.End),
+		}
+		for _, sf := range d.SuggestedFixes {
+			if len(sf.TextEdits) == 0 {
+				// Reported as hints.
+				continue
+			}
+			fix := jsonSuggestedFix{Message: sf.Message, Edits: []jsonTextEdit{}}
+			for _, edit := range sf.TextEdits {
+				fix.Edits = append(fix.Edits, jsonTextEdit{
+					jsonRange: newJSONRange(fset, edit.Pos, edit.End),
+					NewText:   string(edit.NewText),
+				})
+			}
+			jd.SuggestedFixes = append(jd.SuggestedFixes, fix)
+		}
+		jd.Hints = fixHints(d.Diagnostic)
+		for _, rel := range d.Related {
+			jd.Related = append(jd.Related, jsonRelatedInfo{
+				jsonRange: newJSONRange(fset, rel.Pos, rel.End),
+				Message:   rel.Message,
+			})
+		}
+		report.Diagnostics = append(report.Diagnostics, jd)
+	}
+	return report
+}
+
+// writeDiagnosticsReport writes the report as indented JSON.
+func writeDiagnosticsReport(w io.Writer, report diagnosticsReport) error {
+	if report.Diagnostics == nil {
+		report.Diagnostics = []jsonDiagnostic{}
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
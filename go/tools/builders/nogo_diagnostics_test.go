@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+func TestWriteDiagnosticsReport(t *testing.T) {
+	fset := token.NewFileSet()
+	f := fset.AddFile("pkg/file1.go", fset.Base(), 100)
+	f.AddLine(0)
+	f.AddLine(20)
+	f.AddLine(40)
+
+	diagnostics := []diagnosticEntry{
+		{
+			analyzerName: "analyzer1",
+			Diagnostic: analysis.Diagnostic{
+				Pos:      token.Pos(23),
+				End:      token.Pos(27),
+				Category: "style",
+				Message:  `found "foo"`,
+				SuggestedFixes: []analysis.SuggestedFix{{
+					Message: "replace with bar",
+					TextEdits: []analysis.TextEdit{
+						{Pos: token.Pos(23), End: token.Pos(27), NewText: []byte("bar")},
+					},
+				}},
+				Related: []analysis.RelatedInformation{
+					{Pos: token.Pos(42), Message: "declared here"},
+				},
+			},
+		},
+		{
+			analyzerName: "analyzer2",
+			severity:     severityWarning,
+			Diagnostic: analysis.Diagnostic{
+				Message: "no position",
+				SuggestedFixes: []analysis.SuggestedFix{
+					{Message: "rename the package"},
+				},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := writeDiagnosticsReport(&buf, newDiagnosticsReport("example.com/pkg", diagnostics, fset)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `{
+  "package": "example.com/pkg",
+  "diagnostics": [
+    {
+      "analyzer": "analyzer1",
+      "category": "style",
+      "severity": "error",
+      "message": "found \"foo\"",
+      "file": "pkg/file1.go",
+      "line": 2,
+      "column": 3,
+      "offset": 22,
+      "end_line": 2,
+      "end_column": 7,
+      "end_offset": 26,
+      "suggested_fixes": [
+        {
+          "message": "replace with bar",
+          "edits": [
+            {
+              "file": "pkg/file1.go",
+              "line": 2,
+              "column": 3,
+              "offset": 22,
+              "end_line": 2,
+              "end_column": 7,
+              "end_offset": 26,
+              "new_text": "bar"
+            }
+          ]
+        }
+      ],
+      "related": [
+        {
+          "file": "pkg/file1.go",
+          "line": 3,
+          "column": 2,
+          "offset": 41,
+          "end_line": 3,
+          "end_column": 2,
+          "end_offset": 41,
+          "message": "declared here"
+        }
+      ]
+    },
+    {
+      "analyzer": "analyzer2",
+      "severity": "warning",
+      "message": "no position",
+      "hints": [
+        "rename the package"
+      ]
+    }
+  ]
+}
+`
+	if got := buf.String(); got != expected {
+		t.Errorf("unexpected diagnostics:\n\tgot:\n%s\n\twant:\n%s", got, expected)
+	}
+}
+
+func TestWriteDiagnosticsReport_NoDiagnostics(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeDiagnosticsReport(&buf, newDiagnosticsReport("example.com/pkg", nil, token.NewFileSet())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The builder writes this document itself for packages without Go sources.
+	quoted, err := json.Marshal("example.com/pkg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), fmt.Sprintf(emptyDiagnosticsJSONFormat, quoted); got != want {
+		t.Errorf("unexpected diagnostics:\n\tgot:\n%s\n\twant:\n%s", got, want)
+	}
+}
+
+func TestWriteDiagnosticsReport_Suppressed(t *testing.T) {
+	var buf bytes.Buffer
+	report := newDiagnosticsReport("example.com/pkg", nil, token.NewFileSet())
+	report.Suppressed = 12
+	if err := writeDiagnosticsReport(&buf, report); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `{
+  "package": "example.com/pkg",
+  "diagnostics": [],
+  "suppressed": 12
+}
+`
+	if got := buf.String(); got != expected {
+		t.Errorf("unexpected diagnostics:\n\tgot:\n%s\n\twant:\n%s", got, expected)
+	}
+}
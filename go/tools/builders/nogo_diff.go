@@ -0,0 +1,117 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// nogo_diff reports the nogo findings of a build that are not present in a
+// baseline build, e.g. the findings introduced by a change.
+//
+// Usage: bazel run @io_bazel_rules_go//go/tools/builders:nogo_diff -- base head
+//
+// base and head are inspection results files written by nogo (see the
+// nogo_inspection output group) or directories that are searched for them.
+// Findings are matched by file, analyzer and message, but not by line, so that
+// findings moved by unrelated edits aren't reported as new. nogo_diff exits
+// with a non-zero status if there are new findings.
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("nogo_diff: ")
+	n, err := runDiff(os.Args[1:], os.Stdout)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if n > 0 {
+		os.Exit(1)
+	}
+}
+
+// runDiff prints the new findings and returns their number.
+func runDiff(args []string, stdout io.Writer) (int, error) {
+	if len(args) != 2 {
+		return 0, fmt.Errorf("usage: nogo_diff base head")
+	}
+	// Relative paths are given relative to the directory bazel run was invoked
+	// from.
+	cwd := os.Getenv("BUILD_WORKING_DIRECTORY")
+	var findings [2][]inspectionProblem
+	for i, path := range args {
+		if cwd != "" && !filepath.IsAbs(path) {
+			path = filepath.Join(cwd, path)
+		}
+		var err error
+		if findings[i], err = loadFindings(path); err != nil {
+			return 0, err
+		}
+	}
+
+	added := newFindings(findings[0], findings[1])
+	for _, p := range added {
+		fmt.Fprintf(stdout, "%s:%d:%d: %s (%s)\n", strings.TrimPrefix(p.File, inspectionProjectDir), p.Line, p.Offset+1, p.Description, p.ProblemClass.Name)
+	}
+	if len(added) == 0 {
+		fmt.Fprintln(stdout, "nogo found no new issues.")
+	} else {
+		fmt.Fprintf(stdout, "nogo found %d new issue(s).\n", len(added))
+	}
+	return len(added), nil
+}
+
+// newFindings returns the findings in head that don't have a counterpart in
+// base, sorted by position. If a file has more findings with the same analyzer
+// and message in head than in base, the last ones are considered new.
+func newFindings(base, head []inspectionProblem) []inspectionProblem {
+	type key struct {
+		file, analyzer, message string
+	}
+	keyOf := func(p inspectionProblem) key {
+		return key{p.File, p.ProblemClass.Name, p.Description}
+	}
+	baseCounts := make(map[key]int)
+	for _, p := range base {
+		baseCounts[keyOf(p)]++
+	}
+
+	sorted := make([]inspectionProblem, len(head))
+	copy(sorted, head)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Offset < b.Offset
+	})
+	var added []inspectionProblem
+	for _, p := range sorted {
+		k := keyOf(p)
+		if baseCounts[k] > 0 {
+			baseCounts[k]--
+			continue
+		}
+		added = append(added, p)
+	}
+	return added
+}
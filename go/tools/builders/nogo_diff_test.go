@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testProblem(file string, line int, analyzer, message string) inspectionProblem {
+	return inspectionProblem{
+		File:         inspectionProjectDir + file,
+		Line:         line,
+		ProblemClass: inspectionProblemClass{ID: analyzer, Name: analyzer},
+		Description:  message,
+	}
+}
+
+func TestNewFindings(t *testing.T) {
+	base := []inspectionProblem{
+		testProblem("pkg/a.go", 10, "printf", "bad format"),
+		testProblem("pkg/a.go", 20, "printf", "bad format"),
+		testProblem("pkg/b.go", 5, "unusedresult", "result unused"),
+	}
+	head := []inspectionProblem{
+		// Moved by an unrelated edit.
+		testProblem("pkg/b.go", 7, "unusedresult", "result unused"),
+		testProblem("pkg/a.go", 12, "printf", "bad format"),
+		testProblem("pkg/a.go", 22, "printf", "bad format"),
+		// A third instance of an existing finding.
+		testProblem("pkg/a.go", 30, "printf", "bad format"),
+		// A new finding on a line with an existing one.
+		testProblem("pkg/a.go", 12, "shadow", "x shadows x"),
+	}
+	got := newFindings(base, head)
+	want := []inspectionProblem{
+		testProblem("pkg/a.go", 12, "shadow", "x shadows x"),
+		testProblem("pkg/a.go", 30, "printf", "bad format"),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d new findings, want %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("finding %d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRunDiff(t *testing.T) {
+	dir := t.TempDir()
+	writeXML := func(path string, problems ...inspectionProblem) {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		data, err := xml.Marshal(inspectionProblems{Problems: problems})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeXML("base/pkg/lib.nogo.xml", testProblem("pkg/a.go", 10, "printf", "bad format"))
+	writeXML("head/pkg/lib.nogo.xml", testProblem("pkg/a.go", 11, "printf", "bad format"))
+	writeXML("head/other/lib.nogo.xml", testProblem("other/c.go", 3, "nilness", "nil dereference"))
+	// Files with other names are ignored.
+	writeXML("head/other/lib.xml", testProblem("other/c.go", 4, "nilness", "nil dereference"))
+
+	var stdout bytes.Buffer
+	n, err := runDiff([]string{filepath.Join(dir, "base"), filepath.Join(dir, "head")}, &stdout)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("got %d new findings, want 1", n)
+	}
+	want := "other/c.go:3:1: nil dereference (nilness)\nnogo found 1 new issue(s).\n"
+	if stdout.String() != want {
+		t.Errorf("unexpected output:\n\tgot:\n%s\n\twant:\n%s", stdout.String(), want)
+	}
+
+	stdout.Reset()
+	n, err = runDiff([]string{filepath.Join(dir, "head"), filepath.Join(dir, "head/pkg/lib.nogo.xml")}, &stdout)
+	if err != nil || n != 0 || !strings.Contains(stdout.String(), "no new issues") {
+		t.Errorf("expected no new findings, got %d, %v:\n%s", n, err, stdout.String())
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os/exec"
+)
+
+// nogoWorkers holds the nogo processes started as persistent workers by the
+// builder when it runs as a persistent worker itself, keyed by the path of
+// the nogo binary. It is nil otherwise, see startNogoWorkers.
+var nogoWorkers map[string]*workerProcess
+
+// startNogoWorkers makes execNogo run nogo as a persistent worker, which is
+// started by the first package analyzed with each nogo binary, until
+// closeNogoWorkers is called.
+func startNogoWorkers() {
+	nogoWorkers = make(map[string]*workerProcess)
+}
+
+// execNogo runs nogo with the params file and returns its exit code and
+// output. An error means that nogo did not exit normally.
+func execNogo(nogoPath, paramsFile string) (int, []byte, error) {
+	if nogoWorkers != nil {
+		// A warm nogo reuses the state it keeps between packages, see
+		// nogo_worker.go.
+		w := nogoWorkers[nogoPath]
+		if w == nil {
+			var err error
+			if w, err = startWorkerProcess(nogoPath); err != nil {
+				return 0, nil, err
+			}
+			nogoWorkers[nogoPath] = w
+		}
+		resp, err := w.call([]string{"-param=" + paramsFile})
+		if err != nil {
+			// The next package starts a new nogo.
+			delete(nogoWorkers, nogoPath)
+			w.close()
+			return 0, nil, err
+		}
+		return resp.ExitCode, []byte(resp.Output), nil
+	}
+
+	cmd := exec.Command(nogoPath, "-param="+paramsFile)
+	out := &bytes.Buffer{}
+	cmd.Stdout, cmd.Stderr = out, out
+	err := cmd.Run()
+	if err == nil {
+		return nogoSuccess, out.Bytes(), nil
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.Exited() {
+		return exitErr.ExitCode(), out.Bytes(), nil
+	}
+	return 0, out.Bytes(), err
+}
+
+// closeNogoWorkers stops the nogo processes started as persistent workers.
+func closeNogoWorkers() {
+	for path, w := range nogoWorkers {
+		w.close()
+		delete(nogoWorkers, path)
+	}
+	nogoWorkers = nil
+}
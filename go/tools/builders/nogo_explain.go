@@ -0,0 +1,72 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// explainCommand is the first argument of nogo that prints the documentation
+// of analyzers instead of analyzing a package, e.g. with
+// bazel run //:nogo_actual -- explain printf.
+const explainCommand = "explain"
+
+// explain prints the documentation of the named analyzers, or a summary of
+// each analyzer if no names are given.
+func explain(w io.Writer, analyzers []*analysis.Analyzer, names []string) error {
+	byName := make(map[string]*analysis.Analyzer, len(analyzers))
+	for _, a := range analyzers {
+		byName[a.Name] = a
+	}
+	if len(names) == 0 {
+		sorted := append([]*analysis.Analyzer{}, analyzers...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+		for _, a := range sorted {
+			summary := strings.TrimSpace(a.Doc)
+			if i := strings.IndexByte(summary, '\n'); i >= 0 {
+				summary = summary[:i]
+			}
+			fmt.Fprintf(w, "%s: %s\n", a.Name, summary)
+		}
+		return nil
+	}
+	for i, name := range names {
+		a, ok := byName[name]
+		if !ok {
+			return fmt.Errorf("unknown analyzer %q, run %q without arguments to list the analyzers", name, explainCommand)
+		}
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s: %s\n", a.Name, strings.TrimSpace(a.Doc))
+		if a.URL != "" {
+			fmt.Fprintf(w, "\nSee %s\n", a.URL)
+		}
+		hasFlags := false
+		a.Flags.VisitAll(func(*flag.Flag) { hasFlags = true })
+		if hasFlags {
+			fmt.Fprintf(w, "\nFlags, set with analyzer_flags in the nogo config:\n")
+			a.Flags.SetOutput(w)
+			a.Flags.PrintDefaults()
+		}
+	}
+	return nil
+}
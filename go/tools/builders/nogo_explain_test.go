@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+func TestExplain(t *testing.T) {
+	withFlags := &analysis.Analyzer{
+		Name: "withflags",
+		Doc:  "check something\n\nThe check is explained in more detail here.\n",
+		URL:  "https://example.com/withflags",
+	}
+	withFlags.Flags.Init("withflags", flag.ContinueOnError)
+	withFlags.Flags.Bool("strict", false, "report more findings")
+	plain := &analysis.Analyzer{Name: "plain", Doc: "check something else"}
+	analyzers := []*analysis.Analyzer{withFlags, plain}
+
+	for _, tt := range []struct {
+		desc  string
+		names []string
+		want  string
+	}{
+		{
+			desc: "list",
+			want: "plain: check something else\nwithflags: check something\n",
+		},
+		{
+			desc:  "analyzers",
+			names: []string{"withflags", "plain"},
+			want: `withflags: check something
+
+The check is explained in more detail here.
+
+See https://example.com/withflags
+
+Flags, set with analyzer_flags in the nogo config:
+  -strict
+    	report more findings
+
+plain: check something else
+`,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := explain(&buf, analyzers, tt.names); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+
+	if err := explain(&bytes.Buffer{}, analyzers, []string{"unknown"}); err == nil {
+		t.Error("expected an error for an unknown analyzer")
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This file implements the protocol of external analyzers, which run as
+// separate executables instead of being compiled into nogo. nogo writes an
+// externalRequest as JSON to the standard input of the executable, which
+// writes an externalResponse as JSON to its standard output and exits with
+// status 0. Its findings then go through the same configuration, nolint
+// handling and fix generation as those of compiled analyzers.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// externalProtocolVersion is the version of the protocol of external
+// analyzers, sent in each request.
+const externalProtocolVersion = 1
+
+// An externalRequest asks an external analyzer to analyze a package.
+type externalRequest struct {
+	Version     int    `json:"version"`
+	PackagePath string `json:"package_path"`
+	// Files are the Go source files of the package, relative to the working
+	// directory of the analyzer or absolute.
+	Files []string `json:"files"`
+	// ImportMap maps the import paths used in the files to package paths.
+	ImportMap map[string]string `json:"import_map"`
+	// PackageFiles maps package paths to the export data of the packages,
+	// which allows analyzers to type-check the package like the compiler.
+	PackageFiles map[string]string `json:"package_files"`
+}
+
+// An externalResponse holds the findings of an external analyzer.
+type externalResponse struct {
+	Diagnostics []externalDiagnostic `json:"diagnostics"`
+}
+
+// An externalDiagnostic is a finding of an external analyzer. Positions are
+// byte offsets into one of the files of the request.
+type externalDiagnostic struct {
+	// Analyzer is the name the finding is reported and configured under. It
+	// defaults to the name of the executable, so that one executable can
+	// implement several analyzers.
+	Analyzer       string            `json:"analyzer"`
+	File           string            `json:"file"`
+	Offset         int               `json:"offset"`
+	End            int               `json:"end"`
+	Message        string            `json:"message"`
+	Category       string            `json:"category"`
+	SuggestedFixes []externalFix     `json:"suggested_fixes"`
+	Related        []externalRelated `json:"related"`
+}
+
+// externalRelated is a secondary position of a finding, such as where the
+// reported identifier is declared.
+type externalRelated struct {
+	File    string `json:"file"`
+	Offset  int    `json:"offset"`
+	End     int    `json:"end"`
+	Message string `json:"message"`
+}
+
+type externalFix struct {
+	Message string         `json:"message"`
+	Edits   []externalEdit `json:"edits"`
+}
+
+type externalEdit struct {
+	File    string `json:"file"`
+	Offset  int    `json:"offset"`
+	End     int    `json:"end"`
+	NewText string `json:"new_text"`
+}
+
+// externalAnalyzerName returns the default analyzer name of the findings of
+// an external analyzer.
+func externalAnalyzerName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), ".exe")
+}
+
+// runExternalAnalyzer runs the external analyzer executable on a package. A
+// timeout of zero means no timeout.
+func runExternalAnalyzer(path string, req externalRequest, timeout time.Duration) (externalResponse, error) {
+	in, err := json.Marshal(req)
+	if err != nil {
+		return externalResponse{}, err
+	}
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdin = bytes.NewReader(in)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return externalResponse{}, fmt.Errorf("timed out after %v", timeout)
+		}
+		if msg := firstLine(stderr.String()); msg != "" {
+			return externalResponse{}, fmt.Errorf("%v: %s", err, msg)
+		}
+		return externalResponse{}, err
+	}
+	var resp externalResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return externalResponse{}, fmt.Errorf("invalid response: %v", err)
+	}
+	return resp, nil
+}
+
+func firstLine(s string) string {
+	return strings.TrimSpace(strings.SplitN(strings.TrimSpace(s), "\n", 2)[0])
+}
+
+// externalDiagnostics converts the findings of an external analyzer to
+// diagnostics positioned in fset, grouped by analyzer name.
+func externalDiagnostics(resp externalResponse, defaultName string, fset *token.FileSet) (map[string][]analysis.Diagnostic, error) {
+	files := make(map[string]*token.File)
+	fset.Iterate(func(f *token.File) bool {
+		files[f.Name()] = f
+		return true
+	})
+	pos := func(file string, offset, end int) (token.Pos, token.Pos, error) {
+		f, ok := files[file]
+		if !ok {
+			return token.NoPos, token.NoPos, fmt.Errorf("unknown file %q", file)
+		}
+		if end == 0 {
+			end = offset
+		}
+		if offset < 0 || offset > end || end > f.Size() {
+			return token.NoPos, token.NoPos, fmt.Errorf("invalid range [%d, %d) in %s of size %d", offset, end, file, f.Size())
+		}
+		return f.Pos(offset), f.Pos(end), nil
+	}
+
+	byAnalyzer := make(map[string][]analysis.Diagnostic)
+	for _, ed := range resp.Diagnostics {
+		name := ed.Analyzer
+		if name == "" {
+			name = defaultName
+		}
+		if ed.Message == "" {
+			return nil, errors.New("diagnostic without message")
+		}
+		start, end, err := pos(ed.File, ed.Offset, ed.End)
+		if err != nil {
+			return nil, fmt.Errorf("diagnostic %q: %v", ed.Message, err)
+		}
+		d := analysis.Diagnostic{Pos: start, End: end, Category: ed.Category, Message: ed.Message}
+		for _, ef := range ed.SuggestedFixes {
+			fix := analysis.SuggestedFix{Message: ef.Message}
+			for _, ee := range ef.Edits {
+				start, end, err := pos(ee.File, ee.Offset, ee.End)
+				if err != nil {
+					return nil, fmt.Errorf("suggested fix of diagnostic %q: %v", ed.Message, err)
+				}
+				fix.TextEdits = append(fix.TextEdits, analysis.TextEdit{Pos: start, End: end, NewText: []byte(ee.NewText)})
+			}
+			d.SuggestedFixes = append(d.SuggestedFixes, fix)
+		}
+		for _, er := range ed.Related {
+			start, end, err := pos(er.File, er.Offset, er.End)
+			if err != nil {
+				return nil, fmt.Errorf("related information of diagnostic %q: %v", ed.Message, err)
+			}
+			d.Related = append(d.Related, analysis.RelatedInformation{Pos: start, End: end, Message: er.Message})
+		}
+		byAnalyzer[name] = append(byAnalyzer[name], d)
+	}
+	return byAnalyzer, nil
+}
+
+// runfilePath returns the path of a file in the runfiles of nogo, given by its
+// runfiles path such as "my_repo/tools/lint/analyzer". The external analyzers
+// are runfiles of nogo, so that changing them doesn't relink it.
+func runfilePath(rlocation string) (string, error) {
+	exe := os.Args[0]
+	if path := filepath.Join(exe+".runfiles", filepath.FromSlash(rlocation)); fileExists(path) {
+		return path, nil
+	}
+	// Without a runfiles tree, e.g. on Windows, look the file up in the
+	// manifest.
+	manifest, err := os.Open(exe + ".runfiles_manifest")
+	if err != nil {
+		return "", fmt.Errorf("runfile %s not found", rlocation)
+	}
+	defer manifest.Close()
+	s := bufio.NewScanner(manifest)
+	for s.Scan() {
+		if fields := strings.SplitN(s.Text(), " ", 2); len(fields) == 2 && fields[0] == rlocation {
+			return fields[1], nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("runfile %s not found in %s", rlocation, manifest.Name())
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
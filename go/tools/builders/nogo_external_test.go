@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// externalTestModeEnv makes the test binary act as an external analyzer.
+const externalTestModeEnv = "NOGO_EXTERNAL_TEST_MODE"
+
+func init() {
+	mode := os.Getenv(externalTestModeEnv)
+	if mode == "" {
+		return
+	}
+	var req externalRequest
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	switch mode {
+	case "ok":
+		json.NewEncoder(os.Stdout).Encode(externalResponse{
+			Diagnostics: []externalDiagnostic{{
+				File:    req.Files[0],
+				Message: fmt.Sprintf("checked %s (v%d)", req.PackagePath, req.Version),
+			}},
+		})
+	case "fail":
+		fmt.Fprintln(os.Stderr, "something broke\nmore details")
+		os.Exit(3)
+	case "hang":
+		time.Sleep(time.Minute)
+	case "garbage":
+		fmt.Println("not json")
+	}
+	os.Exit(0)
+}
+
+func TestRunExternalAnalyzer(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := externalRequest{Version: externalProtocolVersion, PackagePath: "example.com/a", Files: []string{"a.go"}}
+
+	t.Setenv(externalTestModeEnv, "ok")
+	resp, err := runExternalAnalyzer(exe, req, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := externalResponse{Diagnostics: []externalDiagnostic{{File: "a.go", Message: "checked example.com/a (v1)"}}}
+	if !reflect.DeepEqual(resp, want) {
+		t.Errorf("got %+v, want %+v", resp, want)
+	}
+
+	for _, tt := range []struct {
+		mode, wantErr string
+	}{
+		{"fail", "something broke"},
+		{"hang", "timed out after 100ms"},
+		{"garbage", "invalid response"},
+	} {
+		t.Setenv(externalTestModeEnv, tt.mode)
+		_, err := runExternalAnalyzer(exe, req, 100*time.Millisecond)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: got error %v, want one containing %q", tt.mode, err, tt.wantErr)
+		}
+	}
+}
+
+func TestExternalDiagnostics(t *testing.T) {
+	fset := token.NewFileSet()
+	a := fset.AddFile("pkg/a.go", fset.Base(), 100)
+	b := fset.AddFile("pkg/b.go", fset.Base(), 100)
+	resp := externalResponse{Diagnostics: []externalDiagnostic{
+		{File: "pkg/a.go", Offset: 10, Message: "default name"},
+		{
+			Analyzer: "other",
+			File:     "pkg/b.go",
+			Offset:   5,
+			End:      8,
+			Message:  "with fix",
+			Category: "style",
+			SuggestedFixes: []externalFix{{
+				Message: "replace",
+				Edits:   []externalEdit{{File: "pkg/b.go", Offset: 5, End: 8, NewText: "new"}},
+			}},
+			Related: []externalRelated{{File: "pkg/a.go", Offset: 20, End: 23, Message: "declared here"}},
+		},
+	}}
+	got, err := externalDiagnostics(resp, "external", fset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]analysis.Diagnostic{
+		"external": {{Pos: a.Pos(10), End: a.Pos(10), Message: "default name"}},
+		"other": {{
+			Pos:      b.Pos(5),
+			End:      b.Pos(8),
+			Category: "style",
+			Message:  "with fix",
+			SuggestedFixes: []analysis.SuggestedFix{{
+				Message:   "replace",
+				TextEdits: []analysis.TextEdit{{Pos: b.Pos(5), End: b.Pos(8), NewText: []byte("new")}},
+			}},
+			Related: []analysis.RelatedInformation{{Pos: a.Pos(20), End: a.Pos(23), Message: "declared here"}},
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	for _, tt := range []struct {
+		desc string
+		d    externalDiagnostic
+	}{
+		{"unknown file", externalDiagnostic{File: "pkg/c.go", Message: "m"}},
+		{"out of range", externalDiagnostic{File: "pkg/a.go", Offset: 90, End: 101, Message: "m"}},
+		{"inverted range", externalDiagnostic{File: "pkg/a.go", Offset: 10, End: 5, Message: "m"}},
+		{"no message", externalDiagnostic{File: "pkg/a.go"}},
+		{"bad edit", externalDiagnostic{File: "pkg/a.go", Message: "m", SuggestedFixes: []externalFix{{
+			Edits: []externalEdit{{File: "pkg/a.go", Offset: -1}},
+		}}}},
+		{"bad related", externalDiagnostic{File: "pkg/a.go", Message: "m", Related: []externalRelated{{File: "pkg/c.go", Message: "r"}}}},
+	} {
+		if _, err := externalDiagnostics(externalResponse{Diagnostics: []externalDiagnostic{tt.d}}, "external", fset); err == nil {
+			t.Errorf("%s: expected an error", tt.desc)
+		}
+	}
+}
+
+func TestRunfilePath(t *testing.T) {
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "nogo")
+	args := os.Args
+	defer func() { os.Args = args }()
+	os.Args = []string{exe}
+
+	tree := filepath.Join(exe+".runfiles", "repo", "tools", "analyzer")
+	if err := os.MkdirAll(filepath.Dir(tree), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tree, nil, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := runfilePath("repo/tools/analyzer"); err != nil || got != tree {
+		t.Errorf("from the runfiles tree: got %q, %v, want %q", got, err, tree)
+	}
+
+	target := filepath.Join(dir, "bin", "other")
+	manifest := "repo/tools/other " + target + "\n"
+	if err := os.WriteFile(exe+".runfiles_manifest", []byte(manifest), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := runfilePath("repo/tools/other"); err != nil || got != target {
+		t.Errorf("from the manifest: got %q, %v, want %q", got, err, target)
+	}
+	if _, err := runfilePath("repo/tools/missing"); err == nil {
+		t.Errorf("expected an error for a missing runfile")
+	}
+}
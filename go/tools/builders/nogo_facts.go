@@ -0,0 +1,154 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// factFileMagic starts the envelope of fact files written by nogo. Fact files
+// without it hold the serialized facts as they are, as written by older
+// versions of nogo, and are still read.
+const factFileMagic = "nogofacts\x00"
+
+// factFileVersion is the version of the fact file envelope, which follows the
+// magic as a single byte together with a byte selecting the codec of the
+// serialized facts.
+const factFileVersion = 1
+
+// factCodecIDs maps the fact codecs to their byte in the envelope.
+var factCodecIDs = map[string]byte{
+	factCodecNone: 0,
+	factCodecGzip: 1,
+}
+
+// encodeFactFile wraps the serialized facts of a package in the envelope,
+// compressing them with codec. Packages without facts are written as empty
+// files, like the files the builder writes for packages without sources.
+func encodeFactFile(facts []byte, codec string) ([]byte, error) {
+	if len(facts) == 0 {
+		return nil, nil
+	}
+	if codec == "" {
+		codec = factCodecNone
+	}
+	id, ok := factCodecIDs[codec]
+	if !ok {
+		return nil, fmt.Errorf("unknown fact codec %q", codec)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(factFileMagic)
+	buf.WriteByte(factFileVersion)
+	buf.WriteByte(id)
+	switch codec {
+	case factCodecNone:
+		buf.Write(facts)
+	case factCodecGzip:
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(facts); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeFactFile returns the serialized facts in a fact file written by any
+// version of nogo.
+func decodeFactFile(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(factFileMagic)) {
+		return data, nil
+	}
+	data = data[len(factFileMagic):]
+	if len(data) < 2 {
+		return nil, fmt.Errorf("truncated fact file envelope")
+	}
+	if version := data[0]; version != factFileVersion {
+		return nil, fmt.Errorf("unsupported fact file version %d, want %d", version, factFileVersion)
+	}
+	id, payload := data[1], data[2:]
+	switch id {
+	case factCodecIDs[factCodecNone]:
+		return payload, nil
+	case factCodecIDs[factCodecGzip]:
+		r, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("decompressing facts: %v", err)
+		}
+		facts, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("decompressing facts: %v", err)
+		}
+		return facts, nil
+	default:
+		return nil, fmt.Errorf("unknown fact codec %d", id)
+	}
+}
+
+// A factArchive holds the fact files of several packages, keyed by package
+// path. The facts of the standard library are distributed in one, since its
+// packages are not compiled with nogo.
+type factArchive struct {
+	r     *zip.ReadCloser
+	files map[string]*zip.File
+	// facts caches the facts read for the packages analyzed by a persistent
+	// worker, which keeps the archive open.
+	facts map[string][]byte
+}
+
+func openFactArchive(path string) (*factArchive, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	a := &factArchive{r: r, files: make(map[string]*zip.File, len(r.File)), facts: make(map[string][]byte)}
+	for _, f := range r.File {
+		a.files[f.Name] = f
+	}
+	return a, nil
+}
+
+// read returns the serialized facts of a package, or nil if the archive has
+// none for it.
+func (a *factArchive) read(pkgPath string) ([]byte, error) {
+	if facts, ok := a.facts[pkgPath]; ok {
+		return facts, nil
+	}
+	f, ok := a.files[pkgPath]
+	if !ok {
+		return nil, nil
+	}
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	facts, err := decodeFactFile(data)
+	if err != nil {
+		return nil, err
+	}
+	a.facts[pkgPath] = facts
+	return facts, nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFactFileRoundTrip(t *testing.T) {
+	facts := bytes.Repeat([]byte("serialized facts "), 100)
+	for _, codec := range []string{"", factCodecNone, factCodecGzip} {
+		data, err := encodeFactFile(facts, codec)
+		if err != nil {
+			t.Fatalf("%q: %v", codec, err)
+		}
+		if !bytes.HasPrefix(data, []byte(factFileMagic)) {
+			t.Errorf("%q: fact file doesn't start with the envelope magic", codec)
+		}
+		if codec == factCodecGzip && len(data) >= len(facts) {
+			t.Errorf("%q: fact file has %d bytes, expected fewer than the %d bytes of facts", codec, len(data), len(facts))
+		}
+		got, err := decodeFactFile(data)
+		if err != nil {
+			t.Fatalf("%q: %v", codec, err)
+		}
+		if !bytes.Equal(got, facts) {
+			t.Errorf("%q: got facts %q, want %q", codec, got, facts)
+		}
+	}
+}
+
+func TestDecodeFactFile(t *testing.T) {
+	// Fact files without the envelope are read as they are.
+	for _, data := range [][]byte{nil, []byte("legacy gob facts")} {
+		got, err := decodeFactFile(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("got facts %q, want %q", got, data)
+		}
+	}
+
+	for _, tt := range []struct {
+		desc string
+		data string
+	}{
+		{"truncated", factFileMagic + "\x01"},
+		{"unknown version", factFileMagic + "\x02\x00facts"},
+		{"unknown codec", factFileMagic + "\x01\x07facts"},
+		{"corrupt gzip", factFileMagic + "\x01\x01facts"},
+	} {
+		if _, err := decodeFactFile([]byte(tt.data)); err == nil {
+			t.Errorf("%s: expected an error", tt.desc)
+		}
+	}
+}
+
+func TestEncodeFactFileNoFacts(t *testing.T) {
+	data, err := encodeFactFile(nil, factCodecGzip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 0 {
+		t.Errorf("got %d bytes for no facts, want an empty file", len(data))
+	}
+}
+
+func TestFactArchive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stdlib_facts.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	facts := []byte("fmt facts")
+	data, err := encodeFactFile(facts, factCodecGzip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := w.Create("fmt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := entry.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := openFactArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := a.read("fmt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, facts) {
+		t.Errorf("got facts %q, want %q", got, facts)
+	}
+	// Packages that are not in the archive have no facts.
+	if got, err := a.read("os"); err != nil || got != nil {
+		t.Errorf("got facts %q and error %v for a package that is not in the archive", got, err)
+	}
+}
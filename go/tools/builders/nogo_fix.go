@@ -2,16 +2,24 @@ package main
 
 import (
 	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"go/token"
 	"io"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"unicode/utf8"
 
-	"github.com/pmezard/go-difflib/difflib"
 	"golang.org/x/tools/go/analysis"
 )
 
@@ -19,6 +27,320 @@ import (
 type diagnosticEntry struct {
 	analysis.Diagnostic
 	analyzerName string
+	// fixOnly is set for diagnostics that are not reported because the
+	// config of the analyzer disables them, but whose fixes are applied.
+	fixOnly bool
+	// excludedFixFiles are the files outside the scope of the config that the
+	// dropped suggested fixes of the diagnostic would edit.
+	excludedFixFiles []string
+	// remappedFixFiles are the files with //line directives that the dropped
+	// suggested fixes of the diagnostic would edit, see excludeRemappedFixes.
+	remappedFixFiles []string
+	// splitRuneFixFiles are the files that the dropped suggested fixes of the
+	// diagnostic would edit in the middle of a character, see
+	// excludeSplitRuneFixes.
+	splitRuneFixFiles []string
+	// severity is the configured severity of the findings of the analyzer,
+	// one of the severity constants. Empty means severityError.
+	severity string
+}
+
+// findingSeverity returns the severity of the diagnostic.
+func (d diagnosticEntry) findingSeverity() string {
+	if d.severity == "" {
+		return severityError
+	}
+	return d.severity
+}
+
+// A fileScope selects files by name like the only_files and exclude_files
+// keys of a config: a file is in scope if it matches one of the only patterns,
+// or there are none, and none of the exclude patterns.
+type fileScope struct {
+	only, exclude []*regexp.Regexp
+}
+
+// contains reports whether the file is in the scope.
+func (s fileScope) contains(name string) bool {
+	if len(s.only) > 0 {
+		matched := false
+		for _, pattern := range s.only {
+			if pattern.MatchString(name) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for _, pattern := range s.exclude {
+		if pattern.MatchString(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// testFilePattern matches the names of test files, of both the internal and
+// the external test package.
+var testFilePattern = regexp.MustCompile(`_test\.go$`)
+
+// withoutTests returns the scope without test files.
+func (s fileScope) withoutTests() fileScope {
+	exclude := append(append([]*regexp.Regexp{}, s.exclude...), testFilePattern)
+	return fileScope{only: s.only, exclude: exclude}
+}
+
+// excludeFixes drops the suggested fixes of the diagnostic that edit a file
+// whose name, as returned by fileName, is outside one of the scopes. It
+// returns the names of these files in the order they were found.
+func excludeFixes(d *analysis.Diagnostic, scopes []fileScope, fileName func(token.Pos) string) []string {
+	var restricted []fileScope
+	for _, scope := range scopes {
+		if len(scope.only) > 0 || len(scope.exclude) > 0 {
+			restricted = append(restricted, scope)
+		}
+	}
+	if len(restricted) == 0 || len(d.SuggestedFixes) == 0 {
+		return nil
+	}
+	var kept []analysis.SuggestedFix
+	var excluded []string
+	seen := make(map[string]bool)
+	for _, fix := range d.SuggestedFixes {
+		keep := true
+		for _, edit := range fix.TextEdits {
+			name := fileName(edit.Pos)
+			for _, scope := range restricted {
+				if !scope.contains(name) {
+					keep = false
+					if !seen[name] {
+						seen[name] = true
+						excluded = append(excluded, name)
+					}
+					break
+				}
+			}
+		}
+		if keep {
+			kept = append(kept, fix)
+		}
+	}
+	d.SuggestedFixes = kept
+	return excluded
+}
+
+// excludeRemappedFixes drops the suggested fixes of the diagnostic that edit
+// code remapped by //line directives, such as code generated from another
+// source. Positions in such code, and thus the diagnostics in the log, refer
+// to the source, while the edits would change the generated file, which can't
+// be patched in the workspace. It returns the names of the generated files, as
+// returned by rawFileName, in the order they were found.
+func excludeRemappedFixes(d *analysis.Diagnostic, fset *token.FileSet, rawFileName func(token.Pos) string) []string {
+	remapped := func(pos token.Pos) bool {
+		raw, adjusted := fset.PositionFor(pos, false), fset.PositionFor(pos, true)
+		return raw.Filename != adjusted.Filename || raw.Line != adjusted.Line || raw.Column != adjusted.Column
+	}
+	var kept []analysis.SuggestedFix
+	var excluded []string
+	seen := make(map[string]bool)
+	for _, fix := range d.SuggestedFixes {
+		keep := true
+		for _, edit := range fix.TextEdits {
+			if !remapped(edit.Pos) && (!edit.End.IsValid() || !remapped(edit.End)) {
+				continue
+			}
+			keep = false
+			if name := rawFileName(edit.Pos); !seen[name] {
+				seen[name] = true
+				excluded = append(excluded, name)
+			}
+		}
+		if keep {
+			kept = append(kept, fix)
+		}
+	}
+	d.SuggestedFixes = kept
+	return excluded
+}
+
+// insideRune reports whether offset is inside the UTF-8 encoding of a
+// character in content. Bytes that are not valid UTF-8, which Go allows in
+// comments and string literals, are characters of their own.
+func insideRune(content []byte, offset int) bool {
+	if offset <= 0 || offset >= len(content) || utf8.RuneStart(content[offset]) {
+		return false
+	}
+	for start := offset - 1; start >= 0 && start > offset-utf8.UTFMax; start-- {
+		if utf8.RuneStart(content[start]) {
+			r, size := utf8.DecodeRune(content[start:])
+			return (r != utf8.RuneError || size > 1) && start+size > offset
+		}
+	}
+	return false
+}
+
+// byteOrderMark is the UTF-8 encoding of U+FEFF, which Go source files may
+// start with.
+const byteOrderMark = "\uFEFF"
+
+// skipByteOrderMark moves the insertions of the suggested fixes of the
+// diagnostic at the start of a file that starts with a byte order mark behind
+// it. Analyzers insert text at the start of the file, e.g. a copyright header,
+// without regard to the byte order mark, which the compiler rejects anywhere
+// but at the start. Files whose contents are not in src are not checked.
+func skipByteOrderMark(d *analysis.Diagnostic, fset *token.FileSet, src map[string][]byte) {
+	var fixes []analysis.SuggestedFix
+	for i, fix := range d.SuggestedFixes {
+		var edits []analysis.TextEdit
+		for j, edit := range fix.TextEdits {
+			file := fset.File(edit.Pos)
+			if file == nil || int(edit.Pos) != file.Base() || (edit.End.IsValid() && edit.End != edit.Pos) {
+				continue
+			}
+			if !bytes.HasPrefix(src[file.Name()], []byte(byteOrderMark)) || bytes.HasPrefix(edit.NewText, []byte(byteOrderMark)) {
+				continue
+			}
+			if edits == nil {
+				// The fixes may be shared with the analyzer.
+				edits = append([]analysis.TextEdit(nil), fix.TextEdits...)
+			}
+			edits[j].Pos += token.Pos(len(byteOrderMark))
+			edits[j].End = edits[j].Pos
+		}
+		if edits == nil {
+			continue
+		}
+		if fixes == nil {
+			fixes = append([]analysis.SuggestedFix(nil), d.SuggestedFixes...)
+		}
+		fixes[i].TextEdits = edits
+	}
+	if fixes != nil {
+		d.SuggestedFixes = fixes
+	}
+}
+
+// excludeSplitRuneFixes drops the suggested fixes of the diagnostic with an
+// edit that starts or ends inside the UTF-8 encoding of a character, e.g.
+// because the analyzer counted runes rather than bytes, which would leave
+// invalid source in the patch. Files whose contents are not in src are not
+// checked. It returns the names of the files, as returned by rawFileName, in
+// the order they were found.
+func excludeSplitRuneFixes(d *analysis.Diagnostic, fset *token.FileSet, src map[string][]byte, rawFileName func(token.Pos) string) []string {
+	splitsRune := func(pos token.Pos) bool {
+		file := fset.File(pos)
+		if file == nil {
+			return false
+		}
+		content, ok := src[file.Name()]
+		return ok && insideRune(content, int(pos)-file.Base())
+	}
+	var kept []analysis.SuggestedFix
+	var excluded []string
+	seen := make(map[string]bool)
+	for _, fix := range d.SuggestedFixes {
+		keep := true
+		for _, edit := range fix.TextEdits {
+			if !splitsRune(edit.Pos) && (!edit.End.IsValid() || !splitsRune(edit.End)) {
+				continue
+			}
+			keep = false
+			if name := rawFileName(edit.Pos); !seen[name] {
+				seen[name] = true
+				excluded = append(excluded, name)
+			}
+		}
+		if keep {
+			kept = append(kept, fix)
+		}
+	}
+	d.SuggestedFixes = kept
+	return excluded
+}
+
+// dedupeDiagnostics drops the diagnostics that report the same issue at the
+// same position as another diagnostic, e.g. from a vet analyzer and a clone
+// of it, so that the issue is reported once and its fixes don't conflict.
+// Messages are compared ignoring case, whitespace and a trailing period. Of
+// the duplicates, the one that is reported rather than only fixed, then the
+// one with fixes, then the one of the analyzer with the first name is kept.
+// Diagnostics without a position, such as analyzer failures, are always kept.
+func dedupeDiagnostics(diagnostics []diagnosticEntry) []diagnosticEntry {
+	type key struct {
+		pos     token.Pos
+		message string
+	}
+	better := func(a, b diagnosticEntry) bool {
+		if a.fixOnly != b.fixOnly {
+			return !a.fixOnly
+		}
+		if fa, fb := hasEdits(a.Diagnostic), hasEdits(b.Diagnostic); fa != fb {
+			return fa
+		}
+		return a.analyzerName < b.analyzerName
+	}
+	kept := make(map[key]int)
+	var deduped []diagnosticEntry
+	for _, d := range diagnostics {
+		if !d.Pos.IsValid() {
+			deduped = append(deduped, d)
+			continue
+		}
+		k := key{d.Pos, normalizeMessage(d.Message)}
+		i, ok := kept[k]
+		if !ok {
+			kept[k] = len(deduped)
+			deduped = append(deduped, d)
+		} else if better(d, deduped[i]) {
+			deduped[i] = d
+		}
+	}
+	return deduped
+}
+
+// limitDiagnostics returns the first max diagnostics and the number of the
+// others, which are suppressed. A max of zero means no limit. Since the
+// diagnostics are sorted, the same ones are kept when the package is analyzed
+// again.
+func limitDiagnostics(diagnostics []diagnosticEntry, max int) ([]diagnosticEntry, int) {
+	if max == 0 || len(diagnostics) <= max {
+		return diagnostics, 0
+	}
+	return diagnostics[:max], len(diagnostics) - max
+}
+
+// sortDiagnostics orders the diagnostics by file, offset and analyzer, then by
+// end and message, so that the log and the other outputs of nogo don't depend
+// on the order in which the analyzers ran or reported their diagnostics.
+// Diagnostics without a position, such as analyzer failures, come first. Files
+// are compared by their names ignoring //line directives.
+func sortDiagnostics(diagnostics []diagnosticEntry, fset *token.FileSet) {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i], diagnostics[j]
+		if a.Pos.IsValid() != b.Pos.IsValid() {
+			return !a.Pos.IsValid()
+		}
+		pa, pb := fset.PositionFor(a.Pos, false), fset.PositionFor(b.Pos, false)
+		if pa.Filename != pb.Filename {
+			return pa.Filename < pb.Filename
+		}
+		if pa.Offset != pb.Offset {
+			return pa.Offset < pb.Offset
+		}
+		if a.analyzerName != b.analyzerName {
+			return a.analyzerName < b.analyzerName
+		}
+		if a.End != b.End {
+			return a.End < b.End
+		}
+		return a.Message < b.Message
+	})
+}
+
+func normalizeMessage(message string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.Join(strings.Fields(message), " ")), ".")
 }
 
 // A nogoEdit describes the replacement of a portion of a text file.
@@ -27,11 +349,20 @@ type nogoEdit struct {
 	Start int    // starting byte offset of the region to replace
 	End   int    // (exclusive) ending byte offset of the region to replace
 	analyzerName string
+	// parts are the edits suggested by the analyzer that this edit applies
+	// if it differs from them, e.g. after composeEdits merged them.
+	parts []nogoEdit
 }
 
 type fileChange struct {
 	fileName string
 	changes []nogoEdit
+	// comments are written before the diff of the file, see addFixProvenance.
+	comments []string
+	// metadata are the metadata lines written after the comments, see
+	// addFixMetadata. If there are any, the digest of the content of the file
+	// is added to them when the diff is written.
+	metadata []string
 }
 
 func (e nogoEdit) String() string {
@@ -39,7 +370,12 @@ func (e nogoEdit) String() string {
 }
 
 func (e nogoEdit) Equals(other nogoEdit) bool {
-	return e.Start == other.Start && e.End == other.End && e.New == other.New
+	return e.offsetEdit() == other.offsetEdit()
+}
+
+// offsetEdit returns the edit without its analyzer, as in the fix file.
+func (e nogoEdit) offsetEdit() offsetEdit {
+	return offsetEdit{New: e.New, Start: e.Start, End: e.End}
 }
 
 // byStartEnd orders a slice of nogoEdits by (start, end) offset.
@@ -81,14 +417,41 @@ func applyEdits(src []byte, edits []nogoEdit) []byte {
 	return out
 }
 
+// A fixStrategy selects how suggested fixes that overlap with previously
+// selected fixes are resolved.
+type fixStrategy struct {
+	// conflicts is one of the fixConflicts constants.
+	conflicts string
+	// priority returns the priority of the fixes of an analyzer for
+	// fixConflictsPriority. Fixes with a higher priority are selected first.
+	priority func(analyzerName string) int
+}
+
 // getFixes merges the suggested fixes from all analyzers, returns one fileChange object per file,
 // while reporting conflicts as error.
 func getFixes(entries []diagnosticEntry, fileSet *token.FileSet) ([]fileChange, error) {
+	return getFixesWithStrategy(entries, fileSet, fixStrategy{conflicts: fixConflictsFirst})
+}
+
+// getFixesWithStrategy is like getFixes, but resolves conflicts between fixes
+// according to strategy.
+func getFixesWithStrategy(entries []diagnosticEntry, fileSet *token.FileSet, strategy fixStrategy) ([]fileChange, error) {
 	var allErrors []error
 	finalChanges := make(map[string][]nogoEdit)
 
+	if strategy.conflicts == fixConflictsPriority && strategy.priority != nil {
+		// Consider the fixes of higher priority analyzers first, keeping the
+		// order of the diagnostics of analyzers with the same priority.
+		sorted := make([]diagnosticEntry, len(entries))
+		copy(sorted, entries)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return strategy.priority(sorted[i].analyzerName) > strategy.priority(sorted[j].analyzerName)
+		})
+		entries = sorted
+	}
+
 	for _, entry := range entries {
-		if len(entry.Diagnostic.SuggestedFixes) == 0 {
+		if !hasEdits(entry.Diagnostic) {
 			continue
 		}
 		// According to the [doc](https://pkg.go.dev/golang.org/x/tools@v0.28.0/go/analysis#Diagnostic),
@@ -100,33 +463,34 @@ func getFixes(entries []diagnosticEntry, fileSet *token.FileSet) ([]fileChange,
 		foundApplicableFix := false
 		var perAnalyzerErrors []error
 		for _, sf := range entry.Diagnostic.SuggestedFixes {
+			if len(sf.TextEdits) == 0 {
+				// Fixes without edits are only hints, see fixHints.
+				continue
+			}
 			candidateChanges := make(map[string][]nogoEdit)
 			applicable := true
 			for _, edit := range sf.TextEdits {
-				start, end := edit.Pos, edit.End
-				if !end.IsValid() {
-					end = start
-				}
-
-				file := fileSet.File(start)
-				if file == nil {
+				fileName, fix, ok := newNogoEdit(edit, entry.analyzerName, fileSet)
+				if !ok {
 					// missing file info, most likely due to analyzer bug.
 					applicable = false
 					break
 				}
-
-				fix := nogoEdit{
-					Start: file.Offset(start),
-					End: file.Offset(end),
-					New: string(edit.NewText),
-					analyzerName: entry.analyzerName,
-				}
-				candidateChanges[file.Name()] = append(candidateChanges[file.Name()], fix)
+				candidateChanges[fileName] = append(candidateChanges[fileName], fix)
 			}
 			// validating the edits from current SuggestedFix. All edits from a SuggestedFix must be
 			// either accepted or discarded atomically, because a SuggestedFix may move a statement from one place
 			// to the other. If we only accept part of the edits, the statement may either appear twice or disappear.
-			for fileName, edits := range candidateChanges {
+			for _, fileName := range sortedFileNames(candidateChanges) {
+				edits := candidateChanges[fileName]
+				// A fix whose own edits overlap is broken regardless of the other
+				// fixes. Only this fix is skipped, the analyzer's alternative fixes
+				// and its fixes for other diagnostics are still considered.
+				if _, err := validate(edits); err != nil {
+					applicable = false
+					perAnalyzerErrors = append(perAnalyzerErrors, fmt.Errorf("invalid suggested fix %q: %v", sf.Message, err))
+					break
+				}
 				edits = append(edits, finalChanges[fileName]...)
 				var err error
 
@@ -146,6 +510,17 @@ func getFixes(entries []diagnosticEntry, fileSet *token.FileSet) ([]fileChange,
 			}
 			// Move on to the next SuggestedFix of the same Diagnostic if any edit of the current SuggestedFix has issues.
 		}
+		if !foundApplicableFix && strategy.conflicts == fixConflictsPartial {
+			kept, total := applyPartialFix(entry, fileSet, finalChanges)
+			if kept > 0 {
+				allErrors = append(allErrors, fmt.Errorf(
+					"applying only %d of %d edits of the suggested fix from analyzer %q at %s because:\n\t%s",
+					kept, total, entry.analyzerName, fileSet.Position(entry.Pos),
+					strings.Join(formatErrors(perAnalyzerErrors), "\n\t"),
+				))
+				continue
+			}
+		}
 		if !foundApplicableFix {
 			allErrors = append(allErrors, fmt.Errorf(
 				"ignoring suggested fixes from analyzer %q at %s because:\n\t%s",
@@ -156,8 +531,8 @@ func getFixes(entries []diagnosticEntry, fileSet *token.FileSet) ([]fileChange,
 	}
 
 	var finalFileChanges []fileChange
-	for fileName, edits := range finalChanges {
-		finalFileChanges = append(finalFileChanges, fileChange{fileName: fileName, changes: edits})
+	for _, fileName := range sortedFileNames(finalChanges) {
+		finalFileChanges = append(finalFileChanges, fileChange{fileName: fileName, changes: composeEdits(finalChanges[fileName])})
 	}
 
 	if len(allErrors) == 0 {
@@ -172,6 +547,312 @@ func getFixes(entries []diagnosticEntry, fileSet *token.FileSet) ([]fileChange,
 	return finalFileChanges, errors.New(errMsg.String())
 }
 
+// sortedFileNames returns the names of the files with edits in order, so that
+// fixes are validated and returned in the same order in every run.
+func sortedFileNames(changes map[string][]nogoEdit) []string {
+	names := make([]string, 0, len(changes))
+	for name := range changes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newNogoEdit converts a text edit of a suggested fix. It returns false if the
+// file of the edit is unknown.
+func newNogoEdit(edit analysis.TextEdit, analyzerName string, fileSet *token.FileSet) (string, nogoEdit, bool) {
+	start, end := edit.Pos, edit.End
+	if !end.IsValid() {
+		end = start
+	}
+	file := fileSet.File(start)
+	if file == nil {
+		return "", nogoEdit{}, false
+	}
+	return file.Name(), nogoEdit{
+		Start:        file.Offset(start),
+		End:          file.Offset(end),
+		New:          string(edit.NewText),
+		analyzerName: analyzerName,
+	}, true
+}
+
+// hasEdits reports whether any suggested fix of the diagnostic has edits.
+func hasEdits(d analysis.Diagnostic) bool {
+	for _, sf := range d.SuggestedFixes {
+		if len(sf.TextEdits) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// fixHints returns the messages of the suggested fixes of the diagnostic that
+// have no edits. Such fixes can't be applied, but still tell the user what
+// the analyzer proposes.
+func fixHints(d analysis.Diagnostic) []string {
+	var hints []string
+	for _, sf := range d.SuggestedFixes {
+		if len(sf.TextEdits) == 0 && sf.Message != "" {
+			hints = append(hints, sf.Message)
+		}
+	}
+	return hints
+}
+
+// relatedLines returns the related information of the diagnostic, e.g. where
+// an identifier it reports is declared, formatted like the diagnostic itself
+// with the position followed by the message.
+func relatedLines(d analysis.Diagnostic, fset *token.FileSet) []string {
+	var lines []string
+	for _, rel := range d.Related {
+		if pos := fset.Position(rel.Pos); pos.IsValid() {
+			lines = append(lines, fmt.Sprintf("%s: %s", pos, rel.Message))
+		} else {
+			lines = append(lines, rel.Message)
+		}
+	}
+	return lines
+}
+
+// addFixProvenance adds a comment to the changes of each file for every
+// diagnostic whose fix contributed edits to it, naming the position, message
+// and analyzer of the diagnostic. Tools applying the patch ignore the comments,
+// but they tell reviewers where each change comes from.
+func addFixProvenance(changes []fileChange, entries []diagnosticEntry, fileSet *token.FileSet, paths patchPaths) {
+	forEachFixedFile(changes, entries, fileSet, func(c *fileChange, entry diagnosticEntry) {
+		c.comments = append(c.comments, provenanceComment(entry, fileSet, paths))
+	})
+}
+
+// addFixMetadata adds the metadata lines to the changes of each file that a
+// fix of the entries contributed edits to: the label of the target, unless it
+// is empty, followed by the analyzer and the message of each diagnostic.
+// nogo_apply uses the metadata to check that the file hasn't changed since the
+// patch was generated.
+func addFixMetadata(changes []fileChange, entries []diagnosticEntry, fileSet *token.FileSet, target string) {
+	forEachFixedFile(changes, entries, fileSet, func(c *fileChange, entry diagnosticEntry) {
+		if len(c.metadata) == 0 && target != "" {
+			c.metadata = append(c.metadata, formatPatchMetadata(metadataTarget, target))
+		}
+		message := strings.Join(strings.Fields(entry.Message), " ")
+		c.metadata = append(c.metadata, formatPatchMetadata(metadataAnalyzer, entry.analyzerName, metadataMessage, message))
+	})
+}
+
+// forEachFixedFile calls f for the changes of each file and each entry whose
+// suggested fixes contributed edits to it, in the order of the entries.
+func forEachFixedFile(changes []fileChange, entries []diagnosticEntry, fileSet *token.FileSet, f func(c *fileChange, entry diagnosticEntry)) {
+	index := make(map[string]int)
+	for i, c := range changes {
+		index[c.fileName] = i
+	}
+	for _, entry := range entries {
+		visited := make(map[string]bool)
+		for _, sf := range entry.SuggestedFixes {
+			for _, edit := range sf.TextEdits {
+				fileName, e, ok := newNogoEdit(edit, entry.analyzerName, fileSet)
+				if !ok || visited[fileName] {
+					continue
+				}
+				i, ok := index[fileName]
+				if !ok || !containsEdit(changes[i].changes, e) {
+					continue
+				}
+				visited[fileName] = true
+				f(&changes[i], entry)
+			}
+		}
+	}
+}
+
+func containsEdit(edits []nogoEdit, e nogoEdit) bool {
+	for _, other := range edits {
+		if other.Equals(e) {
+			return true
+		}
+		for _, part := range other.parts {
+			if part.Equals(e) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// provenanceComment describes the diagnostic on a single patch comment line.
+func provenanceComment(entry diagnosticEntry, fileSet *token.FileSet, paths patchPaths) string {
+	message := strings.Join(strings.Fields(entry.Message), " ")
+	p := fileSet.Position(entry.Pos)
+	if !p.IsValid() {
+		return fmt.Sprintf("# %s (%s)", message, entry.analyzerName)
+	}
+	return fmt.Sprintf("# %s:%d:%d: %s (%s)", filepath.ToSlash(paths.path(p.Filename)), p.Line, p.Column, message, entry.analyzerName)
+}
+
+// applyPartialFix adds the edits of the first suggested fix of the entry with
+// edits that don't overlap with the selected changes, for fixConflictsPartial. Unlike the
+// fixes selected by getFixes, the result may not compile, e.g. if the fix
+// moves code. It returns the number of edits kept and the total number of
+// edits of the fix.
+func applyPartialFix(entry diagnosticEntry, fileSet *token.FileSet, finalChanges map[string][]nogoEdit) (int, int) {
+	var sf analysis.SuggestedFix
+	for _, sf = range entry.Diagnostic.SuggestedFixes {
+		if len(sf.TextEdits) > 0 {
+			break
+		}
+	}
+	kept := 0
+	for _, edit := range sf.TextEdits {
+		fileName, fix, ok := newNogoEdit(edit, entry.analyzerName, fileSet)
+		if !ok {
+			continue
+		}
+		edits, err := validate(append([]nogoEdit{fix}, finalChanges[fileName]...))
+		if err != nil {
+			continue
+		}
+		finalChanges[fileName] = edits
+		kept++
+	}
+	return kept, len(sf.TextEdits)
+}
+
+// composeEdits merges each run of abutting edits of the same analyzer, where
+// one edit ends at the offset the next one starts, into a single edit, so
+// that analyzers suggesting many small insertions and deletions produce fewer,
+// larger edits. The edits must be sorted and non-overlapping, as returned by
+// validate.
+func composeEdits(edits []nogoEdit) []nogoEdit {
+	var composed []nogoEdit
+	for _, e := range edits {
+		if n := len(composed); n > 0 && composed[n-1].End == e.Start && composed[n-1].analyzerName == e.analyzerName {
+			prev := &composed[n-1]
+			if prev.parts == nil {
+				prev.parts = []nogoEdit{*prev}
+			}
+			prev.parts = append(prev.parts, e)
+			prev.New += e.New
+			prev.End = e.End
+			continue
+		}
+		composed = append(composed, e)
+	}
+	return composed
+}
+
+// minimizeFixes drops the edits of the changes that replace text with
+// identical text and trims the text that an edit leaves unchanged at its
+// start and end, so that the patch and the fix file only contain real
+// changes. Analyzers insert lines ending with "\n", which are converted to
+// "\r\n" in files whose lines mostly end with it first. src holds the contents
+// of the files by name; the edits of other files are kept as they are. Files
+// left without edits are dropped.
+func minimizeFixes(changes []fileChange, src map[string][]byte) []fileChange {
+	var minimized []fileChange
+	for _, c := range changes {
+		content, ok := src[c.fileName]
+		if !ok {
+			minimized = append(minimized, c)
+			continue
+		}
+		crlf := usesCRLF(content)
+		var edits []nogoEdit
+		for _, e := range c.changes {
+			if e.End > len(content) {
+				edits = append(edits, e)
+				continue
+			}
+			if crlf {
+				e = withCRLF(content, e)
+			}
+			if m, changed := minimizeEdit(content, e); changed {
+				edits = append(edits, m)
+			}
+		}
+		if len(edits) > 0 {
+			c.changes = edits
+			minimized = append(minimized, c)
+		}
+	}
+	return minimized
+}
+
+// usesCRLF reports whether most lines of content end with "\r\n".
+func usesCRLF(content []byte) bool {
+	lines := bytes.Count(content, []byte("\n"))
+	crlf := bytes.Count(content, []byte("\r\n"))
+	return crlf > lines-crlf
+}
+
+// withCRLF returns the edit with the line endings "\n" of its new text
+// replaced with "\r\n". A "\n" at the start of the new text completes a "\r"
+// before the edit in content.
+func withCRLF(content []byte, e nogoEdit) nogoEdit {
+	if !strings.Contains(e.New, "\n") {
+		return e
+	}
+	var b strings.Builder
+	prev := byte(0)
+	if e.Start > 0 {
+		prev = content[e.Start-1]
+	}
+	for i := 0; i < len(e.New); i++ {
+		if e.New[i] == '\n' && prev != '\r' {
+			b.WriteByte('\r')
+		}
+		prev = e.New[i]
+		b.WriteByte(prev)
+	}
+	if b.Len() == len(e.New) {
+		return e
+	}
+	m := e
+	m.New = b.String()
+	if m.parts == nil {
+		m.parts = []nogoEdit{e}
+	}
+	return m
+}
+
+// minimizeEdit trims the text that the edit leaves unchanged in content at its
+// start and end, without splitting a character. It returns false if the edit
+// doesn't change anything.
+func minimizeEdit(content []byte, e nogoEdit) (nogoEdit, bool) {
+	old := string(content[e.Start:e.End])
+	if old == e.New {
+		return nogoEdit{}, false
+	}
+	// boundary reports whether i is at the start of a character in both texts.
+	boundary := func(i, j int) bool {
+		return (i == len(old) || utf8.RuneStart(old[i])) && (j == len(e.New) || utf8.RuneStart(e.New[j]))
+	}
+	prefix := 0
+	for prefix < len(old) && prefix < len(e.New) && old[prefix] == e.New[prefix] {
+		prefix++
+	}
+	for prefix > 0 && !boundary(prefix, prefix) {
+		prefix--
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(e.New)-prefix && old[len(old)-1-suffix] == e.New[len(e.New)-1-suffix] {
+		suffix++
+	}
+	for suffix > 0 && !boundary(len(old)-suffix, len(e.New)-suffix) {
+		suffix--
+	}
+	if prefix == 0 && suffix == 0 {
+		return e, true
+	}
+	m := e
+	m.Start += prefix
+	m.End -= suffix
+	m.New = e.New[prefix : len(e.New)-suffix]
+	if m.parts == nil {
+		m.parts = []nogoEdit{e}
+	}
+	return m, true
+}
 
 // validate whether the list of edits has overlaps or contains invalid ones.
 // If there is any issue, an error is returned. Otherwise, the function
@@ -191,12 +872,11 @@ func validate(edits []nogoEdit) ([]nogoEdit, error) {
 		}
 		if i > 0 {
 			prev := validatedEdits[i-1]
-			if prev.Equals(cur) {
+			switch compareEdits(prev.offsetEdit(), cur.offsetEdit()) {
+			case editsIdentical:
 				// equivalent ones are safely skipped
 				continue
-			}
-
-			if prev.End > cur.Start {
+			case editsOverlapping:
 				return nil, fmt.Errorf("overlapping suggestions from %q and %q at %s and %s",
 					prev.analyzerName, cur.analyzerName, prev, cur)
 			}
@@ -208,40 +888,542 @@ func validate(edits []nogoEdit) ([]nogoEdit, error) {
 }
 
 
-func writePatch(patchFile io.Writer, changes []fileChange) error {
+// patchBaseDir returns the execroot-relative directory the paths in the patch
+// are made relative to, given the root configured on the nogo target.
+func patchBaseDir(root, workspaceRoot, packageDir string) string {
+	switch root {
+	case patchRootWorkspace:
+		return workspaceRoot
+	case patchRootPackage:
+		return packageDir
+	default:
+		return ""
+	}
+}
+
+// patchPaths determines the paths of the files in a patch.
+type patchPaths struct {
+	// execroot is the directory the relative file names are relative to. If
+	// empty, absolute file names are kept as is.
+	execroot string
+	// baseDir is the execroot-relative directory the paths in the patch are
+	// relative to, see patchBaseDir.
+	baseDir string
+	// prefixes are the prefixes of the file names in the patch. If nil, they
+	// are a/ and b/, and the strip level is not recorded in the patch.
+	prefixes *patchPrefixes
+}
+
+// patchPrefixes are the prefixes of the old and the new file names in a patch,
+// like the --src-prefix and --dst-prefix options of git diff, and the number of
+// leading path components that tools applying the patch strip from the names.
+type patchPrefixes struct {
+	src, dst string
+	strip    int
+}
+
+// names returns the old and the new name of the file in the patch.
+func (p patchPaths) names(fileName string) (string, string) {
+	// Patches use forward slashes on all platforms.
+	name := filepath.ToSlash(p.path(fileName))
+	if p.prefixes == nil {
+		return "a/" + name, "b/" + name
+	}
+	return p.prefixes.src + name, p.prefixes.dst + name
+}
+
+// path returns the path of the file relative to the base directory. Files
+// outside of it, such as generated files, keep their execroot-relative path.
+// Absolute file names are made relative to the execroot first, so that the
+// patch doesn't depend on the directory the action ran in, e.g. a sandbox.
+func (p patchPaths) path(fileName string) string {
+	if filepath.IsAbs(fileName) {
+		if p.execroot == "" {
+			return fileName
+		}
+		rel, ok := relPath(p.execroot, fileName)
+		if !ok {
+			return fileName
+		}
+		fileName = rel
+	}
+	if p.baseDir == "" {
+		return fileName
+	}
+	if rel, ok := relPath(p.baseDir, fileName); ok {
+		return rel
+	}
+	return fileName
+}
+
+// relPath returns the path of name relative to dir if name is inside of dir.
+func relPath(dir, name string) (string, bool) {
+	rel, err := filepath.Rel(dir, name)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+// patchMemoryBudget bounds the approximate memory used by the files that are
+// diffed concurrently by writePatch.
+const patchMemoryBudget = 512 << 20
+
+// A fixFormatter returns the content of a file after applying fixes to it,
+// given its original content and the content with the fixes applied.
+type fixFormatter func(original, fixed []byte) []byte
+
+// writePatch writes a unified diff for the changes to patchFile with the given
+// number of context lines. The paths in the diff are determined by paths. If format is not nil, the
+// fixed files are passed through it before they are diffed. src holds the
+// contents of the files by name as the analyzers saw them, which the diff is
+// computed against; other files are read from disk.
+func writePatch(patchFile io.Writer, changes []fileChange, src map[string][]byte, paths patchPaths, format fixFormatter, context int) error {
+	_, err := writePatchWithBudget(patchFile, changes, src, paths, format, context, patchMemoryBudget, runtime.GOMAXPROCS(0), 0)
+	return err
+}
+
+// A fixFileWriter writes a fix file, compressing it with gzip if that is the
+// configured compression. Nothing is written to the underlying writer before
+// the first non-empty write, so that fix files without fixes stay empty.
+type fixFileWriter struct {
+	w    io.Writer
+	gzip bool
+	gz   *gzip.Writer
+}
+
+func newFixFileWriter(w io.Writer, compression string) *fixFileWriter {
+	return &fixFileWriter{w: w, gzip: compression == fixCompressionGzip}
+}
+
+func (f *fixFileWriter) Write(p []byte) (int, error) {
+	if !f.gzip {
+		return f.w.Write(p)
+	}
+	if f.gz == nil {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		f.gz = gzip.NewWriter(f.w)
+	}
+	return f.gz.Write(p)
+}
+
+// Close flushes the compressed content. It doesn't close the underlying
+// writer.
+func (f *fixFileWriter) Close() error {
+	if f.gz == nil {
+		return nil
+	}
+	return f.gz.Close()
+}
+
+// writePatchWithLimit is like writePatch, but leaves out the diffs of the files
+// that would make the patch larger than maxSize bytes. It returns the changes
+// of the files it left out.
+func writePatchWithLimit(patchFile io.Writer, changes []fileChange, src map[string][]byte, paths patchPaths, format fixFormatter, context int, maxSize int64) ([]fileChange, error) {
+	return writePatchWithBudget(patchFile, changes, src, paths, format, context, patchMemoryBudget, runtime.GOMAXPROCS(0), maxSize)
+}
+
+// writePatchWithBudget diffs the files concurrently, with at most jobs files
+// being read, fixed and diffed and at most budget bytes of sources and diffs
+// in memory at any time. The diffs are written in the order of the file names,
+// so the patch doesn't depend on the scheduling. If maxSize is positive, the
+// diffs that don't fit into maxSize bytes are left out and their changes are
+// returned.
+func writePatchWithBudget(patchFile io.Writer, changes []fileChange, src map[string][]byte, paths patchPaths, format fixFormatter, context int, budget int64, jobs int, maxSize int64) ([]fileChange, error) {
 	// sort the changes by file name to make sure the patch is stable.
 	sort.Slice(changes, func(i, j int) bool {
 		return changes[i].fileName < changes[j].fileName
 	})
 
+	type shard struct {
+		change fileChange
+		weight int64
+		diff   []byte
+		err    error
+		done   chan struct{}
+	}
+	shards := make([]*shard, 0, len(changes))
+	for _, c := range changes {
+		if len(c.changes) > 0 {
+			shards = append(shards, &shard{change: c, done: make(chan struct{})})
+		}
+	}
+
+	// The writer releases the memory of each shard once it has been written.
+	// Since shards are started in order, the next shard to be written is
+	// always running, so this can't deadlock.
+	mem := newMemoryBudget(budget)
+	writeErr := make(chan error, 1)
+	// omitted is only accessed by the writer until it sends to writeErr.
+	var omitted []fileChange
+	go func() {
+		var err error
+		var written int64
+		// The strip level precedes the first diff, so that patches without
+		// changes stay empty.
+		var header string
+		if paths.prefixes != nil {
+			header = formatPatchMetadata(metadataStrip, strconv.Itoa(paths.prefixes.strip)) + "\n"
+		}
+		for _, s := range shards {
+			<-s.done
+			if err == nil {
+				err = s.err
+			}
+			if err == nil && len(s.diff) > 0 {
+				size := int64(len(header) + len(s.diff))
+				if maxSize > 0 && written+size > maxSize {
+					omitted = append(omitted, s.change)
+				} else {
+					if header != "" {
+						_, err = io.WriteString(patchFile, header)
+						header = ""
+					}
+					if err == nil {
+						_, err = patchFile.Write(s.diff)
+					}
+					written += size
+				}
+			}
+			s.diff = nil
+			mem.release(s.weight)
+		}
+		writeErr <- err
+	}()
+
+	if jobs < 1 {
+		jobs = 1
+	}
+	// Unlike memory, a job is released as soon as its diff is computed, so
+	// that the files after a large one don't wait for it to be written.
+	sem := make(chan struct{}, jobs)
+	i := 0
 	for _, c := range changes {
 		if len(c.changes) == 0 {
 			continue
 		}
+		s := shards[i]
+		i++
+		// The source, the fixed source and the diff are in memory at once.
+		var size int64 = 1
+		if content, ok := src[c.fileName]; ok {
+			size += 3 * int64(len(content))
+		} else if info, err := os.Stat(longPath(c.fileName)); err == nil {
+			size += 3 * info.Size()
+		}
+		s.weight = mem.acquire(size)
+		sem <- struct{}{}
+		go func(c fileChange) {
+			defer close(s.done)
+			defer func() { <-sem }()
+			s.diff, s.err = diffFile(c, src, paths, format, context)
+		}(c)
+	}
+	if err := <-writeErr; err != nil {
+		return nil, err
+	}
+	return omitted, nil
+}
 
-		contents, err := os.ReadFile(c.fileName)
-		if err != nil {
-			return fmt.Errorf("failed to read file %s: %v", c.fileName, err)
+// diffFile returns the unified diff for the changes to a single file, whose
+// contents are taken from src if it holds them.
+func diffFile(c fileChange, src map[string][]byte, paths patchPaths, format fixFormatter, context int) ([]byte, error) {
+	contents, ok := src[c.fileName]
+	var err error
+	if !ok {
+		contents, err = os.ReadFile(longPath(c.fileName))
+	}
+	created := false
+	if os.IsNotExist(err) && createsFile(c) {
+		contents, err, created = nil, nil, true
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %v", c.fileName, err)
+	}
+
+	out := fixedContent(contents, c, format)
+
+	fromFile, toFile := paths.names(c.fileName)
+	if created {
+		fromFile = devNull
+	} else if len(out) == 0 && len(contents) > 0 {
+		// A fix that removes all of the content of a file deletes it.
+		toFile = devNull
+	}
+	var diff bytes.Buffer
+	writeUnifiedDiff(&diff, fromFile, toFile, splitDiffLines(contents), splitDiffLines(out), context)
+	if diff.Len() == 0 || len(c.comments) == 0 && len(c.metadata) == 0 {
+		return diff.Bytes(), nil
+	}
+	var buf bytes.Buffer
+	for _, comment := range c.comments {
+		buf.WriteString(comment)
+		buf.WriteByte('\n')
+	}
+	for _, line := range c.metadata {
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	if len(c.metadata) > 0 && !created {
+		buf.WriteString(formatPatchMetadata(metadataSHA256, contentDigest(contents)))
+		buf.WriteByte('\n')
+	}
+	buf.Write(diff.Bytes())
+	return buf.Bytes(), nil
+}
+
+// createsFile reports whether the changes can create the file they edit if it
+// doesn't exist, which is the case if they only insert text at its start.
+// Analyzers create files by adding them to the file set of the pass, e.g.
+// with pass.Fset.AddFile(name, -1, 0), and inserting the content at the
+// position of the empty file.
+func createsFile(c fileChange) bool {
+	for _, e := range c.changes {
+		if e.Start != 0 || e.End != 0 {
+			return false
+		}
+	}
+	return len(c.changes) > 0
+}
+
+// fixedContent returns the content of the file after applying the changes to
+// it and passing it through format, if not nil.
+func fixedContent(contents []byte, c fileChange, format fixFormatter) []byte {
+	// edits are guaranteed to be unique, sorted and non-overlapping
+	// see validate() that is called before this function.
+	out := applyEdits(contents, c.changes)
+	if format != nil {
+		out = format(contents, out)
+	}
+	return out
+}
+
+// newFixFile returns the structured form of the changes. The paths of the
+// files are determined by paths, as in the patch. Unlike the patch, the edits
+// are not formatted.
+func newFixFile(changes []fileChange, paths patchPaths) fixFile {
+	fix := fixFile{Version: fixFileVersion, Files: make(map[string]map[string][]offsetEdit)}
+	for _, c := range changes {
+		if len(c.changes) == 0 {
+			continue
+		}
+		name := filepath.ToSlash(paths.path(c.fileName))
+		analyzerToEdits := fix.Files[name]
+		if analyzerToEdits == nil {
+			analyzerToEdits = make(map[string][]offsetEdit)
+			fix.Files[name] = analyzerToEdits
 		}
+		for _, e := range c.changes {
+			analyzerToEdits[e.analyzerName] = append(analyzerToEdits[e.analyzerName], e.offsetEdit())
+		}
+	}
+	if len(fix.Files) > 0 {
+		fix.Digest = fix.digest()
+	}
+	return fix
+}
 
-		// edits are guaranteed to be unique, sorted and non-overlapping
-		// see validate() that is called before this function.
-		out := applyEdits(contents, c.changes)
+// splitByAnalyzer splits the changes by the analyzer that suggested each
+// edit. The changes of each analyzer are in the order of the files and have
+// no comments.
+func splitByAnalyzer(changes []fileChange) map[string][]fileChange {
+	byAnalyzer := make(map[string][]fileChange)
+	for _, c := range changes {
+		index := make(map[string]int)
+		for _, e := range c.changes {
+			analyzerChanges := byAnalyzer[e.analyzerName]
+			i, ok := index[e.analyzerName]
+			if !ok {
+				i = len(analyzerChanges)
+				index[e.analyzerName] = i
+				analyzerChanges = append(analyzerChanges, fileChange{fileName: c.fileName})
+			}
+			analyzerChanges[i].changes = append(analyzerChanges[i].changes, e)
+			byAnalyzer[e.analyzerName] = analyzerChanges
+		}
+	}
+	return byAnalyzer
+}
+
+// A diagnosticFix is the part of the changes that the selected suggested fix of
+// a single diagnostic contributed, see splitByDiagnostic.
+type diagnosticFix struct {
+	// id identifies the fix across builds, see diagnosticFixID.
+	id      string
+	entry   diagnosticEntry
+	changes []fileChange
+}
 
-		diff := difflib.UnifiedDiff{
-			A:        difflib.SplitLines(string(contents)),
-			B:        difflib.SplitLines(string(out)),
-			FromFile: filepath.Join("a", c.fileName),
-			ToFile:   filepath.Join("b", c.fileName),
-			Context:  3,
+// splitByDiagnostic splits the changes by the diagnostic whose suggested fix
+// contributed the edits. The fix of a diagnostic is its first fix whose edits
+// are all in the changes. The changes of each fix are the edits suggested by
+// the analyzer, in the order of the files, with a comment naming the
+// diagnostic. Fixes are in the order of the entries, and diagnostics without
+// a fix in the changes are left out.
+func splitByDiagnostic(changes []fileChange, entries []diagnosticEntry, fileSet *token.FileSet, paths patchPaths) []diagnosticFix {
+	index := make(map[string]int)
+	for i, c := range changes {
+		index[c.fileName] = i
+	}
+	var fixes []diagnosticFix
+	ids := make(map[string]int)
+	for _, entry := range entries {
+		for _, sf := range entry.SuggestedFixes {
+			if len(sf.TextEdits) == 0 {
+				continue
+			}
+			edits := make(map[int][]nogoEdit)
+			selected := true
+			for _, edit := range sf.TextEdits {
+				fileName, e, ok := newNogoEdit(edit, entry.analyzerName, fileSet)
+				i, inChanges := index[fileName]
+				if !ok || !inChanges || !containsEdit(changes[i].changes, e) {
+					selected = false
+					break
+				}
+				if !containsEdit(edits[i], e) {
+					edits[i] = append(edits[i], e)
+				}
+			}
+			if !selected {
+				continue
+			}
+			fix := diagnosticFix{id: diagnosticFixID(entry, fileSet, paths), entry: entry}
+			// Diagnostics reported twice at the same position with the same
+			// message get distinct IDs in the order they are reported.
+			ids[fix.id]++
+			if n := ids[fix.id]; n > 1 {
+				fix.id += "-" + strconv.Itoa(n)
+			}
+			comment := provenanceComment(entry, fileSet, paths)
+			for i, c := range changes {
+				if len(edits[i]) == 0 {
+					continue
+				}
+				sort.Stable(byStartEnd(edits[i]))
+				fix.changes = append(fix.changes, fileChange{fileName: c.fileName, changes: edits[i], comments: []string{comment}})
+			}
+			fixes = append(fixes, fix)
+			break
 		}
+	}
+	return fixes
+}
+
+// diagnosticFixID returns the name of the analyzer of the diagnostic followed
+// by a hash of its position and message. Unlike an index, the ID stays the
+// same when other diagnostics are added or removed, and it doesn't depend on
+// the execroot.
+func diagnosticFixID(entry diagnosticEntry, fileSet *token.FileSet, paths patchPaths) string {
+	message := strings.Join(strings.Fields(entry.Message), " ")
+	key := message
+	if p := fileSet.Position(entry.Pos); p.IsValid() {
+		key = fmt.Sprintf("%s:%d:%d: %s", filepath.ToSlash(paths.path(p.Filename)), p.Line, p.Column, message)
+	}
+	sum := sha256.Sum256([]byte(key))
+	return entry.analyzerName + "-" + hex.EncodeToString(sum[:6])
+}
+
+// writeFixFile writes the structured form of the changes as indented JSON.
+func writeFixFile(w io.Writer, changes []fileChange, paths patchPaths) error {
+	data, err := json.MarshalIndent(newFixFile(changes, paths), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
 
-		if err := difflib.WriteUnifiedDiff(patchFile, diff); err != nil {
-			return fmt.Errorf("creating patch for %q: %w", c.fileName, err)
+// writeUnifiedDiff writes the unified diff of the lines a and b with the given
+// number of context lines. Lines without a terminating newline are followed
+// by a "\ No newline at end of file" marker.
+func writeUnifiedDiff(w *bytes.Buffer, fromFile, toFile string, a, b []string, context int) {
+	groups := groupDiffOps(diffLines(a, b), context)
+	if len(groups) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", fromFile, toFile)
+	for _, group := range groups {
+		first, last := group[0], group[len(group)-1]
+		fmt.Fprintf(w, "@@ -%s +%s @@\n", unifiedRange(first.i1, last.i2), unifiedRange(first.j1, last.j2))
+		for _, op := range group {
+			if op.tag == 'e' {
+				for _, line := range a[op.i1:op.i2] {
+					writeDiffLine(w, ' ', line)
+				}
+				continue
+			}
+			if op.tag == 'r' || op.tag == 'd' {
+				for _, line := range a[op.i1:op.i2] {
+					writeDiffLine(w, '-', line)
+				}
+			}
+			if op.tag == 'r' || op.tag == 'i' {
+				for _, line := range b[op.j1:op.j2] {
+					writeDiffLine(w, '+', line)
+				}
+			}
 		}
 	}
+}
+
+// unifiedRange formats the range of lines [start, stop) for a hunk header.
+func unifiedRange(start, stop int) string {
+	beginning, length := start+1, stop-start
+	if length == 1 {
+		return strconv.Itoa(beginning)
+	}
+	if length == 0 {
+		// An empty range is given by the line before it.
+		beginning--
+	}
+	return fmt.Sprintf("%d,%d", beginning, length)
+}
+
+func writeDiffLine(w *bytes.Buffer, kind byte, line string) {
+	w.WriteByte(kind)
+	w.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		w.WriteString("\n\\ No newline at end of file\n")
+	}
+}
+
+// A memoryBudget is a semaphore counting bytes.
+type memoryBudget struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	total int64
+	avail int64
+}
+
+func newMemoryBudget(total int64) *memoryBudget {
+	b := &memoryBudget{total: total, avail: total}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// acquire blocks until n bytes are available and returns the amount acquired.
+// Requests larger than the whole budget wait for the budget to be unused.
+func (b *memoryBudget) acquire(n int64) int64 {
+	if n > b.total {
+		n = b.total
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.avail < n {
+		b.cond.Wait()
+	}
+	b.avail -= n
+	return n
+}
 
-	return nil
+func (b *memoryBudget) release(n int64) {
+	b.mu.Lock()
+	b.avail += n
+	b.mu.Unlock()
+	b.cond.Broadcast()
 }
 
 func formatErrors(errs []error) []string {
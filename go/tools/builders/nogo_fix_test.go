@@ -7,9 +7,12 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"golang.org/x/tools/go/analysis"
 )
@@ -198,6 +201,164 @@ func TestGetFixes_Conflict(t *testing.T) {
 	}
 }
 
+func TestGetFixes_OverlappingEditsInFix(t *testing.T) {
+	fset := token.NewFileSet()
+	f := fset.AddFile("file1.go", fset.Base(), 100)
+	f.AddLine(0)
+	f.AddLine(20)
+
+	diagnosticEntries := []diagnosticEntry{
+		{
+			analyzerName: "analyzer1",
+			Diagnostic: analysis.Diagnostic{
+				SuggestedFixes: []analysis.SuggestedFix{
+					{
+						// The edits of this fix overlap each other.
+						Message: "broken",
+						TextEdits: []analysis.TextEdit{
+							{Pos: token.Pos(5), End: token.Pos(13), NewText: []byte("new_text")},
+							{Pos: token.Pos(9), End: token.Pos(11)},
+						},
+					},
+					{
+						Message: "alternative",
+						TextEdits: []analysis.TextEdit{
+							{Pos: token.Pos(5), End: token.Pos(13), NewText: []byte("alternative")},
+						},
+					},
+				},
+			},
+		},
+		{
+			// Another diagnostic of the same analyzer is fixed independently.
+			analyzerName: "analyzer1",
+			Diagnostic: analysis.Diagnostic{
+				SuggestedFixes: []analysis.SuggestedFix{
+					{
+						TextEdits: []analysis.TextEdit{
+							{Pos: token.Pos(31), End: token.Pos(35), NewText: []byte("other")},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	fileChanges, err := getFixes(diagnosticEntries, fset)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []fileChange{
+		{
+			fileName: "file1.go",
+			changes: []nogoEdit{
+				{Start: 4, End: 12, New: "alternative", analyzerName: "analyzer1"},
+				{Start: 30, End: 34, New: "other", analyzerName: "analyzer1"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(fileChanges, expected) {
+		t.Errorf("unexpected changes:\n\tgot:\t%v\n\twant:\t%v", fileChanges, expected)
+	}
+
+	// Without an alternative, only the broken fix is dropped.
+	diagnosticEntries[0].SuggestedFixes = diagnosticEntries[0].SuggestedFixes[:1]
+	fileChanges, err = getFixes(diagnosticEntries, fset)
+	if err == nil || !strings.Contains(err.Error(), `invalid suggested fix "broken"`) {
+		t.Errorf("expected an error about the broken fix, got: %v", err)
+	}
+	expected[0].changes = expected[0].changes[1:]
+	if !reflect.DeepEqual(fileChanges, expected) {
+		t.Errorf("unexpected changes:\n\tgot:\t%v\n\twant:\t%v", fileChanges, expected)
+	}
+}
+
+func TestGetFixesWithStrategy(t *testing.T) {
+	fset := token.NewFileSet()
+	f := fset.AddFile("file1.go", fset.Base(), 100)
+	f.AddLine(0)
+	f.AddLine(20)
+
+	diagnosticEntries := []diagnosticEntry{
+		{
+			analyzerName: "analyzer1",
+			Diagnostic: analysis.Diagnostic{
+				SuggestedFixes: []analysis.SuggestedFix{
+					{
+						TextEdits: []analysis.TextEdit{
+							{Pos: token.Pos(5), End: token.Pos(13), NewText: []byte("new_text")},
+						},
+					},
+				},
+			},
+		},
+		{
+			analyzerName: "analyzer2",
+			Diagnostic: analysis.Diagnostic{
+				SuggestedFixes: []analysis.SuggestedFix{
+					{
+						// The first edit overlaps with the fix of analyzer1.
+						TextEdits: []analysis.TextEdit{
+							{Pos: token.Pos(9), End: token.Pos(11)},
+							{Pos: token.Pos(31), End: token.Pos(35), NewText: []byte("other")},
+						},
+					},
+				},
+			},
+		},
+	}
+	priorities := map[string]int{"analyzer2": 1}
+
+	tests := []struct {
+		name            string
+		strategy        fixStrategy
+		expectedChanges []nogoEdit
+		expectedError   string
+	}{
+		{
+			name:     "first",
+			strategy: fixStrategy{conflicts: fixConflictsFirst},
+			expectedChanges: []nogoEdit{
+				{Start: 4, End: 12, New: "new_text", analyzerName: "analyzer1"},
+			},
+			expectedError: `ignoring suggested fixes from analyzer "analyzer2"`,
+		},
+		{
+			name: "priority",
+			strategy: fixStrategy{
+				conflicts: fixConflictsPriority,
+				priority:  func(analyzerName string) int { return priorities[analyzerName] },
+			},
+			expectedChanges: []nogoEdit{
+				{Start: 8, End: 10, analyzerName: "analyzer2"},
+				{Start: 30, End: 34, New: "other", analyzerName: "analyzer2"},
+			},
+			expectedError: `ignoring suggested fixes from analyzer "analyzer1"`,
+		},
+		{
+			name:     "partial",
+			strategy: fixStrategy{conflicts: fixConflictsPartial},
+			expectedChanges: []nogoEdit{
+				{Start: 4, End: 12, New: "new_text", analyzerName: "analyzer1"},
+				{Start: 30, End: 34, New: "other", analyzerName: "analyzer2"},
+			},
+			expectedError: `applying only 1 of 2 edits of the suggested fix from analyzer "analyzer2"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileChanges, err := getFixesWithStrategy(diagnosticEntries, fset, tt.strategy)
+			if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("expected error containing %q, got: %v", tt.expectedError, err)
+			}
+			expected := []fileChange{{fileName: "file1.go", changes: tt.expectedChanges}}
+			if !reflect.DeepEqual(fileChanges, expected) {
+				t.Errorf("unexpected changes:\n\tgot:\t%v\n\twant:\t%v", fileChanges, expected)
+			}
+		})
+	}
+}
+
 func TestGetFixes_NoFixes(t *testing.T) {
 	fset := token.NewFileSet()
 
@@ -220,6 +381,279 @@ func TestGetFixes_NoFixes(t *testing.T) {
 	}
 }
 
+func TestGetFixes_MessageOnlyFixes(t *testing.T) {
+	fset := token.NewFileSet()
+	f := fset.AddFile("file1.go", fset.Base(), 100)
+
+	diagnosticEntries := []diagnosticEntry{
+		{
+			analyzerName: "analyzer1",
+			Diagnostic: analysis.Diagnostic{
+				SuggestedFixes: []analysis.SuggestedFix{
+					{Message: "consider renaming the function"},
+				},
+			},
+		},
+		{
+			analyzerName: "analyzer2",
+			Diagnostic: analysis.Diagnostic{
+				SuggestedFixes: []analysis.SuggestedFix{
+					{Message: "consider removing the call"},
+					{Message: "replace the call", TextEdits: []analysis.TextEdit{
+						{Pos: f.Pos(10), End: f.Pos(15), NewText: []byte("new")},
+					}},
+				},
+			},
+		},
+	}
+
+	fileChanges, err := getFixes(diagnosticEntries, fset)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []fileChange{
+		{fileName: "file1.go", changes: []nogoEdit{{Start: 10, End: 15, New: "new", analyzerName: "analyzer2"}}},
+	}
+	if !reflect.DeepEqual(fileChanges, expected) {
+		t.Errorf("got %+v, want %+v", fileChanges, expected)
+	}
+
+	if got, want := fixHints(diagnosticEntries[1].Diagnostic), []string{"consider removing the call"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("fixHints: got %q, want %q", got, want)
+	}
+}
+
+func TestExcludeFixes(t *testing.T) {
+	fset := token.NewFileSet()
+	src := fset.AddFile("pkg/src.go", fset.Base(), 100)
+	gen := fset.AddFile("pkg/src.pb.go", fset.Base(), 100)
+	fileName := func(pos token.Pos) string {
+		return fset.Position(pos).Filename
+	}
+	edit := func(f *token.File) analysis.TextEdit {
+		return analysis.TextEdit{Pos: f.Pos(0), End: f.Pos(1), NewText: []byte("x")}
+	}
+	d := analysis.Diagnostic{
+		SuggestedFixes: []analysis.SuggestedFix{
+			{Message: "source only", TextEdits: []analysis.TextEdit{edit(src)}},
+			{Message: "generated only", TextEdits: []analysis.TextEdit{edit(gen)}},
+			{Message: "both", TextEdits: []analysis.TextEdit{edit(src), edit(gen)}},
+		},
+	}
+
+	excluded := excludeFixes(&d, []fileScope{{exclude: []*regexp.Regexp{regexp.MustCompile(`\.pb\.go$`)}}}, fileName)
+	if want := []string{"pkg/src.pb.go"}; !reflect.DeepEqual(excluded, want) {
+		t.Errorf("excluded files: got %q, want %q", excluded, want)
+	}
+	if len(d.SuggestedFixes) != 1 || d.SuggestedFixes[0].Message != "source only" {
+		t.Errorf("kept fixes: got %+v, want only the fix of the source file", d.SuggestedFixes)
+	}
+
+	if excluded := excludeFixes(&d, []fileScope{{}}, fileName); excluded != nil || len(d.SuggestedFixes) != 1 {
+		t.Errorf("without patterns: got excluded files %q and %d fixes, want none and 1", excluded, len(d.SuggestedFixes))
+	}
+}
+
+func TestExcludeRemappedFixes(t *testing.T) {
+	fset := token.NewFileSet()
+	src := fset.AddFile("pkg/src.go", fset.Base(), 100)
+	src.SetLines([]int{0, 20, 40})
+	// The second half of the generated file is mapped to the grammar it was
+	// generated from.
+	gen := fset.AddFile("pkg/parser.go", fset.Base(), 100)
+	gen.SetLines([]int{0, 20, 40, 60, 80})
+	gen.AddLineColumnInfo(40, "pkg/parser.y", 10, 1)
+	rawFileName := func(pos token.Pos) string {
+		return fset.PositionFor(pos, false).Filename
+	}
+	edit := func(f *token.File, offset int) analysis.TextEdit {
+		return analysis.TextEdit{Pos: f.Pos(offset), End: f.Pos(offset + 1), NewText: []byte("x")}
+	}
+	d := analysis.Diagnostic{
+		SuggestedFixes: []analysis.SuggestedFix{
+			{Message: "source", TextEdits: []analysis.TextEdit{edit(src, 0)}},
+			{Message: "generated", TextEdits: []analysis.TextEdit{edit(gen, 0)}},
+			{Message: "remapped", TextEdits: []analysis.TextEdit{edit(src, 0), edit(gen, 60)}},
+		},
+	}
+
+	remapped := excludeRemappedFixes(&d, fset, rawFileName)
+	if want := []string{"pkg/parser.go"}; !reflect.DeepEqual(remapped, want) {
+		t.Errorf("remapped files: got %q, want %q", remapped, want)
+	}
+	var kept []string
+	for _, fix := range d.SuggestedFixes {
+		kept = append(kept, fix.Message)
+	}
+	if want := []string{"source", "generated"}; !reflect.DeepEqual(kept, want) {
+		t.Errorf("kept fixes: got %q, want %q", kept, want)
+	}
+}
+
+func TestExcludeSplitRuneFixes(t *testing.T) {
+	const content = "package a\n\nvar s = \"héllo, 世界\"\n"
+	fset := token.NewFileSet()
+	f := fset.AddFile("pkg/a.go", fset.Base(), len(content))
+	unknown := fset.AddFile("pkg/b.go", fset.Base(), len(content))
+	src := map[string][]byte{"pkg/a.go": []byte(content)}
+	rawFileName := func(pos token.Pos) string {
+		return fset.PositionFor(pos, false).Filename
+	}
+	at := func(s string, delta int) int {
+		return strings.Index(content, s) + delta
+	}
+	edit := func(f *token.File, start, end int) analysis.TextEdit {
+		return analysis.TextEdit{Pos: f.Pos(start), End: f.Pos(end), NewText: []byte("x")}
+	}
+	d := analysis.Diagnostic{
+		SuggestedFixes: []analysis.SuggestedFix{
+			{Message: "whole characters", TextEdits: []analysis.TextEdit{edit(f, at("é", 0), at("llo", 0)), edit(f, at("世", 0), at("界", 0))}},
+			{Message: "insertion", TextEdits: []analysis.TextEdit{{Pos: f.Pos(at("界", 0)), NewText: []byte("x")}}},
+			{Message: "start in character", TextEdits: []analysis.TextEdit{edit(f, at("é", 1), at("llo", 0))}},
+			{Message: "end in character", TextEdits: []analysis.TextEdit{edit(f, at("s", 0), at("世", 2))}},
+			{Message: "insertion in character", TextEdits: []analysis.TextEdit{{Pos: f.Pos(at("界", 1)), NewText: []byte("x")}}},
+			{Message: "unknown contents", TextEdits: []analysis.TextEdit{edit(unknown, at("é", 1), at("llo", 0))}},
+			{Message: "end of file", TextEdits: []analysis.TextEdit{edit(f, len(content)-1, len(content))}},
+		},
+	}
+
+	split := excludeSplitRuneFixes(&d, fset, src, rawFileName)
+	if want := []string{"pkg/a.go"}; !reflect.DeepEqual(split, want) {
+		t.Errorf("files with split characters: got %q, want %q", split, want)
+	}
+	var kept []string
+	for _, fix := range d.SuggestedFixes {
+		kept = append(kept, fix.Message)
+	}
+	if want := []string{"whole characters", "insertion", "unknown contents", "end of file"}; !reflect.DeepEqual(kept, want) {
+		t.Errorf("kept fixes: got %q, want %q", kept, want)
+	}
+}
+
+func TestInsideRune(t *testing.T) {
+	// A string literal with invalid UTF-8 between two valid characters.
+	content := []byte("s := \"é\x80\xe4\xb8界\"")
+	var inside []int
+	for offset := 0; offset <= len(content); offset++ {
+		if insideRune(content, offset) {
+			inside = append(inside, offset)
+		}
+	}
+	// Only the offsets in é and 界 split a character, the invalid bytes are
+	// characters of their own.
+	e, world := bytes.IndexRune(content, 'é'), bytes.IndexRune(content, '界')
+	if want := []int{e + 1, world + 1, world + 2}; !reflect.DeepEqual(inside, want) {
+		t.Errorf("got offsets inside characters %v, want %v", inside, want)
+	}
+}
+
+func TestSkipByteOrderMark(t *testing.T) {
+	const content = byteOrderMark + "package a\n"
+	fset := token.NewFileSet()
+	f := fset.AddFile("a.go", fset.Base(), len(content))
+	plain := fset.AddFile("b.go", fset.Base(), len(content)-len(byteOrderMark))
+	src := map[string][]byte{"a.go": []byte(content), "b.go": []byte("package a\n")}
+	header := []byte("// Copyright\n")
+	fixes := []analysis.SuggestedFix{
+		{Message: "header", TextEdits: []analysis.TextEdit{{Pos: f.Pos(0), End: f.Pos(0), NewText: header}}},
+		{Message: "header without end", TextEdits: []analysis.TextEdit{{Pos: f.Pos(0), NewText: header}}},
+		{Message: "with byte order mark", TextEdits: []analysis.TextEdit{{Pos: f.Pos(0), End: f.Pos(0), NewText: append([]byte(byteOrderMark), header...)}}},
+		{Message: "replacement", TextEdits: []analysis.TextEdit{{Pos: f.Pos(0), End: f.Pos(len(content)), NewText: []byte("package b\n")}}},
+		{Message: "without byte order mark", TextEdits: []analysis.TextEdit{{Pos: plain.Pos(0), End: plain.Pos(0), NewText: header}}},
+	}
+	d := analysis.Diagnostic{SuggestedFixes: fixes}
+	skipByteOrderMark(&d, fset, src)
+
+	afterMark := f.Pos(len(byteOrderMark))
+	want := []token.Pos{afterMark, afterMark, f.Pos(0), f.Pos(0), plain.Pos(0)}
+	for i, fix := range d.SuggestedFixes {
+		if got := fix.TextEdits[0].Pos; got != want[i] {
+			t.Errorf("%s: got edit at offset %d, want %d", fix.Message, got-f.Pos(0), want[i]-f.Pos(0))
+		}
+	}
+	if fixes[0].TextEdits[0].Pos != f.Pos(0) {
+		t.Error("skipByteOrderMark modified the fixes of the analyzer")
+	}
+}
+
+func TestExcludeFixesScopes(t *testing.T) {
+	fset := token.NewFileSet()
+	a := fset.AddFile("pkg/a.go", fset.Base(), 100)
+	b := fset.AddFile("pkg/b.go", fset.Base(), 100)
+	gen := fset.AddFile("pkg/a.pb.go", fset.Base(), 100)
+	fileName := func(pos token.Pos) string {
+		return fset.Position(pos).Filename
+	}
+	fix := func(files ...*token.File) analysis.SuggestedFix {
+		var edits []analysis.TextEdit
+		var names []string
+		for _, f := range files {
+			edits = append(edits, analysis.TextEdit{Pos: f.Pos(0), End: f.Pos(1), NewText: []byte("x")})
+			names = append(names, f.Name())
+		}
+		return analysis.SuggestedFix{Message: strings.Join(names, " "), TextEdits: edits}
+	}
+	d := analysis.Diagnostic{
+		SuggestedFixes: []analysis.SuggestedFix{fix(a), fix(b), fix(gen), fix(a, b)},
+	}
+	scopes := []fileScope{
+		// The files the analyzer reports diagnostics for.
+		{exclude: []*regexp.Regexp{regexp.MustCompile(`\.pb\.go$`)}},
+		// The files its fixes may edit.
+		{only: []*regexp.Regexp{regexp.MustCompile(`a(\.pb)?\.go$`)}},
+	}
+
+	excluded := excludeFixes(&d, scopes, fileName)
+	if want := []string{"pkg/b.go", "pkg/a.pb.go"}; !reflect.DeepEqual(excluded, want) {
+		t.Errorf("excluded files: got %q, want %q", excluded, want)
+	}
+	var kept []string
+	for _, fix := range d.SuggestedFixes {
+		kept = append(kept, fix.Message)
+	}
+	if want := []string{"pkg/a.go"}; !reflect.DeepEqual(kept, want) {
+		t.Errorf("kept fixes: got %q, want %q", kept, want)
+	}
+}
+
+func TestFileScope(t *testing.T) {
+	scope := fileScope{
+		only:    []*regexp.Regexp{regexp.MustCompile(`^pkg/`)},
+		exclude: []*regexp.Regexp{regexp.MustCompile(`_test\.go$`)},
+	}
+	for name, want := range map[string]bool{
+		"pkg/a.go":      true,
+		"pkg/a_test.go": false,
+		"other/a.go":    false,
+	} {
+		if got := scope.contains(name); got != want {
+			t.Errorf("contains(%q): got %v, want %v", name, got, want)
+		}
+	}
+	if !(fileScope{}).contains("-") {
+		t.Errorf("empty scope doesn't contain all files")
+	}
+}
+
+func TestFileScopeWithoutTests(t *testing.T) {
+	scope := fileScope{exclude: []*regexp.Regexp{regexp.MustCompile(`\.pb\.go$`)}}
+	withoutTests := scope.withoutTests()
+	for name, want := range map[string]bool{
+		"pkg/a.go":         true,
+		"pkg/a_test.go":    false,
+		"pkg/a.pb.go":      false,
+		"pkg/test.go":      true,
+		"pkg/a_test.go.in": true,
+	} {
+		if got := withoutTests.contains(name); got != want {
+			t.Errorf("contains(%q): got %v, want %v", name, got, want)
+		}
+	}
+	if !scope.contains("pkg/a_test.go") {
+		t.Errorf("withoutTests modified the original scope")
+	}
+}
+
 func TestValidate_Success(t *testing.T) {
 	edits := []nogoEdit{
 		{Start: 20, End: 30, New: "new_text"},
@@ -245,6 +679,136 @@ func TestValidate_Success(t *testing.T) {
 	}
 }
 
+func TestComposeEdits(t *testing.T) {
+	edits := []nogoEdit{
+		{Start: 0, End: 0, New: "a", analyzerName: "one"},
+		{Start: 0, End: 2, New: "b", analyzerName: "one"},
+		{Start: 2, End: 4, analyzerName: "one"},
+		{Start: 4, End: 4, New: "c", analyzerName: "two"},
+		{Start: 4, End: 6, New: "d", analyzerName: "one"},
+		{Start: 8, End: 9, New: "e", analyzerName: "one"},
+	}
+	got := composeEdits(edits)
+	want := []nogoEdit{
+		{Start: 0, End: 4, New: "ab", analyzerName: "one", parts: edits[:3]},
+		{Start: 4, End: 4, New: "c", analyzerName: "two"},
+		{Start: 4, End: 6, New: "d", analyzerName: "one"},
+		{Start: 8, End: 9, New: "e", analyzerName: "one"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got edits %v, want %v", got, want)
+	}
+	src := []byte("0123456789")
+	if got, want := string(applyEdits(src, got)), string(applyEdits(src, edits)); got != want {
+		t.Errorf("composed edits produce %q, want %q", got, want)
+	}
+}
+
+func TestMinimizeFixes(t *testing.T) {
+	const content = "package a\n\nvar s = \"héllo\"\n"
+	at := func(s string) int {
+		return strings.Index(content, s)
+	}
+	edit := func(old, new string) nogoEdit {
+		return nogoEdit{Start: at(old), End: at(old) + len(old), New: new, analyzerName: "a"}
+	}
+	same := edit("var", "var")
+	trimmed := edit("var s", "var t")
+	accent := edit("é", "è")
+	unchanged := []nogoEdit{same, {Start: 4, End: 4, analyzerName: "a"}}
+	changes := []fileChange{
+		{fileName: "a.go", changes: []nogoEdit{same, trimmed, accent}},
+		{fileName: "b.go", changes: unchanged},
+		{fileName: "c.go", changes: unchanged},
+	}
+	got := minimizeFixes(changes, map[string][]byte{"a.go": []byte(content), "b.go": []byte(content)})
+	want := []fileChange{
+		{fileName: "a.go", changes: []nogoEdit{
+			{Start: at("s ="), End: at("s =") + 1, New: "t", analyzerName: "a", parts: []nogoEdit{trimmed}},
+			// é and è share their first byte, but edits don't split
+			// characters.
+			accent,
+		}},
+		// The contents of c.go are unknown, so its edits are kept.
+		{fileName: "c.go", changes: unchanged},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got changes %v, want %v", got, want)
+	}
+	src := []byte(content)
+	if got, want := string(applyEdits(src, got[0].changes)), string(applyEdits(src, changes[0].changes[1:])); got != want {
+		t.Errorf("minimized edits produce %q, want %q", got, want)
+	}
+}
+
+func TestMinimizeFixes_CRLF(t *testing.T) {
+	const content = "package a\r\n\r\nvar x = 1\r\n"
+	at := func(s string) int {
+		return strings.Index(content, s)
+	}
+	// Analyzers insert lines ending with "\n", e.g. from go/format.
+	reformatted := nogoEdit{Start: at("var"), End: len(content), New: "var x = 1\n", analyzerName: "a"}
+	comment := nogoEdit{Start: at("var"), End: at("var"), New: "// x is one.\n", analyzerName: "a"}
+	afterCR := nogoEdit{Start: at("\n"), End: at("\n") + 1, New: "\n// a\n", analyzerName: "b"}
+	lf := nogoEdit{Start: 0, End: 0, New: "// b\n", analyzerName: "a"}
+	changes := []fileChange{
+		{fileName: "a.go", changes: []nogoEdit{afterCR, comment, reformatted}},
+		{fileName: "b.go", changes: []nogoEdit{lf}},
+	}
+	got := minimizeFixes(changes, map[string][]byte{"a.go": []byte(content), "b.go": []byte("package b\n")})
+	want := []fileChange{
+		{fileName: "a.go", changes: []nogoEdit{
+			{Start: at("\n") + 1, End: at("\n") + 1, New: "// a\r\n", analyzerName: "b", parts: []nogoEdit{afterCR}},
+			{Start: at("var"), End: at("var"), New: "// x is one.\r\n", analyzerName: "a", parts: []nogoEdit{comment}},
+		}},
+		// Files with "\n" line endings keep the edits as they are.
+		{fileName: "b.go", changes: []nogoEdit{lf}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got changes %v, want %v", got, want)
+	}
+	if got, want := string(applyEdits([]byte(content), got[0].changes)), "package a\r\n// a\r\n\r\n// x is one.\r\nvar x = 1\r\n"; got != want {
+		t.Errorf("fixed content %q, want %q", got, want)
+	}
+}
+
+func TestGetFixes_ComposedProvenance(t *testing.T) {
+	const content = "package main\n\nvar x, y = 1, 2\n"
+	file := filepath.Join(t.TempDir(), "file.go")
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	f := fset.AddFile(file, fset.Base(), len(content))
+	f.SetLinesForContent([]byte(content))
+	// Each diagnostic renames one variable, so their edits abut.
+	entry := func(message, old, new string) diagnosticEntry {
+		offset := strings.Index(content, old)
+		return diagnosticEntry{
+			analyzerName: "rename",
+			Diagnostic: analysis.Diagnostic{
+				Pos:     f.Pos(offset),
+				Message: message,
+				SuggestedFixes: []analysis.SuggestedFix{{TextEdits: []analysis.TextEdit{
+					{Pos: f.Pos(offset), End: f.Pos(offset + len(old)), NewText: []byte(new)},
+				}}},
+			},
+		}
+	}
+	entries := []diagnosticEntry{entry("rename x", "x", "a"), entry("rename y", ", y", ", b")}
+	changes, err := getFixes(entries, fset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || len(changes[0].changes) != 1 {
+		t.Fatalf("got changes %v, want a single composed edit", changes)
+	}
+	addFixProvenance(changes, entries, fset, patchPaths{})
+	if want := []string{"# " + filepath.ToSlash(file) + ":3:5: rename x (rename)", "# " + filepath.ToSlash(file) + ":3:6: rename y (rename)"}; !reflect.DeepEqual(changes[0].comments, want) {
+		t.Errorf("got comments %q, want %q", changes[0].comments, want)
+	}
+}
+
 func TestValidate_Failure(t *testing.T) {
 	tests := []struct{
 		name string
@@ -296,6 +860,12 @@ func TestWritePatch(t *testing.T) {
 		t.Fatalf("Failed to create temporary file2.go: %v", err)
 	}
 
+	// A file with CRLF line endings and without a newline at the end.
+	file3 := tmpDir + "/file3.go"
+	if err := os.WriteFile(file3, []byte("package main\r\nvar x = 10"), 0644); err != nil {
+		t.Fatalf("Failed to create temporary file3.go: %v", err)
+	}
+
 	tests := []struct {
 		name      string
 		fileChanges       []fileChange
@@ -310,26 +880,54 @@ func TestWritePatch(t *testing.T) {
 			},
 			expected: fmt.Sprintf(`--- %s
 +++ %s
-@@ -1,3 +1,5 @@
+@@ -1,2 +1,4 @@
  package main
 -func Hello() {}
 +func Hello() {
 +Hello, world!
 +}
- 
 --- %s
 +++ %s
-@@ -1,3 +1,4 @@
+@@ -1,2 +1,3 @@
  package main
  var x = 10
 +var y = 20
- 
-`, filepath.Join("a", file1), filepath.Join("b", file1), filepath.Join("a", file2), filepath.Join("b", file2)),
+`, "a/"+filepath.ToSlash(file1), "b/"+filepath.ToSlash(file1), "a/"+filepath.ToSlash(file2), "b/"+filepath.ToSlash(file2)),
+		},
+		{
+			name: "CRLF and no newline at end of file",
+			fileChanges: []fileChange{
+				{fileName: file3, changes: []nogoEdit{{Start: 22, End: 24, New: "11"}}},
+			},
+			expected: fmt.Sprintf("--- %s\n+++ %s\n@@ -1,2 +1,2 @@\n package main\r\n-var x = 10\n\\ No newline at end of file\n+var x = 11\n\\ No newline at end of file\n",
+				"a/"+filepath.ToSlash(file3), "b/"+filepath.ToSlash(file3)),
+		},
+		{
+			name: "adding a newline at end of file",
+			fileChanges: []fileChange{
+				{fileName: file3, changes: []nogoEdit{{Start: 24, End: 24, New: "\r\n"}}},
+			},
+			expected: fmt.Sprintf("--- %s\n+++ %s\n@@ -1,2 +1,2 @@\n package main\r\n-var x = 10\n\\ No newline at end of file\n+var x = 10\r\n",
+				"a/"+filepath.ToSlash(file3), "b/"+filepath.ToSlash(file3)),
+		},
+		{
+			name: "creating a file",
+			fileChanges: []fileChange{
+				{fileName: tmpDir + "/new.go", changes: []nogoEdit{{Start: 0, End: 0, New: "package main\nvar z = 30\n"}}},
+			},
+			expected: fmt.Sprintf("--- /dev/null\n+++ %s\n@@ -0,0 +1,2 @@\n+package main\n+var z = 30\n", "b/"+filepath.ToSlash(tmpDir+"/new.go")),
+		},
+		{
+			name: "deleting a file",
+			fileChanges: []fileChange{
+				{fileName: file2, changes: []nogoEdit{{Start: 0, End: 24}}},
+			},
+			expected: fmt.Sprintf("--- %s\n+++ /dev/null\n@@ -1,2 +0,0 @@\n-package main\n-var x = 10\n", "a/"+filepath.ToSlash(file2)),
 		},
 		{
 			name: "file not found",
 			fileChanges: []fileChange{
-				{fileName: "nonexistent.go", changes: []nogoEdit{{Start: 0, End: 0, New: "new content"}}},
+				{fileName: "nonexistent.go", changes: []nogoEdit{{Start: 5, End: 5, New: "new content"}}},
 			},
 			expectErr: true,
 		},
@@ -341,7 +939,7 @@ func TestWritePatch(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			var patchWriter bytes.Buffer
-			err := writePatch(&patchWriter, tt.fileChanges)
+			err := writePatch(&patchWriter, tt.fileChanges, nil, patchPaths{}, nil, 3)
 
 			// Verify error expectation
 			if (err != nil) != tt.expectErr {
@@ -356,3 +954,783 @@ func TestWritePatch(t *testing.T) {
 		})
 	}
 }
+
+func TestWritePatch_Context(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file.go")
+	if err := os.WriteFile(file, []byte("package main\n\nvar a = 1\nvar b = 2\nvar c = 3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	changes := []fileChange{{fileName: file, changes: []nogoEdit{{Start: 32, End: 33, New: "4"}}}}
+	name := filepath.ToSlash(file)
+
+	for _, tt := range []struct {
+		context  int
+		expected string
+	}{
+		{0, "@@ -4 +4 @@\n-var b = 2\n+var b = 4\n"},
+		{1, "@@ -3,3 +3,3 @@\n var a = 1\n-var b = 2\n+var b = 4\n var c = 3\n"},
+	} {
+		var patch bytes.Buffer
+		if err := writePatch(&patch, changes, nil, patchPaths{}, nil, tt.context); err != nil {
+			t.Fatal(err)
+		}
+		expected := fmt.Sprintf("--- a/%s\n+++ b/%s\n%s", name, name, tt.expected)
+		if got := patch.String(); got != expected {
+			t.Errorf("context %d: got patch:\n%s\nwant:\n%s", tt.context, got, expected)
+		}
+	}
+}
+
+func TestWritePatch_Prefixes(t *testing.T) {
+	src := map[string][]byte{"pkg/file.go": []byte("package main\n\nvar a = 1\n")}
+	changes := []fileChange{{fileName: "pkg/file.go", changes: []nogoEdit{{Start: 22, End: 23, New: "2"}}}}
+	hunk := "@@ -3 +3 @@\n-var a = 1\n+var a = 2\n"
+
+	for _, tt := range []struct {
+		prefixes *patchPrefixes
+		expected string
+	}{
+		{nil, "--- a/pkg/file.go\n+++ b/pkg/file.go\n"},
+		{&patchPrefixes{src: "", dst: "", strip: 0}, "# nogo: strip=0\n--- pkg/file.go\n+++ pkg/file.go\n"},
+		{&patchPrefixes{src: "old/src/", dst: "new/src/", strip: 2}, "# nogo: strip=2\n--- old/src/pkg/file.go\n+++ new/src/pkg/file.go\n"},
+	} {
+		var patch bytes.Buffer
+		if err := writePatch(&patch, changes, src, patchPaths{prefixes: tt.prefixes}, nil, 0); err != nil {
+			t.Fatal(err)
+		}
+		if got := patch.String(); got != tt.expected+hunk {
+			t.Errorf("prefixes %+v: got patch:\n%s\nwant:\n%s", tt.prefixes, got, tt.expected+hunk)
+		}
+	}
+
+	// Patches without changes stay empty.
+	var patch bytes.Buffer
+	if err := writePatch(&patch, nil, nil, patchPaths{prefixes: &patchPrefixes{strip: 0}}, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	if patch.Len() != 0 {
+		t.Errorf("got patch %q for no changes, want an empty one", patch.String())
+	}
+}
+
+func TestWritePatch_Snapshot(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file.go")
+	// The file was edited in the workspace after the analyzers saw it.
+	if err := os.WriteFile(file, []byte("package main\n\nvar a = 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	src := map[string][]byte{file: []byte("package main\n\nvar a = 1\n")}
+	changes := []fileChange{{fileName: file, changes: []nogoEdit{{Start: 14, End: 17, New: "const"}}}}
+	var patch bytes.Buffer
+	if err := writePatch(&patch, changes, src, patchPaths{}, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.ToSlash(file)
+	if got, want := patch.String(), fmt.Sprintf("--- a/%s\n+++ b/%s\n@@ -3 +3 @@\n-var a = 1\n+const a = 1\n", name, name); got != want {
+		t.Errorf("got patch:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestWritePatch_RoundTrip checks that parsing the patch yields edits with the
+// same result as the changes the patch was written from.
+func TestWritePatch_RoundTrip(t *testing.T) {
+	execroot := t.TempDir()
+	sources := map[string]string{
+		"pkg/a.go": "package pkg\n\nfunc A() {}\n\nfunc B() {}\n\nvar x = 10\n",
+		"pkg/b.go": "package pkg\r\nvar y = 20",
+		"pkg/c.go": "package pkg\n",
+	}
+	for name, content := range sources {
+		path := filepath.Join(execroot, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := func(name string) string {
+		return filepath.Join(execroot, filepath.FromSlash(name))
+	}
+	// The changes edit a.go in several places, b.go at its end without a
+	// newline, delete c.go and create d.go.
+	changes := []fileChange{
+		{
+			fileName: path("pkg/a.go"),
+			changes:  []nogoEdit{{Start: 18, End: 19, New: "AA"}, {Start: 25, End: 37}, {Start: 47, End: 49, New: "11"}},
+			comments: []string{"# pkg/a.go:3:6: rename A (analyzer)"},
+		},
+		{fileName: path("pkg/b.go"), changes: []nogoEdit{{Start: 21, End: 23, New: "21\r\n"}}},
+		{fileName: path("pkg/c.go"), changes: []nogoEdit{{Start: 0, End: 12}}},
+		{fileName: path("pkg/d.go"), changes: []nogoEdit{{Start: 0, End: 0, New: "package pkg\n"}}},
+	}
+	paths := patchPaths{execroot: execroot}
+	readFile := func(name string) ([]byte, error) {
+		return os.ReadFile(filepath.Join(execroot, name))
+	}
+	for _, context := range []int{0, 3} {
+		var patch bytes.Buffer
+		if err := writePatch(&patch, changes, nil, paths, nil, context); err != nil {
+			t.Fatal(err)
+		}
+		fileToEdits, err := parsePatchEdits(patch.Bytes(), readFile, 1)
+		if err != nil {
+			t.Fatalf("context %d: parsing the patch: %v\n%s", context, err, patch.String())
+		}
+		if len(fileToEdits) != len(changes) {
+			t.Errorf("context %d: got edits for %d files, want %d", context, len(fileToEdits), len(changes))
+		}
+		for _, c := range changes {
+			name := paths.path(c.fileName)
+			var edits []nogoEdit
+			for _, e := range fileToEdits[name] {
+				edits = append(edits, nogoEdit{Start: e.Start, End: e.End, New: e.New})
+			}
+			content := []byte(sources[filepath.ToSlash(name)])
+			if got, want := applyEdits(content, edits), applyEdits(content, c.changes); !bytes.Equal(got, want) {
+				t.Errorf("context %d: %s: got %q after the round trip, want %q", context, name, got, want)
+			}
+		}
+	}
+}
+
+func TestAddFixProvenance(t *testing.T) {
+	dir := t.TempDir()
+	file1 := filepath.Join(dir, "file1.go")
+	if err := os.WriteFile(file1, []byte("package main\nvar x = 10\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	f := fset.AddFile(file1, fset.Base(), 24)
+	f.SetLinesForContent([]byte("package main\nvar x = 10\n"))
+
+	entry := func(analyzer, message string, offset int, newText string) diagnosticEntry {
+		return diagnosticEntry{
+			analyzerName: analyzer,
+			Diagnostic: analysis.Diagnostic{
+				Pos:     f.Pos(offset),
+				Message: message,
+				SuggestedFixes: []analysis.SuggestedFix{{TextEdits: []analysis.TextEdit{
+					{Pos: f.Pos(offset), End: f.Pos(offset + 2), NewText: []byte(newText)},
+				}}},
+			},
+		}
+	}
+	entries := []diagnosticEntry{
+		entry("analyzer1", "use 11", 21, "11"),
+		// Conflicts with the first fix, so it isn't mentioned.
+		entry("analyzer2", "use 12", 21, "12"),
+		entry("analyzer3", "rename\nx", 17, "y "),
+	}
+	changes, _ := getFixes(entries, fset)
+	addFixProvenance(changes, entries, fset, patchPaths{execroot: dir})
+
+	var patch bytes.Buffer
+	if err := writePatch(&patch, changes, nil, patchPaths{execroot: dir}, nil, 3); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# file1.go:2:9: use 11 (analyzer1)
+# file1.go:2:5: rename x (analyzer3)
+--- a/file1.go
++++ b/file1.go
+@@ -1,2 +1,2 @@
+ package main
+-var x = 10
++var y = 11
+`
+	if got := patch.String(); got != expected {
+		t.Errorf("got patch:\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+func TestAddFixMetadata(t *testing.T) {
+	dir := t.TempDir()
+	content := "package main\nvar x = 10\n"
+	file1 := filepath.Join(dir, "file1.go")
+	if err := os.WriteFile(file1, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	f := fset.AddFile(file1, fset.Base(), len(content))
+	f.SetLinesForContent([]byte(content))
+	created := fset.AddFile(filepath.Join(dir, "new.go"), fset.Base(), 0)
+
+	entries := []diagnosticEntry{
+		{
+			analyzerName: "analyzer1",
+			Diagnostic: analysis.Diagnostic{
+				Pos:     f.Pos(21),
+				Message: "use \"11\"\nnot 10",
+				SuggestedFixes: []analysis.SuggestedFix{{TextEdits: []analysis.TextEdit{
+					{Pos: f.Pos(21), End: f.Pos(23), NewText: []byte("11")},
+					{Pos: created.Pos(0), NewText: []byte("package main\n")},
+				}}},
+			},
+		},
+	}
+	changes, _ := getFixes(entries, fset)
+	addFixMetadata(changes, entries, fset, "//pkg:lib")
+
+	var patch bytes.Buffer
+	if err := writePatch(&patch, changes, nil, patchPaths{execroot: dir}, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	// Created files have no digest.
+	expected := `# nogo: target=//pkg:lib
+# nogo: analyzer=analyzer1 message="use \"11\" not 10"
+# nogo: sha256=` + contentDigest([]byte(content)) + `
+--- a/file1.go
++++ b/file1.go
+@@ -2 +2 @@
+-var x = 10
++var x = 11
+# nogo: target=//pkg:lib
+# nogo: analyzer=analyzer1 message="use \"11\" not 10"
+--- /dev/null
++++ b/new.go
+@@ -0,0 +1 @@
++package main
+`
+	if got := patch.String(); got != expected {
+		t.Errorf("got patch:\n%s\nwant:\n%s", got, expected)
+	}
+
+	patches, err := parsePatch(patch.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patches) != 2 || patches[0].digest != contentDigest([]byte(content)) || patches[1].digest != "" {
+		t.Errorf("got patches %+v, want the digest of the first file", patches)
+	}
+}
+
+func TestWriteFixFile(t *testing.T) {
+	execroot := filepath.FromSlash("/execroot")
+	changes := []fileChange{
+		{
+			fileName: filepath.Join(execroot, "pkg", "a.go"),
+			changes: []nogoEdit{
+				{Start: 1, End: 2, New: "x", analyzerName: "analyzer1"},
+				{Start: 5, End: 5, New: "y", analyzerName: "analyzer2"},
+				{Start: 7, End: 9, New: "", analyzerName: "analyzer1"},
+			},
+		},
+		{fileName: filepath.Join(execroot, "pkg", "b.go")},
+	}
+	var buf bytes.Buffer
+	if err := writeFixFile(&buf, changes, patchPaths{execroot: execroot}); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{
+  "version": 1,
+  "digest": "112cf3a9d252cdaeae50348d9990d64ee652f94d6fb230a5eb78c4da6ee67390",
+  "files": {
+    "pkg/a.go": {
+      "analyzer1": [
+        {
+          "new": "x",
+          "start": 1,
+          "end": 2
+        },
+        {
+          "new": "",
+          "start": 7,
+          "end": 9
+        }
+      ],
+      "analyzer2": [
+        {
+          "new": "y",
+          "start": 5,
+          "end": 5
+        }
+      ]
+    }
+  }
+}
+`
+	if got := buf.String(); got != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", got, expected)
+	}
+
+	buf.Reset()
+	if err := writeFixFile(&buf, nil, patchPaths{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != emptyFixFileJSON {
+		t.Errorf("got:\n%s\nwant:\n%s", got, emptyFixFileJSON)
+	}
+}
+
+func TestSplitByAnalyzer(t *testing.T) {
+	changes := []fileChange{
+		{
+			fileName: "a.go",
+			changes: []nogoEdit{
+				{Start: 1, End: 2, New: "x", analyzerName: "analyzer1"},
+				{Start: 5, End: 5, New: "y", analyzerName: "analyzer2"},
+				{Start: 7, End: 9, New: "", analyzerName: "analyzer1"},
+			},
+			comments: []string{"# comment"},
+		},
+		{
+			fileName: "b.go",
+			changes:  []nogoEdit{{Start: 3, End: 4, New: "z", analyzerName: "analyzer2"}},
+		},
+	}
+	expected := map[string][]fileChange{
+		"analyzer1": {{
+			fileName: "a.go",
+			changes: []nogoEdit{
+				{Start: 1, End: 2, New: "x", analyzerName: "analyzer1"},
+				{Start: 7, End: 9, New: "", analyzerName: "analyzer1"},
+			},
+		}},
+		"analyzer2": {
+			{fileName: "a.go", changes: []nogoEdit{{Start: 5, End: 5, New: "y", analyzerName: "analyzer2"}}},
+			{fileName: "b.go", changes: []nogoEdit{{Start: 3, End: 4, New: "z", analyzerName: "analyzer2"}}},
+		},
+	}
+	if got := splitByAnalyzer(changes); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v, want %v", got, expected)
+	}
+}
+
+func TestSplitByDiagnostic(t *testing.T) {
+	dir := t.TempDir()
+	content := "package main\nvar x = 10\nvar y = 20\n"
+	file1 := filepath.Join(dir, "file1.go")
+	if err := os.WriteFile(file1, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	f := fset.AddFile(file1, fset.Base(), len(content))
+	f.SetLinesForContent([]byte(content))
+
+	entry := func(analyzer, message string, offset int, fixes ...string) diagnosticEntry {
+		d := diagnosticEntry{
+			analyzerName: analyzer,
+			Diagnostic:   analysis.Diagnostic{Pos: f.Pos(offset), Message: message},
+		}
+		for _, newText := range fixes {
+			d.SuggestedFixes = append(d.SuggestedFixes, analysis.SuggestedFix{TextEdits: []analysis.TextEdit{
+				{Pos: f.Pos(offset), End: f.Pos(offset + 2), NewText: []byte(newText)},
+			}})
+		}
+		return d
+	}
+	entries := []diagnosticEntry{
+		entry("analyzer1", "use 11", 21, "11"),
+		// Conflicts with the first fix, so it has no patch.
+		entry("analyzer2", "use 12", 21, "12"),
+		// The second fix is selected since the first one conflicts with the
+		// first diagnostic.
+		entry("analyzer2", "use 21", 32, "", "21"),
+		entry("analyzer1", "use 22", 21),
+	}
+	entries[2].SuggestedFixes[0].TextEdits[0] = analysis.TextEdit{Pos: f.Pos(21), End: f.Pos(23), NewText: []byte("0")}
+	paths := patchPaths{execroot: dir}
+	changes, _ := getFixes(entries, fset)
+	fixes := splitByDiagnostic(changes, entries, fset, paths)
+
+	var got []string
+	for _, fix := range fixes {
+		var patch bytes.Buffer
+		if err := writePatch(&patch, fix.changes, nil, paths, nil, 0); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fix.id+"\n"+patch.String())
+	}
+	want := []string{
+		diagnosticFixID(entries[0], fset, paths) + `
+# file1.go:2:9: use 11 (analyzer1)
+--- a/file1.go
++++ b/file1.go
+@@ -2 +2 @@
+-var x = 10
++var x = 11
+`,
+		diagnosticFixID(entries[2], fset, paths) + `
+# file1.go:3:9: use 21 (analyzer2)
+--- a/file1.go
++++ b/file1.go
+@@ -3 +3 @@
+-var y = 20
++var y = 21
+`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got patches:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// The IDs depend on the analyzer, position and message of the diagnostic,
+	// but not on the execroot.
+	id := diagnosticFixID(entries[0], fset, paths)
+	if !regexp.MustCompile(`^analyzer1-[0-9a-f]{12}$`).MatchString(id) {
+		t.Errorf("got ID %q, want the analyzer followed by a hash", id)
+	}
+	otherFset := token.NewFileSet()
+	other := otherFset.AddFile(filepath.Join(t.TempDir(), "file1.go"), otherFset.Base(), len(content))
+	other.SetLinesForContent([]byte(content))
+	moved := entries[0]
+	moved.Pos = other.Pos(21)
+	if otherID := diagnosticFixID(moved, otherFset, patchPaths{execroot: filepath.Dir(other.Name())}); otherID != id {
+		t.Errorf("got ID %q in another execroot, want %q", otherID, id)
+	}
+	for _, e := range entries[1:] {
+		if otherID := diagnosticFixID(e, fset, paths); otherID == id {
+			t.Errorf("diagnostic %q has the same ID %q as %q", e.Message, id, entries[0].Message)
+		}
+	}
+
+	// Duplicate diagnostics get distinct IDs.
+	duplicates := []diagnosticEntry{entries[0], entries[0]}
+	changes, _ = getFixes(duplicates, fset)
+	fixes = splitByDiagnostic(changes, duplicates, fset, paths)
+	if len(fixes) != 2 || fixes[0].id != id || fixes[1].id != id+"-2" {
+		t.Errorf("got fixes %v for duplicate diagnostics, want IDs %q and %q", fixes, id, id+"-2")
+	}
+}
+
+func TestPatchPath(t *testing.T) {
+	execroot := filepath.Join(t.TempDir(), "execroot", "_main")
+	tests := []struct {
+		root          string
+		fileName      string
+		workspaceRoot string
+		packageDir    string
+		expected      string
+	}{
+		{patchRootExecroot, "external/repo/pkg/file.go", "external/repo", "external/repo/pkg", "external/repo/pkg/file.go"},
+		{patchRootWorkspace, "external/repo/pkg/file.go", "external/repo", "external/repo/pkg", "pkg/file.go"},
+		{patchRootPackage, "external/repo/pkg/file.go", "external/repo", "external/repo/pkg", "file.go"},
+		{patchRootWorkspace, "pkg/file.go", "", "pkg", "pkg/file.go"},
+		{patchRootPackage, "pkg/sub/file.go", "", "pkg", "sub/file.go"},
+		// Generated files are outside of the package and keep their path.
+		{patchRootPackage, "bazel-out/k8-fastbuild/bin/pkg/gen.go", "", "pkg", "bazel-out/k8-fastbuild/bin/pkg/gen.go"},
+		{patchRootPackage, "pkg2/file.go", "", "pkg", "pkg2/file.go"},
+		// Absolute paths in the execroot, e.g. in a sandbox, are made relative to it.
+		{patchRootExecroot, filepath.Join(execroot, "pkg/file.go"), "", "pkg", "pkg/file.go"},
+		{patchRootWorkspace, filepath.Join(execroot, "external/repo/pkg/file.go"), "external/repo", "external/repo/pkg", "pkg/file.go"},
+		{patchRootPackage, filepath.Join(execroot, "pkg/sub/file.go"), "", "pkg", "sub/file.go"},
+	}
+	for _, tt := range tests {
+		paths := patchPaths{execroot: execroot, baseDir: filepath.FromSlash(patchBaseDir(tt.root, tt.workspaceRoot, tt.packageDir))}
+		if got := paths.path(filepath.FromSlash(tt.fileName)); got != filepath.FromSlash(tt.expected) {
+			t.Errorf("path(%q) with root %s: got %q, want %q", tt.fileName, tt.root, got, tt.expected)
+		}
+	}
+
+	// Absolute paths outside of the execroot are kept.
+	outside := filepath.Join(filepath.Dir(execroot), "other", "file.go")
+	if got := (patchPaths{execroot: execroot}).path(outside); got != outside {
+		t.Errorf("path(%q): got %q, want it unchanged", outside, got)
+	}
+}
+
+func TestWritePatchWithBudget(t *testing.T) {
+	tmpDir := t.TempDir()
+	var changes []fileChange
+	for i := 0; i < 20; i++ {
+		fileName := filepath.Join(tmpDir, fmt.Sprintf("file%02d.go", i))
+		if err := os.WriteFile(fileName, []byte("package main\nvar x = 10\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		changes = append(changes, fileChange{fileName: fileName, changes: []nogoEdit{{Start: 21, End: 23, New: fmt.Sprint(100 + i)}}})
+	}
+
+	var unbounded bytes.Buffer
+	if _, err := writePatchWithBudget(&unbounded, changes, nil, patchPaths{}, nil, 3, 1<<30, 4, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// With a budget smaller than a single file, the files are diffed one at a
+	// time but the patch must be the same.
+	var sharded bytes.Buffer
+	if _, err := writePatchWithBudget(&sharded, changes, nil, patchPaths{}, nil, 3, 1, 4, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if unbounded.String() != sharded.String() {
+		t.Errorf("patches differ:\nunbounded:\n%s\nsharded:\n%s", unbounded.String(), sharded.String())
+	}
+	if n := strings.Count(sharded.String(), "+++ "); n != len(changes) {
+		t.Errorf("expected %d files in the patch, got %d", len(changes), n)
+	}
+
+	// An error stops the patch at the failing file.
+	changes = append(changes, fileChange{fileName: filepath.Join(tmpDir, "file10a.go"), changes: []nogoEdit{{Start: 1, End: 1, New: "x"}}})
+	var failed bytes.Buffer
+	if _, err := writePatchWithBudget(&failed, changes, nil, patchPaths{}, nil, 3, 1, 4, 0); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+	if n := strings.Count(failed.String(), "+++ "); n != 11 {
+		t.Errorf("expected the 11 files sorted before the missing file in the patch, got %d", n)
+	}
+}
+
+func TestWritePatchWithLimit(t *testing.T) {
+	src := map[string][]byte{
+		"a.go": []byte("package a\n\nvar x = 1\nvar y = 2\nvar z = 3\n"),
+		"b.go": []byte("package b\n\nvar x = 1\n"),
+	}
+	changes := []fileChange{
+		{fileName: "a.go", changes: []nogoEdit{{Start: 11, End: 41, New: "const (\n\tx = 1\n\ty = 2\n\tz = 3\n)\n"}}},
+		{fileName: "b.go", changes: []nogoEdit{{Start: 11, End: 14, New: "const"}}},
+	}
+	var full bytes.Buffer
+	if err := writePatch(&full, changes, src, patchPaths{}, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	bStart := strings.Index(full.String(), "--- a/b.go")
+
+	for _, tt := range []struct {
+		maxSize int64
+		want    string
+		omitted []string
+	}{
+		{0, full.String(), nil},
+		{int64(full.Len()), full.String(), nil},
+		// The diffs that don't fit are left out, but later ones still can be
+		// written.
+		{int64(full.Len() - bStart), full.String()[bStart:], []string{"a.go"}},
+		{1, "", []string{"a.go", "b.go"}},
+	} {
+		var patch bytes.Buffer
+		omitted, err := writePatchWithLimit(&patch, changes, src, patchPaths{}, nil, 0, tt.maxSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if patch.String() != tt.want {
+			t.Errorf("max size %d: got patch:\n%s\nwant:\n%s", tt.maxSize, patch.String(), tt.want)
+		}
+		var names []string
+		for _, c := range omitted {
+			names = append(names, c.fileName)
+		}
+		if !reflect.DeepEqual(names, tt.omitted) {
+			t.Errorf("max size %d: got omitted files %q, want %q", tt.maxSize, names, tt.omitted)
+		}
+	}
+}
+
+func TestFixFileWriter(t *testing.T) {
+	const patch = "--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-package a\n+package b\n"
+	for _, compression := range []string{fixCompressionNone, fixCompressionGzip} {
+		var empty bytes.Buffer
+		w := newFixFileWriter(&empty, compression)
+		if _, err := w.Write(nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if empty.Len() != 0 {
+			t.Errorf("%s: got %d bytes without fixes, want an empty file", compression, empty.Len())
+		}
+
+		var buf bytes.Buffer
+		w = newFixFileWriter(&buf, compression)
+		if _, err := w.Write([]byte(patch)); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		content, compressed, err := decompressFixFile(buf.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != patch || compressed != (compression == fixCompressionGzip) {
+			t.Errorf("%s: got %q, compressed %v, want %q", compression, content, compressed, patch)
+		}
+	}
+}
+
+func TestWritePatchWithBudget_Jobs(t *testing.T) {
+	tmpDir := t.TempDir()
+	var changes []fileChange
+	for i := 0; i < 20; i++ {
+		fileName := filepath.Join(tmpDir, fmt.Sprintf("file%02d.go", i))
+		if err := os.WriteFile(fileName, []byte("package main\nvar x = 10\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		changes = append(changes, fileChange{fileName: fileName, changes: []nogoEdit{{Start: 21, End: 23, New: fmt.Sprint(100 + i)}}})
+	}
+	// The formatter runs while a file is diffed and tracks how many files are
+	// diffed at once.
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	format := func(original, fixed []byte) []byte {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return fixed
+	}
+
+	var sequential bytes.Buffer
+	if _, err := writePatchWithBudget(&sequential, changes, nil, patchPaths{}, format, 3, 1<<30, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if maxRunning != 1 {
+		t.Errorf("diffed up to %d files at once with 1 job", maxRunning)
+	}
+	maxRunning = 0
+	var parallel bytes.Buffer
+	if _, err := writePatchWithBudget(&parallel, changes, nil, patchPaths{}, format, 3, 1<<30, 3, 0); err != nil {
+		t.Fatal(err)
+	}
+	if maxRunning > 3 {
+		t.Errorf("diffed up to %d files at once with 3 jobs", maxRunning)
+	}
+	if sequential.String() != parallel.String() {
+		t.Errorf("patches differ:\nsequential:\n%s\nparallel:\n%s", sequential.String(), parallel.String())
+	}
+}
+
+func TestDedupeDiagnostics(t *testing.T) {
+	fset := token.NewFileSet()
+	f := fset.AddFile("pkg/a.go", fset.Base(), 100)
+	fix := []analysis.SuggestedFix{{TextEdits: []analysis.TextEdit{{Pos: f.Pos(1), End: f.Pos(2)}}}}
+	entry := func(analyzer string, offset int, message string, fixes []analysis.SuggestedFix, fixOnly bool) diagnosticEntry {
+		pos := token.NoPos
+		if offset >= 0 {
+			pos = f.Pos(offset)
+		}
+		return diagnosticEntry{
+			Diagnostic:   analysis.Diagnostic{Pos: pos, Message: message, SuggestedFixes: fixes},
+			analyzerName: analyzer,
+			fixOnly:      fixOnly,
+		}
+	}
+	diagnostics := []diagnosticEntry{
+		entry("printf", 10, "Sprintf format %d has arg of wrong type", nil, false),
+		entry("printfclone", 10, "sprintf format %d  has arg of wrong type.", fix, false),
+		entry("zeta", 20, "unused result", nil, false),
+		entry("alpha", 20, "unused result", nil, false),
+		entry("fixer", 30, "use strings.Cut", fix, true),
+		entry("linter", 30, "use strings.Cut", nil, false),
+		entry("other", 30, "different message", nil, false),
+		entry("panicky", -1, "analyzer panicked: boom", nil, false),
+		entry("panicky2", -1, "analyzer panicked: boom", nil, false),
+	}
+
+	var got []string
+	for _, d := range dedupeDiagnostics(diagnostics) {
+		got = append(got, d.analyzerName)
+	}
+	want := []string{"printfclone", "alpha", "linter", "other", "panicky", "panicky2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got analyzers %q, want %q", got, want)
+	}
+}
+
+func TestRelatedLines(t *testing.T) {
+	fset := token.NewFileSet()
+	f := fset.AddFile("pkg/a.go", fset.Base(), 100)
+	f.SetLines([]int{0, 20, 40})
+	d := analysis.Diagnostic{
+		Pos:     f.Pos(45),
+		Message: "x declared and not used",
+		Related: []analysis.RelatedInformation{
+			{Pos: f.Pos(22), End: f.Pos(23), Message: "x declared here"},
+			{Message: "no position"},
+		},
+	}
+	want := []string{"pkg/a.go:2:3: x declared here", "no position"}
+	if got := relatedLines(d, fset); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestLimitDiagnostics(t *testing.T) {
+	diagnostics := []diagnosticEntry{{analyzerName: "a"}, {analyzerName: "b"}, {analyzerName: "c"}}
+	for _, tt := range []struct {
+		max            int
+		wantKept       int
+		wantSuppressed int
+	}{
+		{max: 0, wantKept: 3},
+		{max: 3, wantKept: 3},
+		{max: 5, wantKept: 3},
+		{max: 2, wantKept: 2, wantSuppressed: 1},
+		{max: 1, wantKept: 1, wantSuppressed: 2},
+	} {
+		kept, suppressed := limitDiagnostics(diagnostics, tt.max)
+		if !reflect.DeepEqual(kept, diagnostics[:tt.wantKept]) || suppressed != tt.wantSuppressed {
+			t.Errorf("limitDiagnostics(%d) kept %d and suppressed %d diagnostics, want %d and %d", tt.max, len(kept), suppressed, tt.wantKept, tt.wantSuppressed)
+		}
+	}
+}
+
+func TestSortDiagnostics(t *testing.T) {
+	fset := token.NewFileSet()
+	// b.go is added first, so its positions are lower than those of a.go.
+	b := fset.AddFile("pkg/b.go", fset.Base(), 100)
+	a := fset.AddFile("pkg/a.go", fset.Base(), 100)
+	entry := func(analyzer string, pos token.Pos, message string) diagnosticEntry {
+		return diagnosticEntry{
+			Diagnostic:   analysis.Diagnostic{Pos: pos, Message: message},
+			analyzerName: analyzer,
+		}
+	}
+	diagnostics := []diagnosticEntry{
+		entry("printf", b.Pos(5), "b5"),
+		entry("shadow", a.Pos(30), "a30"),
+		entry("printf", a.Pos(30), "a30 second"),
+		entry("printf", a.Pos(30), "a30 first"),
+		entry("printf", a.Pos(10), "a10"),
+		entry("panicky", token.NoPos, "analyzer panicked"),
+	}
+	sortDiagnostics(diagnostics, fset)
+	var got []string
+	for _, d := range diagnostics {
+		got = append(got, d.analyzerName+": "+d.Message)
+	}
+	want := []string{
+		"panicky: analyzer panicked",
+		"printf: a10",
+		"printf: a30 first",
+		"printf: a30 second",
+		"shadow: a30",
+		"printf: b5",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got order %q, want %q", got, want)
+	}
+}
+
+func TestGetFixes_FileOrder(t *testing.T) {
+	fset := token.NewFileSet()
+	var entries []diagnosticEntry
+	for _, name := range []string{"c.go", "a.go", "d.go", "b.go"} {
+		f := fset.AddFile(name, fset.Base(), 100)
+		entries = append(entries, diagnosticEntry{
+			analyzerName: "analyzer",
+			Diagnostic: analysis.Diagnostic{
+				SuggestedFixes: []analysis.SuggestedFix{{
+					TextEdits: []analysis.TextEdit{{Pos: f.Pos(1), End: f.Pos(2), NewText: []byte("x")}},
+				}},
+			},
+		})
+	}
+	// The order doesn't depend on the iteration order of maps.
+	for i := 0; i < 10; i++ {
+		changes, err := getFixes(entries, fset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, c := range changes {
+			got = append(got, c.fileName)
+		}
+		if want := []string{"a.go", "b.go", "c.go", "d.go"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got files %q, want %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This file defines the structured JSON fix file written by nogo next to the
+// patch, the metadata lines of the patch and how compressed fix files are
+// read. It is shared by nogo, which writes them, and nogo_apply, which derives
+// patches from the fix file and checks the metadata.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fixFileVersion is the version of the structured fix file format. It is
+// incremented for changes that older readers can't ignore. Keep it in sync
+// with emptyFixFileJSON.
+const fixFileVersion = 1
+
+// devNull is the name of the missing side of the diff of a file that a fix
+// creates or deletes, as in the patches written by git.
+const devNull = "/dev/null"
+
+// A fixFile holds the fixes selected by nogo for a single package. A file
+// that doesn't exist is created by inserting its content at offset 0, and a
+// file is deleted by an edit that removes all of its content.
+type fixFile struct {
+	Version int `json:"version"`
+	// Digest is the digest of the edits, see fixFile.digest. It is omitted if
+	// there are no edits.
+	Digest string `json:"digest,omitempty"`
+	// Files maps the paths of the fixed files, which are relative to the
+	// patch root like the paths in the patch, to the edits of each analyzer.
+	Files map[string]map[string][]offsetEdit `json:"files"`
+}
+
+// An offsetEdit replaces the bytes [Start, End) of the original content of a
+// file with New.
+type offsetEdit struct {
+	New   string `json:"new"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// An editOverlap describes how an edit relates to the edit before it when the
+// edits of a file are sorted by their start and end offsets.
+type editOverlap int
+
+const (
+	// editsDisjoint edits don't overlap. Insertions at the same offset are
+	// disjoint and applied in order.
+	editsDisjoint editOverlap = iota
+	// editsIdentical edits make the same change, which is applied once.
+	editsIdentical
+	// editsOverlapping edits change the same text differently and can't be
+	// applied together.
+	editsOverlapping
+)
+
+// compareEdits returns how cur relates to prev, which comes before it in the
+// order of start and end offsets. nogo, when it selects the edits of the
+// fixes, and nogo_apply, when it applies the edits of a fix file, both follow
+// these rules.
+func compareEdits(prev, cur offsetEdit) editOverlap {
+	switch {
+	case prev == cur:
+		return editsIdentical
+	case prev.End > cur.Start:
+		return editsOverlapping
+	default:
+		return editsDisjoint
+	}
+}
+
+// digest returns a hash of the edits in the fix file. It doesn't depend on
+// the order of the edits or on the version of the file, so that the fixes of
+// a package built in different configurations, which are usually identical,
+// can be recognized as such and only processed once.
+func (f fixFile) digest() string {
+	h := sha256.New()
+	writeString := func(s string) {
+		fmt.Fprintf(h, "%d:%s", len(s), s)
+	}
+	names := make([]string, 0, len(f.Files))
+	for name := range f.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		analyzerToEdits := f.Files[name]
+		var analyzers []string
+		for analyzer, edits := range analyzerToEdits {
+			if len(edits) > 0 {
+				analyzers = append(analyzers, analyzer)
+			}
+		}
+		if len(analyzers) == 0 {
+			continue
+		}
+		sort.Strings(analyzers)
+		writeString(name)
+		fmt.Fprintf(h, "%d\n", len(analyzers))
+		for _, analyzer := range analyzers {
+			edits := make([]offsetEdit, len(analyzerToEdits[analyzer]))
+			copy(edits, analyzerToEdits[analyzer])
+			sort.Slice(edits, func(i, j int) bool {
+				if edits[i].Start != edits[j].Start {
+					return edits[i].Start < edits[j].Start
+				}
+				if edits[i].End != edits[j].End {
+					return edits[i].End < edits[j].End
+				}
+				return edits[i].New < edits[j].New
+			})
+			writeString(analyzer)
+			fmt.Fprintf(h, "%d\n", len(edits))
+			for _, e := range edits {
+				fmt.Fprintf(h, "%d-%d:", e.Start, e.End)
+				writeString(e.New)
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// patchMetadataPrefix starts the comment lines of a patch that describe the
+// diff of the file following them as key=value pairs, see
+// formatPatchMetadata. Tools applying the patch ignore them like any other
+// comment.
+const patchMetadataPrefix = "# nogo: "
+
+// The keys of the metadata of a patch.
+const (
+	// metadataTarget is the label of the target whose analysis suggested the
+	// fixes.
+	metadataTarget = "target"
+	// metadataAnalyzer and metadataMessage describe a diagnostic whose fix
+	// contributed to the diff.
+	metadataAnalyzer = "analyzer"
+	metadataMessage  = "message"
+	// metadataSHA256 is the content digest of the file the diff was computed
+	// against, see contentDigest.
+	metadataSHA256 = "sha256"
+	// metadataStrip is the number of leading path components to strip from
+	// the file names of the patch, like patch -pN. Unlike the other keys, it
+	// applies to the whole patch and precedes its first file section.
+	metadataStrip = "strip"
+)
+
+// formatPatchMetadata returns a metadata line, without line terminator, with
+// the given keys and values in alternating order. Values that are empty or
+// contain spaces, quotes or equal signs are quoted like Go strings.
+func formatPatchMetadata(keyValues ...string) string {
+	var b strings.Builder
+	b.WriteString(patchMetadataPrefix)
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		value := keyValues[i+1]
+		if value == "" || strings.ContainsAny(value, " \t\r\n\"=") || !strconv.CanBackquote(value) {
+			value = strconv.Quote(value)
+		}
+		b.WriteString(keyValues[i])
+		b.WriteByte('=')
+		b.WriteString(value)
+	}
+	return b.String()
+}
+
+// parsePatchMetadata parses a line written by formatPatchMetadata. It returns
+// false if the line is not a metadata line or is malformed.
+func parsePatchMetadata(line string) (map[string]string, bool) {
+	if !strings.HasPrefix(line, patchMetadataPrefix) {
+		return nil, false
+	}
+	rest := strings.TrimRight(line[len(patchMetadataPrefix):], "\r\n")
+	metadata := make(map[string]string)
+	for rest != "" {
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 || strings.ContainsAny(rest[:eq], " \"") {
+			return nil, false
+		}
+		key := rest[:eq]
+		rest = rest[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := 1
+			for end < len(rest) && rest[end] != '"' {
+				if rest[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(rest) {
+				return nil, false
+			}
+			var err error
+			if value, err = strconv.Unquote(rest[:end+1]); err != nil {
+				return nil, false
+			}
+			rest = rest[end+1:]
+		} else {
+			end := strings.IndexByte(rest, ' ')
+			if end < 0 {
+				end = len(rest)
+			}
+			value, rest = rest[:end], rest[end:]
+		}
+		if rest != "" && rest[0] != ' ' {
+			return nil, false
+		}
+		metadata[key] = value
+		rest = strings.TrimLeft(rest, " ")
+	}
+	return metadata, true
+}
+
+// contentDigest returns the hex SHA-256 digest of the content of a file, as
+// in the sha256 metadata of a patch.
+func contentDigest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// recordedStrip returns the strip level recorded in the metadata at the start of
+// a patch, before its first file section, and false if there is none.
+func recordedStrip(data []byte) (int, bool) {
+	for len(data) > 0 {
+		line := data
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line, data = data[:i], data[i+1:]
+		} else {
+			data = nil
+		}
+		if bytes.HasPrefix(line, []byte("--- ")) {
+			break
+		}
+		metadata, ok := parsePatchMetadata(string(line))
+		if !ok || metadata[metadataStrip] == "" {
+			continue
+		}
+		strip, err := strconv.Atoi(metadata[metadataStrip])
+		if err != nil || strip < 0 {
+			return 0, false
+		}
+		return strip, true
+	}
+	return 0, false
+}
+
+// gzipMagic starts the content of fix files compressed with gzip. Neither
+// patches nor JSON start with it.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// readFixFile reads a patch or a structured fix file and decompresses it if
+// nogo compressed it, see fix_compression. It reports whether the file was
+// compressed.
+func readFixFile(path string) ([]byte, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	return decompressFixFile(data)
+}
+
+// decompressFixFile returns the decompressed content of a fix file compressed
+// with gzip and other content unchanged.
+func decompressFixFile(data []byte) ([]byte, bool, error) {
+	if !bytes.HasPrefix(data, gzipMagic) {
+		return data, false, nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, false, fmt.Errorf("decompressing fix file: %v", err)
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, false, fmt.Errorf("decompressing fix file: %v", err)
+	}
+	return content, true, nil
+}
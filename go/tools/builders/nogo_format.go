@@ -0,0 +1,113 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"strconv"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// newFixFormatter returns the formatter for the fixed files selected by mode,
+// one of the fixFormat constants, or nil if they are not formatted.
+// importNames maps the import paths of the package to the names of the
+// imported packages.
+func newFixFormatter(mode string, importNames map[string]string) fixFormatter {
+	switch mode {
+	case fixFormatGofmt:
+		return func(original, fixed []byte) []byte {
+			return formatFixed(original, fixed, nil)
+		}
+	case fixFormatGoimports:
+		if importNames == nil {
+			importNames = map[string]string{}
+		}
+		return func(original, fixed []byte) []byte {
+			return formatFixed(original, fixed, importNames)
+		}
+	default:
+		return nil
+	}
+}
+
+// formatFixed formats a file after applying fixes to it like gofmt. If
+// importNames is not nil, imports that are no longer used are removed as well.
+// The fixed file is returned as is if it can't be parsed or if the original
+// file wasn't formatted, so that the fixes don't reformat unrelated code.
+func formatFixed(original, fixed []byte, importNames map[string]string) []byte {
+	if formatted, err := format.Source(original); err != nil || !bytes.Equal(formatted, original) {
+		return fixed
+	}
+	src := fixed
+	if importNames != nil {
+		if pruned, err := removeUnusedImports(fixed, importNames); err == nil {
+			src = pruned
+		}
+	}
+	formatted, err := format.Source(src)
+	if err != nil {
+		return fixed
+	}
+	return formatted
+}
+
+// removeUnusedImports removes the imports of a file whose package name isn't
+// referenced anymore. Imports of packages whose name is unknown, such as
+// imports added by the fixes, are kept.
+func removeUnusedImports(src []byte, importNames map[string]string) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	// References to imported packages are never resolved by the parser.
+	used := make(map[string]bool)
+	for _, id := range f.Unresolved {
+		used[id.Name] = true
+	}
+
+	removed := false
+	imports := append(f.Imports[:0:0], f.Imports...)
+	for _, spec := range imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil || path == "C" {
+			continue
+		}
+		name, pkgName := "", ""
+		if spec.Name != nil {
+			name, pkgName = spec.Name.Name, spec.Name.Name
+		} else if pkgName = importNames[path]; pkgName == "" {
+			continue
+		}
+		if pkgName == "_" || pkgName == "." || used[pkgName] {
+			continue
+		}
+		if astutil.DeleteNamedImport(fset, f, name, path) {
+			removed = true
+		}
+	}
+	if !removed {
+		return src, nil
+	}
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, f); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
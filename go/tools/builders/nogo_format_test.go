@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const formatTestSource = `package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func Hello() {
+	fmt.Println(strings.ToUpper("hello"))
+}
+`
+
+// formatTestFix removes the use of strings and leaves the call misindented.
+func formatTestFix(src string) string {
+	return strings.Replace(src, `	fmt.Println(strings.ToUpper("hello"))`, `fmt.Println("HELLO")`, 1)
+}
+
+func TestNewFixFormatter(t *testing.T) {
+	importNames := map[string]string{"fmt": "fmt", "strings": "strings"}
+	unformatted := strings.Replace(formatTestSource, "func Hello() {", "func Hello()  {", 1)
+
+	tests := []struct {
+		name     string
+		mode     string
+		original string
+		fixed    string // defaults to formatTestFix(original)
+		expected string
+	}{
+		{
+			name:     "gofmt",
+			mode:     fixFormatGofmt,
+			original: formatTestSource,
+			expected: strings.Replace(formatTestSource, `strings.ToUpper("hello")`, `"HELLO"`, 1),
+		},
+		{
+			name:     "goimports",
+			mode:     fixFormatGoimports,
+			original: formatTestSource,
+			expected: `package main
+
+import (
+	"fmt"
+)
+
+func Hello() {
+	fmt.Println("HELLO")
+}
+`,
+		},
+		{
+			// Unformatted files are not reformatted.
+			name:     "unformatted original",
+			mode:     fixFormatGoimports,
+			original: unformatted,
+			expected: formatTestFix(unformatted),
+		},
+		{
+			// Fixes that break the syntax are kept as is.
+			name:     "syntax error",
+			mode:     fixFormatGofmt,
+			original: formatTestSource,
+			fixed:    strings.TrimSuffix(formatTestSource, "}\n"),
+			expected: strings.TrimSuffix(formatTestSource, "}\n"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixed := tt.fixed
+			if fixed == "" {
+				fixed = formatTestFix(tt.original)
+			}
+			got := string(newFixFormatter(tt.mode, importNames)([]byte(tt.original), []byte(fixed)))
+			if got != tt.expected {
+				t.Errorf("unexpected result:\n\tgot:\n%s\n\twant:\n%s", got, tt.expected)
+			}
+		})
+	}
+
+	if newFixFormatter(fixFormatNone, importNames) != nil {
+		t.Error("expected no formatter for fixFormatNone")
+	}
+}
+
+func TestRemoveUnusedImports(t *testing.T) {
+	src := `package main
+
+import (
+	_ "embed"
+	"example.com/renamed/v2"
+	"example.com/unknown"
+	"fmt"
+	yaml "gopkg.in/yaml.v3"
+)
+
+var _ = v2.X
+`
+	// The name of the package example.com/renamed/v2 is "renamed", so v2.X
+	// refers to something else and the import is unused.
+	importNames := map[string]string{"fmt": "fmt", "embed": "embed", "gopkg.in/yaml.v3": "yaml", "example.com/renamed/v2": "renamed"}
+	got, err := removeUnusedImports([]byte(src), importNames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `package main
+
+import (
+	_ "embed"
+	"example.com/unknown"
+)
+
+var _ = v2.X
+`
+	if string(got) != expected {
+		t.Errorf("unexpected result:\n\tgot:\n%s\n\twant:\n%s", got, expected)
+	}
+}
+
+func TestWritePatch_Format(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.go")
+	if err := os.WriteFile(path, []byte(formatTestSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := `	fmt.Println(strings.ToUpper("hello"))`
+	start := strings.Index(formatTestSource, old)
+	changes := []fileChange{{
+		fileName: path,
+		changes:  []nogoEdit{{Start: start, End: start + len(old), New: `fmt.Println("HELLO")`}},
+	}}
+
+	var patch bytes.Buffer
+	if err := writePatch(&patch, changes, nil, patchPaths{}, newFixFormatter(fixFormatGoimports, map[string]string{"strings": "strings"}), 3); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, line := range []string{"-\t\"strings\"\n", "+\tfmt.Println(\"HELLO\")\n"} {
+		if !strings.Contains(patch.String(), line) {
+			t.Errorf("expected the patch to contain %q:\n%s", line, patch.String())
+		}
+	}
+}
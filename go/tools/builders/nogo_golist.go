@@ -0,0 +1,159 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// A listedPackage is a package as printed by go list -json. Fields that nogo
+// doesn't use are omitted.
+type listedPackage struct {
+	// ImportPath is the import path of the package. The variants of packages
+	// compiled for a test have the test in brackets, e.g.
+	// "example.com/a [example.com/a.test]".
+	ImportPath string
+	Name       string
+	Dir        string
+	// ForTest is the package whose test the package is compiled for.
+	ForTest  string
+	DepOnly  bool
+	Standard bool
+	// Export is the file with the export data of the package.
+	Export string
+	// CompiledGoFiles are the files given to the compiler, relative to Dir
+	// unless they are generated, e.g. by cgo.
+	CompiledGoFiles []string
+	// ImportMap maps the import paths in the source of the package to the
+	// packages they resolve to, if these differ.
+	ImportMap map[string]string
+	// Deps are the import paths of all the dependencies of the package.
+	Deps  []string
+	Error *struct {
+		Err string
+	}
+}
+
+// listedPackageFields are the fields of listedPackage, for go list -json.
+const listedPackageFields = "ImportPath,Name,Dir,ForTest,DepOnly,Standard,Export,CompiledGoFiles,ImportMap,Deps,Error"
+
+// goList lists the packages matching the patterns and their dependencies,
+// dependencies first, with the export data that the go command compiles for
+// them. With tests, the test variants of the packages are listed as well.
+func goList(dir, tags string, tests bool, patterns []string) ([]*listedPackage, error) {
+	args := []string{"list", "-e", "-export", "-deps", "-compiled", "-json=" + listedPackageFields}
+	if tests {
+		args = append(args, "-test")
+	}
+	if tags != "" {
+		args = append(args, "-tags", tags)
+	}
+	args = append(args, "--")
+	args = append(args, patterns...)
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list failed: %v\n%s", err, stderr.Bytes())
+	}
+	return decodeListedPackages(bytes.NewReader(out))
+}
+
+// decodeListedPackages decodes the stream of JSON objects printed by go list.
+func decodeListedPackages(r io.Reader) ([]*listedPackage, error) {
+	var pkgs []*listedPackage
+	dec := json.NewDecoder(r)
+	for dec.More() {
+		var p listedPackage
+		if err := dec.Decode(&p); err != nil {
+			return nil, fmt.Errorf("decoding the output of go list: %v", err)
+		}
+		pkgs = append(pkgs, &p)
+	}
+	return pkgs, nil
+}
+
+// listedPackagePath returns the package path of the listed package, without
+// the test it may be compiled for. It is the path of the package in its export
+// data and in the facts of nogo.
+func listedPackagePath(importPath string) string {
+	if i := strings.Index(importPath, " ["); i >= 0 {
+		return importPath[:i]
+	}
+	return importPath
+}
+
+// isTestMain reports whether the package is the main package that go test
+// generates to run the tests of a package.
+func isTestMain(p *listedPackage) bool {
+	return p.Name == "main" && p.ForTest == "" && strings.HasSuffix(p.ImportPath, ".test")
+}
+
+// reportedPackages returns the import paths of the listed packages whose
+// findings are reported: the packages matching the patterns, replaced by their
+// test variant if their tests are listed, and their external tests. Other
+// packages are only analyzed for facts.
+func reportedPackages(pkgs []*listedPackage) map[string]bool {
+	tested := make(map[string]bool)
+	for _, p := range pkgs {
+		if p.ForTest != "" && listedPackagePath(p.ImportPath) == p.ForTest {
+			tested[p.ForTest] = true
+		}
+	}
+	reported := make(map[string]bool)
+	for _, p := range pkgs {
+		if p.DepOnly || p.Standard || isTestMain(p) {
+			continue
+		}
+		path := listedPackagePath(p.ImportPath)
+		if p.ForTest == "" && !tested[p.ImportPath] || p.ForTest != "" && (path == p.ForTest || path == p.ForTest+"_test") {
+			reported[p.ImportPath] = true
+		}
+	}
+	return reported
+}
+
+// listedImportcfg returns the import configuration of the package in the form
+// that nogo gets it in from the build: the archives with the export data of
+// its dependencies and the package paths of its imports that differ from
+// them, and the facts of its dependencies among factFiles, which holds the
+// facts of the packages analyzed so far by import path.
+func listedImportcfg(p *listedPackage, byImportPath map[string]*listedPackage, factFiles map[string]string) (packageFile, importMap, factMap map[string]string) {
+	packageFile = make(map[string]string)
+	factMap = make(map[string]string)
+	for _, dep := range p.Deps {
+		path := listedPackagePath(dep)
+		if d, ok := byImportPath[dep]; ok && d.Export != "" {
+			packageFile[path] = d.Export
+		}
+		if facts, ok := factFiles[dep]; ok {
+			factMap[path] = facts
+		}
+	}
+	importMap = make(map[string]string)
+	for imp, dep := range p.ImportMap {
+		if path := listedPackagePath(dep); path != imp {
+			importMap[imp] = path
+		}
+	}
+	return packageFile, importMap, factMap
+}
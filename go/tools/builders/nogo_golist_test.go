@@ -0,0 +1,133 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// listOutput is the output of go list -test -deps for the packages a, which
+// has internal and external tests, and b, which imports a, reduced to the
+// packages of the module and fmt.
+const listOutput = `{
+	"ImportPath": "fmt",
+	"Name": "fmt",
+	"Standard": true,
+	"DepOnly": true,
+	"Export": "/cache/fmt-d"
+}
+{
+	"ImportPath": "example.com/m/a",
+	"Name": "a",
+	"Dir": "/src/m/a",
+	"Export": "/cache/a-d",
+	"CompiledGoFiles": ["a.go"],
+	"Deps": ["fmt"]
+}
+{
+	"ImportPath": "example.com/m/b",
+	"Name": "b",
+	"Dir": "/src/m/b",
+	"Export": "/cache/b-d",
+	"CompiledGoFiles": ["b.go"],
+	"Deps": ["example.com/m/a", "fmt"]
+}
+{
+	"ImportPath": "example.com/m/a [example.com/m/a.test]",
+	"Name": "a",
+	"Dir": "/src/m/a",
+	"ForTest": "example.com/m/a",
+	"Export": "/cache/a.test-d",
+	"CompiledGoFiles": ["a.go", "a_test.go"],
+	"Deps": ["fmt"]
+}
+{
+	"ImportPath": "example.com/m/a_test [example.com/m/a.test]",
+	"Name": "a_test",
+	"Dir": "/src/m/a",
+	"ForTest": "example.com/m/a",
+	"Export": "/cache/a_test-d",
+	"CompiledGoFiles": ["x_test.go"],
+	"ImportMap": {"example.com/m/a": "example.com/m/a [example.com/m/a.test]"},
+	"Deps": ["example.com/m/a [example.com/m/a.test]", "fmt"]
+}
+{
+	"ImportPath": "example.com/m/a.test",
+	"Name": "main",
+	"Dir": "/src/m/a",
+	"Export": "/cache/main-d",
+	"ImportMap": {
+		"example.com/m/a": "example.com/m/a [example.com/m/a.test]",
+		"example.com/m/a_test": "example.com/m/a_test [example.com/m/a.test]"
+	},
+	"Deps": ["example.com/m/a [example.com/m/a.test]", "example.com/m/a_test [example.com/m/a.test]", "fmt"]
+}
+`
+
+func TestReportedPackages(t *testing.T) {
+	pkgs, err := decodeListedPackages(strings.NewReader(listOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 6 {
+		t.Fatalf("got %d packages, want 6", len(pkgs))
+	}
+	got := reportedPackages(pkgs)
+	want := map[string]bool{
+		"example.com/m/b":                             true,
+		"example.com/m/a [example.com/m/a.test]":      true,
+		"example.com/m/a_test [example.com/m/a.test]": true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got reported packages %v, want %v", got, want)
+	}
+
+	// Without tests, the packages themselves are reported.
+	got = reportedPackages(pkgs[:3])
+	want = map[string]bool{
+		"example.com/m/a": true,
+		"example.com/m/b": true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got reported packages %v without tests, want %v", got, want)
+	}
+}
+
+func TestListedImportcfg(t *testing.T) {
+	pkgs, err := decodeListedPackages(strings.NewReader(listOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	byImportPath := make(map[string]*listedPackage)
+	for _, p := range pkgs {
+		byImportPath[p.ImportPath] = p
+	}
+	factFiles := map[string]string{
+		"example.com/m/a":                        "/tmp/1.x",
+		"example.com/m/a [example.com/m/a.test]": "/tmp/3.x",
+	}
+	packageFile, importMap, factMap := listedImportcfg(byImportPath["example.com/m/a_test [example.com/m/a.test]"], byImportPath, factFiles)
+	if want := map[string]string{"example.com/m/a": "/cache/a.test-d", "fmt": "/cache/fmt-d"}; !reflect.DeepEqual(packageFile, want) {
+		t.Errorf("got package files %v, want %v", packageFile, want)
+	}
+	if len(importMap) != 0 {
+		t.Errorf("got import map %v, want the test variant to be imported by its package path", importMap)
+	}
+	if want := map[string]string{"example.com/m/a": "/tmp/3.x"}; !reflect.DeepEqual(factMap, want) {
+		t.Errorf("got fact files %v, want %v", factMap, want)
+	}
+
+	vendored := &listedPackage{
+		ImportPath: "example.com/m/c",
+		ImportMap:  map[string]string{"golang.org/x/text": "example.com/m/vendor/golang.org/x/text"},
+	}
+	if _, importMap, _ := listedImportcfg(vendored, byImportPath, factFiles); !reflect.DeepEqual(importMap, vendored.ImportMap) {
+		t.Errorf("got import map %v for a vendored import, want %v", importMap, vendored.ImportMap)
+	}
+}
+
+func TestDecodeListedPackages_Invalid(t *testing.T) {
+	if _, err := decodeListedPackages(strings.NewReader(`{"ImportPath": `)); err == nil {
+		t.Error("decodeListedPackages accepted truncated output")
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"go/token"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// inspectionProjectDir is the macro IntelliJ-based IDEs expand to the root of
+// the opened project when importing inspection results. Source paths seen by
+// nogo are relative to the execroot, which mirrors the workspace layout.
+const inspectionProjectDir = "file://$PROJECT_DIR$/"
+
+// inspectionFileSuffix is the suffix of the inspection results files declared
+// for each Go package.
+const inspectionFileSuffix = ".nogo.xml"
+
+// inspectionProblems is the root element of an inspection results file as
+// exported by IntelliJ-based IDEs (Code | Inspect Code | Export to XML) and
+// loaded by their "Import Results" action.
+type inspectionProblems struct {
+	XMLName  xml.Name            `xml:"problems"`
+	Problems []inspectionProblem `xml:"problem"`
+}
+
+type inspectionProblem struct {
+	File         string                 `xml:"file"`
+	Line         int                    `xml:"line"`
+	Offset       int                    `xml:"offset"`
+	Length       int                    `xml:"length"`
+	Package      string                 `xml:"package"`
+	EntryPoint   inspectionEntryPoint   `xml:"entry_point"`
+	ProblemClass inspectionProblemClass `xml:"problem_class"`
+	Description  string                 `xml:"description"`
+}
+
+type inspectionEntryPoint struct {
+	Type   string `xml:"TYPE,attr"`
+	FQName string `xml:"FQNAME,attr"`
+}
+
+type inspectionProblemClass struct {
+	ID           string `xml:"id,attr"`
+	Severity     string `xml:"severity,attr"`
+	AttributeKey string `xml:"attribute_key,attr"`
+	Name         string `xml:",chardata"`
+}
+
+// inspectionSeverity returns the IDE severity of findings of the given severity
+// and the key of the attributes they are highlighted with.
+func inspectionSeverity(severity string) (string, string) {
+	switch severity {
+	case severityWarning:
+		return "WARNING", "WARNING_ATTRIBUTES"
+	case severityNote:
+		return "WEAK WARNING", "INFO_ATTRIBUTES"
+	default:
+		return "ERROR", "ERROR_ATTRIBUTES"
+	}
+}
+
+// writeInspectionXML writes the diagnostics in the inspection results format
+// understood by IntelliJ IDEA and GoLand. The document is always well-formed,
+// even when there are no diagnostics, so that it can be imported as-is.
+func writeInspectionXML(w io.Writer, packagePath string, diagnostics []diagnosticEntry, fset *token.FileSet) error {
+	doc := inspectionProblems{Problems: []inspectionProblem{}}
+	for _, d := range diagnostics {
+		pos := fset.Position(d.Pos)
+		if !pos.IsValid() {
+			// Diagnostics without a position can't be shown in the IDE.
+			continue
+		}
+		length := 0
+		if end := fset.Position(d.End); d.End.IsValid() && end.Filename == pos.Filename && end.Offset > pos.Offset {
+			length = end.Offset - pos.Offset
+		}
+		file := inspectionProjectDir + filepath.ToSlash(pos.Filename)
+		severity, attributeKey := inspectionSeverity(d.findingSeverity())
+		doc.Problems = append(doc.Problems, inspectionProblem{
+			File:    file,
+			Line:    pos.Line,
+			Offset:  pos.Column - 1,
+			Length:  length,
+			Package: packagePath,
+			EntryPoint: inspectionEntryPoint{
+				Type:   "file",
+				FQName: file,
+			},
+			ProblemClass: inspectionProblemClass{
+				ID:           d.analyzerName,
+				Severity:     severity,
+				AttributeKey: attributeKey,
+				Name:         d.analyzerName,
+			},
+			Description: d.Message,
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("encoding inspection results: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// loadFindings reads an inspection results file or all such files in a
+// directory tree.
+func loadFindings(path string) ([]inspectionProblem, error) {
+	files, err := loadFindingFiles(path)
+	var findings []inspectionProblem
+	for _, problems := range files {
+		findings = append(findings, problems...)
+	}
+	return findings, err
+}
+
+// loadFindingFiles is like loadFindings, but keeps the findings of each
+// inspection results file, and thus of each compiled package, separate.
+func loadFindingFiles(path string) ([][]inspectionProblem, error) {
+	// bazel-bin and friends are symlinks, which WalkDir doesn't follow.
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		problems, err := readInspectionXML(path)
+		if err != nil {
+			return nil, err
+		}
+		return [][]inspectionProblem{problems}, nil
+	}
+	var files [][]inspectionProblem
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(p, inspectionFileSuffix) {
+			return nil
+		}
+		problems, err := readInspectionXML(p)
+		if err != nil {
+			return err
+		}
+		files = append(files, problems)
+		return nil
+	})
+	return files, err
+}
+
+func readInspectionXML(path string) ([]inspectionProblem, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc inspectionProblems
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	return doc.Problems, nil
+}
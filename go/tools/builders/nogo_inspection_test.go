@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"go/token"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+func TestWriteInspectionXML(t *testing.T) {
+	fset := token.NewFileSet()
+	f := fset.AddFile("pkg/file1.go", fset.Base(), 100)
+	f.AddLine(0)
+	f.AddLine(20)
+	f.AddLine(40)
+
+	diagnostics := []diagnosticEntry{
+		{
+			analyzerName: "analyzer1",
+			Diagnostic: analysis.Diagnostic{
+				Pos:     token.Pos(23),
+				End:     token.Pos(27),
+				Message: `found "foo" & <bar>`,
+			},
+		},
+		{
+			analyzerName: "analyzer3",
+			severity:     severityNote,
+			Diagnostic: analysis.Diagnostic{
+				Pos:     token.Pos(42),
+				Message: "consider renaming",
+			},
+		},
+		{
+			// Diagnostics without a position are skipped.
+			analyzerName: "analyzer2",
+			Diagnostic: analysis.Diagnostic{
+				Message: "no position",
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := writeInspectionXML(&buf, "example.com/pkg", diagnostics, fset); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<problems>
+  <problem>
+    <file>file://$PROJECT_DIR$/pkg/file1.go</file>
+    <line>2</line>
+    <offset>2</offset>
+    <length>4</length>
+    <package>example.com/pkg</package>
+    <entry_point TYPE="file" FQNAME="file://$PROJECT_DIR$/pkg/file1.go"></entry_point>
+    <problem_class id="analyzer1" severity="ERROR" attribute_key="ERROR_ATTRIBUTES">analyzer1</problem_class>
+    <description>found &#34;foo&#34; &amp; &lt;bar&gt;</description>
+  </problem>
+  <problem>
+    <file>file://$PROJECT_DIR$/pkg/file1.go</file>
+    <line>3</line>
+    <offset>1</offset>
+    <length>0</length>
+    <package>example.com/pkg</package>
+    <entry_point TYPE="file" FQNAME="file://$PROJECT_DIR$/pkg/file1.go"></entry_point>
+    <problem_class id="analyzer3" severity="WEAK WARNING" attribute_key="INFO_ATTRIBUTES">analyzer3</problem_class>
+    <description>consider renaming</description>
+  </problem>
+</problems>
+`
+	if got := buf.String(); got != expected {
+		t.Errorf("unexpected inspection results:\n\tgot:\n%s\n\twant:\n%s", got, expected)
+	}
+}
+
+func TestWriteInspectionXML_NoDiagnostics(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeInspectionXML(&buf, "example.com/pkg", nil, token.NewFileSet()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := buf.String(); got != emptyInspectionXML {
+		t.Errorf("unexpected inspection results:\n\tgot:\n%s\n\twant:\n%s", got, emptyInspectionXML)
+	}
+}
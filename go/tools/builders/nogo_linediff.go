@@ -0,0 +1,231 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "strings"
+
+// splitDiffLines splits content into lines, keeping the line terminators.
+// It doesn't add a newline to the last line, so that CRLF line endings are
+// preserved and a missing newline at the end of the file is detected.
+func splitDiffLines(content []byte) []string {
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// A diffOp turns the lines a[i1:i2] into the lines b[j1:j2]. Its tag is 'e'
+// if the lines are equal, 'd' if they are deleted, 'i' if they are inserted
+// and 'r' if they are replaced.
+type diffOp struct {
+	tag            byte
+	i1, i2, j1, j2 int
+}
+
+// diffLines returns the operations that turn the lines a into the lines b,
+// computed with Myers' algorithm, so that the number of deleted and inserted
+// lines is minimal. Lines are compared byte by byte, including their line
+// terminators and any whitespace at their start and end.
+func diffLines(a, b []string) []diffOp {
+	// Compare lines by their index in a table of the distinct lines.
+	ids := make(map[string]int)
+	intern := func(lines []string) []int {
+		interned := make([]int, len(lines))
+		for i, line := range lines {
+			id, ok := ids[line]
+			if !ok {
+				id = len(ids)
+				ids[line] = id
+			}
+			interned[i] = id
+		}
+		return interned
+	}
+	d := &lineDiff{
+		a:        intern(a),
+		b:        intern(b),
+		deleted:  make([]bool, len(a)),
+		inserted: make([]bool, len(b)),
+	}
+	d.compare(0, len(a), 0, len(b))
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		i1, j1 := i, j
+		for i < len(a) && j < len(b) && !d.deleted[i] && !d.inserted[j] {
+			i++
+			j++
+		}
+		if i > i1 {
+			ops = append(ops, diffOp{'e', i1, i, j1, j})
+			continue
+		}
+		for i < len(a) && d.deleted[i] {
+			i++
+		}
+		for j < len(b) && d.inserted[j] {
+			j++
+		}
+		tag := byte('r')
+		if j == j1 {
+			tag = 'd'
+		} else if i == i1 {
+			tag = 'i'
+		}
+		ops = append(ops, diffOp{tag, i1, i, j1, j})
+	}
+	return ops
+}
+
+// A lineDiff marks the lines of a that are deleted and the lines of b that
+// are inserted by a shortest edit script.
+type lineDiff struct {
+	a, b              []int
+	deleted, inserted []bool
+}
+
+// compare marks the edits that turn a[aLo:aHi] into b[bLo:bHi]. It splits the
+// lines at the middle snake of a shortest edit script, which only takes space
+// linear in the number of lines.
+func (d *lineDiff) compare(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		aLo++
+		bLo++
+	}
+	for aLo < aHi && bLo < bHi && d.a[aHi-1] == d.b[bHi-1] {
+		aHi--
+		bHi--
+	}
+	switch {
+	case aLo == aHi:
+		for j := bLo; j < bHi; j++ {
+			d.inserted[j] = true
+		}
+	case bLo == bHi:
+		for i := aLo; i < aHi; i++ {
+			d.deleted[i] = true
+		}
+	default:
+		// Both halves need fewer edits than the whole since the lines differ
+		// at both ends, so the recursion terminates.
+		x1, y1, x2, y2 := d.middleSnake(aLo, aHi, bLo, bHi)
+		d.compare(aLo, x1, bLo, y1)
+		d.compare(x2, aHi, y2, bHi)
+	}
+}
+
+// middleSnake returns the start and the end of the diagonal in the middle of
+// a shortest edit script from a[aLo:aHi] to b[bLo:bHi], which it finds by
+// searching forward from the start and backward from the end at the same
+// time until the searches overlap.
+func (d *lineDiff) middleSnake(aLo, aHi, bLo, bHi int) (x1, y1, x2, y2 int) {
+	n, m := aHi-aLo, bHi-bLo
+	delta := n - m
+	odd := delta%2 != 0
+	max := (n + m + 1) / 2
+	// forward[offset+k] is the furthest x on the diagonal k = x-y reached from
+	// the start, backward[offset+k] the furthest distance from the end on the
+	// diagonal k in the reversed lines.
+	offset := max + 1
+	forward := make([]int, 2*offset+1)
+	backward := make([]int, 2*offset+1)
+	for e := 0; e <= max; e++ {
+		for k := -e; k <= e; k += 2 {
+			var x int
+			if k == -e || (k != e && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && d.a[aLo+x] == d.b[bLo+y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+			if c := delta - k; odd && c >= -(e-1) && c <= e-1 && x+backward[offset+c] >= n {
+				return aLo + startX, bLo + startY, aLo + x, bLo + y
+			}
+		}
+		for c := -e; c <= e; c += 2 {
+			var x int
+			if c == -e || (c != e && backward[offset+c-1] < backward[offset+c+1]) {
+				x = backward[offset+c+1]
+			} else {
+				x = backward[offset+c-1] + 1
+			}
+			y := x - c
+			startX, startY := x, y
+			for x < n && y < m && d.a[aHi-1-x] == d.b[bHi-1-y] {
+				x++
+				y++
+			}
+			backward[offset+c] = x
+			if k := delta - c; !odd && k >= -e && k <= e && forward[offset+k]+x >= n {
+				return aHi - x, bHi - y, aHi - startX, bHi - startY
+			}
+		}
+	}
+	panic("no middle snake found")
+}
+
+// groupDiffOps groups the operations into the hunks of a unified diff with
+// the given number of lines of context around each change. Changes that are
+// separated by at most twice as many unchanged lines share a hunk. It returns
+// nil if there are no changes.
+func groupDiffOps(ops []diffOp, context int) [][]diffOp {
+	var groups [][]diffOp
+	var group []diffOp
+	for n, op := range ops {
+		if op.tag != 'e' {
+			group = append(group, op)
+			continue
+		}
+		if n > 0 {
+			// Context after the previous change.
+			end := op
+			if end.i2-end.i1 > context {
+				end.i2, end.j2 = end.i1+context, end.j1+context
+			}
+			if n == len(ops)-1 || op.i2-op.i1 > 2*context {
+				if end.i2 > end.i1 {
+					group = append(group, end)
+				}
+				groups = append(groups, group)
+				group = nil
+			} else {
+				group = append(group, op)
+				continue
+			}
+		}
+		if n < len(ops)-1 {
+			// Context before the next change.
+			start := op
+			if start.i2-start.i1 > context {
+				start.i1, start.j1 = start.i2-context, start.j2-context
+			}
+			if start.i2 > start.i1 {
+				group = append(group, start)
+			}
+		}
+	}
+	if group != nil {
+		groups = append(groups, group)
+	}
+	return groups
+}
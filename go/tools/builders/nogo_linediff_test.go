@@ -0,0 +1,160 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	for _, tt := range []struct {
+		desc string
+		a, b string
+		want []diffOp
+	}{
+		{
+			desc: "equal",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: []diffOp{{'e', 0, 2, 0, 2}},
+		},
+		{
+			desc: "empty",
+		},
+		{
+			desc: "replace",
+			a:    "a\nb\nc\n",
+			b:    "a\nx\nc\n",
+			want: []diffOp{{'e', 0, 1, 0, 1}, {'r', 1, 2, 1, 2}, {'e', 2, 3, 2, 3}},
+		},
+		{
+			desc: "insert and delete",
+			a:    "a\nb\nc\n",
+			b:    "x\na\nc\n",
+			want: []diffOp{{'i', 0, 0, 0, 1}, {'e', 0, 1, 1, 2}, {'d', 1, 2, 2, 2}, {'e', 2, 3, 2, 3}},
+		},
+		{
+			// Repeated blank lines and braces are matched like any other
+			// line, so the inserted function is not spread over the file.
+			desc: "repeated lines",
+			a:    "func a() {\n}\n\nfunc c() {\n}\n",
+			b:    "func a() {\n}\n\nfunc b() {\n}\n\nfunc c() {\n}\n",
+			want: []diffOp{{'e', 0, 3, 0, 3}, {'i', 3, 3, 3, 6}, {'e', 3, 5, 6, 8}},
+		},
+		{
+			desc: "whitespace at the edges",
+			a:    "\tx := 1\r\n",
+			b:    "\tx := 1\n",
+			want: []diffOp{{'r', 0, 1, 0, 1}},
+		},
+		{
+			desc: "no newline at end of file",
+			a:    "a\nb",
+			b:    "a\nb\n",
+			want: []diffOp{{'e', 0, 1, 0, 1}, {'r', 1, 2, 1, 2}},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if got := diffLines(splitDiffLines([]byte(tt.a)), splitDiffLines([]byte(tt.b))); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got operations %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDiffLines_Minimal checks that the operations of random inputs turn a
+// into b with the smallest number of changed lines.
+func TestDiffLines_Minimal(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	lines := func() []string {
+		s := make([]string, rng.Intn(30))
+		for i := range s {
+			s[i] = string(rune('a' + rng.Intn(4)))
+		}
+		return s
+	}
+	for n := 0; n < 1000; n++ {
+		a, b := lines(), lines()
+		ops := diffLines(a, b)
+		var got []string
+		changed, i, j := 0, 0, 0
+		for _, op := range ops {
+			if op.i1 != i || op.j1 != j {
+				t.Fatalf("diffLines(%q, %q): operation %v doesn't start where the previous one ends", a, b, op)
+			}
+			if op.tag == 'e' {
+				if !reflect.DeepEqual(a[op.i1:op.i2], b[op.j1:op.j2]) {
+					t.Fatalf("diffLines(%q, %q): operation %v isn't equal", a, b, op)
+				}
+			} else {
+				changed += op.i2 - op.i1 + op.j2 - op.j1
+			}
+			got = append(got, b[op.j1:op.j2]...)
+			i, j = op.i2, op.j2
+		}
+		if i != len(a) || !reflect.DeepEqual(got, b) && len(b) > 0 {
+			t.Fatalf("diffLines(%q, %q) doesn't turn a into b: %v", a, b, ops)
+		}
+		if want := len(a) + len(b) - 2*lcsLength(a, b); changed != want {
+			t.Fatalf("diffLines(%q, %q) changes %d lines, want %d: %v", a, b, changed, want, ops)
+		}
+	}
+}
+
+func lcsLength(a, b []string) int {
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			case lengths[i+1][j] > lengths[i][j+1]:
+				lengths[i][j] = lengths[i+1][j]
+			default:
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+	return lengths[0][0]
+}
+
+func TestGroupDiffOps(t *testing.T) {
+	a := strings.Split("1 2 3 4 5 6 7 8 9 10 11 12", " ")
+	b := strings.Split("1 x 3 4 5 6 y 8 9 10 11 12", " ")
+	ops := diffLines(a, b)
+	for _, tt := range []struct {
+		context int
+		want    [][]diffOp
+	}{
+		{
+			context: 0,
+			want:    [][]diffOp{{{'r', 1, 2, 1, 2}}, {{'r', 6, 7, 6, 7}}},
+		},
+		{
+			context: 1,
+			want: [][]diffOp{
+				{{'e', 0, 1, 0, 1}, {'r', 1, 2, 1, 2}, {'e', 2, 3, 2, 3}},
+				{{'e', 5, 6, 5, 6}, {'r', 6, 7, 6, 7}, {'e', 7, 8, 7, 8}},
+			},
+		},
+		{
+			// The changes are four lines apart, which share a hunk with two
+			// lines of context.
+			context: 2,
+			want: [][]diffOp{
+				{{'e', 0, 1, 0, 1}, {'r', 1, 2, 1, 2}, {'e', 2, 6, 2, 6}, {'r', 6, 7, 6, 7}, {'e', 7, 9, 7, 9}},
+			},
+		},
+	} {
+		if got := groupDiffOps(ops, tt.context); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("context %d: got hunks %v, want %v", tt.context, got, tt.want)
+		}
+	}
+	if got := groupDiffOps(diffLines(a, a), 3); got != nil {
+		t.Errorf("got hunks %v for equal lines", got)
+	}
+}
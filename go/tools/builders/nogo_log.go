@@ -0,0 +1,28 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This file is shared by the tools that read the nogo log: the builder and
+// nogo_summary.
+
+package main
+
+import "regexp"
+
+// findingRegexp matches the first line of a finding in the nogo log, e.g.
+// "pkg/file.go:12:3: message (analyzer)". The submatches are the file, the
+// severity, if it isn't an error, and the analyzer. Diagnostics without a
+// position start with "-". The indented lines following a finding, such as
+// remediation notes, hints and source snippets, belong to it, even if they
+// look like a finding.
+var findingRegexp = regexp.MustCompile(`^(\S.*?)(?::\d+)?(?::\d+)?: (?:(warning|note): )?.* \(([^()\s]+)\)$`)
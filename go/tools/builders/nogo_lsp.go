@@ -0,0 +1,183 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This file renders the fixes selected by nogo as a WorkspaceEdit of the
+// Language Server Protocol, so that editor integrations can offer them as
+// code actions without parsing the patch.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// A workspaceEdit is an LSP WorkspaceEdit that lists its changes as
+// documentChanges, which are text document edits and, for the files that
+// fixes create or delete, file operations.
+type workspaceEdit struct {
+	DocumentChanges []interface{} `json:"documentChanges"`
+}
+
+// An lspTextDocumentEdit is an LSP TextDocumentEdit.
+type lspTextDocumentEdit struct {
+	TextDocument lspVersionedDocument `json:"textDocument"`
+	Edits        []lspTextEdit        `json:"edits"`
+}
+
+// An lspVersionedDocument is an LSP OptionalVersionedTextDocumentIdentifier.
+// The version is always null since nogo doesn't know the version of the
+// document in the editor.
+type lspVersionedDocument struct {
+	URI     string `json:"uri"`
+	Version *int   `json:"version"`
+}
+
+// An lspFileOperation is an LSP CreateFile or DeleteFile operation, depending
+// on its kind.
+type lspFileOperation struct {
+	Kind string `json:"kind"`
+	URI  string `json:"uri"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+// An lspPosition is a zero-based line and a character offset in UTF-16 code
+// units, the default position encoding of LSP.
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// newWorkspaceEdit converts the changes to a workspace edit. The contents of
+// the files are taken from src if it holds them, and uri returns the URI of a
+// file. Like the structured fix file, the edits are not formatted.
+func newWorkspaceEdit(changes []fileChange, src map[string][]byte, uri func(fileName string) string) (workspaceEdit, error) {
+	sorted := make([]fileChange, 0, len(changes))
+	for _, c := range changes {
+		if len(c.changes) > 0 {
+			sorted = append(sorted, c)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].fileName < sorted[j].fileName
+	})
+
+	edit := workspaceEdit{DocumentChanges: []interface{}{}}
+	for _, c := range sorted {
+		content, ok := src[c.fileName]
+		var err error
+		if !ok {
+			content, err = os.ReadFile(longPath(c.fileName))
+		}
+		fileURI := uri(c.fileName)
+		if os.IsNotExist(err) && createsFile(c) {
+			content, err = nil, nil
+			edit.DocumentChanges = append(edit.DocumentChanges, lspFileOperation{Kind: "create", URI: fileURI})
+		}
+		if err != nil {
+			return workspaceEdit{}, fmt.Errorf("failed to read file %s: %v", c.fileName, err)
+		}
+		if len(content) > 0 && len(applyEdits(content, c.changes)) == 0 {
+			// A fix that removes all of the content of a file deletes it.
+			edit.DocumentChanges = append(edit.DocumentChanges, lspFileOperation{Kind: "delete", URI: fileURI})
+			continue
+		}
+		position := lspPositions(content)
+		docEdit := lspTextDocumentEdit{TextDocument: lspVersionedDocument{URI: fileURI}}
+		for _, e := range c.changes {
+			if e.Start < 0 || e.End < e.Start || e.End > len(content) {
+				return workspaceEdit{}, fmt.Errorf("%s: edit %v is outside of the file", c.fileName, e)
+			}
+			docEdit.Edits = append(docEdit.Edits, lspTextEdit{
+				Range:   lspRange{Start: position(e.Start), End: position(e.End)},
+				NewText: e.New,
+			})
+		}
+		edit.DocumentChanges = append(edit.DocumentChanges, docEdit)
+	}
+	return edit, nil
+}
+
+// lspPositions returns a function that converts byte offsets in content to LSP
+// positions. Like LSP, it treats "\n", "\r\n" and "\r" as line terminators.
+// Bytes that are not valid UTF-8 count as one code unit each.
+func lspPositions(content []byte) func(offset int) lspPosition {
+	lineStarts := []int{0}
+	for i, b := range content {
+		if b == '\n' || b == '\r' && (i+1 == len(content) || content[i+1] != '\n') {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	return func(offset int) lspPosition {
+		line := sort.Search(len(lineStarts), func(i int) bool { return lineStarts[i] > offset }) - 1
+		character := 0
+		for rest := content[lineStarts[line]:offset]; len(rest) > 0; {
+			r, size := utf8.DecodeRune(rest)
+			if r >= 0x10000 {
+				// Encoded as a surrogate pair in UTF-16.
+				character += 2
+			} else {
+				character++
+			}
+			rest = rest[size:]
+		}
+		return lspPosition{Line: line, Character: character}
+	}
+}
+
+// fileURI returns the file URI of an absolute path.
+func fileURI(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		// Windows paths start with a drive letter.
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// writeWorkspaceEdit writes the changes as the JSON of an LSP WorkspaceEdit.
+// Relative file names are resolved against execroot.
+func writeWorkspaceEdit(w io.Writer, changes []fileChange, src map[string][]byte, execroot string) error {
+	edit, err := newWorkspaceEdit(changes, src, func(fileName string) string {
+		if !filepath.IsAbs(fileName) {
+			fileName = filepath.Join(execroot, fileName)
+		}
+		return fileURI(fileName)
+	})
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(edit, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLSPPositions(t *testing.T) {
+	content := []byte("a\r\nbé😀c\rd\n\xffe")
+	position := lspPositions(content)
+	for _, tt := range []struct {
+		offset int
+		want   lspPosition
+	}{
+		{0, lspPosition{0, 0}},
+		{1, lspPosition{0, 1}},
+		{3, lspPosition{1, 0}},
+		// é is a single UTF-16 code unit and 😀 a surrogate pair.
+		{6, lspPosition{1, 2}},
+		{10, lspPosition{1, 4}},
+		// A lone carriage return ends a line, too.
+		{12, lspPosition{2, 0}},
+		// Invalid bytes count as one code unit.
+		{15, lspPosition{3, 1}},
+		{16, lspPosition{3, 2}},
+	} {
+		if got := position(tt.offset); got != tt.want {
+			t.Errorf("offset %d: got %+v, want %+v", tt.offset, got, tt.want)
+		}
+	}
+}
+
+func TestFileURI(t *testing.T) {
+	for path, want := range map[string]string{
+		"/work/pkg/file.go":   "file:///work/pkg/file.go",
+		"/work/my pkg/a#b.go": "file:///work/my%20pkg/a%23b.go",
+		"C:/work/pkg/file.go": "file:///C:/work/pkg/file.go",
+	} {
+		if got := fileURI(path); got != want {
+			t.Errorf("fileURI(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestWriteWorkspaceEdit(t *testing.T) {
+	dir := t.TempDir()
+	deleted := filepath.Join(dir, "deleted.go")
+	if err := os.WriteFile(deleted, []byte("package a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	changes := []fileChange{
+		{
+			fileName: "a.go",
+			changes: []nogoEdit{
+				{Start: 8, End: 9, New: "b"},
+				{Start: 11, End: 11, New: "// x\n"},
+			},
+		},
+		{fileName: "new.go", changes: []nogoEdit{{New: "package a\n"}}},
+		// Files that are not in src are read.
+		{fileName: deleted, changes: []nogoEdit{{Start: 0, End: 10}}},
+		{fileName: "unchanged.go"},
+	}
+	src := map[string][]byte{"a.go": []byte("package a\n\nvar x = 1\n")}
+
+	var buf bytes.Buffer
+	if err := writeWorkspaceEdit(&buf, changes, src, dir); err != nil {
+		t.Fatal(err)
+	}
+	uri := func(name string) string { return fileURI(filepath.Join(dir, name)) }
+	want := workspaceEdit{DocumentChanges: []interface{}{
+		lspFileOperation{Kind: "delete", URI: fileURI(deleted)},
+		lspTextDocumentEdit{
+			TextDocument: lspVersionedDocument{URI: uri("a.go")},
+			Edits: []lspTextEdit{
+				{Range: lspRange{Start: lspPosition{0, 8}, End: lspPosition{0, 9}}, NewText: "b"},
+				{Range: lspRange{Start: lspPosition{2, 0}, End: lspPosition{2, 0}}, NewText: "// x\n"},
+			},
+		},
+		lspFileOperation{Kind: "create", URI: uri("new.go")},
+		lspTextDocumentEdit{
+			TextDocument: lspVersionedDocument{URI: uri("new.go")},
+			Edits:        []lspTextEdit{{Range: lspRange{}, NewText: "package a\n"}},
+		},
+	}}
+	wantJSON, err := json.MarshalIndent(want, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != string(wantJSON)+"\n" {
+		t.Errorf("got workspace edit:\n%s\nwant:\n%s", got, wantJSON)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`"version": null`)) {
+		t.Errorf("the version of the documents isn't null:\n%s", buf.String())
+	}
+}
@@ -34,18 +34,19 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
-	"regexp"
+	"runtime"
+	"runtime/debug"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/gcexportdata"
 	"golang.org/x/tools/internal/facts"
 )
 
-const nogoBaseConfigName = "_base"
-
 func init() {
 	if err := analysis.Validate(analyzers); err != nil {
 		log.Fatal(err)
@@ -54,9 +55,50 @@ func init() {
 
 var typesSizes = types.SizesFor("gc", os.Getenv("GOARCH"))
 
+// nogoTracer records spans if tracing is enabled in the environment, see
+// nogo_trace.go. It is nil otherwise.
+var nogoTracer *tracer
+
+// nogoMetrics records the cost of each analyzer on the package if nogo is run
+// with -metrics. It is nil otherwise.
+var nogoMetrics *metricsRecorder
+
+// stdlibFactArchive holds the facts of the standard library if the nogo rule
+// sets stdlib_facts. It is nil otherwise.
+var stdlibFactArchive *factArchive
+
 func main() {
 	log.SetFlags(0) // no timestamp
 	log.SetPrefix("nogo: ")
+	if len(os.Args) > 1 && os.Args[1] == explainCommand {
+		if err := explain(os.Stdout, analyzers, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == checkConfigCommand {
+		externalNames := make([]string, 0, len(externalAnalyzers))
+		for _, rlocation := range externalAnalyzers {
+			externalNames = append(externalNames, externalAnalyzerName(rlocation))
+		}
+		if err := checkConfig(os.Stdout, analyzers, externalNames, configs, configProblems); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == analyzeCommand {
+		if err, exitCode := analyzeStandalone(os.Args[2:]); err != nil {
+			log.Print(err)
+			os.Exit(exitCode)
+		}
+		return
+	}
+	if _, ok := persistentWorkerArgs(os.Args[1:]); ok {
+		if err := serveNogoWorker(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 	if err, exitCode := run(os.Args[1:]); err != nil {
 		log.Print(err)
 		os.Exit(exitCode)
@@ -78,75 +120,551 @@ func run(args []string) (error, int) {
 	packagePath := flags.String("p", "", "The package path (importmap) of the package being compiled")
 	xPath := flags.String("x", "", "The archive file where serialized facts should be written")
 	nogoFixPath := flags.String("fix", "", "The path of the file to store the nogo fixes")
+	inspectionXMLPath := flags.String("inspection_xml", "", "The path of the file to store the diagnostics in the IntelliJ inspection results format")
+	diagnosticsJSONPath := flags.String("diagnostics_json", "", "The path of the file to store the diagnostics in as JSON")
+	fixJSONPath := flags.String("fix_json", "", "The path of the file to store the nogo fixes in as JSON")
+	fixDir := flags.String("fix_dir", "", "The directory to store a patch with the nogo fixes of each analyzer in")
+	workspaceRoot := flags.String("workspace_root", "", "The execroot-relative path of the root of the repository containing the package")
+	packageDir := flags.String("package_dir", "", "The execroot-relative path of the Bazel package containing the package")
+	label := flags.String("label", "", "The label of the target being analyzed, for the metadata of the fix files")
+	execroot := flags.String("execroot", "", "The directory the source paths are relative to (default: the current directory)")
+	cpuProfile := flags.String("cpuprofile", "", "The file to write a CPU profile of nogo to")
+	memProfile := flags.String("memprofile", "", "The file to write a heap profile of nogo at the end of the analysis to")
+	executionTrace := flags.String("trace", "", "The file to write a Go execution trace of nogo to")
+	metricsPath := flags.String("metrics", "", "The file to write the wall time of each analyzer to as JSON")
+	metricsAllocs := flags.Bool("metrics_allocs", false, "Whether to also write the bytes allocated by each analyzer to the -metrics file. The analyzers run one at a time.")
+	factsOnly := flags.Bool("facts_only", false, "Only compute the facts of the package, e.g. of the standard library, without reporting diagnostics")
 	var ignores multiFlag
 	flags.Var(&ignores, "ignore", "Names of files to ignore")
 	flags.Parse(args)
 	srcs := flags.Args()
 
+	stopProfiles, err := startProfiles(*cpuProfile, *memProfile, *executionTrace)
+	if err != nil {
+		return fmt.Errorf("error starting profiles: %v", err), nogoError
+	}
+
+	nogoTracer = newTracerFromEnv("nogo.package", *packagePath)
+	rootSpan := nogoTracer.startRoot("nogo", "nogo.package", *packagePath)
+
 	packageFile, importMap, err := readImportCfg(*importcfg)
 	if err != nil {
 		return fmt.Errorf("error parsing importcfg: %v", err), nogoError
 	}
 
 
-	diagnostics, pkg, err := checkPackage(analyzers, *packagePath, packageFile, importMap, factMap, srcs, ignores)
+	if *execroot == "" {
+		if *execroot, err = os.Getwd(); err != nil {
+			return fmt.Errorf("nogo failed to get CWD: %w", err), nogoError
+		}
+	}
+
+	if *metricsPath != "" {
+		nogoMetrics = &metricsRecorder{allocs: *metricsAllocs}
+		if *metricsAllocs {
+			// Analyzers run one at a time, so that the allocations during
+			// their runs are their own.
+			analyzerSlots = make(chan struct{}, 1)
+		}
+	}
+	checkedAnalyzers := analyzers
+	if *factsOnly {
+		// Only analyzers with facts and their prerequisites are run. External
+		// analyzers can't export facts. The facts of the standard library are
+		// computed in this mode, so they are passed with -fact instead of
+		// being read from the archive.
+		checkedAnalyzers = factAnalyzers(analyzers)
+		externalAnalyzers = nil
+	} else if err := openStdlibFacts(); err != nil {
+		return err, nogoError
+	}
+
+	diagnostics, pkg, err := checkPackage(checkedAnalyzers, *packagePath, packageFile, importMap, factMap, srcs, ignores, *execroot)
 	if err != nil {
 		return fmt.Errorf("error running analyzers: %v", err), nogoError
 	}
+	// Analyzing the package again to verify the suggested fixes is not
+	// recorded.
+	metrics := nogoMetrics
+	nogoMetrics = nil
+	diagnostics = dedupeDiagnostics(diagnostics)
+	// The outputs only include the first findings of packages with more than
+	// max_findings. Whether the build fails still depends on all of them.
+	maxFindings := configs[nogoBaseConfigName].maxFindings
+	// Diagnostics disabled by the config only contribute their fixes.
+	fixDiagnostics, _ := limitDiagnostics(diagnostics, maxFindings)
+	diagnostics = reportedDiagnostics(diagnostics)
 	// Write the facts file for downstream consumers before failing due to diagnostics.
 	if *xPath != "" {
-		if err := os.WriteFile(abs(*xPath), pkg.facts.Encode(), 0o666); err != nil {
+		span := nogoTracer.start("nogo.facts.encode")
+		data, err := encodeFactFile(pkg.facts.Encode(), configs[nogoBaseConfigName].factCompression)
+		if err == nil {
+			err = os.WriteFile(abs(*xPath), data, 0o666)
+		}
+		span.finish()
+		if err != nil {
 			return fmt.Errorf("error writing facts: %v", err), nogoError
 		}
 	}
+	if *factsOnly {
+		if err := stopProfiles(); err != nil {
+			return fmt.Errorf("error writing profiles: %v", err), nogoError
+		}
+		return nil, nogoSuccess
+	}
+	// Findings in the baseline are not reported, but are still recorded in the
+	// inspection and diagnostics outputs, which the baseline is generated from.
+	logged := unbaselinedDiagnostics(diagnostics, pkg.fset)
 	exitCode := nogoSuccess
 	var errMsg bytes.Buffer
-	if len(diagnostics) > 0 {
-		// debugMode is defined by the template in generate_nogo_main.go.
-		exitCode = nogoViolation
-		if debugMode {
-			// Force actions running nogo to fail to help debug issues.
-			exitCode = nogoError
-		}
-		errMsg.WriteString("errors found by nogo during build-time code analysis:")
-		for _, d := range diagnostics {
-			fmt.Fprintf(&errMsg, "\n%s: %s (%s)", pkg.fset.Position(d.Pos), d.Message, d.analyzerName)
+	// notices are reported even if no finding fails the build, since they
+	// concern the outputs of nogo rather than the findings.
+	var notices bytes.Buffer
+	if len(logged) > 0 {
+		blocking := false
+		for _, d := range logged {
+			if severity(d.analyzerName) == severityError {
+				blocking = true
+				break
+			}
+		}
+		if blocking {
+			// debugMode is defined by the template in generate_nogo_main.go.
+			exitCode = nogoViolation
+			if debugMode {
+				// Force actions running nogo to fail to help debug issues.
+				exitCode = nogoError
+			}
+			errMsg.WriteString(nogoErrorsHeader)
+		} else {
+			exitCode = nogoWarning
+			errMsg.WriteString(nogoWarningsHeader)
 		}
+		writeFindings(&errMsg, logged, pkg, maxFindings)
 	}
 
-	if errs := saveSuggestedFixes(*nogoFixPath, diagnostics, pkg); len(errs) > 0 {
+	// patchRoot is defined by the template in generate_nogo_main.go.
+	paths := patchPaths{execroot: *execroot, baseDir: patchBaseDir(patchRoot, *workspaceRoot, *packageDir), prefixes: configuredPatchPrefixes()}
+	fixSpan := nogoTracer.start("nogo.fixes")
+	fixes, omitted, errs := saveSuggestedFixes(*nogoFixPath, paths, *label, fixDiagnostics, pkg)
+	fixSpan.finish()
+	if len(omitted) > 0 {
+		names := make([]string, len(omitted))
+		for i, c := range omitted {
+			names[i] = filepath.ToSlash(paths.path(c.fileName))
+		}
+		// maxFixFileSize is defined by the template in generate_nogo_main.go.
+		fmt.Fprintf(&notices, "\nsuggested fixes of %d file(s) left out of the fix file since max_fix_file_size is %d bytes: %s", len(omitted), maxFixFileSize, strings.Join(names, ", "))
+	}
+	if len(errs) > 0 {
 		errMsg.WriteString("\nsaving suggested fixes:")
 		for _, err := range errs {
 			fmt.Fprintf(&errMsg, "\n%v", err)
 		}
 	}
+	if err := saveFixFileJSON(*fixJSONPath, paths, fixes); err != nil {
+		fmt.Fprintf(&errMsg, "\nsaving fix file:\n%v", err)
+	}
+	if err := saveAnalyzerPatches(*fixDir, paths, *label, fixes, fixDiagnostics, pkg); err != nil {
+		fmt.Fprintf(&errMsg, "\nsaving the patches of each analyzer:\n%v", err)
+	}
+	// diagnosticPatches is defined by the template in generate_nogo_main.go.
+	if diagnosticPatches {
+		if err := saveDiagnosticPatches(*fixDir, paths, *label, fixes, fixDiagnostics, pkg); err != nil {
+			fmt.Fprintf(&errMsg, "\nsaving the patches of each diagnostic:\n%v", err)
+		}
+	}
+	// verifyFixes is defined by the template in generate_nogo_main.go.
+	if verifyFixes && len(fixes) > 0 {
+		verifySpan := nogoTracer.start("nogo.fixes.verify")
+		problems := verifySuggestedFixes(fixes, fixDiagnostics, pkg, *packagePath, packageFile, importMap, factMap, srcs, ignores, *execroot)
+		verifySpan.finish()
+		if len(problems) > 0 {
+			notices.WriteString("\nverifying suggested fixes:")
+			for _, problem := range problems {
+				fmt.Fprintf(&notices, "\n%s", problem)
+			}
+		}
+	}
+	if exitCode == nogoViolation {
+		haveFixes := false
+		if *nogoFixPath != "" {
+			if info, err := os.Stat(longPath(*nogoFixPath)); err == nil {
+				haveFixes = info.Size() > 0
+			}
+		}
+		if !failsBuild(haveFixes) {
+			// The findings are still recorded in the inspection and
+			// diagnostics outputs.
+			exitCode = nogoSuccess
+		}
+	}
 
+	recorded, suppressed := limitDiagnostics(diagnostics, maxFindings)
+	inspectionSpan := nogoTracer.start("nogo.inspection")
+	if err := saveInspectionXML(*inspectionXMLPath, *packagePath, recorded, pkg); err != nil {
+		fmt.Fprintf(&errMsg, "\nsaving inspection results:\n%v", err)
+	}
+	inspectionSpan.finish()
+
+	diagnosticsSpan := nogoTracer.start("nogo.diagnostics")
+	if err := saveDiagnosticsJSON(*diagnosticsJSONPath, *packagePath, recorded, suppressed, pkg); err != nil {
+		fmt.Fprintf(&errMsg, "\nsaving diagnostics:\n%v", err)
+	}
+	diagnosticsSpan.finish()
+
+	if metrics != nil {
+		if err := metrics.write(*metricsPath, *packagePath); err != nil {
+			fmt.Fprintf(&errMsg, "\nwriting metrics:\n%v", err)
+		}
+	}
+	if err := stopProfiles(); err != nil {
+		fmt.Fprintf(&errMsg, "\nwriting profiles:\n%v", err)
+	}
+	rootSpan.finish()
+	if err := nogoTracer.flush(); err != nil {
+		fmt.Fprintf(&notices, "\nexporting trace:\n%v", err)
+	}
+
+	if notices.Len() > 0 {
+		if exitCode == nogoSuccess {
+			// The builder only logs the output of nogo if it has findings,
+			// and the findings that don't fail the build are left out.
+			// Reported as warnings, the notices end up in the nogo log
+			// without failing the validation.
+			exitCode = nogoWarning
+			errMsg.Reset()
+			errMsg.WriteString(nogoWarningsHeader)
+		}
+		errMsg.Write(notices.Bytes())
+	}
 	if errMsg.Len() > 0 {
 		return errors.New(errMsg.String()), exitCode
 	}
 	return nil, exitCode
 }
 
-func saveSuggestedFixes(nogoFixPath string, diagnostics []diagnosticEntry, pkg *goPackage) []error {
-	if nogoFixPath == "" {
+// writeFindings writes the findings of the package to the log, each starting
+// on a new line and followed by its indented details, and notes how many were
+// left out due to max_findings.
+func writeFindings(w *bytes.Buffer, logged []diagnosticEntry, pkg *goPackage, maxFindings int) {
+	shown, suppressed := limitDiagnostics(logged, maxFindings)
+	for _, d := range shown {
+		label := ""
+		if s := severity(d.analyzerName); s != severityError {
+			label = s + ": "
+		}
+		fmt.Fprintf(w, "\n%s: %s%s (%s)", pkg.fset.Position(d.Pos), label, d.Message, d.analyzerName)
+		// sourceSnippets is defined by the template in generate_nogo_main.go.
+		if sourceSnippets {
+			writeSnippet(w, pkg.fset, pkg.src, d.Pos, d.End)
+		}
+		for _, line := range relatedLines(d.Diagnostic, pkg.fset) {
+			fmt.Fprintf(w, "\n    %s", line)
+		}
+		writeRemediation(w, d.analyzerName)
+		if d.URL != "" {
+			fmt.Fprintf(w, "\n    see %s", d.URL)
+		}
+		for _, hint := range fixHints(d.Diagnostic) {
+			fmt.Fprintf(w, "\n    hint: %s", hint)
+		}
+		if len(d.excludedFixFiles) > 0 {
+			fmt.Fprintf(w, "\n    suggested fix not emitted since it edits %s outside the files configured for the analyzer", strings.Join(d.excludedFixFiles, ", "))
+		}
+		if len(d.remappedFixFiles) > 0 {
+			fmt.Fprintf(w, "\n    suggested fix not emitted since it edits %s, whose //line directives map the code to another source", strings.Join(d.remappedFixFiles, ", "))
+		}
+		if len(d.splitRuneFixFiles) > 0 {
+			fmt.Fprintf(w, "\n    suggested fix not emitted since it edits %s in the middle of a multi-byte character", strings.Join(d.splitRuneFixFiles, ", "))
+		}
+	}
+	if suppressed > 0 {
+		fmt.Fprintf(w, "\n%d additional findings suppressed since max_findings is %d", suppressed, maxFindings)
+	}
+}
+
+// configuredPatchPrefixes returns the prefixes of the file names in the fix
+// files configured by the nogo rule.
+func configuredPatchPrefixes() *patchPrefixes {
+	// patchSrcPrefix, patchDstPrefix and patchStrip are defined by the template
+	// in generate_nogo_main.go.
+	return &patchPrefixes{src: patchSrcPrefix, dst: patchDstPrefix, strip: patchStrip}
+}
+
+// openStdlibFacts opens the facts of the standard library if the nogo rule
+// sets stdlib_facts. The archive stays open for the following packages of a
+// persistent worker.
+func openStdlibFacts() error {
+	// stdlibFacts is defined by the template in generate_nogo_main.go.
+	if stdlibFacts == "" || stdlibFactArchive != nil {
 		return nil
 	}
+	path, err := runfilePath(stdlibFacts)
+	if err == nil {
+		stdlibFactArchive, err = openFactArchive(path)
+	}
+	if err != nil {
+		return fmt.Errorf("error opening the facts of the standard library: %v", err)
+	}
+	return nil
+}
+
+// saveSuggestedFixes writes the patch with the suggested fixes and returns
+// the fixes it contains and the fixes it left out to stay within
+// max_fix_file_size.
+func saveSuggestedFixes(nogoFixPath string, paths patchPaths, target string, diagnostics []diagnosticEntry, pkg *goPackage) ([]fileChange, []fileChange, []error) {
+	if nogoFixPath == "" {
+		return nil, nil, nil
+	}
 	var errs []error
 	// the patch file has to be created even if there is no fix.
-	patchFile, err := os.Create(nogoFixPath)
+	patchFile, err := os.Create(longPath(nogoFixPath))
 	if err != nil {
 		errs = append(errs, fmt.Errorf("creating %q: %w", nogoFixPath, err))
-		return errs
+		return nil, nil, errs
 	}
 	defer patchFile.Close()
-	fixes, err := getFixes(diagnostics, pkg.fset)
+	fixes, err := getFixesWithStrategy(diagnostics, pkg.fset, configuredFixStrategy())
 	if err != nil {
 		errs = append(errs, err)
 	}
-	if err := writePatch(patchFile, fixes); err != nil {
+	fixes = minimizeFixes(fixes, pkg.src)
+	addFixProvenance(fixes, diagnostics, pkg.fset, paths)
+	addFixMetadata(fixes, diagnostics, pkg.fset, target)
+	// fixCompression is defined by the template in generate_nogo_main.go.
+	w := newFixFileWriter(patchFile, fixCompression)
+	// fixFormat is defined by the template in generate_nogo_main.go.
+	// fixContext is defined by the template in generate_nogo_main.go.
+	// maxFixFileSize is defined by the template in generate_nogo_main.go.
+	omitted, err := writePatchWithLimit(w, fixes, pkg.src, paths, newFixFormatter(fixFormat, importNames(pkg)), fixContext, maxFixFileSize)
+	if err != nil {
 		errs = append(errs, err)
 	}
-	return errs
+	if err := w.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(omitted) > 0 {
+		// The other outputs only contain the fixes in the patch.
+		left := make(map[string]bool, len(omitted))
+		for _, c := range omitted {
+			left[c.fileName] = true
+		}
+		kept := fixes[:0]
+		for _, c := range fixes {
+			if !left[c.fileName] {
+				kept = append(kept, c)
+			}
+		}
+		fixes = kept
+	}
+	return fixes, omitted, errs
+}
+
+// saveAnalyzerPatches writes a patch with the fixes of each analyzer, named
+// after the analyzer, to fixDir. Each patch applies on its own.
+func saveAnalyzerPatches(fixDir string, paths patchPaths, target string, fixes []fileChange, diagnostics []diagnosticEntry, pkg *goPackage) error {
+	if fixDir == "" {
+		return nil
+	}
+	// The directory has to be created even if there is no fix.
+	if err := os.MkdirAll(longPath(fixDir), 0o777); err != nil {
+		return err
+	}
+	format := newFixFormatter(fixFormat, importNames(pkg))
+	byAnalyzer := splitByAnalyzer(fixes)
+	analyzers := make([]string, 0, len(byAnalyzer))
+	for analyzer := range byAnalyzer {
+		analyzers = append(analyzers, analyzer)
+	}
+	// Write the patches in order, so that the first error is the same in
+	// every run.
+	sort.Strings(analyzers)
+	for _, analyzer := range analyzers {
+		changes := byAnalyzer[analyzer]
+		var entries []diagnosticEntry
+		for _, d := range diagnostics {
+			if d.analyzerName == analyzer {
+				entries = append(entries, d)
+			}
+		}
+		addFixProvenance(changes, entries, pkg.fset, paths)
+		addFixMetadata(changes, entries, pkg.fset, target)
+		patchPath := filepath.Join(fixDir, analyzer+".patch")
+		f, err := os.Create(longPath(patchPath))
+		if err != nil {
+			return fmt.Errorf("creating %q: %w", patchPath, err)
+		}
+		if err := writePatch(f, changes, pkg.src, paths, format, fixContext); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveDiagnosticPatches writes a patch with the fix of each diagnostic to the
+// diagnostics subdirectory of fixDir, named after the ID of the fix, see
+// diagnosticFixID. Each patch applies on its own.
+func saveDiagnosticPatches(fixDir string, paths patchPaths, target string, fixes []fileChange, diagnostics []diagnosticEntry, pkg *goPackage) error {
+	if fixDir == "" {
+		return nil
+	}
+	dir := filepath.Join(fixDir, "diagnostics")
+	// The directory has to be created even if there is no fix.
+	if err := os.MkdirAll(longPath(dir), 0o777); err != nil {
+		return err
+	}
+	format := newFixFormatter(fixFormat, importNames(pkg))
+	for _, fix := range splitByDiagnostic(fixes, diagnostics, pkg.fset, paths) {
+		patchPath := filepath.Join(dir, fix.id+".patch")
+		f, err := os.Create(longPath(patchPath))
+		if err != nil {
+			return fmt.Errorf("creating %q: %w", patchPath, err)
+		}
+		changes := minimizeFixes(fix.changes, pkg.src)
+		addFixMetadata(changes, []diagnosticEntry{fix.entry}, pkg.fset, target)
+		if err := writePatch(f, changes, pkg.src, paths, format, fixContext); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveFixFileJSON writes the structured form of the fixes.
+func saveFixFileJSON(fixJSONPath string, paths patchPaths, fixes []fileChange) error {
+	if fixJSONPath == "" {
+		return nil
+	}
+	f, err := os.Create(longPath(fixJSONPath))
+	if err != nil {
+		return fmt.Errorf("creating %q: %w", fixJSONPath, err)
+	}
+	// fixCompression is defined by the template in generate_nogo_main.go.
+	w := newFixFileWriter(f, fixCompression)
+	if err := writeFixFile(w, fixes, paths); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// verifySuggestedFixes analyzes the package again with the fixes applied to
+// copies of its sources. It describes the problems with the fixes: fixes that
+// break the package and fixes that don't resolve their diagnostics.
+func verifySuggestedFixes(fixes []fileChange, diagnostics []diagnosticEntry, pkg *goPackage, packagePath string, packageFile, importMap, factMap map[string]string, srcs, ignores []string, execroot string) []string {
+	dir, err := os.MkdirTemp("", "nogo_verify")
+	if err != nil {
+		return []string{err.Error()}
+	}
+	defer os.RemoveAll(dir)
+	// fixFormat is defined by the template in generate_nogo_main.go.
+	fixedSrcs, originals, err := writeFixedSources(dir, execroot, srcs, pkg.src, fixes, newFixFormatter(fixFormat, importNames(pkg)))
+	if err != nil {
+		return []string{fmt.Sprintf("writing the fixed sources: %v", err)}
+	}
+	fixedNames := make(map[string]string)
+	for fixed, src := range originals {
+		fixedNames[src] = fixed
+	}
+	var fixedIgnores []string
+	for _, ignore := range ignores {
+		if fixed, ok := fixedNames[ignore]; ok {
+			fixedIgnores = append(fixedIgnores, fixed)
+		}
+	}
+
+	fixedDiagnostics, fixedPkg, err := checkPackage(analyzers, packagePath, packageFile, importMap, factMap, fixedSrcs, fixedIgnores, dir)
+	// Errors refer to the copies by their execroot-relative paths.
+	relativize := func(err error) string {
+		return strings.ReplaceAll(err.Error(), dir+string(filepath.Separator), "")
+	}
+	if fixedPkg == nil {
+		return []string{"the fixed package could not be analyzed: " + relativize(err)}
+	}
+	if fixedPkg.illTyped && !pkg.illTyped {
+		return []string{"the fixes break the package: " + relativize(fixedPkg.typeCheckError)}
+	}
+
+	var problems []string
+	before := func(pos token.Pos) string {
+		return pkg.fset.Position(pos).Filename
+	}
+	after := func(pos token.Pos) string {
+		return originals[fixedPkg.fset.Position(pos).Filename]
+	}
+	for _, d := range unresolvedDiagnostics(diagnostics, before, fixedDiagnostics, after) {
+		problems = append(problems, fmt.Sprintf("%s: the fix doesn't resolve %q (%s)", pkg.fset.Position(d.Pos), d.Message, d.analyzerName))
+	}
+	return problems
+}
+
+// importNames maps the import paths of the package to the names of the
+// imported packages.
+func importNames(pkg *goPackage) map[string]string {
+	names := make(map[string]string)
+	if pkg.typesInfo == nil {
+		return names
+	}
+	for _, f := range pkg.syntax {
+		for _, spec := range f.Imports {
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+			obj := pkg.typesInfo.Implicits[spec]
+			if spec.Name != nil {
+				obj = pkg.typesInfo.Defs[spec.Name]
+			}
+			if pkgName, ok := obj.(*types.PkgName); ok {
+				names[path] = pkgName.Imported().Name()
+			}
+		}
+	}
+	return names
+}
+
+func saveInspectionXML(inspectionXMLPath, packagePath string, diagnostics []diagnosticEntry, pkg *goPackage) error {
+	if inspectionXMLPath == "" {
+		return nil
+	}
+	f, err := os.Create(longPath(inspectionXMLPath))
+	if err != nil {
+		return fmt.Errorf("creating %q: %w", inspectionXMLPath, err)
+	}
+	if err := writeInspectionXML(f, packagePath, diagnostics, pkg.fset); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func saveDiagnosticsJSON(diagnosticsJSONPath, packagePath string, diagnostics []diagnosticEntry, suppressed int, pkg *goPackage) error {
+	if diagnosticsJSONPath == "" {
+		return nil
+	}
+	f, err := os.Create(longPath(diagnosticsJSONPath))
+	if err != nil {
+		return fmt.Errorf("creating %q: %w", diagnosticsJSONPath, err)
+	}
+	report := newDiagnosticsReport(packagePath, diagnostics, pkg.fset)
+	report.Suppressed = suppressed
+	if err := writeDiagnosticsReport(f, report); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
 }
 
 // Adapted from go/src/cmd/compile/internal/gc/main.go. Keep in sync.
@@ -197,15 +715,23 @@ func readImportCfg(file string) (packageFile map[string]string, importMap map[st
 // It returns an empty string if no source code diagnostics need to be printed.
 //
 // This implementation was adapted from that of golang.org/x/tools/go/checker/internal/checker.
-func checkPackage(analyzers []*analysis.Analyzer, packagePath string, packageFile, importMap, factMap map[string]string, filenames, ignoreFiles []string) ([]diagnosticEntry, *goPackage, error) {
+func checkPackage(analyzers []*analysis.Analyzer, packagePath string, packageFile, importMap, factMap map[string]string, filenames, ignoreFiles []string, execroot string) ([]diagnosticEntry, *goPackage, error) {
 	// Register fact types and establish dependencies between analyzers.
 	actions := make(map[*analysis.Analyzer]*action)
+	shared := make(map[sharedResultKey]*action)
 	var visit func(a *analysis.Analyzer) *action
 	visit = func(a *analysis.Analyzer) *action {
 		act, ok := actions[a]
+		key, share := sharedResult(a)
+		if !ok && share {
+			act, ok = shared[key]
+		}
 		if !ok {
 			act = &action{a: a}
 			actions[a] = act
+			if share {
+				shared[key] = act
+			}
 			for _, f := range a.FactTypes {
 				act.usesFacts = true
 				gob.Register(f)
@@ -223,6 +749,7 @@ func checkPackage(analyzers []*analysis.Analyzer, packagePath string, packageFil
 	}
 
 	roots := make([]*action, 0, len(analyzers))
+	rootSet := make(map[*action]bool)
 	for _, a := range analyzers {
 		if cfg, ok := configs[a.Name]; ok {
 			for flagKey, flagVal := range cfg.analyzerFlags {
@@ -239,12 +766,19 @@ func checkPackage(analyzers []*analysis.Analyzer, packagePath string, packageFil
 				}
 			}
 		}
-		roots = append(roots, visit(a))
+		// Copies of an analyzer that share an action are run and reported
+		// once.
+		if act := visit(a); !rootSet[act] {
+			rootSet[act] = true
+			roots = append(roots, act)
+		}
 	}
 
 	// Load the package, including AST, types, and facts.
 	imp := newImporter(importMap, packageFile, factMap)
+	loadSpan := nogoTracer.start("nogo.load")
 	pkg, err := load(packagePath, imp, filenames)
+	loadSpan.finish()
 	if err != nil {
 		return nil, nil, fmt.Errorf("error loading package: %v", err)
 	}
@@ -252,6 +786,12 @@ func checkPackage(analyzers []*analysis.Analyzer, packagePath string, packageFil
 	for _, act := range actions {
 		act.pkg = pkg
 	}
+	// Run the external analyzers before processing the nolint directives, so
+	// that these apply to their findings as well.
+	externalActions := runExternalAnalyzers(packagePath, packageFile, importMap, filenames, pkg)
+	for _, act := range externalActions {
+		actions[act.a] = act
+	}
 
 	ignoreFilesSet := map[string]struct{}{}
 	for _, ignore := range ignoreFiles {
@@ -294,10 +834,26 @@ func checkPackage(analyzers []*analysis.Analyzer, packagePath string, packageFil
 		}
 	}
 
+	for _, act := range externalActions {
+		var reported []analysis.Diagnostic
+		for _, d := range act.diagnostics {
+			if !act.nolinted(d) {
+				reported = append(reported, d)
+			}
+		}
+		act.diagnostics = reported
+	}
+
 	// Execute the analyzers.
 	execAll(roots)
+	// Complete the renames that analyzers mark with facts, see renameFact.
+	for _, act := range roots {
+		if factTypes := renameFactTypes(act.a); act.err == nil && len(factTypes) > 0 {
+			act.renames = renameUses(pkg.facts.AllObjectFacts(factTypes), pkg.syntax, pkg.typesInfo)
+		}
+	}
 
-	diagnostics, err := checkAnalysisResults(roots, pkg)
+	diagnostics, err := checkAnalysisResults(append(roots, externalActions...), pkg, execroot)
 	return diagnostics, pkg, err
 }
 
@@ -322,6 +878,14 @@ type action struct {
 	usesFacts   bool
 	err         error
 	nolint      []*Range
+	// renames complete the renames that the analyzer marks with facts in the
+	// package, see renameUses.
+	renames []analysis.Diagnostic
+	// mu guards diagnostics and stopped, since an analyzer that timed out may
+	// still report diagnostics.
+	mu sync.Mutex
+	// stopped is set once the analyzer returned or timed out.
+	stopped bool
 }
 
 func (act *action) String() string {
@@ -356,31 +920,36 @@ func (act *action) execOnce() {
 	if failed != nil {
 		sort.Strings(failed)
 		act.err = fmt.Errorf("failed prerequisites: %s", strings.Join(failed, ", "))
+		for _, dep := range act.deps {
+			var failure *analyzerFailure
+			if errors.As(dep.err, &failure) {
+				act.err = &analyzerFailure{act.err.Error()}
+				break
+			}
+		}
 		return
 	}
 
 	// Plumb the output values of the dependencies
 	// into the inputs of this action.
 	inputs := make(map[*analysis.Analyzer]interface{})
-	for _, dep := range act.deps {
+	for i, req := range act.a.Requires {
 		// Same package, different analysis (horizontal edge):
 		// in-memory outputs of prerequisite analyzers
-		// become inputs to this analysis pass.
-		inputs[dep.a] = dep.result
+		// become inputs to this analysis pass. The action of a
+		// prerequisite may be shared with a copy of it, so the
+		// result is keyed by the required analyzer itself.
+		inputs[req] = act.deps[i].result
 	}
 
 	ignoreNolintReporter := func(d analysis.Diagnostic) {
-		pos := act.pkg.fset.Position(d.Pos)
-		for _, rng := range act.nolint {
-			// The list of nolint ranges is built for the entire package. Make sure we
-			// only apply ranges to the correct file.
-			if pos.Filename != rng.from.Filename {
-				continue
-			}
-			if pos.Line < rng.from.Line || pos.Line > rng.to {
-				continue
-			}
-			// Found a nolint range. Ignore the issue.
+		if act.nolinted(d) {
+			return
+		}
+		act.mu.Lock()
+		defer act.mu.Unlock()
+		if act.stopped {
+			// The analyzer timed out and its findings are no longer read.
 			return
 		}
 		act.diagnostics = append(act.diagnostics, d)
@@ -411,7 +980,10 @@ func (act *action) execOnce() {
 
 	var err error
 	if !act.pkg.illTyped || pass.Analyzer.RunDespiteErrors {
-		act.result, err = pass.Analyzer.Run(pass)
+		act.result, err = runAnalyzer(pass)
+		act.mu.Lock()
+		act.stopped = true
+		act.mu.Unlock()
 		if err == nil {
 			if got, want := reflect.TypeOf(act.result), pass.Analyzer.ResultType; got != want {
 				err = fmt.Errorf(
@@ -423,6 +995,144 @@ func (act *action) execOnce() {
 	act.err = err
 }
 
+// nolinted reports whether the diagnostic is in a range of the package that
+// disables the analyzer of the action with a nolint directive.
+func (act *action) nolinted(d analysis.Diagnostic) bool {
+	pos := act.pkg.fset.Position(d.Pos)
+	for _, rng := range act.nolint {
+		// The list of nolint ranges is built for the entire package. Make sure we
+		// only apply ranges to the correct file.
+		if pos.Filename != rng.from.Filename {
+			continue
+		}
+		if pos.Line < rng.from.Line || pos.Line > rng.to {
+			continue
+		}
+		// Found a nolint range.
+		return true
+	}
+	return false
+}
+
+// runExternalAnalyzers runs the external analyzers on the package and returns
+// an action holding the findings of each analyzer they report for. An
+// external analyzer that fails, e.g. by exiting with an error or timing out,
+// is reported as a finding of the analyzer named after it, like an analyzer
+// that panicked.
+func runExternalAnalyzers(packagePath string, packageFile, importMap map[string]string, filenames []string, pkg *goPackage) []*action {
+	req := externalRequest{
+		Version:      externalProtocolVersion,
+		PackagePath:  packagePath,
+		Files:        filenames,
+		ImportMap:    importMap,
+		PackageFiles: packageFile,
+	}
+	var actions []*action
+	byName := make(map[string]*action)
+	actionFor := func(name string) *action {
+		act, ok := byName[name]
+		if !ok {
+			// External analyzers type-check the package themselves, so they
+			// are not skipped for packages with type errors.
+			act = &action{a: &analysis.Analyzer{Name: name, RunDespiteErrors: true}, pkg: pkg}
+			byName[name] = act
+			actions = append(actions, act)
+		}
+		return act
+	}
+	for _, rlocation := range externalAnalyzers {
+		name := externalAnalyzerName(rlocation)
+		span := nogoTracer.start("nogo.analyzer", "nogo.analyzer", name)
+		stopMetrics := nogoMetrics.measure(name, false)
+		byAnalyzer, err := func() (map[string][]analysis.Diagnostic, error) {
+			path, err := runfilePath(rlocation)
+			if err != nil {
+				return nil, err
+			}
+			resp, err := runExternalAnalyzer(path, req, analyzerTimeout(name))
+			if err != nil {
+				return nil, err
+			}
+			return externalDiagnostics(resp, name, pkg.fset)
+		}()
+		stopMetrics()
+		span.finish()
+		if err != nil {
+			actionFor(name).err = &analyzerFailure{fmt.Sprintf("external analyzer failed: %v", err)}
+			continue
+		}
+		names := make([]string, 0, len(byAnalyzer))
+		for analyzer := range byAnalyzer {
+			names = append(names, analyzer)
+		}
+		sort.Strings(names)
+		for _, analyzer := range names {
+			act := actionFor(analyzer)
+			act.diagnostics = append(act.diagnostics, byAnalyzer[analyzer]...)
+		}
+	}
+	return actions
+}
+
+// analyzerSlots bounds the number of analyzers that run at the same time to
+// the number of CPUs available to nogo. Independent analyzers still run
+// concurrently, but large sets of analyzers no longer compete for the CPUs and
+// hold their intermediate state in memory all at once. Actions wait for their
+// dependencies without holding a slot, so the bound can't deadlock.
+var analyzerSlots = make(chan struct{}, runtime.GOMAXPROCS(0))
+
+// An analyzerFailure is a panic or a timeout of an analyzer, or of an analyzer
+// it requires. Unlike other errors, it is reported as a finding of the
+// analyzer instead of failing nogo, so that a misbehaving analyzer doesn't
+// break the compilation of every package.
+type analyzerFailure struct {
+	msg string
+}
+
+func (f *analyzerFailure) Error() string { return f.msg }
+
+// runAnalyzer runs the analyzer of a pass once a slot is available. Panics
+// and timeouts of the analyzer are returned as analyzerFailures. An analyzer
+// that times out keeps running in the background, but its slot is released.
+func runAnalyzer(pass *analysis.Pass) (interface{}, error) {
+	analyzerSlots <- struct{}{}
+	defer func() { <-analyzerSlots }()
+	span := nogoTracer.start("nogo.analyzer", "nogo.analyzer", pass.Analyzer.Name)
+	defer span.finish()
+	defer nogoMetrics.measure(pass.Analyzer.Name, true)()
+
+	type outcome struct {
+		result interface{}
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				// The stack doesn't fit into a finding, but is needed to debug
+				// the analyzer.
+				fmt.Fprintf(os.Stderr, "analyzer %s panicked: %v\n%s", pass.Analyzer.Name, r, debug.Stack())
+				msg := strings.Join(strings.Fields(fmt.Sprint(r)), " ")
+				done <- outcome{err: &analyzerFailure{fmt.Sprintf("analyzer panicked: %s", msg)}}
+			}
+		}()
+		result, err := pass.Analyzer.Run(pass)
+		done <- outcome{result, err}
+	}()
+	var timeout <-chan time.Time
+	if d := analyzerTimeout(pass.Analyzer.Name); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case o := <-done:
+		return o.result, o.err
+	case <-timeout:
+		return nil, &analyzerFailure{fmt.Sprintf("analyzer timed out after %v", analyzerTimeout(pass.Analyzer.Name))}
+	}
+}
+
 // load parses and type checks the source code in each file in filenames.
 // load also deserializes facts stored for imported packages.
 func load(packagePath string, imp *importer, filenames []string) (*goPackage, error) {
@@ -430,14 +1140,20 @@ func load(packagePath string, imp *importer, filenames []string) (*goPackage, er
 		return nil, errors.New("no filenames")
 	}
 	var syntax []*ast.File
+	src := make(map[string][]byte, len(filenames))
 	for _, file := range filenames {
-		s, err := parser.ParseFile(imp.fset, file, nil, parser.ParseComments)
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		s, err := parser.ParseFile(imp.fset, file, content, parser.ParseComments)
 		if err != nil {
 			return nil, err
 		}
 		syntax = append(syntax, s)
+		src[file] = content
 	}
-	pkg := &goPackage{fset: imp.fset, syntax: syntax}
+	pkg := &goPackage{fset: imp.fset, syntax: syntax, src: src}
 
 	config := types.Config{Importer: imp}
 	info := &types.Info{
@@ -457,7 +1173,9 @@ func load(packagePath string, imp *importer, filenames []string) (*goPackage, er
 	}
 	pkg.types, pkg.typesInfo = types, info
 
+	span := nogoTracer.start("nogo.facts.decode")
 	pkg.facts, err = facts.NewDecoder(pkg.types).Decode(imp.readFacts)
+	span.finish()
 	if err != nil {
 		return nil, fmt.Errorf("internal error decoding facts: %v", err)
 	}
@@ -472,6 +1190,8 @@ type goPackage struct {
 	fset *token.FileSet
 	// syntax is the package's syntax trees.
 	syntax []*ast.File
+	// src holds the contents of the files of syntax by name.
+	src map[string][]byte
 	// types provides type information for the package.
 	types *types.Package
 	// facts contains information saved by the analysis framework. Passes may
@@ -496,12 +1216,33 @@ func (g *goPackage) String() string {
 // checkAnalysisResults checks the analysis diagnostics in the given actions
 // and returns a string containing all the diagnostics that should be printed
 // to the build log.
-func checkAnalysisResults(actions []*action, pkg *goPackage) ([]diagnosticEntry, error) {
+func checkAnalysisResults(actions []*action, pkg *goPackage, execroot string) ([]diagnosticEntry, error) {
 	var diagnostics []diagnosticEntry
 	var errs []error
-	cwd, err := os.Getwd()
-	if cwd == "" || err != nil {
-		errs = append(errs, fmt.Errorf("nogo failed to get CWD: %w", err))
+	// relFileName returns the name of the file of p relative to the execroot.
+	relFileName := func(p token.Position) string {
+		// NOTE(golang.org/issue/31008): nilness does not set positions,
+		// so don't assume the position is valid.
+		filename := "-"
+		if p.IsValid() {
+			filename = p.Filename
+		}
+		if execroot != "" {
+			if relname, err := filepath.Rel(execroot, filename); err == nil {
+				filename = relname
+			}
+		}
+		return filename
+	}
+	// fileName returns the name the file patterns in the config are matched
+	// against.
+	fileName := func(pos token.Pos) string {
+		return relFileName(pkg.fset.Position(pos))
+	}
+	// rawFileName returns the name of the file that contains pos, ignoring
+	// //line directives.
+	rawFileName := func(pos token.Pos) string {
+		return relFileName(pkg.fset.PositionFor(pos, false))
 	}
 	numSkipped := 0
 	for _, act := range actions {
@@ -511,82 +1252,80 @@ func checkAnalysisResults(actions []*action, pkg *goPackage) ([]diagnosticEntry,
 			continue
 		}
 		if act.err != nil {
+			var failure *analyzerFailure
+			if errors.As(act.err, &failure) {
+				diagnostics = append(diagnostics, diagnosticEntry{
+					Diagnostic:   analysis.Diagnostic{Message: failure.Error()},
+					analyzerName: act.a.Name,
+					severity:     severity(act.a.Name),
+				})
+				continue
+			}
+			if c := analyzerConfig(configs, act.a.Name); c.reportErrors != nil && *c.reportErrors {
+				// Report the error like the findings of the analyzer, which
+				// only fail the validation action, so that the facts of the
+				// package are still written for its dependents.
+				diagnostics = append(diagnostics, diagnosticEntry{
+					Diagnostic:   analysis.Diagnostic{Message: fmt.Sprintf("analyzer failed: %v", act.err)},
+					analyzerName: act.a.Name,
+					severity:     severity(act.a.Name),
+				})
+				continue
+			}
 			// Analyzer failed.
 			errs = append(errs, fmt.Errorf("analyzer %q failed: %v", act.a.Name, act.err))
 			continue
 		}
-		if len(act.diagnostics) == 0 {
+		if len(act.diagnostics) == 0 && len(act.renames) == 0 {
 			continue
 		}
-		var currentConfig config
-		// Use the base config if it exists.
-		if baseConfig, ok := configs[nogoBaseConfigName]; ok {
-			currentConfig = baseConfig
+		currentConfig := analyzerConfig(configs, act.a.Name)
+		fixes := currentConfig.fixes == nil || *currentConfig.fixes
+		report := currentConfig.diagnostics == nil || *currentConfig.diagnostics
+		if !fixes && !report {
+			continue
 		}
-		// Overwrite the config with the desired config. Any unset fields
-		// in the config will default to the base config.
-		if actionConfig, ok := configs[act.a.Name]; ok {
-			if actionConfig.analyzerFlags != nil {
-				currentConfig.analyzerFlags = actionConfig.analyzerFlags
-			}
-			if actionConfig.onlyFiles != nil {
-				currentConfig.onlyFiles = actionConfig.onlyFiles
-			}
-			if actionConfig.excludeFiles != nil {
-				currentConfig.excludeFiles = actionConfig.excludeFiles
-			}
+		// Suggested fixes may only edit the files the analyzer reports
+		// diagnostics for that are also in its fix scope.
+		scope := fileScope{only: currentConfig.onlyFiles, exclude: currentConfig.excludeFiles}
+		if currentConfig.tests != nil && !*currentConfig.tests {
+			scope = scope.withoutTests()
 		}
-
-		if currentConfig.onlyFiles == nil && currentConfig.excludeFiles == nil {
-			for _, diag := range act.diagnostics {
-				diagnostics = append(diagnostics, diagnosticEntry{Diagnostic: diag, analyzerName: act.a.Name})
-			}
-			continue
+		fixScope := fileScope{only: currentConfig.fixOnlyFiles, exclude: currentConfig.fixExcludeFiles}
+		if currentConfig.fixTests != nil && !*currentConfig.fixTests {
+			fixScope = fixScope.withoutTests()
 		}
-		// Discard diagnostics based on the analyzer configuration.
-		for _, d := range act.diagnostics {
-			// NOTE(golang.org/issue/31008): nilness does not set positions,
-			// so don't assume the position is valid.
-			p := pkg.fset.Position(d.Pos)
-			filename := "-"
-			if p.IsValid() {
-				filename = p.Filename
-			}
-			if cwd != "" {
-				if relname, err := filepath.Rel(cwd, filename); err == nil {
-					filename = relname
-				}
-			}
-			include := true
-			if len(currentConfig.onlyFiles) > 0 {
-				// This analyzer emits diagnostics for only a set of files.
-				include = false
-				for _, pattern := range currentConfig.onlyFiles {
-					if pattern.MatchString(filename) {
-						include = true
-						break
-					}
-				}
+		fixScopes := []fileScope{scope, fixScope}
+		actDiagnostics := act.diagnostics
+		if fixes {
+			// The diagnostics completing the renames of the analyzer are
+			// never reported, only their fixes are applied.
+			actDiagnostics = append(actDiagnostics[:len(actDiagnostics):len(actDiagnostics)], act.renames...)
+		}
+		for i, d := range actDiagnostics {
+			// Discard diagnostics based on the analyzer configuration.
+			if !scope.contains(fileName(d.Pos)) {
+				continue
 			}
-			if include {
-				for _, pattern := range currentConfig.excludeFiles {
-					if pattern.MatchString(filename) {
-						include = false
-						break
-					}
-				}
+			if !fixes {
+				d.SuggestedFixes = nil
 			}
-			if include {
-				diagnostics = append(diagnostics, diagnosticEntry{Diagnostic: d, analyzerName: act.a.Name})
+			if d.URL == "" {
+				// Like other analysis drivers, link the documentation of
+				// the analyzer if the diagnostic doesn't link its own.
+				d.URL = act.a.URL
 			}
+			excluded := excludeFixes(&d, fixScopes, fileName)
+			remapped := excludeRemappedFixes(&d, pkg.fset, rawFileName)
+			skipByteOrderMark(&d, pkg.fset, pkg.src)
+			splitRune := excludeSplitRuneFixes(&d, pkg.fset, pkg.src, rawFileName)
+			diagnostics = append(diagnostics, diagnosticEntry{Diagnostic: d, analyzerName: act.a.Name, fixOnly: !report || i >= len(act.diagnostics), excludedFixFiles: excluded, remappedFixFiles: remapped, splitRuneFixFiles: splitRune, severity: severity(act.a.Name)})
 		}
 	}
 	if numSkipped > 0 {
 		errs = append(errs, fmt.Errorf("%d analyzers skipped due to type-checking error: %v", numSkipped, pkg.typeCheckError))
 	}
-	sort.Slice(diagnostics, func(i, j int) bool {
-		return diagnostics[i].Pos < diagnostics[j].Pos
-	})
+	sortDiagnostics(diagnostics, pkg.fset)
 
 	if len(errs) == 0 {
 		return diagnostics, nil
@@ -602,23 +1341,123 @@ func checkAnalysisResults(actions []*action, pkg *goPackage) ([]diagnosticEntry,
 	return diagnostics, errors.New(errMsg.String())
 }
 
-// config determines which source files an analyzer will emit diagnostics for.
-// config values are generated in another file that is compiled with
-// nogo_main.go by the nogo rule.
-type config struct {
-	// onlyFiles is a list of regular expressions that match files an analyzer
-	// will emit diagnostics for. When empty, the analyzer will emit diagnostics
-	// for all files.
-	onlyFiles []*regexp.Regexp
+// reportedDiagnostics returns the diagnostics that are reported, dropping the
+// ones that are only kept for their fixes.
+func reportedDiagnostics(diagnostics []diagnosticEntry) []diagnosticEntry {
+	var reported []diagnosticEntry
+	for _, d := range diagnostics {
+		if !d.fixOnly {
+			reported = append(reported, d)
+		}
+	}
+	return reported
+}
 
-	// excludeFiles is a list of regular expressions that match files that an
-	// analyzer will not emit diagnostics for.
-	excludeFiles []*regexp.Regexp
+// unbaselinedDiagnostics returns the diagnostics that are not in the baseline
+// of known findings. If the baseline has fewer findings with the same file,
+// analyzer and message than the package, the last ones by position are
+// returned.
+func unbaselinedDiagnostics(diagnostics []diagnosticEntry, fset *token.FileSet) []diagnosticEntry {
+	// baseline is defined by the template in generate_nogo_main.go.
+	if len(baseline) == 0 {
+		return diagnostics
+	}
+	order := make([]int, len(diagnostics))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return diagnostics[order[i]].Pos < diagnostics[order[j]].Pos
+	})
+	known := newBaselineMatcher(baseline)
+	inBaseline := make([]bool, len(diagnostics))
+	for _, i := range order {
+		d := diagnostics[i]
+		if pos := fset.Position(d.Pos); pos.IsValid() {
+			inBaseline[i] = known.match(filepath.ToSlash(pos.Filename), d.analyzerName, d.Message)
+		}
+	}
+	var unbaselined []diagnosticEntry
+	for i, d := range diagnostics {
+		if !inBaseline[i] {
+			unbaselined = append(unbaselined, d)
+		}
+	}
+	return unbaselined
+}
+
+// configuredFixStrategy returns the strategy for resolving conflicts between
+// suggested fixes selected by the base config.
+func configuredFixStrategy() fixStrategy {
+	strategy := fixStrategy{
+		conflicts: configs[nogoBaseConfigName].fixConflicts,
+		priority: func(analyzerName string) int {
+			return configs[analyzerName].fixPriority
+		},
+	}
+	if strategy.conflicts == "" {
+		strategy.conflicts = fixConflictsFirst
+	}
+	return strategy
+}
+
+// failsBuild reports whether reported findings fail the build under the
+// fail_on mode of the base config, given whether nogo suggested fixes.
+func failsBuild(haveFixes bool) bool {
+	switch configs[nogoBaseConfigName].failOn {
+	case failOnFixable:
+		return haveFixes
+	case failOnUnfixable:
+		return !haveFixes
+	default:
+		return true
+	}
+}
+
+// severity returns the severity of the findings of the analyzer.
+func severity(analyzerName string) string {
+	if s := configs[analyzerName].severity; s != "" {
+		return s
+	}
+	if s := configs[nogoBaseConfigName].severity; s != "" {
+		return s
+	}
+	return severityError
+}
+
+// analyzerTimeout returns the timeout of the analyzer, or zero if it has none.
+func analyzerTimeout(analyzerName string) time.Duration {
+	if t := configs[analyzerName].timeout; t != 0 {
+		return t
+	}
+	return configs[nogoBaseConfigName].timeout
+}
 
-	// analyzerFlags is a map of flag names to flag values which will be passed
-	// to Analyzer.Flags. Note that no leading '-' should be present in a flag
-	// name
-	analyzerFlags map[string]string
+// writeRemediation writes the remediation note configured for the analyzer,
+// or for all analyzers in the base config, indented below a diagnostic.
+func writeRemediation(w *bytes.Buffer, analyzerName string) {
+	remediation := configs[analyzerName].remediation
+	if remediation == "" {
+		remediation = configs[nogoBaseConfigName].remediation
+	}
+	if remediation == "" {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimRight(remediation, "\n"), "\n") {
+		fmt.Fprintf(w, "\n    %s", line)
+	}
+}
+
+// factAnalyzers returns the analyzers that export facts. Their prerequisites
+// are run as well when they are checked.
+func factAnalyzers(analyzers []*analysis.Analyzer) []*analysis.Analyzer {
+	var withFacts []*analysis.Analyzer
+	for _, a := range analyzers {
+		if len(a.FactTypes) > 0 {
+			withFacts = append(withFacts, a)
+		}
+	}
+	return withFacts
 }
 
 // importer is an implementation of go/types.Importer that imports type
@@ -632,10 +1471,16 @@ type importer struct {
 }
 
 func newImporter(importMap, packageFile map[string]string, factMap map[string]string) *importer {
+	fset, packageCache := token.NewFileSet(), make(map[string]*types.Package)
+	if stdlibExports != nil {
+		// The standard library was decoded for a previous package analyzed
+		// by this persistent worker.
+		fset, packageCache = stdlibExports.importerState(packageFile)
+	}
 	return &importer{
-		fset:         token.NewFileSet(),
+		fset:         fset,
 		importMap:    importMap,
-		packageCache: make(map[string]*types.Package),
+		packageCache: packageCache,
 		packageFile:  packageFile,
 		factMap:      factMap,
 	}
@@ -685,14 +1530,26 @@ func (i *importer) readFacts(pkgPath string) ([]byte, error) {
 	if facts == "" {
 		// Packages that were not built with the nogo toolchain will not be
 		// analyzed, so there's no opportunity to store facts. This includes
-		// packages in the standard library and packages built with go_tool_library,
-		// such as coverdata. Analyzers are expected to hard code information
-		// about standard library definitions and must gracefully handle packages
-		// that don't have facts. For example, the "printf" analyzer must know
-		// fmt.Printf accepts a format string.
+		// packages built with go_tool_library, such as coverdata, and packages
+		// in the standard library, unless the nogo rule sets stdlib_facts.
+		// Analyzers are expected to hard code information about standard
+		// library definitions and must gracefully handle packages that don't
+		// have facts. For example, the "printf" analyzer must know fmt.Printf
+		// accepts a format string.
+		if stdlibFactArchive != nil {
+			return stdlibFactArchive.read(pkgPath)
+		}
 		return nil, nil
 	}
-	return os.ReadFile(facts)
+	data, err := os.ReadFile(facts)
+	if err != nil {
+		return nil, err
+	}
+	data, err = decodeFactFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("reading facts of %s from %s: %v", pkgPath, facts, err)
+	}
+	return data, nil
 }
 
 type factMultiFlag map[string]string
@@ -0,0 +1,91 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A metricsReport records the cost of each analyzer on a package.
+type metricsReport struct {
+	Package   string            `json:"package"`
+	Analyzers []analyzerMetrics `json:"analyzers"`
+}
+
+type analyzerMetrics struct {
+	Analyzer   string `json:"analyzer"`
+	WallTimeNs int64  `json:"wall_time_ns"`
+	// AllocBytes is omitted unless allocations are recorded and for
+	// external analyzers, which run in a process of their own.
+	AllocBytes uint64 `json:"alloc_bytes,omitempty"`
+}
+
+// A metricsRecorder collects the metrics of the analyzers run on a package. The
+// allocations of an analyzer are the bytes allocated by nogo while it runs, so
+// they are only recorded if allocs is set, which requires running the
+// analyzers one at a time.
+type metricsRecorder struct {
+	allocs    bool
+	mu        sync.Mutex
+	analyzers []analyzerMetrics
+}
+
+// measure starts measuring an analyzer and returns a function that records
+// its metrics. Allocations are only recorded for analyzers that run in the
+// nogo process, as reported by inProcess. It does nothing on a nil recorder.
+func (m *metricsRecorder) measure(analyzer string, inProcess bool) (stop func()) {
+	if m == nil {
+		return func() {}
+	}
+	allocs := m.allocs && inProcess
+	var stats runtime.MemStats
+	if allocs {
+		runtime.ReadMemStats(&stats)
+	}
+	startAlloc := stats.TotalAlloc
+	start := time.Now()
+	return func() {
+		metrics := analyzerMetrics{Analyzer: analyzer, WallTimeNs: int64(time.Since(start))}
+		if allocs {
+			runtime.ReadMemStats(&stats)
+			metrics.AllocBytes = stats.TotalAlloc - startAlloc
+		}
+		m.mu.Lock()
+		m.analyzers = append(m.analyzers, metrics)
+		m.mu.Unlock()
+	}
+}
+
+// report returns the metrics of the package, sorted by analyzer.
+func (m *metricsRecorder) report(packagePath string) metricsReport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	analyzers := append([]analyzerMetrics{}, m.analyzers...)
+	sort.Slice(analyzers, func(i, j int) bool { return analyzers[i].Analyzer < analyzers[j].Analyzer })
+	return metricsReport{Package: packagePath, Analyzers: analyzers}
+}
+
+func (m *metricsRecorder) write(path, packagePath string) error {
+	data, err := json.Marshal(m.report(packagePath))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(longPath(path), append(data, '\n'), 0o666)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var metricsSink []byte
+
+func TestMetricsRecorder(t *testing.T) {
+	m := &metricsRecorder{allocs: true}
+	stop := m.measure("printf", true)
+	metricsSink = make([]byte, 1<<20)
+	stop()
+	m.measure("external", false)()
+	// A nil recorder doesn't record anything.
+	var disabled *metricsRecorder
+	disabled.measure("printf", true)()
+
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	if err := m.write(path, "example.com/pkg"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report metricsReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Package != "example.com/pkg" || len(report.Analyzers) != 2 {
+		t.Fatalf("got report %+v, want the metrics of two analyzers of example.com/pkg", report)
+	}
+	external, printf := report.Analyzers[0], report.Analyzers[1]
+	if external.Analyzer != "external" || printf.Analyzer != "printf" {
+		t.Errorf("got analyzers %q and %q, want them sorted by name", external.Analyzer, printf.Analyzer)
+	}
+	if printf.AllocBytes < 1<<20 {
+		t.Errorf("got %d allocated bytes for printf, want at least %d", printf.AllocBytes, 1<<20)
+	}
+	if external.AllocBytes != 0 {
+		t.Errorf("got %d allocated bytes for an external analyzer, want none", external.AllocBytes)
+	}
+	if printf.WallTimeNs <= 0 {
+		t.Errorf("got wall time %d for printf, want a positive duration", printf.WallTimeNs)
+	}
+}
+
+func TestMetricsRecorderWallTimeOnly(t *testing.T) {
+	m := &metricsRecorder{}
+	stop := m.measure("printf", true)
+	metricsSink = make([]byte, 1<<20)
+	stop()
+	report := m.report("example.com/pkg")
+	if len(report.Analyzers) != 1 {
+		t.Fatalf("got report %+v, want the metrics of one analyzer", report)
+	}
+	if printf := report.Analyzers[0]; printf.AllocBytes != 0 || printf.WallTimeNs <= 0 {
+		t.Errorf("got %+v, want only the wall time of printf", printf)
+	}
+}
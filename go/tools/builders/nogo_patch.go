@@ -0,0 +1,985 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This file contains a reader for the unified diffs emitted by nogo and the
+// logic to merge them and apply them to the original sources.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A filePatch is the part of a unified diff that modifies a single file.
+type filePatch struct {
+	oldName string
+	newName string
+	hunks   []patchHunk
+	// digest is the content digest of the file the patch was generated
+	// against, from the sha256 metadata before the file section, if any.
+	digest string
+}
+
+// A patchHunk is a single "@@ -l,s +l,s @@" section of a unified diff.
+type patchHunk struct {
+	oldStart, oldLines int
+	newStart, newLines int
+	lines              []patchLine
+}
+
+// A patchLine is a line of a hunk. kind is one of ' ', '-' or '+' and text
+// includes the line terminator unless the line is at the end of a file
+// without a trailing newline.
+type patchLine struct {
+	kind byte
+	text string
+}
+
+// A lineEdit replaces the lines old, starting at the 0-based line index
+// start of the original file, with the lines new.
+type lineEdit struct {
+	start  int
+	old    []string
+	new    []string
+	source string // the patch file the edit comes from, for error messages.
+}
+
+// parseFixFile parses a fix file written by nogo. Besides unified diffs, it
+// accepts the structured JSON fix files and the JSON format written by
+// earlier versions of nogo. Their edits are converted to patches against the
+// current content of the files as returned by readFile.
+func parseFixFile(data []byte, readFile func(string) ([]byte, error)) ([]filePatch, error) {
+	// A unified diff never starts with a brace.
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var version struct {
+			Version *int `json:"version"`
+		}
+		if err := json.Unmarshal(trimmed, &version); err != nil {
+			return nil, fmt.Errorf("parsing JSON fix file: %v", err)
+		}
+		if version.Version == nil {
+			return migrateLegacyFix(trimmed, readFile)
+		}
+		return migrateFixFile(trimmed, *version.Version, readFile)
+	}
+	return parsePatch(data)
+}
+
+// fixFileDigest returns the digest of the edits in a structured JSON fix file
+// with edits, or false if data is not one.
+func fixFileDigest(data []byte) (string, bool) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return "", false
+	}
+	var fix fixFile
+	if err := json.Unmarshal(trimmed, &fix); err != nil || fix.Version == 0 || len(fix.Files) == 0 {
+		return "", false
+	}
+	return fix.digest(), true
+}
+
+// migrateFixFile converts a structured JSON fix file into patches.
+func migrateFixFile(data []byte, version int, readFile func(string) ([]byte, error)) ([]filePatch, error) {
+	if version < 1 || version > fixFileVersion {
+		return nil, fmt.Errorf("unsupported fix file version %d, expected at most %d", version, fixFileVersion)
+	}
+	var fix fixFile
+	if err := json.Unmarshal(data, &fix); err != nil {
+		return nil, fmt.Errorf("parsing fix file: %v", err)
+	}
+	analyzerToFileToEdits := make(map[string]map[string][]offsetEdit)
+	for fileName, analyzerToEdits := range fix.Files {
+		for analyzer, edits := range analyzerToEdits {
+			if analyzerToFileToEdits[analyzer] == nil {
+				analyzerToFileToEdits[analyzer] = make(map[string][]offsetEdit)
+			}
+			analyzerToFileToEdits[analyzer][fileName] = edits
+		}
+	}
+	return offsetEditPatches(analyzerToFileToEdits, readFile)
+}
+
+// legacyFix is the JSON fix file format written by earlier versions of nogo.
+// It maps analyzer names to the byte offset edits each analyzer suggests per
+// file.
+type legacyFix struct {
+	AnalyzerToFileToEdits map[string]map[string][]offsetEdit `json:"analyzer_file_to_edits"`
+}
+
+// migrateLegacyFix converts a legacy JSON fix file into patches.
+func migrateLegacyFix(data []byte, readFile func(string) ([]byte, error)) ([]filePatch, error) {
+	var fix legacyFix
+	if err := json.Unmarshal(data, &fix); err != nil {
+		return nil, fmt.Errorf("parsing legacy fix file: %v", err)
+	}
+	return offsetEditPatches(fix.AnalyzerToFileToEdits, readFile)
+}
+
+// offsetEditPatches converts the byte offset edits of each analyzer per file
+// into patches. The edits of each analyzer become separate hunks, so that
+// conflicts between analyzers are detected when the hunks are merged.
+func offsetEditPatches(analyzerToFileToEdits map[string]map[string][]offsetEdit, readFile func(string) ([]byte, error)) ([]filePatch, error) {
+	analyzers := make([]string, 0, len(analyzerToFileToEdits))
+	byFile := make(map[string]*filePatch)
+	for analyzer := range analyzerToFileToEdits {
+		analyzers = append(analyzers, analyzer)
+	}
+	sort.Strings(analyzers)
+	contents := make(map[string][]byte)
+	for _, analyzer := range analyzers {
+		for fileName, edits := range analyzerToFileToEdits[analyzer] {
+			if len(edits) == 0 {
+				continue
+			}
+			content, ok := contents[fileName]
+			if !ok {
+				var err error
+				if content, err = readFile(fileName); os.IsNotExist(err) && createsFileWithEdits(edits) {
+					// The edits create the file.
+					content, err = nil, nil
+				}
+				if err != nil {
+					return nil, err
+				}
+				contents[fileName] = content
+			}
+			hunks, err := offsetEditHunks(content, edits)
+			if err != nil {
+				return nil, fmt.Errorf("%s: edits from %s: %v", fileName, analyzer, err)
+			}
+			p, ok := byFile[fileName]
+			if !ok {
+				p = &filePatch{oldName: "a/" + fileName, newName: "b/" + fileName}
+				byFile[fileName] = p
+			}
+			if content == nil {
+				p.oldName = devNull
+			} else if len(edits) == 1 && edits[0].Start == 0 && edits[0].End == len(content) && edits[0].New == "" {
+				p.newName = devNull
+			}
+			p.hunks = append(p.hunks, hunks...)
+		}
+	}
+	patches := make([]filePatch, 0, len(byFile))
+	for _, p := range byFile {
+		patches = append(patches, *p)
+	}
+	sort.Slice(patches, func(i, j int) bool {
+		return patches[i].newName < patches[j].newName
+	})
+	return patches, nil
+}
+
+// createsFileWithEdits reports whether the edits only insert text at the start
+// of a file, which creates the file if it doesn't exist.
+func createsFileWithEdits(edits []offsetEdit) bool {
+	for _, e := range edits {
+		if e.Start != 0 || e.End != 0 {
+			return false
+		}
+	}
+	return len(edits) > 0
+}
+
+// offsetEditHunks converts byte offset edits into hunks that replace the
+// whole lines touched by the edits. Edits touching the same lines share a hunk.
+// Identical edits are applied once, see compareEdits.
+func offsetEditHunks(content []byte, edits []offsetEdit) ([]patchHunk, error) {
+	all := make([]offsetEdit, len(edits))
+	copy(all, edits)
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].Start != all[j].Start {
+			return all[i].Start < all[j].Start
+		}
+		return all[i].End < all[j].End
+	})
+	var sorted []offsetEdit
+	for i, e := range all {
+		if i == 0 || compareEdits(all[i-1], e) != editsIdentical {
+			sorted = append(sorted, e)
+		}
+	}
+	lines := splitLines(content)
+	lineStarts := make([]int, len(lines)+1)
+	for i, l := range lines {
+		lineStarts[i+1] = lineStarts[i] + len(l)
+	}
+	// lineOf returns the index of the line containing offset, or len(lines)
+	// for the end of a file that ends with a newline.
+	lineOf := func(offset int) int {
+		return sort.Search(len(lines), func(i int) bool { return lineStarts[i+1] > offset })
+	}
+
+	var hunks []patchHunk
+	for i := 0; i < len(sorted); {
+		// Extend the group of edits [i, j) and its lines [lo, hi) as long as the
+		// next edit touches the lines of the group.
+		lo, hi := len(lines), len(lines)
+		j := i
+		for ; j < len(sorted); j++ {
+			e := sorted[j]
+			if e.Start < 0 || e.Start > e.End || e.End > len(content) {
+				return nil, fmt.Errorf("invalid edit [%d, %d)", e.Start, e.End)
+			}
+			if j > i && compareEdits(sorted[j-1], e) == editsOverlapping {
+				return nil, fmt.Errorf("overlapping edits [%d, %d) and [%d, %d)", sorted[j-1].Start, sorted[j-1].End, e.Start, e.End)
+			}
+			first, last := lineOf(e.Start), lineOf(e.Start)
+			if e.End > e.Start {
+				last = lineOf(e.End - 1)
+			}
+			if j > i && first >= hi {
+				break
+			}
+			if j == i {
+				lo = first
+			}
+			hi = last + 1
+			if hi > len(lines) {
+				hi = len(lines)
+			}
+		}
+
+		var out bytes.Buffer
+		offset := lineStarts[lo]
+		for _, e := range sorted[i:j] {
+			out.Write(content[offset:e.Start])
+			out.WriteString(e.New)
+			offset = e.End
+		}
+		out.Write(content[offset:lineStarts[hi]])
+
+		h := patchHunk{oldStart: lo + 1, oldLines: hi - lo}
+		if h.oldLines == 0 {
+			h.oldStart = lo
+		}
+		for _, l := range lines[lo:hi] {
+			h.lines = append(h.lines, patchLine{kind: '-', text: l})
+		}
+		for _, l := range splitLines(out.Bytes()) {
+			h.lines = append(h.lines, patchLine{kind: '+', text: l})
+		}
+		h.newStart, h.newLines = h.oldStart, len(h.lines)-h.oldLines
+		hunks = append(hunks, h)
+		i = j
+	}
+	return hunks, nil
+}
+
+// parsePatchEdits parses a patch written by nogo back into the byte offset
+// edits it makes to each file, the inverse of writing the patch. Files are
+// keyed by their path in the patch with strip leading components removed, and
+// the edits apply to the content of the files returned by readFile for these
+// paths. Files created by the patch are not read and start out empty.
+func parsePatchEdits(data []byte, readFile func(string) ([]byte, error), strip int) (map[string][]offsetEdit, error) {
+	patches, err := parsePatch(data)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	byPath := make(map[string]*filePatch)
+	for _, p := range patches {
+		name := p.newName
+		if name == devNull {
+			name = p.oldName
+		}
+		path, err := stripPath(name, strip)
+		if err != nil {
+			return nil, err
+		}
+		if byPath[path] == nil {
+			byPath[path] = &filePatch{oldName: p.oldName, newName: p.newName}
+			paths = append(paths, path)
+		}
+		byPath[path].hunks = append(byPath[path].hunks, p.hunks...)
+	}
+
+	fileToEdits := make(map[string][]offsetEdit)
+	for _, path := range paths {
+		p := byPath[path]
+		var content []byte
+		if p.oldName != devNull {
+			if content, err = readFile(path); err != nil {
+				return nil, err
+			}
+		}
+		edits, err := p.offsetEdits(content)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		fileToEdits[path] = edits
+	}
+	return fileToEdits, nil
+}
+
+// offsetEdits converts the hunks of the patch into byte offset edits of the
+// original content of the file, sorted by offset. Each edit replaces the
+// lines changed by a hunk, without its context lines.
+func (p filePatch) offsetEdits(content []byte) ([]offsetEdit, error) {
+	lines := splitLines(content)
+	var edits []lineEdit
+	for _, h := range p.hunks {
+		e, err := h.lineEdits(lines, "")
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, e...)
+	}
+	edits, err := mergeLineEdits(edits)
+	if err != nil {
+		return nil, err
+	}
+
+	offsetEdits := make([]offsetEdit, 0, len(edits))
+	line, offset := 0, 0
+	for _, e := range edits {
+		for ; line < e.start; line++ {
+			offset += len(lines[line])
+		}
+		old, new := strings.Join(e.old, ""), strings.Join(e.new, "")
+		offsetEdits = append(offsetEdits, offsetEdit{New: new, Start: offset, End: offset + len(old)})
+	}
+	return offsetEdits, nil
+}
+
+// parsePatch parses a unified diff containing changes to any number of files.
+// Lines outside of file sections, such as "diff --git" headers, are ignored.
+func parsePatch(data []byte) ([]filePatch, error) {
+	var patches []filePatch
+	var cur *filePatch
+	var hunk *patchHunk
+	// remaining line counts of the current hunk.
+	var oldLeft, newLeft int
+	// digest is the sha256 metadata of the next file section.
+	var digest string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<30)
+	scanner.Split(scanLinesWithTerminator)
+	lineNum := 0
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineNum++
+		switch {
+		case hunk != nil && (oldLeft > 0 || newLeft > 0) && line != "" && strings.ContainsRune(" -+\r\n", rune(line[0])):
+			pl := patchLine{kind: line[0], text: line[1:]}
+			if pl.kind == '\r' || pl.kind == '\n' {
+				// Some tools strip the space of empty context lines.
+				pl = patchLine{kind: ' ', text: line}
+			}
+			if pl.kind != '+' {
+				oldLeft--
+			}
+			if pl.kind != '-' {
+				newLeft--
+			}
+			if oldLeft < 0 || newLeft < 0 {
+				return nil, fmt.Errorf("line %d: hunk is longer than its header says", lineNum)
+			}
+			hunk.lines = append(hunk.lines, pl)
+		case strings.HasPrefix(line, `\`):
+			// "\ No newline at end of file" applies to the preceding line.
+			if hunk == nil || len(hunk.lines) == 0 {
+				return nil, fmt.Errorf("line %d: unexpected %q", lineNum, strings.TrimSpace(line))
+			}
+			last := &hunk.lines[len(hunk.lines)-1]
+			last.text = strings.TrimSuffix(last.text, "\n")
+		case strings.HasPrefix(line, "--- "):
+			if err := checkHunkComplete(hunk, oldLeft, newLeft, lineNum); err != nil {
+				return nil, err
+			}
+			hunk = nil
+			patches = append(patches, filePatch{oldName: patchFileName(line[len("--- "):]), digest: digest})
+			cur = &patches[len(patches)-1]
+			digest = ""
+		case strings.HasPrefix(line, patchMetadataPrefix):
+			if metadata, ok := parsePatchMetadata(line); ok && metadata[metadataSHA256] != "" {
+				digest = metadata[metadataSHA256]
+			}
+		case strings.HasPrefix(line, "+++ "):
+			if cur == nil || cur.newName != "" {
+				return nil, fmt.Errorf("line %d: unexpected %q", lineNum, strings.TrimSpace(line))
+			}
+			cur.newName = patchFileName(line[len("+++ "):])
+		case strings.HasPrefix(line, "@@ "):
+			if cur == nil || cur.newName == "" {
+				return nil, fmt.Errorf("line %d: hunk outside of a file section", lineNum)
+			}
+			if err := checkHunkComplete(hunk, oldLeft, newLeft, lineNum); err != nil {
+				return nil, err
+			}
+			h, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNum, err)
+			}
+			cur.hunks = append(cur.hunks, h)
+			hunk = &cur.hunks[len(cur.hunks)-1]
+			oldLeft, newLeft = h.oldLines, h.newLines
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := checkHunkComplete(hunk, oldLeft, newLeft, lineNum); err != nil {
+		return nil, err
+	}
+	return patches, nil
+}
+
+func checkHunkComplete(hunk *patchHunk, oldLeft, newLeft, lineNum int) error {
+	if hunk != nil && (oldLeft > 0 || newLeft > 0) {
+		return fmt.Errorf("line %d: hunk is shorter than its header says", lineNum)
+	}
+	return nil
+}
+
+// patchFileName extracts the file name from a "---" or "+++" line, dropping
+// the optional tab-separated timestamp.
+func patchFileName(s string) string {
+	s = strings.TrimRight(s, "\r\n")
+	if i := strings.IndexByte(s, '\t'); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
+
+// parseHunkHeader parses a line of the form "@@ -l[,s] +l[,s] @@[ section]".
+func parseHunkHeader(line string) (patchHunk, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || fields[0] != "@@" || fields[3] != "@@" ||
+		!strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return patchHunk{}, fmt.Errorf("invalid hunk header %q", strings.TrimSpace(line))
+	}
+	var h patchHunk
+	var err error
+	if h.oldStart, h.oldLines, err = parseHunkRange(fields[1][1:]); err != nil {
+		return patchHunk{}, fmt.Errorf("invalid hunk header %q: %v", strings.TrimSpace(line), err)
+	}
+	if h.newStart, h.newLines, err = parseHunkRange(fields[2][1:]); err != nil {
+		return patchHunk{}, fmt.Errorf("invalid hunk header %q: %v", strings.TrimSpace(line), err)
+	}
+	return h, nil
+}
+
+func parseHunkRange(s string) (start, lines int, err error) {
+	lines = 1
+	if i := strings.IndexByte(s, ','); i >= 0 {
+		if lines, err = strconv.Atoi(s[i+1:]); err != nil {
+			return 0, 0, err
+		}
+		s = s[:i]
+	}
+	if start, err = strconv.Atoi(s); err != nil {
+		return 0, 0, err
+	}
+	return start, lines, nil
+}
+
+// scanLinesWithTerminator is a bufio.SplitFunc like bufio.ScanLines that
+// keeps the line terminator.
+func scanLinesWithTerminator(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i+1], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// splitLines splits content into lines, keeping the line terminators.
+func splitLines(content []byte) []string {
+	var lines []string
+	for len(content) > 0 {
+		i := bytes.IndexByte(content, '\n')
+		if i < 0 {
+			lines = append(lines, string(content))
+			break
+		}
+		lines = append(lines, string(content[:i+1]))
+		content = content[i+1:]
+	}
+	return lines
+}
+
+// lineEdits verifies that the hunk matches the original lines of the file and
+// converts it into the edits it makes. Only the lines actually changed by the
+// hunk are part of the edits, so that hunks whose context lines overlap can
+// still be combined.
+func (h patchHunk) lineEdits(lines []string, source string) ([]lineEdit, error) {
+	i := h.startIndex()
+	if i < 0 || i > len(lines) {
+		return nil, fmt.Errorf("hunk @@ -%d,%d @@ is out of range", h.oldStart, h.oldLines)
+	}
+	var edits []lineEdit
+	var cur *lineEdit
+	for _, l := range h.lines {
+		if l.kind == ' ' {
+			cur = nil
+		} else if cur == nil {
+			edits = append(edits, lineEdit{start: i, source: source})
+			cur = &edits[len(edits)-1]
+		}
+		switch l.kind {
+		case ' ', '-':
+			if i >= len(lines) {
+				if l.kind == ' ' && l.text == "\n" {
+					// Older versions of nogo emitted an empty context line past the
+					// end of files ending with a newline.
+					continue
+				}
+				return nil, fmt.Errorf("hunk @@ -%d,%d @@ extends past the end of the file", h.oldStart, h.oldLines)
+			}
+			if lines[i] != l.text {
+				return nil, fmt.Errorf("hunk @@ -%d,%d @@ does not match line %d: expected %q, found %q", h.oldStart, h.oldLines, i+1, l.text, lines[i])
+			}
+			if l.kind == '-' {
+				cur.old = append(cur.old, l.text)
+			}
+			i++
+		case '+':
+			cur.new = append(cur.new, l.text)
+		}
+	}
+	return edits, nil
+}
+
+// startIndex returns the 0-based index of the first line of the original file
+// covered by the hunk. For hunks that don't remove any lines, the start line is
+// the line after which the new lines are inserted.
+func (h patchHunk) startIndex() int {
+	if h.oldLines == 0 {
+		return h.oldStart
+	}
+	return h.oldStart - 1
+}
+
+// locate finds the lines of the file that the hunk applies to if the file
+// changed since the patch was generated, like patch does. The hunk is tried
+// at its own line first, then at increasing offsets before and after it. If it
+// doesn't match anywhere, up to fuzz lines of context are ignored at its start
+// and end. It returns the hunk moved to the lines it matches, without the
+// ignored context, and the number of context lines ignored at each end, or
+// false if the hunk doesn't match the file.
+func (h patchHunk) locate(lines []string, fuzz int) (patchHunk, int, bool) {
+	for f := 0; f <= fuzz; f++ {
+		trimmed := h.withoutContext(f)
+		if f > 0 && len(trimmed.lines) == len(h.withoutContext(f-1).lines) {
+			// There is no more context to ignore.
+			break
+		}
+		if trimmed.oldLines == 0 {
+			// A hunk without context or removed lines matches any line, so
+			// it can only be applied where the patch puts it.
+			if _, err := trimmed.lineEdits(lines, ""); f == 0 && err == nil {
+				return trimmed, 0, true
+			}
+			break
+		}
+		start := trimmed.startIndex()
+		for d := 0; start-d >= 0 || start+d <= len(lines); d++ {
+			offsets := []int{-d, d}
+			if d == 0 {
+				offsets = offsets[:1]
+			}
+			for _, offset := range offsets {
+				moved := trimmed
+				moved.oldStart += offset
+				moved.newStart += offset
+				if i := moved.startIndex(); i < 0 || i > len(lines) {
+					continue
+				}
+				if _, err := moved.lineEdits(lines, ""); err == nil {
+					return moved, f, true
+				}
+			}
+		}
+	}
+	return patchHunk{}, 0, false
+}
+
+// withoutContext returns the hunk without up to n lines of context at its
+// start and at its end.
+func (h patchHunk) withoutContext(n int) patchHunk {
+	lead, trail := 0, 0
+	for lead < n && lead < len(h.lines) && h.lines[lead].kind == ' ' {
+		lead++
+	}
+	for trail < n && trail < len(h.lines)-lead && h.lines[len(h.lines)-1-trail].kind == ' ' {
+		trail++
+	}
+	if lead == 0 && trail == 0 {
+		return h
+	}
+	t := h
+	t.lines = h.lines[lead : len(h.lines)-trail]
+	t.oldStart += lead
+	t.newStart += lead
+	t.oldLines -= lead + trail
+	t.newLines -= lead + trail
+	return t
+}
+
+func (e lineEdit) end() int {
+	return e.start + len(e.old)
+}
+
+func (e lineEdit) equals(other lineEdit) bool {
+	return e.start == other.start && strings.Join(e.old, "") == strings.Join(other.old, "") && strings.Join(e.new, "") == strings.Join(other.new, "")
+}
+
+// mergeLineEdits sorts the edits, drops duplicates and reports an error if
+// two different edits overlap. Insertions at the same line from different
+// sources are considered to overlap since their relative order is unknown.
+func mergeLineEdits(edits []lineEdit) ([]lineEdit, error) {
+	merged := make([]lineEdit, len(edits))
+	copy(merged, edits)
+	sort.SliceStable(merged, func(i, j int) bool {
+		if merged[i].start != merged[j].start {
+			return merged[i].start < merged[j].start
+		}
+		return merged[i].end() < merged[j].end()
+	})
+	tail := 0
+	for i, cur := range merged {
+		if i > 0 {
+			prev := merged[tail-1]
+			if prev.equals(cur) {
+				continue
+			}
+			if prev.end() > cur.start || (prev.start == cur.start && prev.source != cur.source) {
+				return nil, fmt.Errorf("conflicting changes to lines %d-%d from %s and lines %d-%d from %s",
+					prev.start+1, prev.end(), prev.source, cur.start+1, cur.end(), cur.source)
+			}
+		}
+		merged[tail] = cur
+		tail++
+	}
+	return merged[:tail], nil
+}
+
+// mergeHunks merges the hunks for a single file without access to the file
+// itself, e.g. in a build action. The context and removed lines of the hunks
+// serve as a partial view of the original file, which must be consistent
+// between all hunks. The merged edits are returned as new hunks with up to
+// context lines of context, together with the number of edits.
+func mergeHunks(hunks []sourcedHunk, context int) ([]patchHunk, int, error) {
+	known := make(map[int]string)
+	numLines := 0
+	for _, h := range hunks {
+		i := h.startIndex()
+		for _, l := range h.lines {
+			if l.kind == '+' {
+				continue
+			}
+			if text, ok := known[i]; ok && text != l.text {
+				return nil, 0, fmt.Errorf("%s: hunk @@ -%d,%d @@ expects %q at line %d, but another patch expects %q; the patches were not generated from the same version of the file",
+					h.source, h.oldStart, h.oldLines, l.text, i+1, text)
+			}
+			known[i] = l.text
+			i++
+		}
+		if i > numLines {
+			numLines = i
+		}
+	}
+	lines := make([]string, numLines)
+	for i, text := range known {
+		lines[i] = text
+	}
+
+	var edits []lineEdit
+	for _, h := range hunks {
+		e, err := h.lineEdits(lines, h.source)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %v", h.source, err)
+		}
+		edits = append(edits, e...)
+	}
+	edits, err := mergeLineEdits(edits)
+	if err != nil {
+		return nil, 0, err
+	}
+	isKnown := func(i int) bool {
+		_, ok := known[i]
+		return ok
+	}
+	return editHunks(lines, isKnown, edits, context), len(edits), nil
+}
+
+// editHunks converts sorted, non-overlapping edits into hunks with up to
+// context lines of context. Only lines for which isKnown returns true are used
+// as context; edits are combined into one hunk if the lines between them are
+// known and no more than 2*context lines apart.
+func editHunks(lines []string, isKnown func(int) bool, edits []lineEdit, context int) []patchHunk {
+	var hunks []patchHunk
+	// The difference between the line numbers of the new and the old file.
+	delta := 0
+	for i := 0; i < len(edits); {
+		j := i + 1
+		for j < len(edits) && edits[j].start-edits[j-1].end() <= 2*context && allKnown(isKnown, edits[j-1].end(), edits[j].start) {
+			j++
+		}
+		start := edits[i].start
+		for k := 0; k < context && start > 0 && isKnown(start-1); k++ {
+			start--
+		}
+		end := edits[j-1].end()
+		for k := 0; k < context && end < len(lines) && isKnown(end); k++ {
+			end++
+		}
+
+		h := patchHunk{oldStart: start + 1, newStart: start + delta + 1}
+		pos := start
+		for _, e := range edits[i:j] {
+			for ; pos < e.start; pos++ {
+				h.lines = append(h.lines, patchLine{' ', lines[pos]})
+			}
+			for _, l := range e.old {
+				h.lines = append(h.lines, patchLine{'-', l})
+			}
+			for _, l := range e.new {
+				h.lines = append(h.lines, patchLine{'+', l})
+			}
+			pos = e.end()
+			delta += len(e.new) - len(e.old)
+		}
+		for ; pos < end; pos++ {
+			h.lines = append(h.lines, patchLine{' ', lines[pos]})
+		}
+		for _, l := range h.lines {
+			if l.kind != '+' {
+				h.oldLines++
+			}
+			if l.kind != '-' {
+				h.newLines++
+			}
+		}
+		// An empty range is given by the line before it.
+		if h.oldLines == 0 {
+			h.oldStart--
+		}
+		if h.newLines == 0 {
+			h.newStart--
+		}
+		hunks = append(hunks, h)
+		i = j
+	}
+	return hunks
+}
+
+func allKnown(isKnown func(int) bool, start, end int) bool {
+	for i := start; i < end; i++ {
+		if !isKnown(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// writeFilePatch writes a file section of a unified diff, preceded by the
+// sha256 metadata if the digest of the file is known.
+func writeFilePatch(w io.Writer, p filePatch) error {
+	var buf bytes.Buffer
+	if p.digest != "" {
+		buf.WriteString(formatPatchMetadata(metadataSHA256, p.digest))
+		buf.WriteByte('\n')
+	}
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", p.oldName, p.newName)
+	for _, h := range p.hunks {
+		fmt.Fprintf(&buf, "@@ -%s +%s @@\n", formatHunkRange(h.oldStart, h.oldLines), formatHunkRange(h.newStart, h.newLines))
+		for _, l := range h.lines {
+			buf.WriteByte(l.kind)
+			buf.WriteString(l.text)
+			if !strings.HasSuffix(l.text, "\n") {
+				buf.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func formatHunkRange(start, lines int) string {
+	if lines == 1 {
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+// applyLineEdits applies sorted, non-overlapping edits to the lines of a file.
+func applyLineEdits(lines []string, edits []lineEdit) []byte {
+	var out bytes.Buffer
+	last := 0
+	for _, e := range edits {
+		for _, l := range lines[last:e.start] {
+			out.WriteString(l)
+		}
+		for _, l := range e.new {
+			out.WriteString(l)
+		}
+		last = e.end()
+	}
+	for _, l := range lines[last:] {
+		out.WriteString(l)
+	}
+	return out.Bytes()
+}
+
+// A fileEdits collects the edits from all patch files for one source file.
+type fileEdits struct {
+	path    string // relative to the workspace root
+	sources []string
+	hunks   []sourcedHunk
+	// created and deleted are set if a patch creates or deletes the file,
+	// i.e. its old or new name is /dev/null.
+	created, deleted bool
+}
+
+// patchNames returns the old and the new name of the file in a patch.
+func (fe *fileEdits) patchNames() (string, string) {
+	name := filepath.ToSlash(fe.path)
+	oldName, newName := "a/"+name, "b/"+name
+	if fe.created {
+		oldName = devNull
+	}
+	if fe.deleted {
+		newName = devNull
+	}
+	return oldName, newName
+}
+
+type sourcedHunk struct {
+	patchHunk
+	source string
+	// digest is the content digest of the file the patch of the hunk was
+	// generated against, if known.
+	digest string
+}
+
+// readPatchList reads a list of patch files. The paths in the list are relative
+// to the current directory, which is the runfiles directory under bazel run,
+// and are returned as absolute paths.
+func readPatchList(listFile string) ([]string, error) {
+	data, err := os.ReadFile(listFile)
+	if err != nil {
+		return nil, err
+	}
+	var patchFiles []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		path, err := filepath.Abs(filepath.FromSlash(line))
+		if err != nil {
+			return nil, err
+		}
+		patchFiles = append(patchFiles, path)
+	}
+	return patchFiles, nil
+}
+
+// collectFileEdits reads the patch files and groups their hunks by the source
+// file they modify. Legacy fix files are migrated against the files returned
+// by readFile. Compressed patches and fix files are decompressed. If strip is
+// negative, the strip level recorded in each patch is used, or 1 if there is
+// none.
+func collectFileEdits(patchFiles []string, cwd string, readFile func(string) ([]byte, error), strip int) ([]*fileEdits, error) {
+	byPath := make(map[string]*fileEdits)
+	// Structured fix files with the same edits, such as the ones of a package
+	// built in several configurations, are only collected once.
+	digests := make(map[string]bool)
+	for _, patchFile := range patchFiles {
+		if cwd != "" && !filepath.IsAbs(patchFile) {
+			patchFile = filepath.Join(cwd, patchFile)
+		}
+		data, _, err := readFixFile(longPath(patchFile))
+		if err != nil {
+			return nil, err
+		}
+		if digest, ok := fixFileDigest(data); ok {
+			if digests[digest] {
+				continue
+			}
+			digests[digest] = true
+		}
+		patches, err := parseFixFile(data, readFile)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %v", patchFile, err)
+		}
+		patchStripLevel := strip
+		if patchStripLevel < 0 {
+			patchStripLevel = 1
+			if recorded, ok := recordedStrip(data); ok {
+				patchStripLevel = recorded
+			}
+		}
+		for _, p := range patches {
+			name := p.newName
+			if name == devNull {
+				name = p.oldName
+			}
+			path, err := stripPath(name, patchStripLevel)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", patchFile, err)
+			}
+			fe, ok := byPath[path]
+			if !ok {
+				fe = &fileEdits{path: path}
+				byPath[path] = fe
+			}
+			fe.created = fe.created || p.oldName == devNull
+			fe.deleted = fe.deleted || p.newName == devNull
+			if len(fe.sources) == 0 || fe.sources[len(fe.sources)-1] != patchFile {
+				fe.sources = append(fe.sources, patchFile)
+			}
+			for _, h := range p.hunks {
+				fe.hunks = append(fe.hunks, sourcedHunk{patchHunk: h, source: patchFile, digest: p.digest})
+			}
+		}
+	}
+
+	files := make([]*fileEdits, 0, len(byPath))
+	for _, fe := range byPath {
+		files = append(files, fe)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].path < files[j].path
+	})
+	return files, nil
+}
+
+// stripPath removes the given number of leading components from a path in a
+// patch, like patch -pN does.
+func stripPath(path string, strip int) (string, error) {
+	parts := strings.Split(filepath.ToSlash(path), "/")
+	if strip >= len(parts) {
+		return "", fmt.Errorf("cannot strip %d components from %q", strip, path)
+	}
+	return filepath.FromSlash(strings.Join(parts[strip:], "/")), nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// startProfiles starts writing a CPU profile and an execution trace of nogo to
+// the given files, if set, and returns a function that stops them and writes a
+// heap profile to memProfile, if set.
+func startProfiles(cpuProfile, memProfile, executionTrace string) (stop func() error, err error) {
+	var files []*os.File
+	create := func(path string) (*os.File, error) {
+		f, err := os.Create(longPath(path))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+		return f, nil
+	}
+	closeFiles := func() error {
+		var firstErr error
+		for _, f := range files {
+			if err := f.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	}
+	cpuStarted := false
+	fail := func(err error) (func() error, error) {
+		if cpuStarted {
+			pprof.StopCPUProfile()
+		}
+		closeFiles()
+		return nil, err
+	}
+
+	if cpuProfile != "" {
+		f, err := create(cpuProfile)
+		if err != nil {
+			return fail(err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			return fail(fmt.Errorf("starting CPU profile: %v", err))
+		}
+		cpuStarted = true
+	}
+	if executionTrace != "" {
+		f, err := create(executionTrace)
+		if err != nil {
+			return fail(err)
+		}
+		if err := trace.Start(f); err != nil {
+			return fail(fmt.Errorf("starting execution trace: %v", err))
+		}
+	}
+
+	return func() error {
+		if cpuStarted {
+			pprof.StopCPUProfile()
+		}
+		if executionTrace != "" {
+			trace.Stop()
+		}
+		if memProfile != "" {
+			f, err := create(memProfile)
+			if err != nil {
+				closeFiles()
+				return err
+			}
+			// Collect garbage so that the profile shows the memory still in
+			// use at the end of the analysis.
+			runtime.GC()
+			if err := pprof.WriteHeapProfile(f); err != nil {
+				closeFiles()
+				return fmt.Errorf("writing heap profile: %v", err)
+			}
+		}
+		return closeFiles()
+	}, nil
+}
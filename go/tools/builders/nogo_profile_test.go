@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStartProfiles(t *testing.T) {
+	dir := t.TempDir()
+	cpu := filepath.Join(dir, "cpu.pprof")
+	mem := filepath.Join(dir, "mem.pprof")
+	trace := filepath.Join(dir, "trace")
+	stop, err := startProfiles(cpu, mem, trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{cpu, mem, trace} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() == 0 {
+			t.Errorf("%s is empty", path)
+		}
+	}
+}
+
+func TestStartProfiles_None(t *testing.T) {
+	stop, err := startProfiles("", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStartProfiles_Error(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing", "trace")
+	if _, err := startProfiles(filepath.Join(dir, "cpu.pprof"), "", missing); err == nil {
+		t.Fatal("expected an error")
+	}
+	// The CPU profile must have been stopped, so that it can be started again.
+	stop, err := startProfiles(filepath.Join(dir, "cpu.pprof"), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+}
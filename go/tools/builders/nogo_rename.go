@@ -0,0 +1,110 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"reflect"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// A renameFact is an object fact of an analyzer that renames the object with
+// a suggested fix of one of its diagnostics, e.g. an exported function whose
+// name doesn't follow a convention. The fix only edits the declaration of the
+// object: nogo completes it with the edits of the uses of the object in the
+// package and in each package importing it while analyzing them, and these
+// edits end up in the fixes of their packages, which the fix aggregation
+// merges. Analyzers implement it without depending on nogo with a
+// PendingRename method on the type of the fact that returns the new name.
+type renameFact interface {
+	analysis.Fact
+	PendingRename() string
+}
+
+// renameFactTypes returns the fact types of the analyzer that implement
+// renameFact, to select its facts with.
+func renameFactTypes(a *analysis.Analyzer) map[reflect.Type]bool {
+	types := make(map[reflect.Type]bool)
+	for _, f := range a.FactTypes {
+		if _, ok := f.(renameFact); ok {
+			types[reflect.TypeOf(f)] = true
+		}
+	}
+	return types
+}
+
+// A renamedObject identifies an object by its declaration rather than by
+// identity, so that the instances of generic functions, methods and fields
+// are renamed along with them.
+type renamedObject struct {
+	pkg  *types.Package
+	name string
+	pos  token.Pos
+}
+
+func newRenamedObject(obj types.Object) renamedObject {
+	return renamedObject{pkg: obj.Pkg(), name: obj.Name(), pos: obj.Pos()}
+}
+
+// renameUses returns a diagnostic with a fix replacing the identifier for each
+// use of an object in files that facts mark as renamed, in the package of the
+// files or a package it depends on. Facts with invalid names are ignored.
+func renameUses(facts []analysis.ObjectFact, files []*ast.File, info *types.Info) []analysis.Diagnostic {
+	renamed := make(map[renamedObject]string)
+	for _, f := range facts {
+		r, ok := f.Fact.(renameFact)
+		if !ok {
+			continue
+		}
+		if name := r.PendingRename(); token.IsIdentifier(name) && name != f.Object.Name() {
+			renamed[newRenamedObject(f.Object)] = name
+		}
+	}
+	if len(renamed) == 0 {
+		return nil
+	}
+	var diagnostics []analysis.Diagnostic
+	for _, f := range files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			id, ok := n.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			obj := info.Uses[id]
+			if obj == nil {
+				return true
+			}
+			name, ok := renamed[newRenamedObject(obj)]
+			if !ok {
+				return true
+			}
+			diagnostics = append(diagnostics, analysis.Diagnostic{
+				Pos:     id.Pos(),
+				End:     id.End(),
+				Message: fmt.Sprintf("%s is renamed to %s", id.Name, name),
+				SuggestedFixes: []analysis.SuggestedFix{{
+					Message:   fmt.Sprintf("Rename %s to %s", id.Name, name),
+					TextEdits: []analysis.TextEdit{{Pos: id.Pos(), End: id.End(), NewText: []byte(name)}},
+				}},
+			})
+			return true
+		})
+	}
+	return diagnostics
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+type pendingRename struct{ name string }
+
+func (*pendingRename) AFact()                  {}
+func (f *pendingRename) PendingRename() string { return f.name }
+
+type otherFact struct{}
+
+func (*otherFact) AFact() {}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+func TestRenameUses(t *testing.T) {
+	const pSrc = `package p
+
+type G[T any] struct{ Field T }
+
+func (G[T]) Old() {}
+
+func Old() {}
+
+func Same() {}
+
+func Invalid() {}
+
+var _ = Old
+`
+	const qSrc = `package q
+
+import "example.com/p"
+
+func F() {
+	p.Old()
+	var g p.G[int]
+	g.Old()
+	_ = p.G[string]{Field: "x"}
+	p.Same()
+	p.Invalid()
+}
+`
+	fset := token.NewFileSet()
+	check := func(path, src string, imp types.Importer) (*types.Package, *ast.File, *types.Info) {
+		f, err := parser.ParseFile(fset, path+".go", src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info := &types.Info{Uses: make(map[*ast.Ident]types.Object)}
+		pkg, err := (&types.Config{Importer: imp}).Check(path, fset, []*ast.File{f}, info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pkg, f, info
+	}
+	p, pFile, pInfo := check("example.com/p", pSrc, nil)
+	_, qFile, qInfo := check("example.com/q", qSrc, importerFunc(func(string) (*types.Package, error) { return p, nil }))
+
+	g := p.Scope().Lookup("G").(*types.TypeName)
+	method, _, _ := types.LookupFieldOrMethod(g.Type(), false, p, "Old")
+	field, _, _ := types.LookupFieldOrMethod(g.Type(), false, p, "Field")
+	facts := []analysis.ObjectFact{
+		{Object: p.Scope().Lookup("Old"), Fact: &pendingRename{"New"}},
+		{Object: method, Fact: &pendingRename{"NewMethod"}},
+		{Object: field, Fact: &pendingRename{"NewField"}},
+		{Object: p.Scope().Lookup("Same"), Fact: &pendingRename{"Same"}},
+		{Object: p.Scope().Lookup("Invalid"), Fact: &pendingRename{"not valid"}},
+	}
+	describe := func(diagnostics []analysis.Diagnostic) []string {
+		var got []string
+		for _, d := range diagnostics {
+			edit := d.SuggestedFixes[0].TextEdits[0]
+			got = append(got, fmt.Sprintf("%s: %s: %d-%d %s", fset.Position(d.Pos), d.Message, fset.Position(edit.Pos).Column, fset.Position(edit.End).Column, edit.NewText))
+		}
+		return got
+	}
+
+	got := strings.Join(describe(renameUses(facts, []*ast.File{qFile}, qInfo)), "\n")
+	want := strings.Join([]string{
+		"example.com/q.go:6:4: Old is renamed to New: 4-7 New",
+		"example.com/q.go:8:4: Old is renamed to NewMethod: 4-7 NewMethod",
+		"example.com/q.go:9:18: Field is renamed to NewField: 18-23 NewField",
+	}, "\n")
+	if got != want {
+		t.Errorf("got renames in the importing package:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Only the uses are renamed in the package declaring the objects, their
+	// declarations are renamed by the fixes of the analyzer.
+	got = strings.Join(describe(renameUses(facts, []*ast.File{pFile}, pInfo)), "\n")
+	want = "example.com/p.go:13:9: Old is renamed to New: 9-12 New"
+	if got != want {
+		t.Errorf("got renames in the declaring package:\n%s\nwant:\n%s", got, want)
+	}
+
+	if got := renameUses([]analysis.ObjectFact{{Object: p.Scope().Lookup("Old"), Fact: &otherFact{}}}, []*ast.File{qFile}, qInfo); got != nil {
+		t.Errorf("got renames %v without rename facts", got)
+	}
+}
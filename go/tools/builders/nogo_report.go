@@ -0,0 +1,153 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// nogo_report copies the nogo logs and fix files of many targets to a
+// directory whose layout doesn't depend on the configuration, so that scripts
+// can find the fixes of a target without knowing its path under bazel-out.
+//
+// Usage: bazel run //:nogo_report -- <dir>
+//
+// It is run by the nogo_report rule, which passes the outputs of the targets
+// in the manifest named by the NOGO_REPORT_MANIFEST environment variable.
+// Relative directories are resolved against the root of the workspace.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// reportManifestEnv names the manifest of the nogo outputs to copy. Its paths
+// are relative to the current directory.
+const reportManifestEnv = "NOGO_REPORT_MANIFEST"
+
+// reportEntry describes the nogo outputs of a single analyzed package.
+type reportEntry struct {
+	// Name is the path of the copies in the report directory, without
+	// extension.
+	Name string `json:"name"`
+	Log  string `json:"log"`
+	Fix  string `json:"fix"`
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("nogo_report: ")
+	if err := runReport(os.Args[1:], os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func runReport(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("nogo_report", flag.ExitOnError)
+	manifest := fs.String("manifest", os.Getenv(reportManifestEnv), "JSON file listing the nogo outputs of each target")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: nogo_report <dir>")
+	}
+	if *manifest == "" {
+		return fmt.Errorf("-manifest or %s must be set", reportManifestEnv)
+	}
+	dir := fs.Arg(0)
+	if !filepath.IsAbs(dir) {
+		root := os.Getenv("BUILD_WORKSPACE_DIRECTORY")
+		if root == "" {
+			var err error
+			if root, err = os.Getwd(); err != nil {
+				return err
+			}
+		}
+		dir = filepath.Join(root, dir)
+	}
+
+	data, err := os.ReadFile(*manifest)
+	if err != nil {
+		return err
+	}
+	var entries []reportEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("parsing %s: %w", *manifest, err)
+	}
+	reported := 0
+	for _, e := range entries {
+		logContent, err := os.ReadFile(e.Log)
+		if err != nil {
+			return err
+		}
+		var fixContent []byte
+		if len(logContent) > 0 {
+			// The copies are never compressed.
+			if fixContent, _, err = readFixFile(e.Fix); err != nil {
+				return err
+			}
+			reported++
+		}
+		fixPath, err := writeReport(dir, e.Name, logContent, fixContent)
+		if err != nil {
+			return err
+		}
+		if fixPath != "" {
+			fmt.Fprintf(stdout, "%s\n", fixPath)
+		}
+	}
+	fmt.Fprintf(stdout, "nogo_report: %d of %d packages have findings, reported in %s\n", reported, len(entries), dir)
+	return nil
+}
+
+// writeReport copies the log and the fix file of a target to the report
+// directory and returns the path of the copy of the fix file, if any. The
+// copies of a target without findings are removed, so that the directory only
+// reports the targets that have findings in the latest build.
+func writeReport(dir, name string, logContent, fixContent []byte) (string, error) {
+	if !filepath.IsAbs(dir) {
+		return "", fmt.Errorf("the nogo report directory %q must be an absolute path", dir)
+	}
+	if name == "" {
+		return "", errors.New("the name of the copies must not be empty")
+	}
+	base := filepath.Join(dir, filepath.FromSlash(name))
+	logPath, fixPath := base+".nogo.log", base+".nogo.patch"
+	if len(logContent) == 0 {
+		fixContent = nil
+	}
+	for _, f := range []struct {
+		path    string
+		content []byte
+	}{{logPath, logContent}, {fixPath, fixContent}} {
+		if len(f.content) == 0 {
+			if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+				return "", err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(f.path), 0o777); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(f.path, f.content, 0o666); err != nil {
+			return "", err
+		}
+	}
+	if len(fixContent) == 0 {
+		return "", nil
+	}
+	return fixPath, nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteReport(t *testing.T) {
+	dir := t.TempDir()
+	log := []byte("nogo: errors found by nogo during build-time code analysis:\npkg/a.go:2:1: package fmt must not be imported (importfmt)\n")
+	fix := []byte("--- a/pkg/a.go\n+++ b/pkg/a.go\n")
+	logPath := filepath.Join(dir, "pkg", "lib.nogo.log")
+	fixPath := filepath.Join(dir, "pkg", "lib.nogo.patch")
+
+	reportedFix, err := writeReport(dir, "pkg/lib", log, fix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reportedFix != fixPath {
+		t.Errorf("got fix path %q, want %q", reportedFix, fixPath)
+	}
+	for path, want := range map[string][]byte{logPath: log, fixPath: fix} {
+		if got, err := os.ReadFile(path); err != nil || string(got) != string(want) {
+			t.Errorf("got %q, %v for %s, want %q", got, err, path, want)
+		}
+	}
+
+	// The copies are removed once the findings are gone.
+	if reportedFix, err = writeReport(dir, "pkg/lib", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if reportedFix != "" {
+		t.Errorf("got fix path %q, want none", reportedFix)
+	}
+	for _, path := range []string{logPath, fixPath} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed, got %v", path, err)
+		}
+	}
+
+	if _, err := writeReport("relative", "pkg/lib", log, fix); err == nil {
+		t.Errorf("expected an error for a relative report directory")
+	}
+}
+
+func TestRunReport(t *testing.T) {
+	outputs := t.TempDir()
+	write := func(name string, content []byte) string {
+		t.Helper()
+		path := filepath.Join(outputs, name)
+		if err := os.WriteFile(path, content, 0o666); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	log := []byte("pkg/a.go:2:1: package fmt must not be imported (importfmt)\n")
+	fix := []byte("--- a/pkg/a.go\n+++ b/pkg/a.go\n")
+	var compressedFix bytes.Buffer
+	zw := gzip.NewWriter(&compressedFix)
+	if _, err := zw.Write(fix); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := json.Marshal([]reportEntry{
+		{Name: "pkg/lib", Log: write("lib.nogo.log", log), Fix: write("lib.nogo.patch", compressedFix.Bytes())},
+		{Name: "external/other/pkg/clean", Log: write("clean.nogo.log", nil), Fix: write("clean.nogo.patch", nil)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifestPath := write("manifest.json", manifest)
+
+	// Relative directories are resolved against the workspace.
+	workspace := t.TempDir()
+	t.Setenv("BUILD_WORKSPACE_DIRECTORY", workspace)
+	stale := filepath.Join(workspace, "report", "external", "other", "pkg", "clean.nogo.log")
+	if err := os.MkdirAll(filepath.Dir(stale), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stale, log, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	var stdout bytes.Buffer
+	if err := runReport([]string{"-manifest", manifestPath, "report"}, &stdout); err != nil {
+		t.Fatal(err)
+	}
+
+	fixPath := filepath.Join(workspace, "report", "pkg", "lib.nogo.patch")
+	if got, err := os.ReadFile(fixPath); err != nil || !bytes.Equal(got, fix) {
+		t.Errorf("got %q, %v for %s, want the decompressed fix %q", got, err, fixPath, fix)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("expected the copy of a package without findings to be removed, got %v", err)
+	}
+	if !strings.Contains(stdout.String(), fixPath) || !strings.Contains(stdout.String(), "1 of 2 packages") {
+		t.Errorf("unexpected output:\n%s", stdout.String())
+	}
+}
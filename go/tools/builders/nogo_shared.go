@@ -0,0 +1,83 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// A sharedResultKey identifies the copies of an analyzer that only computes a
+// result for other analyzers, such as buildssa.
+type sharedResultKey struct {
+	name     string
+	run      uintptr
+	requires string
+	result   reflect.Type
+}
+
+// sharedResult returns the key of the analyzer if its result can be shared
+// with copies of it. Analyzer suites may copy the analyzers they build on, e.g.
+// to wrap them, and each copy would otherwise construct the same result again,
+// which for SSA takes a large part of the time of nogo. An analyzer shares its
+// result with the copies that have the same name, run function,
+// prerequisites and result type, as long as it doesn't use facts. The run
+// function must be a top-level function: closures and method values of the
+// same code may capture different state, so copies made by a factory don't
+// necessarily compute the same result.
+func sharedResult(a *analysis.Analyzer) (sharedResultKey, bool) {
+	if a.ResultType == nil || len(a.FactTypes) > 0 || a.Run == nil {
+		return sharedResultKey{}, false
+	}
+	run := reflect.ValueOf(a.Run).Pointer()
+	if !isTopLevelFunc(run) {
+		return sharedResultKey{}, false
+	}
+	requires := make([]string, len(a.Requires))
+	for i, req := range a.Requires {
+		requires[i] = fmt.Sprintf("%p", req)
+	}
+	return sharedResultKey{
+		name:     a.Name,
+		run:      run,
+		requires: strings.Join(requires, ","),
+		result:   a.ResultType,
+	}, true
+}
+
+// isTopLevelFunc reports whether the function at pc is a top-level function
+// or method, as opposed to a function literal, named like "pkg.f.func1" or
+// "pkg.glob..func1", or a method value, named like "pkg.T.m-fm".
+func isTopLevelFunc(pc uintptr) bool {
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return false
+	}
+	name := fn.Name()
+	if strings.HasSuffix(name, "-fm") {
+		return false
+	}
+	name = name[strings.LastIndex(name, "/")+1:]
+	for _, part := range strings.Split(name, ".")[1:] {
+		if n := strings.TrimPrefix(part, "func"); n != part && n != "" && strings.Trim(n, "0123456789") == "" {
+			return false
+		}
+	}
+	return true
+}
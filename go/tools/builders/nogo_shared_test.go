@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+var intType = reflect.TypeOf(0)
+
+func runZero(*analysis.Pass) (interface{}, error) {
+	return 0, nil
+}
+
+// newConstAnalyzer returns an analyzer whose result is n, like the analyzers
+// suites configure through factories.
+func newConstAnalyzer(n int) *analysis.Analyzer {
+	return &analysis.Analyzer{
+		Name:       "const",
+		Doc:        "returns a constant",
+		Run:        func(*analysis.Pass) (interface{}, error) { return n, nil },
+		ResultType: intType,
+	}
+}
+
+func TestSharedResultFactoryCopies(t *testing.T) {
+	a, b := newConstAnalyzer(1), newConstAnalyzer(2)
+	resultA, _ := a.Run(nil)
+	resultB, _ := b.Run(nil)
+	if resultA == resultB {
+		t.Fatalf("copies made by the factory both return %v", resultA)
+	}
+	if _, ok := sharedResult(a); ok {
+		t.Error("a copy with a closure as run function shares its result")
+	}
+	if _, ok := sharedResult(b); ok {
+		t.Error("a copy with a closure as run function shares its result")
+	}
+}
+
+func TestSharedResultCopies(t *testing.T) {
+	req := &analysis.Analyzer{Name: "req", Doc: "req", Run: runZero, ResultType: intType}
+	otherReq := &analysis.Analyzer{Name: "req", Doc: "req", Run: runZero, ResultType: intType}
+	a := &analysis.Analyzer{Name: "a", Doc: "a", Run: runZero, ResultType: intType, Requires: []*analysis.Analyzer{req}}
+	sameCopy := *a
+	sameCopy.Requires = []*analysis.Analyzer{req}
+	otherCopy := *a
+	otherCopy.Requires = []*analysis.Analyzer{otherReq}
+
+	key, ok := sharedResult(a)
+	if !ok {
+		t.Fatal("an analyzer with a top-level run function doesn't share its result")
+	}
+	if sameKey, ok := sharedResult(&sameCopy); !ok || sameKey != key {
+		t.Error("a copy with the same prerequisites doesn't share the result")
+	}
+	if otherKey, ok := sharedResult(&otherCopy); ok && otherKey == key {
+		t.Error("a copy with different prerequisites shares the result")
+	}
+}
+
+func TestIsTopLevelFunc(t *testing.T) {
+	closure := func() {}
+	for _, tc := range []struct {
+		desc string
+		fn   interface{}
+		want bool
+	}{
+		{"function", runZero, true},
+		{"method expression", (*testing.T).Name, true},
+		{"function literal", closure, false},
+		{"method value", t.Name, false},
+	} {
+		if got := isTopLevelFunc(reflect.ValueOf(tc.fn).Pointer()); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+}
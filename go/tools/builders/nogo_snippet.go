@@ -0,0 +1,124 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxSnippetLines is the number of source lines shown for a diagnostic whose
+// range spans more lines.
+const maxSnippetLines = 3
+
+// writeSnippet writes the source lines of the range from pos to end below a
+// diagnostic, each followed by a line that marks the range, starting with a
+// caret where the diagnostic is reported:
+//
+//	12 | 	fmt.Printf("%d", "a")
+//	   | 	^~~~~~~~~~~~~~~~~~~~~
+//
+// The lines are indented like the other notes of the diagnostic. src holds the
+// contents of the files by name. Nothing is written for diagnostics without a
+// position, in files that are not in src or in code that //line directives
+// map to another source, whose lines would not match the reported position.
+func writeSnippet(w *bytes.Buffer, fset *token.FileSet, src map[string][]byte, pos, end token.Pos) {
+	if !pos.IsValid() {
+		return
+	}
+	start := fset.PositionFor(pos, false)
+	if adjusted := fset.Position(pos); adjusted.Filename != start.Filename || adjusted.Line != start.Line {
+		return
+	}
+	content, ok := src[start.Filename]
+	if !ok || start.Offset > len(content) {
+		return
+	}
+	endOffset := start.Offset
+	if end.IsValid() {
+		if e := fset.PositionFor(end, false); e.Filename == start.Filename && e.Offset > start.Offset && e.Offset <= len(content) {
+			endOffset = e.Offset
+		}
+	}
+
+	type snippetLine struct {
+		text, marker string
+	}
+	var lines []snippetLine
+	lineStart := bytes.LastIndexByte(content[:start.Offset], '\n') + 1
+	for len(lines) < maxSnippetLines && lineStart <= len(content) {
+		lineEnd := len(content)
+		if i := bytes.IndexByte(content[lineStart:], '\n'); i >= 0 {
+			lineEnd = lineStart + i
+		}
+		text := strings.TrimSuffix(string(content[lineStart:lineEnd]), "\r")
+		// The range marked on this line, relative to its start. The lines
+		// following the first are marked from their indentation.
+		from := len(text) - len(strings.TrimLeft(text, " \t"))
+		if len(lines) == 0 {
+			from = start.Offset - lineStart
+		}
+		if len(lines) > 0 && endOffset-lineStart <= from {
+			// The range ends before the code on the line.
+			break
+		}
+		to := len(text)
+		if endOffset-lineStart < to {
+			to = endOffset - lineStart
+		}
+		lines = append(lines, snippetLine{text, rangeMarker(text, from, to, len(lines) == 0)})
+		lineStart = lineEnd + 1
+	}
+	width := len(fmt.Sprint(start.Line + len(lines) - 1))
+	for i, line := range lines {
+		fmt.Fprintf(w, "\n    %*d | %s", width, start.Line+i, line.text)
+		if strings.TrimSpace(line.marker) != "" {
+			fmt.Fprintf(w, "\n    %*s | %s", width, "", line.marker)
+		}
+	}
+}
+
+// rangeMarker returns the line that marks the bytes from..to of line with
+// tildes, or with a caret followed by tildes if caret is set. The marker is
+// preceded by the tabs of line and a space for every other character, so that
+// it is aligned with the marked text however tabs are displayed.
+func rangeMarker(line string, from, to int, caret bool) string {
+	if from > len(line) {
+		from = len(line)
+	}
+	var b strings.Builder
+	for _, r := range line[:from] {
+		if r == '\t' {
+			b.WriteByte('\t')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	n := 0
+	if to > from {
+		n = utf8.RuneCountInString(line[from:to])
+	}
+	if caret {
+		b.WriteByte('^')
+		n--
+	}
+	if n > 0 {
+		b.WriteString(strings.Repeat("~", n))
+	}
+	return b.String()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestWriteSnippet(t *testing.T) {
+	const src = "package a\n\nfunc F() {\n\tfmt.Printf(\"%d\", \"héllo\")\r\n\tcall(a,\n\t\tb)\n}\n//line other.go:1\nvar x = 1\n"
+	fset := token.NewFileSet()
+	f := fset.AddFile("a.go", fset.Base(), len(src))
+	f.SetLinesForContent([]byte(src))
+	f.AddLineColumnInfo(strings.Index(src, "var x"), "other.go", 1, 1)
+	files := map[string][]byte{"a.go": []byte(src)}
+	at := func(s string) token.Pos {
+		i := strings.Index(src, s)
+		if i < 0 {
+			t.Fatalf("%q not in the source", s)
+		}
+		return f.Pos(i)
+	}
+
+	for _, tt := range []struct {
+		desc     string
+		pos, end token.Pos
+		want     string
+	}{
+		{
+			desc: "range",
+			pos:  at("fmt.Printf"),
+			end:  at("\r\n"),
+			want: `
+    4 | 	fmt.Printf("%d", "héllo")
+      | 	^~~~~~~~~~~~~~~~~~~~~~~~~`,
+		},
+		{
+			desc: "position",
+			pos:  at(`"héllo"`),
+			want: `
+    4 | 	fmt.Printf("%d", "héllo")
+      | 	                 ^`,
+		},
+		{
+			desc: "lines",
+			pos:  at("func F"),
+			end:  f.Pos(len(src) - len("//line other.go:1\nvar x = 1\n")),
+			want: `
+    3 | func F() {
+      | ^~~~~~~~~~
+    4 | 	fmt.Printf("%d", "héllo")
+      | 	~~~~~~~~~~~~~~~~~~~~~~~~~
+    5 | 	call(a,
+      | 	~~~~~~~`,
+		},
+		{
+			desc: "end in indentation",
+			pos:  at("call"),
+			end:  at("\tb)"),
+			want: `
+    5 | 	call(a,
+      | 	^~~~~~~`,
+		},
+		{
+			desc: "no position",
+		},
+		{
+			desc: "line directive",
+			pos:  at("var x"),
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			var buf bytes.Buffer
+			writeSnippet(&buf, fset, files, tt.pos, tt.end)
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got snippet:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+
+	// Files that nogo didn't parse have no snippet.
+	g := fset.AddFile("b.go", fset.Base(), 10)
+	var buf bytes.Buffer
+	writeSnippet(&buf, fset, files, g.Pos(1), token.NoPos)
+	if buf.Len() != 0 {
+		t.Errorf("got snippet %q for a file without contents", buf.String())
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// analyzeCommand is the first argument of nogo that analyzes the packages of
+// a Go module loaded with go list instead of a package of the build, e.g. with
+// bazel run //:nogo_actual -- analyze ./... in the module.
+const analyzeCommand = "analyze"
+
+// analyzeStandalone runs the analyzers of nogo on the packages matching the
+// patterns in args and their tests, loaded with go list instead of the build.
+// Each package is analyzed like in the build, with the export data that the
+// go command compiles for its dependencies and the facts that nogo computed
+// for them before, so that the log and the fixes are the same as those of the
+// build.
+func analyzeStandalone(args []string) (error, int) {
+	flags := flag.NewFlagSet("nogo "+analyzeCommand, flag.ExitOnError)
+	dir := flags.String("dir", "", "The directory of the module to analyze, which the file names are relative to (default: the workspace directory with bazel run, the current directory otherwise)")
+	tags := flags.String("tags", "", "The comma-separated build tags to load the packages with")
+	tests := flags.Bool("test", true, "Whether to analyze the tests of the packages as well")
+	fixPath := flags.String("fix", "", "The path of the file to store the nogo fixes in")
+	fixJSONPath := flags.String("fix_json", "", "The path of the file to store the nogo fixes in as JSON")
+	fixLSPPath := flags.String("fix_lsp", "", "The path of the file to store the nogo fixes in as an LSP WorkspaceEdit")
+	flags.Parse(args)
+	patterns := flags.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	if *dir == "" {
+		*dir = os.Getenv("BUILD_WORKSPACE_DIRECTORY")
+	}
+	if err := openStdlibFacts(); err != nil {
+		return err, nogoError
+	}
+	// The output paths are relative to the directory nogo was started in.
+	for _, path := range []*string{fixPath, fixJSONPath, fixLSPPath} {
+		if *path != "" {
+			*path = abs(*path)
+		}
+	}
+	// The file names of the packages are relative to the module, like they
+	// are relative to the execroot in the build, so that the patterns of the
+	// config match them the same way.
+	if *dir != "" {
+		if err := os.Chdir(*dir); err != nil {
+			return err, nogoError
+		}
+	}
+	execroot, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("nogo failed to get CWD: %w", err), nogoError
+	}
+
+	pkgs, err := goList(execroot, *tags, *tests, patterns)
+	if err != nil {
+		return err, nogoError
+	}
+	var errMsg bytes.Buffer
+	for _, p := range pkgs {
+		if p.Error != nil {
+			fmt.Fprintf(&errMsg, "\n%s: %s", p.ImportPath, p.Error.Err)
+		}
+	}
+	if errMsg.Len() > 0 {
+		return fmt.Errorf("error loading packages:%s", errMsg.String()), nogoError
+	}
+	factDir, err := os.MkdirTemp("", "nogo_facts")
+	if err != nil {
+		return err, nogoError
+	}
+	defer os.RemoveAll(factDir)
+
+	reported := reportedPackages(pkgs)
+	byImportPath := make(map[string]*listedPackage, len(pkgs))
+	for _, p := range pkgs {
+		byImportPath[p.ImportPath] = p
+	}
+	factFiles := make(map[string]string)
+	allExternalAnalyzers := externalAnalyzers
+	maxFindings := configs[nogoBaseConfigName].maxFindings
+	paths := patchPaths{execroot: execroot, prefixes: configuredPatchPrefixes()}
+	var findings, patch bytes.Buffer
+	var fixes []fileChange
+	// analyzedSrc holds the contents of the analyzed files of all packages.
+	analyzedSrc := make(map[string][]byte)
+	var errs []error
+	blocking := false
+	// go list prints the dependencies of a package before it, so their facts
+	// are available when it is analyzed.
+	for i, p := range pkgs {
+		// The standard library is not analyzed in the build either, its facts
+		// come from stdlib_facts if the nogo rule sets it.
+		if p.Standard || isTestMain(p) || len(p.CompiledGoFiles) == 0 {
+			continue
+		}
+		checkedAnalyzers := analyzers
+		externalAnalyzers = allExternalAnalyzers
+		if !reported[p.ImportPath] {
+			// Dependencies are only analyzed for the facts of the packages
+			// that import them.
+			checkedAnalyzers = factAnalyzers(analyzers)
+			externalAnalyzers = nil
+		}
+		var srcs []string
+		for _, src := range p.CompiledGoFiles {
+			if !filepath.IsAbs(src) {
+				src = filepath.Join(p.Dir, src)
+			}
+			if rel, ok := relPath(execroot, src); ok {
+				src = rel
+			}
+			srcs = append(srcs, src)
+		}
+		packageFile, importMap, factMap := listedImportcfg(p, byImportPath, factFiles)
+		diagnostics, pkg, err := checkPackage(checkedAnalyzers, listedPackagePath(p.ImportPath), packageFile, importMap, factMap, srcs, nil, execroot)
+		if err != nil {
+			return fmt.Errorf("error running analyzers on %s: %v", p.ImportPath, err), nogoError
+		}
+		factFile := filepath.Join(factDir, strconv.Itoa(i)+".x")
+		data, err := encodeFactFile(pkg.facts.Encode(), configs[nogoBaseConfigName].factCompression)
+		if err == nil {
+			err = os.WriteFile(factFile, data, 0o666)
+		}
+		if err != nil {
+			return fmt.Errorf("error writing facts: %v", err), nogoError
+		}
+		factFiles[p.ImportPath] = factFile
+		if !reported[p.ImportPath] {
+			continue
+		}
+
+		diagnostics = dedupeDiagnostics(diagnostics)
+		fixDiagnostics, _ := limitDiagnostics(diagnostics, maxFindings)
+		logged := unbaselinedDiagnostics(reportedDiagnostics(diagnostics), pkg.fset)
+		for _, d := range logged {
+			if severity(d.analyzerName) == severityError {
+				blocking = true
+			}
+		}
+		writeFindings(&findings, logged, pkg, maxFindings)
+		pkgFixes, err := getFixesWithStrategy(fixDiagnostics, pkg.fset, configuredFixStrategy())
+		if err != nil {
+			errs = append(errs, err)
+		}
+		pkgFixes = minimizeFixes(pkgFixes, pkg.src)
+		addFixProvenance(pkgFixes, fixDiagnostics, pkg.fset, paths)
+		addFixMetadata(pkgFixes, fixDiagnostics, pkg.fset, "")
+		// fixFormat is defined by the template in generate_nogo_main.go.
+		// fixContext is defined by the template in generate_nogo_main.go.
+		if err := writePatch(&patch, pkgFixes, pkg.src, paths, newFixFormatter(fixFormat, importNames(pkg)), fixContext); err != nil {
+			errs = append(errs, err)
+		}
+		fixes = append(fixes, pkgFixes...)
+		for name, content := range pkg.src {
+			analyzedSrc[name] = content
+		}
+	}
+
+	exitCode := nogoSuccess
+	if findings.Len() > 0 {
+		if blocking {
+			errMsg.WriteString(nogoErrorsHeader)
+			if failsBuild(len(fixes) > 0) {
+				exitCode = nogoViolation
+			}
+		} else {
+			errMsg.WriteString(nogoWarningsHeader)
+		}
+		errMsg.Write(findings.Bytes())
+	}
+	if *fixPath != "" {
+		if err := os.WriteFile(longPath(*fixPath), patch.Bytes(), 0o666); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := saveFixFileJSON(*fixJSONPath, paths, fixes); err != nil {
+		errs = append(errs, err)
+	}
+	if *fixLSPPath != "" {
+		var edit bytes.Buffer
+		err := writeWorkspaceEdit(&edit, fixes, analyzedSrc, execroot)
+		if err == nil {
+			err = os.WriteFile(longPath(*fixLSPPath), edit.Bytes(), 0o666)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		errMsg.WriteString("\nsaving suggested fixes:")
+		for _, err := range errs {
+			fmt.Fprintf(&errMsg, "\n%v", err)
+		}
+		exitCode = nogoError
+	}
+	if errMsg.Len() > 0 {
+		return errors.New(errMsg.String()), exitCode
+	}
+	return nil, exitCode
+}
@@ -0,0 +1,280 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// nogoStdlibFacts runs nogo on the packages of the standard library, in
+// dependency order, and writes the facts they export to a zip archive with an
+// entry per package path. nogo reads the facts of standard library packages
+// from this archive, since they are not compiled with nogo like other
+// packages.
+func nogoStdlibFacts(args []string) error {
+	args, _, err := expandParamsFiles(args)
+	if err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("nogostdlibfacts", flag.ExitOnError)
+	goenv := envFlags(fs)
+	listJSON := fs.String("list_json", "", "The go list output of the standard library written by stdliblist")
+	nogoPath := fs.String("nogo", "", "The nogo binary")
+	out := fs.String("out", "", "The archive to write the facts to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := goenv.checkFlagsAndSetGoroot(); err != nil {
+		return err
+	}
+	if *listJSON == "" || *nogoPath == "" || *out == "" {
+		return errors.New("-list_json, -nogo and -out must be set")
+	}
+
+	pkgs, err := readStdlibPackages(*listJSON)
+	if err != nil {
+		return err
+	}
+	workDir, cleanup, err := goenv.workDir()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	facts, err := analyzeStdlibPackages(pkgs, abs(*nogoPath), abs(os.Getenv("GOROOT")), goenv.installSuffix, workDir)
+	if err != nil {
+		return err
+	}
+	return writeStdlibFacts(*out, facts)
+}
+
+// A stdlibPackage is a package of the standard library as analyzed by nogo.
+type stdlibPackage struct {
+	path string
+	// srcs are relative to the execroot.
+	srcs []string
+	// imports maps the import paths in srcs to package paths.
+	imports map[string]string
+}
+
+// readStdlibPackages reads the packages of the standard library from the
+// output of stdliblist. Packages without Go sources, such as builtin, and
+// unsafe, which has no export data, are skipped.
+func readStdlibPackages(listJSON string) ([]*stdlibPackage, error) {
+	data, err := os.ReadFile(listJSON)
+	if err != nil {
+		return nil, err
+	}
+	var pkgs []*stdlibPackage
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for decoder.More() {
+		var flat flatPackage
+		if err := decoder.Decode(&flat); err != nil {
+			return nil, fmt.Errorf("unable to decode package in %s: %v", listJSON, err)
+		}
+		if flat.PkgPath == "builtin" || flat.PkgPath == "unsafe" {
+			continue
+		}
+		// With cgo, the files generated by cgo are only listed in
+		// CompiledGoFiles.
+		files := flat.CompiledGoFiles
+		if len(files) == 0 {
+			files = flat.GoFiles
+		}
+		if len(files) == 0 {
+			continue
+		}
+		pkg := &stdlibPackage{path: flat.PkgPath, imports: make(map[string]string)}
+		for _, f := range files {
+			pkg.srcs = append(pkg.srcs, stdlibSourcePath(f))
+		}
+		for imp, id := range flat.Imports {
+			pkg.imports[imp] = strings.TrimPrefix(id, rulesGoStdlibPrefix)
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs, nil
+}
+
+// stdlibSourcePath returns the execroot-relative path of a source file listed
+// by stdliblist.
+func stdlibSourcePath(path string) string {
+	for _, prefix := range []string{"__BAZEL_OUTPUT_BASE__/", "__BAZEL_EXECROOT__/"} {
+		if strings.HasPrefix(path, prefix) {
+			return strings.TrimPrefix(path, prefix)
+		}
+	}
+	return path
+}
+
+// analyzeStdlibPackages runs nogo on each package once the packages it imports
+// have been analyzed and returns the facts of each package, keyed by package
+// path. Packages that nogo fails to analyze have no facts, as before, and
+// neither do packages without facts.
+func analyzeStdlibPackages(pkgs []*stdlibPackage, nogoPath, goroot, installSuffix, workDir string) (map[string][]byte, error) {
+	done := make(map[string]chan struct{}, len(pkgs))
+	for _, pkg := range pkgs {
+		done[pkg.path] = make(chan struct{})
+	}
+	var (
+		mu        sync.Mutex
+		factFiles = make(map[string]string)
+		firstErr  error
+		wg        sync.WaitGroup
+	)
+	sem := make(chan struct{}, runtime.NumCPU())
+	for i, pkg := range pkgs {
+		wg.Add(1)
+		go func(i int, pkg *stdlibPackage) {
+			defer wg.Done()
+			defer close(done[pkg.path])
+			for _, dep := range pkg.imports {
+				if ch, ok := done[dep]; ok {
+					<-ch
+				}
+			}
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			mu.Lock()
+			depFacts := make(map[string]string)
+			for _, dep := range pkg.imports {
+				if f, ok := factFiles[dep]; ok {
+					depFacts[dep] = f
+				}
+			}
+			mu.Unlock()
+			outFacts := filepath.Join(workDir, strconv.Itoa(i)+".x")
+			ok, err := runNogoForFacts(nogoPath, pkg, depFacts, goroot, installSuffix, workDir, outFacts)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			if ok {
+				factFiles[pkg.path] = outFacts
+			}
+		}(i, pkg)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	facts := make(map[string][]byte)
+	for path, file := range factFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > 0 {
+			facts[path] = data
+		}
+	}
+	return facts, nil
+}
+
+// runNogoForFacts runs nogo in the mode that only computes facts on a package
+// of the standard library. It reports whether nogo wrote the facts, which it
+// doesn't do if it fails, e.g. because an analyzer panics.
+func runNogoForFacts(nogoPath string, pkg *stdlibPackage, depFacts map[string]string, goroot, installSuffix, workDir, outFacts string) (bool, error) {
+	base := strings.TrimSuffix(outFacts, ".x")
+	importcfg := &bytes.Buffer{}
+	imports := make([]string, 0, len(pkg.imports))
+	for imp := range pkg.imports {
+		imports = append(imports, imp)
+	}
+	sort.Strings(imports)
+	for _, imp := range imports {
+		path := pkg.imports[imp]
+		if imp != path {
+			fmt.Fprintf(importcfg, "importmap %s=%s\n", imp, path)
+		}
+		fmt.Fprintf(importcfg, "packagefile %s=%s.a\n", path, filepath.Join(goroot, "pkg", installSuffix, filepath.FromSlash(path)))
+	}
+	importcfgPath := base + ".importcfg"
+	if err := os.WriteFile(importcfgPath, importcfg.Bytes(), 0o666); err != nil {
+		return false, err
+	}
+
+	args := []string{"-facts_only", "-p", pkg.path, "-importcfg", importcfgPath}
+	deps := make([]string, 0, len(depFacts))
+	for path := range depFacts {
+		deps = append(deps, path)
+	}
+	sort.Strings(deps)
+	for _, path := range deps {
+		args = append(args, "-fact", fmt.Sprintf("%s=%s", path, depFacts[path]))
+	}
+	args = append(args, "-x", outFacts)
+	args = append(args, pkg.srcs...)
+	paramsFile := base + ".param"
+	if err := writeParamsFile(paramsFile, args); err != nil {
+		return false, fmt.Errorf("error writing nogo params file: %v", err)
+	}
+
+	err := exec.Command(nogoPath, "-param="+paramsFile).Run()
+	if _, ok := err.(*exec.ExitError); ok {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error running nogo on %s: %v", pkg.path, err)
+	}
+	return true, nil
+}
+
+// writeStdlibFacts writes the facts of each package to a zip archive, sorted
+// by package path so that the archive is reproducible.
+func writeStdlibFacts(out string, facts map[string][]byte) error {
+	paths := make([]string, 0, len(facts))
+	for path := range facts {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	w := zip.NewWriter(f)
+	for _, path := range paths {
+		entry, err := w.CreateHeader(&zip.FileHeader{Name: path, Method: zip.Deflate})
+		if err != nil {
+			f.Close()
+			return err
+		}
+		if _, err := entry.Write(facts[path]); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
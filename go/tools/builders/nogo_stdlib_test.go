@@ -0,0 +1,132 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// stdlibTestModeEnv makes the test binary act as nogo computing facts.
+const stdlibTestModeEnv = "NOGO_STDLIB_TEST_MODE"
+
+func init() {
+	if os.Getenv(stdlibTestModeEnv) == "" {
+		return
+	}
+	args, err := readParamsFile(strings.TrimPrefix(os.Args[1], "-param="))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	var pkg, out string
+	var deps []string
+	for i := 0; i+1 < len(args); i++ {
+		switch args[i] {
+		case "-p":
+			pkg = args[i+1]
+		case "-x":
+			out = args[i+1]
+		case "-fact":
+			deps = append(deps, strings.SplitN(args[i+1], "=", 2)[0])
+		}
+	}
+	if pkg == "broken" {
+		os.Exit(1)
+	}
+	// The facts of a package list the packages whose facts were passed.
+	sort.Strings(deps)
+	if err := os.WriteFile(out, []byte(pkg+":"+strings.Join(deps, ",")), 0o666); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func TestReadStdlibPackages(t *testing.T) {
+	listJSON := filepath.Join(t.TempDir(), "stdlib.pkg.json")
+	id := func(path string) string { return rulesGoStdlibPrefix + path }
+	content := strings.Join([]string{
+		fmt.Sprintf(`{"ID": %q, "PkgPath": "builtin", "GoFiles": ["__BAZEL_OUTPUT_BASE__/external/go_sdk/src/builtin/builtin.go"]}`, id("builtin")),
+		fmt.Sprintf(`{"ID": %q, "PkgPath": "unsafe", "GoFiles": ["__BAZEL_OUTPUT_BASE__/external/go_sdk/src/unsafe/unsafe.go"]}`, id("unsafe")),
+		fmt.Sprintf(`{"ID": %q, "PkgPath": "embed"}`, id("embed")),
+		fmt.Sprintf(`{"ID": %q, "PkgPath": "net", "GoFiles": ["__BAZEL_OUTPUT_BASE__/external/go_sdk/src/net/net.go"], "CompiledGoFiles": ["__BAZEL_OUTPUT_BASE__/external/go_sdk/src/net/net.go", "__BAZEL_EXECROOT__/bazel-out/gocache/cgo.go"], "Imports": {"golang.org/x/net/dns/dnsmessage": %q}}`, id("net"), id("vendor/golang.org/x/net/dns/dnsmessage")),
+	}, "\n")
+	if err := os.WriteFile(listJSON, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pkgs, err := readStdlibPackages(listJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*stdlibPackage{{
+		path: "net",
+		srcs: []string{"external/go_sdk/src/net/net.go", "bazel-out/gocache/cgo.go"},
+		imports: map[string]string{
+			"golang.org/x/net/dns/dnsmessage": "vendor/golang.org/x/net/dns/dnsmessage",
+		},
+	}}
+	if !reflect.DeepEqual(pkgs, want) {
+		t.Errorf("got %+v, want %+v", pkgs, want)
+	}
+}
+
+func TestAnalyzeStdlibPackages(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(stdlibTestModeEnv, "1")
+	pkgs := []*stdlibPackage{
+		{path: "c", srcs: []string{"c.go"}, imports: map[string]string{"b": "b", "broken": "broken"}},
+		{path: "b", srcs: []string{"b.go"}, imports: map[string]string{"a": "a"}},
+		{path: "broken", srcs: []string{"broken.go"}, imports: map[string]string{"a": "a"}},
+		{path: "a", srcs: []string{"a.go"}, imports: map[string]string{}},
+	}
+	facts, err := analyzeStdlibPackages(pkgs, exe, "/goroot", "linux_amd64", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for path, data := range facts {
+		got[path] = string(data)
+	}
+	// Packages are analyzed after their imports and broken has no facts.
+	want := map[string]string{"a": "a:", "b": "b:a", "c": "c:b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got facts %v, want %v", got, want)
+	}
+}
+
+func TestWriteStdlibFacts(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "stdlib_facts.zip")
+	if err := writeStdlibFacts(out, map[string][]byte{"os": []byte("os facts"), "fmt": []byte("fmt facts")}); err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.OpenReader(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var got []string
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, f.Name+"="+string(data))
+	}
+	if want := []string{"fmt=fmt facts", "os=os facts"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got entries %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// nogo_summary aggregates the nogo logs and fix files of many targets into a
+// single summary file. It is used by the nogo_summary rule, which lets users
+// collect all findings of a --keep_going build in one place instead of
+// scrolling through the output of every failed validation action.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// summaryEntry describes the nogo outputs of a single analyzed package.
+type summaryEntry struct {
+	Label   string `json:"label"`
+	Package string `json:"package"`
+	Log     string `json:"log"`
+	Fix     string `json:"fix"`
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("nogo_summary: ")
+	if err := runSummary(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func runSummary(args []string) error {
+	fs := flag.NewFlagSet("nogo_summary", flag.ExitOnError)
+	manifest := fs.String("manifest", "", "JSON file listing the nogo outputs of each target")
+	out := fs.String("out", "", "The summary file to write")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *manifest == "" || *out == "" {
+		return fmt.Errorf("-manifest and -out must be set")
+	}
+
+	data, err := os.ReadFile(*manifest)
+	if err != nil {
+		return err
+	}
+	var entries []summaryEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("parsing %s: %w", *manifest, err)
+	}
+
+	var summary bytes.Buffer
+	if err := writeSummary(&summary, entries, os.ReadFile); err != nil {
+		return err
+	}
+	return os.WriteFile(*out, summary.Bytes(), 0o666)
+}
+
+// writeSummary writes a section for every entry with a non-empty nogo log.
+// Entries are sorted by label and package so that the summary doesn't depend
+// on the order in which Bazel lists the targets.
+func writeSummary(w io.Writer, entries []summaryEntry, readFile func(string) ([]byte, error)) error {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Label != entries[j].Label {
+			return entries[i].Label < entries[j].Label
+		}
+		return entries[i].Package < entries[j].Package
+	})
+
+	var body bytes.Buffer
+	numFailed := 0
+	// reportedBy maps findings to the label of the first entry reporting them.
+	reportedBy := make(map[string]string)
+	for _, e := range entries {
+		logContent, err := readFile(e.Log)
+		if err != nil {
+			return err
+		}
+		logContent = bytes.TrimSpace(logContent)
+		if len(logContent) == 0 {
+			continue
+		}
+		numFailed++
+		logContent, duplicates := dropReportedFindings(logContent, e.Label, reportedBy)
+		fmt.Fprintf(&body, "\n%s (%s)\n%s\n", e.Label, e.Package, logContent)
+		for _, label := range sortedKeys(duplicates) {
+			fmt.Fprintf(&body, "(%d duplicate finding(s) omitted, see %s)\n", duplicates[label], label)
+		}
+		if e.Fix == "" {
+			continue
+		}
+		fixContent, err := readFile(e.Fix)
+		if err != nil {
+			return err
+		}
+		if len(fixContent) > 0 {
+			fmt.Fprintf(&body, "Suggested fix: %s\n", e.Fix)
+		}
+	}
+
+	if numFailed == 0 {
+		_, err := io.WriteString(w, "nogo found no issues.\n")
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "nogo found issues in %d of %d packages.\n", numFailed, len(entries)); err != nil {
+		return err
+	}
+	_, err := w.Write(body.Bytes())
+	return err
+}
+
+// dropReportedFindings removes the findings from the log that were already
+// reported by another entry, such as the findings in the sources shared by a
+// library and its internal test. A finding is identified by its first line,
+// which contains its file, position, message and analyzer. It records the
+// remaining findings in reportedBy and returns the number of findings dropped
+// per label that reported them first.
+func dropReportedFindings(logContent []byte, label string, reportedBy map[string]string) ([]byte, map[string]int) {
+	duplicates := make(map[string]int)
+	var kept []string
+	dropping := false
+	for _, line := range strings.Split(string(logContent), "\n") {
+		if findingRegexp.MatchString(line) {
+			first, ok := reportedBy[line]
+			dropping = ok
+			if dropping {
+				duplicates[first]++
+				continue
+			}
+			reportedBy[line] = label
+		} else if dropping && strings.HasPrefix(line, "    ") {
+			// Part of the dropped finding.
+			continue
+		} else {
+			dropping = false
+		}
+		kept = append(kept, line)
+	}
+	return []byte(strings.Join(kept, "\n")), duplicates
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
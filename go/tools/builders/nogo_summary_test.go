@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestWriteSummary(t *testing.T) {
+	files := map[string]string{
+		"a.log":   "errors found by nogo during build-time code analysis:\na.go:1:1: bad (analyzer1)\n",
+		"a.patch": "--- a/a.go\n+++ b/a.go\n",
+		"b.log":   "",
+		"b.patch": "",
+		"c.log":   "errors found by nogo during build-time code analysis:\nc.go:2:1: bad (analyzer2)\n",
+		"c.patch": "",
+		"d.log": `errors found by nogo during build-time code analysis:
+d.go:1:1: bad (analyzer1)
+    Fix it.
+d.go:2:1: bad (analyzer2)
+`,
+		"d_test.log": `errors found by nogo during build-time code analysis:
+d.go:1:1: bad (analyzer1)
+    Fix it.
+d.go:2:1: bad (analyzer2)
+d_test.go:3:1: bad (analyzer2)
+`,
+	}
+	readFile := func(name string) ([]byte, error) {
+		content, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("no such file: %s", name)
+		}
+		return []byte(content), nil
+	}
+
+	tests := []struct {
+		name     string
+		entries  []summaryEntry
+		expected string
+	}{
+		{
+			name: "findings",
+			entries: []summaryEntry{
+				{Label: "//c", Package: "example.com/c", Log: "c.log", Fix: "c.patch"},
+				{Label: "//b", Package: "example.com/b", Log: "b.log", Fix: "b.patch"},
+				{Label: "//a", Package: "example.com/a", Log: "a.log", Fix: "a.patch"},
+			},
+			expected: `nogo found issues in 2 of 3 packages.
+
+//a (example.com/a)
+errors found by nogo during build-time code analysis:
+a.go:1:1: bad (analyzer1)
+Suggested fix: a.patch
+
+//c (example.com/c)
+errors found by nogo during build-time code analysis:
+c.go:2:1: bad (analyzer2)
+`,
+		},
+		{
+			name: "duplicates",
+			entries: []summaryEntry{
+				{Label: "//d:d_test", Package: "example.com/d", Log: "d_test.log"},
+				{Label: "//d", Package: "example.com/d", Log: "d.log"},
+			},
+			expected: `nogo found issues in 2 of 2 packages.
+
+//d (example.com/d)
+errors found by nogo during build-time code analysis:
+d.go:1:1: bad (analyzer1)
+    Fix it.
+d.go:2:1: bad (analyzer2)
+
+//d:d_test (example.com/d)
+errors found by nogo during build-time code analysis:
+d_test.go:3:1: bad (analyzer2)
+(2 duplicate finding(s) omitted, see //d)
+`,
+		},
+		{
+			name: "no findings",
+			entries: []summaryEntry{
+				{Label: "//b", Package: "example.com/b", Log: "b.log", Fix: "b.patch"},
+			},
+			expected: "nogo found no issues.\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeSummary(&buf, tt.entries, readFile); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := buf.String(); got != tt.expected {
+				t.Errorf("unexpected summary:\n\tgot:\n%s\n\twant:\n%s", got, tt.expected)
+			}
+		})
+	}
+}
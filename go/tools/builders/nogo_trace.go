@@ -0,0 +1,293 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This file implements optional OpenTelemetry tracing of nogo runs. Spans are
+// exported in the OTLP/JSON encoding, either appended as a line to a file or
+// sent to an OTLP/HTTP endpoint, without depending on the OpenTelemetry SDK.
+
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// traceFileEnv names a file that OTLP/JSON trace requests are appended to,
+	// one per line.
+	traceFileEnv = "NOGO_TRACE_FILE"
+	// The standard OpenTelemetry environment variables for OTLP/HTTP export.
+	otlpTracesEndpointEnv = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+	otlpEndpointEnv       = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	// traceParentEnv holds a W3C trace context to nest the spans of nogo in.
+	traceParentEnv = "TRACEPARENT"
+)
+
+// A tracer records the spans of a single nogo run. A nil *tracer is valid and
+// records nothing, so that tracing can be disabled without checks at every
+// call site.
+type tracer struct {
+	file     string
+	endpoint string
+	traceID  string
+	parentID string
+	attrs    map[string]string
+	root     *traceSpan
+
+	mu    sync.Mutex
+	spans []*traceSpan
+}
+
+// A traceSpan is a timed operation. A nil *traceSpan is valid.
+type traceSpan struct {
+	t        *tracer
+	id       string
+	parentID string
+	name     string
+	attrs    map[string]string
+	start    time.Time
+	end      time.Time
+}
+
+// newTracerFromEnv returns a tracer if an exporter is configured in the
+// environment, or nil otherwise. attrs are the resource attributes of all
+// spans, given as key-value pairs.
+func newTracerFromEnv(attrs ...string) *tracer {
+	t := &tracer{
+		file:     os.Getenv(traceFileEnv),
+		endpoint: os.Getenv(otlpTracesEndpointEnv),
+		attrs:    map[string]string{"service.name": "nogo"},
+	}
+	if t.endpoint == "" {
+		if base := os.Getenv(otlpEndpointEnv); base != "" {
+			t.endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+		}
+	}
+	if t.file == "" && t.endpoint == "" {
+		return nil
+	}
+	if traceID, parentID, ok := parseTraceParent(os.Getenv(traceParentEnv)); ok {
+		t.traceID, t.parentID = traceID, parentID
+	} else {
+		t.traceID = randomID(16)
+	}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		t.attrs[attrs[i]] = attrs[i+1]
+	}
+	return t
+}
+
+// parseTraceParent parses a W3C traceparent header value of the form
+// "00-<trace id>-<parent id>-<flags>".
+func parseTraceParent(s string) (traceID, parentID string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", "", false
+	}
+	if _, err := hex.DecodeString(parts[1]); err != nil {
+		return "", "", false
+	}
+	if _, err := hex.DecodeString(parts[2]); err != nil {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+func randomID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// startRoot starts the span covering the whole nogo run. It is a child of
+// the trace context nogo was started in, if any. attrs are given as key-value
+// pairs.
+func (t *tracer) startRoot(name string, attrs ...string) *traceSpan {
+	if t == nil {
+		return nil
+	}
+	t.root = t.newSpan(name, t.parentID, attrs)
+	return t.root
+}
+
+// start starts a span as a child of the root span. attrs are given as
+// key-value pairs.
+func (t *tracer) start(name string, attrs ...string) *traceSpan {
+	if t == nil {
+		return nil
+	}
+	parentID := t.parentID
+	if t.root != nil {
+		parentID = t.root.id
+	}
+	return t.newSpan(name, parentID, attrs)
+}
+
+func (t *tracer) newSpan(name, parentID string, attrs []string) *traceSpan {
+	s := &traceSpan{
+		t:        t,
+		id:       randomID(8),
+		parentID: parentID,
+		name:     name,
+		start:    time.Now(),
+	}
+	if len(attrs) > 0 {
+		s.attrs = make(map[string]string)
+		for i := 0; i+1 < len(attrs); i += 2 {
+			s.attrs[attrs[i]] = attrs[i+1]
+		}
+	}
+	return s
+}
+
+// finish ends the span and records it.
+func (s *traceSpan) finish() {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+	s.t.mu.Lock()
+	s.t.spans = append(s.t.spans, s)
+	s.t.mu.Unlock()
+}
+
+// The OTLP/JSON encoding of an ExportTraceServiceRequest, limited to the fields
+// used by nogo.
+type otlpTraceRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string        `json:"key"`
+	Value otlpAnyString `json:"value"`
+}
+
+type otlpAnyString struct {
+	StringValue string `json:"stringValue"`
+}
+
+// otlpSpanKindInternal is SPAN_KIND_INTERNAL.
+const otlpSpanKindInternal = 1
+
+func otlpAttributes(attrs map[string]string) []otlpKeyValue {
+	var kvs []otlpKeyValue
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		kvs = append(kvs, otlpKeyValue{Key: k, Value: otlpAnyString{StringValue: attrs[k]}})
+	}
+	return kvs
+}
+
+// encode returns the recorded spans as an OTLP/JSON trace request.
+func (t *tracer) encode() ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	spans := make([]otlpSpan, 0, len(t.spans))
+	for _, s := range t.spans {
+		spans = append(spans, otlpSpan{
+			TraceID:           t.traceID,
+			SpanID:            s.id,
+			ParentSpanID:      s.parentID,
+			Name:              s.name,
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        otlpAttributes(s.attrs),
+		})
+	}
+	return json.Marshal(otlpTraceRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: otlpAttributes(t.attrs)},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "nogo"}, Spans: spans}},
+	}}})
+}
+
+// flush exports the recorded spans to the configured file and endpoint.
+func (t *tracer) flush() error {
+	if t == nil {
+		return nil
+	}
+	data, err := t.encode()
+	if err != nil {
+		return err
+	}
+	if t.file != "" {
+		f, err := os.OpenFile(t.file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o666)
+		if err != nil {
+			return err
+		}
+		// A single write keeps the lines of concurrent nogo actions intact.
+		_, err = f.Write(append(data, '\n'))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if t.endpoint != "" {
+		client := http.Client{Timeout: 10 * time.Second}
+		resp, err := client.Post(t.endpoint, "application/json", bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("exporting spans to %s: %s", t.endpoint, resp.Status)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTracer_Disabled(t *testing.T) {
+	t.Setenv(traceFileEnv, "")
+	t.Setenv(otlpTracesEndpointEnv, "")
+	t.Setenv(otlpEndpointEnv, "")
+	tr := newTracerFromEnv()
+	if tr != nil {
+		t.Fatalf("expected no tracer, got %+v", tr)
+	}
+	// All methods are no-ops on a nil tracer.
+	tr.startRoot("nogo").finish()
+	tr.start("nogo.load").finish()
+	if err := tr.flush(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestTracer_File(t *testing.T) {
+	traceFile := filepath.Join(t.TempDir(), "trace.jsonl")
+	t.Setenv(traceFileEnv, traceFile)
+	t.Setenv(otlpTracesEndpointEnv, "")
+	t.Setenv(otlpEndpointEnv, "")
+	t.Setenv(traceParentEnv, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+
+	for i := 0; i < 2; i++ {
+		tr := newTracerFromEnv("nogo.package", "example.com/pkg")
+		root := tr.startRoot("nogo")
+		tr.start("nogo.analyzer", "nogo.analyzer", "printf").finish()
+		root.finish()
+		if err := tr.flush(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(traceFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var requests []otlpTraceRequest
+	for {
+		var req otlpTraceRequest
+		if err := dec.Decode(&req); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		requests = append(requests, req)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("expected one line per run, got %d", len(requests))
+	}
+	rs := requests[0].ResourceSpans[0]
+	if got := rs.Resource.Attributes; len(got) != 2 || got[0].Key != "nogo.package" || got[1].Value.StringValue != "nogo" {
+		t.Errorf("unexpected resource attributes: %+v", got)
+	}
+	spans := rs.ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	analyzer, root := spans[0], spans[1]
+	if root.Name != "nogo" || root.TraceID != "0af7651916cd43dd8448eb211c80319c" || root.ParentSpanID != "b7ad6b7169203331" {
+		t.Errorf("root span is not part of the parent trace: %+v", root)
+	}
+	if analyzer.ParentSpanID != root.SpanID || len(analyzer.Attributes) != 1 || analyzer.Attributes[0].Value.StringValue != "printf" {
+		t.Errorf("unexpected analyzer span: %+v", analyzer)
+	}
+}
+
+func TestTracer_Endpoint(t *testing.T) {
+	var got otlpTraceRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+	t.Setenv(traceFileEnv, "")
+	t.Setenv(otlpTracesEndpointEnv, "")
+	t.Setenv(otlpEndpointEnv, server.URL+"/")
+	t.Setenv(traceParentEnv, "")
+
+	tr := newTracerFromEnv()
+	tr.startRoot("nogo").finish()
+	if err := tr.flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got.ResourceSpans) != 1 || len(got.ResourceSpans[0].ScopeSpans[0].Spans) != 1 {
+		t.Errorf("unexpected request: %+v", got)
+	}
+}
+
+func TestParseTraceParent(t *testing.T) {
+	for _, s := range []string{"", "00-xyz-abc-01", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b716920333z-01"} {
+		if _, _, ok := parseTraceParent(s); ok {
+			t.Errorf("parseTraceParent(%q) succeeded", s)
+		}
+	}
+}
@@ -1,17 +1,156 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 )
 
+// The values of the -color flag of nogovalidation.
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// colorEnv overrides the -color flag of nogovalidation if set to one of its
+// values. Pass it with --action_env to get colored output from Bazel.
+const colorEnv = "NOGO_COLOR"
+
+// The values of the -format flag of nogovalidation.
+const (
+	formatGrouped = "grouped"
+	formatPlain   = "plain"
+)
+
+// formatEnv overrides the -format flag of nogovalidation if set to one of its
+// values.
+const formatEnv = "NOGO_FORMAT"
+
+// failAtEnv overrides the -fail_at flag of nogovalidation if set to one of the
+// severities.
+const failAtEnv = "NOGO_FAIL_AT"
+
+// defaultMaxFixSize is the default of the -max_fix_size flag of
+// nogovalidation. Larger patches are summarized instead of being printed to
+// avoid flooding the logs of CI builds.
+const defaultMaxFixSize = 16 * 1024
+
+// maxFixSizeEnv overrides the -max_fix_size flag of nogovalidation if set.
+// Pass --action_env=NOGO_MAX_FIX_SIZE=0 to Bazel to always print the full
+// suggested fix.
+const maxFixSizeEnv = "NOGO_MAX_FIX_SIZE"
+
+// markersEnv overrides the -markers flag of nogovalidation if set to a
+// boolean value.
+const markersEnv = "NOGO_MARKERS"
+
+// The markers around the output of nogovalidation with -markers. The begin
+// marker is followed by the JSON summary of the findings of the target, the
+// end marker by a JSON object with its label. Build result UIs can use them to
+// extract the findings of each target from the output of the build.
+const (
+	nogoBeginMarker = "##[nogo:begin]"
+	nogoEndMarker   = "##[nogo:end]"
+)
+
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiRed    = "\x1b[31m"
+	ansiYellow = "\x1b[33m"
+	ansiCyan   = "\x1b[36m"
+)
+
+// validationOptions are the options of nogovalidation.
+type validationOptions struct {
+	validationOutput, logFile, fixFile string
+
+	color       string
+	format      string
+	failAt      string
+	summaryOnly bool
+	label       string
+	markers     bool
+	maxFixSize  int
+}
+
+// parseValidationArgs parses the arguments of nogovalidation and applies the
+// overrides from the environment. The validation output, the log file and the
+// fix file can also be passed as positional arguments, in this order.
+func parseValidationArgs(args []string) (validationOptions, error) {
+	var opts validationOptions
+	fs := flag.NewFlagSet("nogovalidation", flag.ContinueOnError)
+	fs.StringVar(&opts.validationOutput, "validation_output", "", "The output of the validation action, or of the summary with -summary_only")
+	fs.StringVar(&opts.logFile, "log", "", "The nogo log of the package")
+	fs.StringVar(&opts.fixFile, "fix", "", "The patch with the suggested fixes of the package")
+	fs.StringVar(&opts.color, "color", colorAuto, "Whether to color the findings: auto, always or never")
+	fs.StringVar(&opts.format, "format", formatGrouped, "How to print the findings: grouped by analyzer and file, or plain as in the log")
+	fs.StringVar(&opts.failAt, "fail_at", severityError, "The lowest severity of the findings that fail the validation: error, warning or note")
+	fs.BoolVar(&opts.summaryOnly, "summary_only", false, "Write a JSON summary of the findings to the output instead of validating them")
+	fs.StringVar(&opts.label, "label", "", "The label of the target, recorded in the summary and the markers")
+	fs.BoolVar(&opts.markers, "markers", false, "Wrap the findings in begin and end markers with the label and summary of the target")
+	fs.IntVar(&opts.maxFixSize, "max_fix_size", defaultMaxFixSize, "The size in bytes above which the suggested fix is summarized instead of printed, or 0 to always print it")
+	if err := fs.Parse(args); err != nil {
+		return validationOptions{}, err
+	}
+	switch positional := fs.Args(); {
+	case len(positional) == 3 && opts.validationOutput == "" && opts.logFile == "" && opts.fixFile == "":
+		opts.validationOutput, opts.logFile, opts.fixFile = positional[0], positional[1], positional[2]
+	case len(positional) > 0 || opts.validationOutput == "" || opts.logFile == "" || opts.fixFile == "":
+		return validationOptions{}, fmt.Errorf("usage: nogovalidation [options] -validation_output=<file> -log=<file> -fix=<file>\n\tor: nogovalidation [options] <validation_output> <log_file> <fix_file>\n\tgot: %v", args)
+	}
+
+	if env := os.Getenv(colorEnv); env != "" {
+		opts.color = env
+	}
+	if env := os.Getenv(formatEnv); env != "" {
+		opts.format = env
+	}
+	switch opts.format {
+	case formatGrouped, formatPlain:
+	default:
+		return validationOptions{}, fmt.Errorf("invalid format %q, must be %q or %q", opts.format, formatGrouped, formatPlain)
+	}
+	if env := os.Getenv(failAtEnv); env != "" {
+		opts.failAt = env
+	}
+	if opts.failAt == severityInfo {
+		opts.failAt = severityNote
+	}
+	if severityRank(opts.failAt) < 0 {
+		return validationOptions{}, fmt.Errorf("invalid fail_at %q, must be %q, %q or %q", opts.failAt, severityError, severityWarning, severityNote)
+	}
+	var err error
+	if env := os.Getenv(maxFixSizeEnv); env != "" {
+		if opts.maxFixSize, err = strconv.Atoi(env); err != nil {
+			return validationOptions{}, fmt.Errorf("invalid %s: %v", maxFixSizeEnv, err)
+		}
+	}
+	if env := os.Getenv(markersEnv); env != "" {
+		if opts.markers, err = strconv.ParseBool(env); err != nil {
+			return validationOptions{}, fmt.Errorf("invalid %s: %v", markersEnv, err)
+		}
+	}
+	return opts, nil
+}
+
 func nogoValidation(args []string) error {
-	if len(args) != 3 {
-		return fmt.Errorf("usage: nogovalidation <validation_output> <log_file> <fix_file>\n\tgot: %v+", args)
+	opts, err := parseValidationArgs(args)
+	if err != nil {
+		return err
+	}
+	validationOutput := opts.validationOutput
+	logFile := opts.logFile
+	fixFile := opts.fixFile
+	if opts.summaryOnly {
+		return writeValidationSummary(validationOutput, opts.label, opts.failAt, logFile, fixFile)
 	}
-	validationOutput := args[0]
-	logFile := args[1]
-	fixFile := args[2]
 	// Always create the output file and only fail if the log file is non-empty to
 	// avoid an "action failed to create outputs" error.
 	logContent, err := os.ReadFile(logFile)
@@ -23,26 +162,327 @@ func nogoValidation(args []string) error {
 		return err
 	}
 	if len(logContent) > 0 {
-		fixContent, err := os.ReadFile(fixFile)
+		fixContent, compressed, err := readFixFile(fixFile)
 		if err != nil {
 			return err
 		}
-		var fixMessage string
-		if len(fixContent) > 0 {
-			// Format the message in a clean and clear way
-			fixMessage = fmt.Sprintf(`
--------------------Suggested Fix---------------------
-%s
------------------------------------------------------
-To apply the suggested fix, run the following command:
-$ patch -p1 < %s
-`, fixContent, fixFile)
+		useColor, err := shouldColor(opts.color)
+		if err != nil {
+			return err
+		}
+		formattedLog := logContent
+		if opts.format == formatGrouped {
+			formattedLog = formatNogoLog(logContent, useColor)
+		}
+		output := fmt.Sprintf("%s%s", formattedLog, formatFixMessage(fixContent, fixFile, compressed, opts.maxFixSize))
+		if opts.markers {
+			if output, err = wrapInMarkers(output, newValidationSummary(opts.label, opts.failAt, logContent, fixContent)); err != nil {
+				return err
+			}
 		}
 		// Separate nogo output from Bazel's --sandbox_debug message via an
 		// empty line.
+		_, _ = fmt.Fprintf(os.Stderr, "\n%s\n", output)
+		if !logFailsBuild(logContent, opts.failAt) {
+			return nil
+		}
 		// Don't return to avoid printing the "nogovalidation:" prefix.
-		_, _ = fmt.Fprintf(os.Stderr, "\n%s%s\n", logContent, fixMessage)
 		os.Exit(1)
 	}
 	return nil
 }
+
+// formatFixMessage formats the suggested fix of a package for stderr. Fixes
+// larger than maxSize bytes are summarized by the number of files and hunks
+// they change, with a pointer to the fix file. A maxSize of 0 or less never
+// summarizes the fix. If the fix file is compressed, the command to apply it
+// decompresses it first.
+func formatFixMessage(fixContent []byte, fixFile string, compressed bool, maxSize int) string {
+	if len(fixContent) == 0 {
+		return ""
+	}
+	fix := string(fixContent)
+	if maxSize > 0 && len(fixContent) > maxSize {
+		var files, hunks int
+		for _, line := range strings.Split(fix, "\n") {
+			switch {
+			case strings.HasPrefix(line, "--- "):
+				files++
+			case strings.HasPrefix(line, "@@ "):
+				hunks++
+			}
+		}
+		fix = fmt.Sprintf(`The suggested fix changes %s in %s and is too large to print (%d bytes).
+See %s, or set %s=0 with --action_env to print it in full.`,
+			plural(files, "file"), plural(hunks, "hunk"), len(fixContent), fixFile, maxFixSizeEnv)
+	}
+	command := fmt.Sprintf("patch -p%d < %s", fixStrip(fixContent), fixFile)
+	if compressed {
+		command = fmt.Sprintf("gunzip -c %s | patch -p%d", fixFile, fixStrip(fixContent))
+	}
+	// Format the message in a clean and clear way
+	return fmt.Sprintf(`
+-------------------Suggested Fix---------------------
+%s
+-----------------------------------------------------
+To apply the suggested fix, run the following command:
+$ %s
+`, fix, command)
+}
+
+// fixStrip returns the number of leading path components to strip from the
+// file names in the fix file, as recorded by nogo, or 1 for the a/ and b/
+// prefixes of fix files without a recorded level.
+func fixStrip(fixContent []byte) int {
+	if strip, ok := recordedStrip(fixContent); ok {
+		return strip
+	}
+	return 1
+}
+
+// wrapInMarkers wraps the output of nogovalidation in the begin and end
+// markers, each on a line of its own.
+func wrapInMarkers(output string, summary validationSummary) (string, error) {
+	begin, err := json.Marshal(summary)
+	if err != nil {
+		return "", err
+	}
+	end, err := json.Marshal(struct {
+		Label string `json:"label,omitempty"`
+	}{summary.Label})
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasSuffix(output, "\n") {
+		output += "\n"
+	}
+	return fmt.Sprintf("%s %s\n%s%s %s", nogoBeginMarker, begin, output, nogoEndMarker, end), nil
+}
+
+// A validationSummary counts the nogo findings of a package, so that
+// dashboards can track them without parsing the nogo log.
+type validationSummary struct {
+	Label    string `json:"label,omitempty"`
+	Findings int    `json:"findings"`
+	// FailsBuild is set if the validation action of the package fails.
+	FailsBuild bool `json:"fails_build"`
+	// HasFixes is set if nogo suggested fixes for the package.
+	HasFixes  bool           `json:"has_fixes"`
+	Analyzers map[string]int `json:"analyzers"`
+	Files     map[string]int `json:"files"`
+}
+
+// logFailsBuild reports whether the nogo log of a package fails its
+// validation. Findings below the failAt severity are only printed. Logs with
+// errors found by nogo, or in an unknown format, always fail.
+func logFailsBuild(logContent []byte, failAt string) bool {
+	if len(logContent) == 0 {
+		return false
+	}
+	if !bytes.HasPrefix(logContent, []byte("nogo: "+nogoWarningsHeader)) {
+		return true
+	}
+	_, findings, _ := parseNogoLog(logContent)
+	for _, f := range findings {
+		if severityRank(f.severity) >= severityRank(failAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// severityRank orders the severities from note to error. It returns -1 for
+// unknown severities.
+func severityRank(severity string) int {
+	switch severity {
+	case severityNote:
+		return 0
+	case severityWarning:
+		return 1
+	case severityError:
+		return 2
+	default:
+		return -1
+	}
+}
+
+// newValidationSummary summarizes the nogo log and fix file of a package.
+func newValidationSummary(label, failAt string, logContent, fixContent []byte) validationSummary {
+	summary := validationSummary{
+		Label:      label,
+		FailsBuild: logFailsBuild(logContent, failAt),
+		HasFixes:   len(fixContent) > 0,
+		Analyzers:  make(map[string]int),
+		Files:      make(map[string]int),
+	}
+	_, findings, _ := parseNogoLog(logContent)
+	for _, f := range findings {
+		summary.Findings++
+		summary.Analyzers[f.analyzer]++
+		summary.Files[f.file]++
+	}
+	return summary
+}
+
+func writeValidationSummary(out, label, failAt, logFile, fixFile string) error {
+	logContent, err := os.ReadFile(logFile)
+	if err != nil {
+		return err
+	}
+	fixContent, _, err := readFixFile(fixFile)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(newValidationSummary(label, failAt, logContent, fixContent), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(out, append(data, '\n'), 0o666)
+}
+
+// shouldColor resolves the -color flag. In auto mode, the output is colored if stderr is a terminal and NO_COLOR is
+// not set.
+func shouldColor(mode string) (bool, error) {
+	switch mode {
+	case colorAlways:
+		return true, nil
+	case colorNever:
+		return false, nil
+	case colorAuto:
+		if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+			return false, nil
+		}
+		info, err := os.Stderr.Stat()
+		return err == nil && info.Mode()&os.ModeCharDevice != 0, nil
+	default:
+		return false, fmt.Errorf("invalid color mode %q, must be %q, %q or %q", mode, colorAuto, colorAlways, colorNever)
+	}
+}
+
+// A logFinding is a finding in the nogo log together with the indented lines
+// that follow it, such as remediation notes and hints.
+type logFinding struct {
+	file, analyzer string
+	// severity is one of the severities, error for findings without a
+	// severity label.
+	severity string
+	lines    []string
+}
+
+// parseNogoLog splits the nogo log into its header, the findings that follow
+// it and the remaining lines. It returns no findings for logs in an unknown
+// format.
+func parseNogoLog(log []byte) (string, []*logFinding, []string) {
+	lines := strings.Split(strings.TrimRight(string(log), "\n"), "\n")
+	header := lines[0]
+	if header != "nogo: "+nogoErrorsHeader && header != "nogo: "+nogoWarningsHeader {
+		return "", nil, nil
+	}
+	var findings []*logFinding
+	rest := len(lines)
+	for i, line := range lines[1:] {
+		if m := findingRegexp.FindStringSubmatch(line); m != nil {
+			severity := m[2]
+			if severity == "" {
+				severity = severityError
+			}
+			findings = append(findings, &logFinding{file: m[1], analyzer: m[3], severity: severity, lines: []string{line}})
+			continue
+		}
+		if strings.HasPrefix(line, "    ") && len(findings) > 0 {
+			f := findings[len(findings)-1]
+			f.lines = append(f.lines, line)
+			continue
+		}
+		rest = i + 1
+		break
+	}
+	return header, findings, lines[rest:]
+}
+
+// formatNogoLog groups the findings at the start of the nogo log by analyzer
+// and by file, with the number of findings in each group. The lines of each
+// finding and the rest of the log, such as errors saving the fixes, are kept
+// as they are. Logs in an unknown format are returned unchanged.
+func formatNogoLog(log []byte, color bool) []byte {
+	header, findings, rest := parseNogoLog(log)
+	if len(findings) == 0 {
+		return log
+	}
+
+	style := func(code, s string) string {
+		if !color {
+			return s
+		}
+		return code + s + ansiReset
+	}
+	byAnalyzer := make(map[string][]*logFinding)
+	var analyzers []string
+	files := make(map[string]bool)
+	for _, f := range findings {
+		if byAnalyzer[f.analyzer] == nil {
+			analyzers = append(analyzers, f.analyzer)
+		}
+		byAnalyzer[f.analyzer] = append(byAnalyzer[f.analyzer], f)
+		files[f.file] = true
+	}
+	sort.Strings(analyzers)
+
+	var out strings.Builder
+	out.WriteString(style(ansiBold, header))
+	out.WriteByte('\n')
+	for _, analyzer := range analyzers {
+		analyzerFindings := byAnalyzer[analyzer]
+		byFile := make(map[string][]*logFinding)
+		var fileNames []string
+		for _, f := range analyzerFindings {
+			if byFile[f.file] == nil {
+				fileNames = append(fileNames, f.file)
+			}
+			byFile[f.file] = append(byFile[f.file], f)
+		}
+		sort.Strings(fileNames)
+		fmt.Fprintf(&out, "\n%s\n", style(ansiBold, fmt.Sprintf("=== %s: %s in %s", analyzer, plural(len(analyzerFindings), "finding"), plural(len(fileNames), "file"))))
+		for _, fileName := range fileNames {
+			fmt.Fprintf(&out, "%s\n", style(ansiCyan, fmt.Sprintf("--- %s: %s", fileName, plural(len(byFile[fileName]), "finding"))))
+			for _, f := range byFile[fileName] {
+				for _, line := range f.lines {
+					out.WriteString(colorSeverity(line, color))
+					out.WriteByte('\n')
+				}
+			}
+		}
+	}
+	fmt.Fprintf(&out, "\n%s\n", style(ansiBold, fmt.Sprintf("nogo: %s from %s in %s", plural(len(findings), "finding"), plural(len(analyzers), "analyzer"), plural(len(files), "file"))))
+	for _, line := range rest {
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	return []byte(out.String())
+}
+
+// colorSeverity colors the severity of the first line of a finding, or the
+// position of the finding if it is an error.
+func colorSeverity(line string, color bool) string {
+	if !color || strings.HasPrefix(line, " ") {
+		return line
+	}
+	if m := findingRegexp.FindStringSubmatchIndex(line); m != nil && m[4] >= 0 {
+		code := ansiYellow
+		if line[m[4]:m[5]] == severityNote {
+			code = ansiCyan
+		}
+		return line[:m[4]] + code + line[m[4]:m[5]] + ansiReset + line[m[5]:]
+	}
+	if i := strings.Index(line, ": "); i >= 0 {
+		return ansiRed + line[:i] + ansiReset + line[i:]
+	}
+	return line
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFormatNogoLog(t *testing.T) {
+	log := `nogo: errors found by nogo during build-time code analysis:
+pkg/b.go:3:1: package fmt must not be imported (importfmt)
+    3 | import "fmt" // note: fmt is fine (really)
+      | ^~~~~~~~~~~~
+pkg/a.go:5:6: function must not be named Foo (foofuncname)
+    Rename the function.
+pkg/a.go:2:1: package fmt must not be imported (importfmt)
+    hint: use log instead
+pkg/b.go:7:2: warning: function D is not visible (visibility)
+-: no position (foofuncname)
+
+saving suggested fixes:
+pkg/a.go: permission denied (not a finding)
+`
+	expected := `nogo: errors found by nogo during build-time code analysis:
+
+=== foofuncname: 2 findings in 2 files
+--- -: 1 finding
+-: no position (foofuncname)
+--- pkg/a.go: 1 finding
+pkg/a.go:5:6: function must not be named Foo (foofuncname)
+    Rename the function.
+
+=== importfmt: 2 findings in 2 files
+--- pkg/a.go: 1 finding
+pkg/a.go:2:1: package fmt must not be imported (importfmt)
+    hint: use log instead
+--- pkg/b.go: 1 finding
+pkg/b.go:3:1: package fmt must not be imported (importfmt)
+    3 | import "fmt" // note: fmt is fine (really)
+      | ^~~~~~~~~~~~
+
+=== visibility: 1 finding in 1 file
+--- pkg/b.go: 1 finding
+pkg/b.go:7:2: warning: function D is not visible (visibility)
+
+nogo: 5 findings from 3 analyzers in 3 files
+
+saving suggested fixes:
+pkg/a.go: permission denied (not a finding)
+`
+	if got := string(formatNogoLog([]byte(log), false)); got != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", got, expected)
+	}
+
+	for _, unknown := range []string{
+		"nogo: 4 analyzers skipped due to type-checking error: a.go:8:10: undefined: x\n",
+		"nogo: errors found by nogo during build-time code analysis:\nsomething else\n",
+	} {
+		if got := string(formatNogoLog([]byte(unknown), false)); got != unknown {
+			t.Errorf("got:\n%s\nwant the log unchanged:\n%s", got, unknown)
+		}
+	}
+}
+
+func TestColorSeverity(t *testing.T) {
+	for _, tt := range []struct {
+		line, expected string
+	}{
+		{
+			line:     "a.go:1:2: message (analyzer)",
+			expected: "\x1b[31ma.go:1:2\x1b[0m: message (analyzer)",
+		},
+		{
+			line:     "a.go:1:2: warning: message (analyzer)",
+			expected: "a.go:1:2: \x1b[33mwarning\x1b[0m: message (analyzer)",
+		},
+		{
+			line:     "a.go:1:2: note: message: warning: (analyzer)",
+			expected: "a.go:1:2: \x1b[36mnote\x1b[0m: message: warning: (analyzer)",
+		},
+		{
+			line:     "    hint: a.go:1:2: message",
+			expected: "    hint: a.go:1:2: message",
+		},
+	} {
+		if got := colorSeverity(tt.line, true); got != tt.expected {
+			t.Errorf("colorSeverity(%q) = %q, want %q", tt.line, got, tt.expected)
+		}
+	}
+}
+
+func TestLogFailsBuild(t *testing.T) {
+	warningsLog := `nogo: warnings found by nogo during build-time code analysis:
+pkg/a.go:2:1: note: package fmt must not be imported (importfmt)
+pkg/a.go:5:6: warning: function must not be named Foo (foofuncname)
+`
+	notesLog := `nogo: warnings found by nogo during build-time code analysis:
+pkg/a.go:2:1: note: package fmt must not be imported (importfmt)
+`
+	errorsLog := `nogo: errors found by nogo during build-time code analysis:
+pkg/a.go:2:1: package fmt must not be imported (importfmt)
+`
+	for _, tt := range []struct {
+		log, failAt string
+		expected    bool
+	}{
+		{"", severityNote, false},
+		{warningsLog, severityError, false},
+		{warningsLog, severityWarning, true},
+		{notesLog, severityWarning, false},
+		{notesLog, severityNote, true},
+		{errorsLog, severityError, true},
+		{"nogo: 4 analyzers skipped due to type-checking error: a.go:8:10: undefined: x\n", severityError, true},
+	} {
+		if got := logFailsBuild([]byte(tt.log), tt.failAt); got != tt.expected {
+			t.Errorf("logFailsBuild(%q, %q) = %v, want %v", tt.log, tt.failAt, got, tt.expected)
+		}
+	}
+}
+
+func TestNewValidationSummary(t *testing.T) {
+	log := `nogo: warnings found by nogo during build-time code analysis:
+pkg/a.go:2:1: warning: package fmt must not be imported (importfmt)
+pkg/a.go:5:6: note: function must not be named Foo (foofuncname)
+pkg/b.go:3:1: warning: package fmt must not be imported (importfmt)
+`
+	expected := validationSummary{
+		Label:     "//pkg",
+		Findings:  3,
+		HasFixes:  true,
+		Analyzers: map[string]int{"importfmt": 2, "foofuncname": 1},
+		Files:     map[string]int{"pkg/a.go": 2, "pkg/b.go": 1},
+	}
+	if got := newValidationSummary("//pkg", severityError, []byte(log), []byte("--- a/pkg/a.go\n")); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %+v, want %+v", got, expected)
+	}
+
+	skipped := "nogo: 4 analyzers skipped due to type-checking error: a.go:8:10: undefined: x\n"
+	expected = validationSummary{
+		FailsBuild: true,
+		Analyzers:  map[string]int{},
+		Files:      map[string]int{},
+	}
+	if got := newValidationSummary("", severityError, []byte(skipped), nil); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %+v, want %+v", got, expected)
+	}
+}
+
+func TestFormatFixMessage(t *testing.T) {
+	fix := `--- a/pkg/a.go
++++ b/pkg/a.go
+@@ -1,3 +1,3 @@
+-import "fmt"
++import "log"
+@@ -8,3 +8,3 @@
+-fmt.Println()
++log.Println()
+--- a/pkg/b.go
++++ b/pkg/b.go
+@@ -2,3 +2,3 @@
+-import "fmt"
++import "log"
+`
+	if got := formatFixMessage(nil, "pkg.nogo.patch", false, 10); got != "" {
+		t.Errorf("got %q for an empty fix, want no message", got)
+	}
+	for _, maxSize := range []int{0, len(fix)} {
+		if got := formatFixMessage([]byte(fix), "pkg.nogo.patch", false, maxSize); !strings.Contains(got, fix) {
+			t.Errorf("maxSize %d: got:\n%s\nwant the full fix", maxSize, got)
+		}
+	}
+	got := formatFixMessage([]byte(fix), "pkg.nogo.patch", false, len(fix)-1)
+	if strings.Contains(got, "import") {
+		t.Errorf("got:\n%s\nwant the fix to be summarized", got)
+	}
+	for _, want := range []string{
+		"changes 2 files in 3 hunks",
+		"See pkg.nogo.patch, or set NOGO_MAX_FIX_SIZE=0",
+		"$ patch -p1 < pkg.nogo.patch",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("got:\n%s\nwant it to contain %q", got, want)
+		}
+	}
+
+	// The strip level recorded by nogo is used for configured prefixes.
+	unprefixed := "# nogo: strip=0\n--- pkg/a.go\n+++ pkg/a.go\n@@ -1 +1 @@\n-package a\n+package b\n"
+	if got, want := formatFixMessage([]byte(unprefixed), "pkg.nogo.patch", false, 0), "$ patch -p0 < pkg.nogo.patch"; !strings.Contains(got, want) {
+		t.Errorf("got:\n%s\nwant it to contain %q", got, want)
+	}
+
+	// Compressed fix files are decompressed before they are applied.
+	if got, want := formatFixMessage([]byte(fix), "pkg.nogo.patch", true, 0), "$ gunzip -c pkg.nogo.patch | patch -p1"; !strings.Contains(got, want) {
+		t.Errorf("got:\n%s\nwant it to contain %q", got, want)
+	}
+}
+
+func TestWrapInMarkers(t *testing.T) {
+	log := "nogo: errors found by nogo during build-time code analysis:\npkg/a.go:2:1: package fmt must not be imported (importfmt)\n"
+	got, err := wrapInMarkers(string(formatNogoLog([]byte(log), false)), newValidationSummary("//pkg", severityError, []byte(log), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `##[nogo:begin] {"label":"//pkg","findings":1,"fails_build":true,"has_fixes":false,"analyzers":{"importfmt":1},"files":{"pkg/a.go":1}}
+nogo: errors found by nogo during build-time code analysis:
+
+=== importfmt: 1 finding in 1 file
+--- pkg/a.go: 1 finding
+pkg/a.go:2:1: package fmt must not be imported (importfmt)
+
+nogo: 1 finding from 1 analyzer in 1 file
+##[nogo:end] {"label":"//pkg"}`
+	if got != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+func TestParseValidationArgs(t *testing.T) {
+	for _, env := range []string{colorEnv, formatEnv, failAtEnv, maxFixSizeEnv, markersEnv} {
+		t.Setenv(env, "")
+	}
+	want := validationOptions{
+		validationOutput: "out", logFile: "log", fixFile: "fix",
+		color: colorAuto, format: formatPlain, failAt: severityError, label: "//pkg", maxFixSize: defaultMaxFixSize,
+	}
+	for _, args := range [][]string{
+		{"-validation_output=out", "-log=log", "-fix=fix", "-format=plain", "-label=//pkg"},
+		// The positional form of the arguments is still supported.
+		{"-format=plain", "-label=//pkg", "out", "log", "fix"},
+	} {
+		got, err := parseValidationArgs(args)
+		if err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		if got != want {
+			t.Errorf("%v: got %+v, want %+v", args, got, want)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"-log=log", "-fix=fix"},
+		{"-validation_output=out", "-log=log", "-fix=fix", "extra"},
+		{"out", "log"},
+		{"-format=fancy", "out", "log", "fix"},
+		{"-fail_at=fatal", "out", "log", "fix"},
+	} {
+		if _, err := parseValidationArgs(args); err == nil {
+			t.Errorf("%v: expected an error", args)
+		}
+	}
+
+	// info is another name of note.
+	got, err := parseValidationArgs([]string{"-fail_at=info", "out", "log", "fix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.failAt != severityNote {
+		t.Errorf("got fail_at %q, want %q", got.failAt, severityNote)
+	}
+
+	t.Setenv(markersEnv, "true")
+	t.Setenv(maxFixSizeEnv, "0")
+	got, err = parseValidationArgs([]string{"out", "log", "fix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.markers || got.maxFixSize != 0 {
+		t.Errorf("got %+v, want the environment to override the flags", got)
+	}
+}
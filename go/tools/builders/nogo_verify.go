@@ -0,0 +1,107 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// writeFixedSources writes copies of the sources with the fixes applied to
+// dir, which takes the place of the execroot: a source keeps its
+// execroot-relative path below dir, so that the file patterns of the config
+// match the copy like the original. The contents of the sources are taken from
+// contents if it holds them. It returns the names of the copies in the order
+// of srcs and a map from the name of each copy to its original. Sources
+// deleted by the fixes are left out.
+func writeFixedSources(dir, execroot string, srcs []string, contents map[string][]byte, fixes []fileChange, format fixFormatter) ([]string, map[string]string, error) {
+	changes := make(map[string]fileChange)
+	for _, c := range fixes {
+		changes[c.fileName] = c
+	}
+	var fixedSrcs []string
+	originals := make(map[string]string)
+	for i, src := range srcs {
+		content, ok := contents[src]
+		if !ok {
+			var err error
+			if content, err = os.ReadFile(longPath(src)); err != nil {
+				return nil, nil, err
+			}
+		}
+		if c, ok := changes[src]; ok {
+			fixed := fixedContent(content, c, format)
+			if len(fixed) == 0 && len(content) > 0 {
+				continue
+			}
+			content = fixed
+		}
+
+		name := filepath.Clean(src)
+		if filepath.IsAbs(name) {
+			if rel, ok := relPath(execroot, name); ok {
+				name = rel
+			}
+		}
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			// Sources outside of the execroot may have the same base name.
+			name = filepath.Join("_external", strconv.Itoa(i), filepath.Base(src))
+		}
+		fixed := filepath.Join(dir, name)
+		if err := os.MkdirAll(longPath(filepath.Dir(fixed)), 0o777); err != nil {
+			return nil, nil, err
+		}
+		if err := os.WriteFile(longPath(fixed), content, 0o666); err != nil {
+			return nil, nil, err
+		}
+		fixedSrcs = append(fixedSrcs, fixed)
+		originals[fixed] = src
+	}
+	return fixedSrcs, originals, nil
+}
+
+// A diagnosticKey identifies a diagnostic independently of its position, which
+// moves when fixes are applied.
+type diagnosticKey struct {
+	analyzer, file, message string
+}
+
+// unresolvedDiagnostics returns the diagnostics with suggested fixes that are
+// reported as often after the fixes were applied as before. before and after
+// return the name of the original source file for a position in the
+// diagnostics before and after applying the fixes.
+func unresolvedDiagnostics(diagnostics []diagnosticEntry, before func(token.Pos) string, fixedDiagnostics []diagnosticEntry, after func(token.Pos) string) []diagnosticEntry {
+	remaining := make(map[diagnosticKey]int)
+	for _, d := range fixedDiagnostics {
+		remaining[diagnosticKey{d.analyzerName, after(d.Pos), d.Message}]++
+	}
+	for _, d := range diagnostics {
+		remaining[diagnosticKey{d.analyzerName, before(d.Pos), d.Message}]--
+	}
+	var unresolved []diagnosticEntry
+	seen := make(map[diagnosticKey]bool)
+	for _, d := range diagnostics {
+		key := diagnosticKey{d.analyzerName, before(d.Pos), d.Message}
+		if len(d.SuggestedFixes) == 0 || remaining[key] < 0 || seen[key] {
+			continue
+		}
+		seen[key] = true
+		unresolved = append(unresolved, d)
+	}
+	return unresolved
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+func TestWriteFixedSources(t *testing.T) {
+	execroot := t.TempDir()
+	for name, content := range map[string]string{
+		"pkg/a.go": "package pkg\nfunc Foo() {}\n",
+		"pkg/b.go": "package pkg\n",
+	} {
+		path := filepath.Join(execroot, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a := filepath.Join(execroot, "pkg", "a.go")
+	b := filepath.Join(execroot, "pkg", "b.go")
+	fixes := []fileChange{{fileName: a, changes: []nogoEdit{{Start: 17, End: 20, New: "Bar"}}}}
+
+	dir := t.TempDir()
+	fixedSrcs, originals, err := writeFixedSources(dir, execroot, []string{a, b}, nil, fixes, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSrcs := []string{filepath.Join(dir, "pkg", "a.go"), filepath.Join(dir, "pkg", "b.go")}
+	if !reflect.DeepEqual(fixedSrcs, wantSrcs) {
+		t.Fatalf("fixed sources: got %q, want %q", fixedSrcs, wantSrcs)
+	}
+	if originals[wantSrcs[0]] != a || originals[wantSrcs[1]] != b {
+		t.Errorf("originals: got %q", originals)
+	}
+	for i, want := range []string{"package pkg\nfunc Bar() {}\n", "package pkg\n"} {
+		got, err := os.ReadFile(fixedSrcs[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s: got %q, want %q", fixedSrcs[i], got, want)
+		}
+	}
+
+	// A source deleted by the fixes has no copy.
+	fixes = append(fixes, fileChange{fileName: b, changes: []nogoEdit{{Start: 0, End: 12}}})
+	fixedSrcs, _, err = writeFixedSources(t.TempDir(), execroot, []string{a, b}, nil, fixes, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixedSrcs) != 1 || filepath.Base(fixedSrcs[0]) != "a.go" {
+		t.Errorf("fixed sources with b.go deleted: got %q, want only a.go", fixedSrcs)
+	}
+}
+
+func TestUnresolvedDiagnostics(t *testing.T) {
+	fset := token.NewFileSet()
+	f := fset.AddFile("a.go", fset.Base(), 100)
+	fixed := fset.AddFile("fixed/a.go", fset.Base(), 100)
+	before := func(pos token.Pos) string {
+		return fset.Position(pos).Filename
+	}
+	after := func(pos token.Pos) string {
+		return filepath.Base(fset.Position(pos).Filename)
+	}
+	fix := []analysis.SuggestedFix{{Message: "fix it"}}
+	entry := func(file *token.File, offset int, message string, fixes []analysis.SuggestedFix) diagnosticEntry {
+		return diagnosticEntry{
+			Diagnostic:   analysis.Diagnostic{Pos: file.Pos(offset), Message: message, SuggestedFixes: fixes},
+			analyzerName: "analyzer",
+		}
+	}
+
+	diagnostics := []diagnosticEntry{
+		entry(f, 0, "resolved", fix),
+		entry(f, 10, "unresolved", fix),
+		entry(f, 20, "partly resolved", fix),
+		entry(f, 30, "partly resolved", fix),
+		entry(f, 40, "without fix", nil),
+	}
+	fixedDiagnostics := []diagnosticEntry{
+		entry(fixed, 15, "unresolved", nil),
+		entry(fixed, 25, "partly resolved", nil),
+		entry(fixed, 45, "without fix", nil),
+	}
+	var got []string
+	for _, d := range unresolvedDiagnostics(diagnostics, before, fixedDiagnostics, after) {
+		got = append(got, d.Message)
+	}
+	if want := []string{"unresolved"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// nogo_vetjson prints the nogo findings of a build in the format of
+// go vet -json, so that editors and wrappers that parse the output of go vet
+// can consume them.
+//
+// Usage: bazel run @io_bazel_rules_go//go/tools/builders:nogo_vetjson -- findings...
+//
+// findings are diagnostics files written by nogo (see the nogo_diagnostics
+// output group) or directories that are searched for them. Like go vet, it
+// prints a "# package" line followed by a JSON object for each package, which
+// maps the package path to the findings of each analyzer. Relative paths of
+// source files are resolved against the workspace when run with bazel run.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// diagnosticsFileSuffix is the suffix of the diagnostics files declared for
+// each compiled package.
+const diagnosticsFileSuffix = ".nogo.json"
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("nogo_vetjson: ")
+	if err := runVetJSON(os.Args[1:], os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func runVetJSON(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: nogo_vetjson findings...")
+	}
+	// Relative paths are given relative to the directory bazel run was invoked
+	// from, while the paths in the diagnostics files are relative to the
+	// execroot, which mirrors the workspace.
+	cwd := os.Getenv("BUILD_WORKING_DIRECTORY")
+	root := os.Getenv("BUILD_WORKSPACE_DIRECTORY")
+	var reports []savedReport
+	for _, path := range args {
+		if cwd != "" && !filepath.IsAbs(path) {
+			path = filepath.Join(cwd, path)
+		}
+		files, err := loadDiagnosticsReports(path)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, files...)
+	}
+	sort.SliceStable(reports, func(i, j int) bool { return reports[i].Package < reports[j].Package })
+	for _, report := range reports {
+		if err := writeVetJSON(stdout, report, root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadDiagnosticsReports reads a diagnostics file or all such files in a
+// directory tree.
+func loadDiagnosticsReports(path string) ([]savedReport, error) {
+	// bazel-bin and friends are symlinks, which WalkDir doesn't follow.
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		report, err := readDiagnosticsReport(path)
+		if err != nil {
+			return nil, err
+		}
+		return []savedReport{report}, nil
+	}
+	var reports []savedReport
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(p, diagnosticsFileSuffix) {
+			return nil
+		}
+		report, err := readDiagnosticsReport(p)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, report)
+		return nil
+	})
+	return reports, err
+}
+
+// savedReport is a diagnosticsReport as read back from a diagnostics file.
+// encoding/json can't allocate the embedded ranges of diagnosticsReport, so
+// ranges are embedded as values here and missing ranges have no file.
+type savedReport struct {
+	Package     string            `json:"package"`
+	Diagnostics []savedDiagnostic `json:"diagnostics"`
+}
+
+type savedDiagnostic struct {
+	Analyzer string `json:"analyzer"`
+	Category string `json:"category"`
+	Message  string `json:"message"`
+	jsonRange
+	SuggestedFixes []struct {
+		Message string `json:"message"`
+		Edits   []struct {
+			jsonRange
+			NewText string `json:"new_text"`
+		} `json:"edits"`
+	} `json:"suggested_fixes"`
+	Related []struct {
+		jsonRange
+		Message string `json:"message"`
+	} `json:"related"`
+}
+
+func readDiagnosticsReport(path string) (savedReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return savedReport{}, err
+	}
+	var report savedReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return savedReport{}, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return report, nil
+}
+
+// The vet* types mirror the JSON schema of the diagnostics printed by
+// go vet -json, see golang.org/x/tools/go/analysis/internal/analysisflags.
+
+type vetDiagnostic struct {
+	Category       string            `json:"category,omitempty"`
+	Posn           string            `json:"posn"`
+	Message        string            `json:"message"`
+	SuggestedFixes []vetSuggestedFix `json:"suggested_fixes,omitempty"`
+	Related        []vetRelatedInfo  `json:"related,omitempty"`
+}
+
+type vetSuggestedFix struct {
+	Message string        `json:"message"`
+	Edits   []vetTextEdit `json:"edits"`
+}
+
+// A vetTextEdit replaces the bytes from Start to End, which are zero-based
+// offsets, in the file.
+type vetTextEdit struct {
+	Filename string `json:"filename"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+	New      string `json:"new"`
+}
+
+type vetRelatedInfo struct {
+	Posn    string `json:"posn"`
+	Message string `json:"message"`
+}
+
+// writeVetJSON writes the findings of a package like go vet -json. Relative
+// paths are joined to root unless it is empty.
+func writeVetJSON(w io.Writer, report savedReport, root string) error {
+	filename := func(r jsonRange) string {
+		if root == "" || filepath.IsAbs(r.File) {
+			return r.File
+		}
+		return filepath.Join(root, r.File)
+	}
+	posn := func(r jsonRange) string {
+		if r.File == "" {
+			// The position of diagnostics without one, as printed by go vet.
+			return "-"
+		}
+		return filename(r) + ":" + strconv.Itoa(r.Line) + ":" + strconv.Itoa(r.Column)
+	}
+
+	analyzers := make(map[string][]vetDiagnostic)
+	for _, d := range report.Diagnostics {
+		vd := vetDiagnostic{Category: d.Category, Posn: posn(d.jsonRange), Message: d.Message}
+		for _, fix := range d.SuggestedFixes {
+			vf := vetSuggestedFix{Message: fix.Message, Edits: []vetTextEdit{}}
+			for _, edit := range fix.Edits {
+				if edit.File == "" {
+					continue
+				}
+				vf.Edits = append(vf.Edits, vetTextEdit{
+					Filename: filename(edit.jsonRange),
+					Start:    edit.Offset,
+					End:      edit.EndOffset,
+					New:      edit.NewText,
+				})
+			}
+			vd.SuggestedFixes = append(vd.SuggestedFixes, vf)
+		}
+		for _, rel := range d.Related {
+			vd.Related = append(vd.Related, vetRelatedInfo{Posn: posn(rel.jsonRange), Message: rel.Message})
+		}
+		analyzers[d.Analyzer] = append(analyzers[d.Analyzer], vd)
+	}
+	tree := map[string]map[string][]vetDiagnostic{}
+	if len(analyzers) > 0 {
+		tree[report.Package] = analyzers
+	}
+	data, err := json.MarshalIndent(tree, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "# %s\n%s\n", report.Package, data)
+	return err
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunVetJSON(t *testing.T) {
+	dir := t.TempDir()
+	writeReport := func(name string, report diagnosticsReport) {
+		var buf bytes.Buffer
+		if err := writeDiagnosticsReport(&buf, report); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeReport("b.nogo.json", diagnosticsReport{Package: "example.com/b"})
+	writeReport("a.nogo.json", diagnosticsReport{
+		Package: "example.com/a",
+		Diagnostics: []jsonDiagnostic{
+			{
+				Analyzer:  "printf",
+				Category:  "format",
+				Message:   "bad format",
+				jsonRange: &jsonRange{File: "a/a.go", Line: 3, Column: 2, Offset: 20, EndLine: 3, EndColumn: 5, EndOffset: 23},
+				SuggestedFixes: []jsonSuggestedFix{{
+					Message: "fix format",
+					Edits: []jsonTextEdit{{
+						jsonRange: &jsonRange{File: "a/a.go", Line: 3, Column: 2, Offset: 20, EndLine: 3, EndColumn: 5, EndOffset: 23},
+						NewText:   "%d",
+					}},
+				}},
+				Related: []jsonRelatedInfo{{
+					jsonRange: &jsonRange{File: "a/b.go", Line: 1, Column: 1},
+					Message:   "declared here",
+				}},
+			},
+			{Analyzer: "timeout", Message: "analyzer timed out"},
+		},
+	})
+	if err := os.WriteFile(filepath.Join(dir, "a.nogo.xml"), []byte("<problems/>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("BUILD_WORKING_DIRECTORY", "")
+	t.Setenv("BUILD_WORKSPACE_DIRECTORY", "/ws")
+
+	var stdout bytes.Buffer
+	if err := runVetJSON([]string{dir}, &stdout); err != nil {
+		t.Fatal(err)
+	}
+	ws := func(path string) string { return filepath.Join("/ws", path) }
+	want := `# example.com/a
+{
+	"example.com/a": {
+		"printf": [
+			{
+				"category": "format",
+				"posn": "` + ws("a/a.go") + `:3:2",
+				"message": "bad format",
+				"suggested_fixes": [
+					{
+						"message": "fix format",
+						"edits": [
+							{
+								"filename": "` + ws("a/a.go") + `",
+								"start": 20,
+								"end": 23,
+								"new": "%d"
+							}
+						]
+					}
+				],
+				"related": [
+					{
+						"posn": "` + ws("a/b.go") + `:1:1",
+						"message": "declared here"
+					}
+				]
+			}
+		],
+		"timeout": [
+			{
+				"posn": "-",
+				"message": "analyzer timed out"
+			}
+		]
+	}
+}
+# example.com/b
+{}
+`
+	if got := stdout.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/gcexportdata"
+)
+
+// serveNogoWorker runs nogo as a persistent worker of the builder, which sends
+// it a work request for each package. The state that doesn't depend on the
+// package stays warm between requests: the analyzers and the config are
+// initialized once, the facts of the standard library are read once and its
+// export data is decoded once, see stdlibExportCache.
+func serveNogoWorker() error {
+	// Anything the analyzers print must not end up in the responses.
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	stdlibExports = &stdlibExportCache{}
+	allExternalAnalyzers := externalAnalyzers
+	return serveWorker(os.Stdin, stdout, func(args []string, output io.Writer) int {
+		log.SetOutput(output)
+		defer log.SetOutput(os.Stderr)
+		// Reset the state that run changes for a single package.
+		nogoMetrics = nil
+		analyzerSlots = make(chan struct{}, runtime.GOMAXPROCS(0))
+		externalAnalyzers = allExternalAnalyzers
+		if err, exitCode := run(args); err != nil {
+			log.Print(err)
+			return exitCode
+		}
+		return nogoSuccess
+	})
+}
+
+// stdlibExports caches the export data of the standard library if nogo runs
+// as a persistent worker. It is nil otherwise.
+var stdlibExports *stdlibExportCache
+
+// maxWorkerFileSetBase bounds the size of the file set shared by the packages
+// that a persistent worker analyzes, since the files of each package are added
+// to it. The cache is dropped once it grows larger.
+const maxWorkerFileSetBase = 1 << 28
+
+// A stdlibExportCache holds the packages of the standard library decoded from
+// their export data, positioned in a file set shared by the packages that nogo
+// analyzes one after another as a worker. The packages of the standard
+// library only reference each other, so they can be shared as long as every
+// package they reference is decoded as well: importing another package may
+// then only add packages to those of the cache and never complete one in it.
+type stdlibExportCache struct {
+	fset     *token.FileSet
+	packages map[string]*types.Package
+	archives map[string]archiveStamp // keyed by package path
+	// disabled is set once the export data can't be decoded, so that it isn't
+	// decoded twice for every package.
+	disabled bool
+}
+
+// An archiveStamp identifies the contents of an archive with export data.
+type archiveStamp struct {
+	path    string
+	size    int64
+	modTime int64
+}
+
+func statArchive(path string) (archiveStamp, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return archiveStamp{}, err
+	}
+	return archiveStamp{path: path, size: info.Size(), modTime: info.ModTime().UnixNano()}, nil
+}
+
+// importerState returns the file set and the imported packages to start the
+// importer of a package with, given the archives of its imports.
+func (c *stdlibExportCache) importerState(packageFile map[string]string) (*token.FileSet, map[string]*types.Package) {
+	if !c.disabled {
+		if err := c.update(packageFile); err != nil {
+			log.New(os.Stderr, "nogo: ", 0).Printf("not caching the export data of the standard library: %v", err)
+			c.reset()
+			c.disabled = true
+		}
+	}
+	if c.disabled {
+		return token.NewFileSet(), make(map[string]*types.Package)
+	}
+	packages := make(map[string]*types.Package, len(c.packages))
+	for path, pkg := range c.packages {
+		packages[path] = pkg
+	}
+	return c.fset, packages
+}
+
+func (c *stdlibExportCache) reset() {
+	c.fset, c.packages, c.archives = nil, nil, nil
+}
+
+// update decodes the packages of the standard library among the imports and
+// those they reference that aren't cached yet, after dropping the cache if
+// one of its archives changed.
+func (c *stdlibExportCache) update(packageFile map[string]string) error {
+	goroot := os.Getenv("GOROOT")
+	if goroot == "" {
+		return errors.New("GOROOT is not set")
+	}
+	// The archives of the standard library are named after their package path
+	// in a directory for the configuration, e.g. pkg/linux_amd64.
+	pkgDir := filepath.Join(abs(goroot), "pkg") + string(filepath.Separator)
+	stdlib := make(map[string]string)
+	libDir := ""
+	for path, archive := range packageFile {
+		if !strings.HasPrefix(archive, pkgDir) {
+			continue
+		}
+		stdlib[path] = archive
+		if dir := strings.TrimSuffix(archive, filepath.FromSlash(path)+".a"); dir != archive {
+			libDir = dir
+		}
+	}
+
+	if c.fset != nil && c.fset.Base() > maxWorkerFileSetBase {
+		c.reset()
+	}
+	for path, stamp := range c.archives {
+		if archive, ok := stdlib[path]; ok && archive != stamp.path {
+			// The package is built in another configuration.
+			c.reset()
+			break
+		}
+		if current, err := statArchive(stamp.path); err != nil || current != stamp {
+			c.reset()
+			break
+		}
+	}
+	if c.fset == nil {
+		c.fset = token.NewFileSet()
+		c.packages = make(map[string]*types.Package)
+		c.archives = make(map[string]archiveStamp)
+	}
+
+	var missing []string
+	for path := range stdlib {
+		if pkg := c.packages[path]; pkg == nil || !pkg.Complete() {
+			missing = append(missing, path)
+		}
+	}
+	sort.Strings(missing)
+	for _, path := range missing {
+		if err := c.decode(path, stdlib[path]); err != nil {
+			return err
+		}
+	}
+	for {
+		var incomplete []string
+		for path, pkg := range c.packages {
+			if !pkg.Complete() {
+				incomplete = append(incomplete, path)
+			}
+		}
+		if len(incomplete) == 0 {
+			return nil
+		}
+		if libDir == "" {
+			return fmt.Errorf("no archive of the standard library among the imports to find the one of %s", incomplete[0])
+		}
+		sort.Strings(incomplete)
+		for _, path := range incomplete {
+			if err := c.decode(path, filepath.Join(libDir, filepath.FromSlash(path)+".a")); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (c *stdlibExportCache) decode(path, archive string) error {
+	stamp, err := statArchive(archive)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := gcexportdata.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("reading export data: %s: %v", archive, err)
+	}
+	pkg, err := gcexportdata.Read(r, c.fset, c.packages, path)
+	if err != nil {
+		return fmt.Errorf("reading export data: %s: %v", archive, err)
+	}
+	if !pkg.Complete() {
+		return fmt.Errorf("reading export data: %s: %s is incomplete", archive, path)
+	}
+	c.archives[path] = stamp
+	return nil
+}
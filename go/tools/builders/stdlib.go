@@ -35,10 +35,15 @@ func stdlib(args []string) error {
 	shared := flags.Bool("shared", false, "Build in shared mode")
 	dynlink := flags.Bool("dynlink", false, "Build in dynlink mode")
 	pgoprofile := flags.String("pgoprofile", "", "Build with pgo using the given pprof file")
+	prebuilt := flags.String("prebuilt", "", "Path to a prebuilt standard library to use if it was built in the same configuration")
 	var packages multiFlag
 	flags.Var(&packages, "package", "Packages to build")
 	var gcflags quoteMultiFlag
 	flags.Var(&gcflags, "gcflags", "Go compiler flags")
+	var packageGcflags multiFlag
+	flags.Var(&packageGcflags, "package_gcflags", "Go compiler flags for the packages matching a pattern, as pattern=flags")
+	var spectre spectreFlag
+	flags.Var(&spectre, "spectre", "Comma-separated list of Spectre mitigations to enable in the compiler and assembler")
 	if err := flags.Parse(args); err != nil {
 		return err
 	}
@@ -50,6 +55,9 @@ func stdlib(args []string) error {
 		return fmt.Errorf("GOROOT not set")
 	}
 	output := abs(*out)
+	if goenv.compiler == "gccgo" {
+		return stdlibGccgo(output, *race, *msan, gcflags, packageGcflags, spectre, *pgoprofile)
+	}
 
 	// Fail fast if cgo is required but a toolchain is not configured.
 	if os.Getenv("CGO_ENABLED") == "1" && filepath.Base(os.Getenv("CC")) == "vc_installation_error.bat" {
@@ -57,16 +65,36 @@ func stdlib(args []string) error {
 You may need to use the flags --cpu=x64_windows --compiler=mingw-gcc.`)
 	}
 
+	var pgoprofilePath string
+	if *pgoprofile != "" {
+		pgoprofilePath = abs(*pgoprofile)
+	}
+	key, err := newStdlibKey(goroot, packages, gcflags, packageGcflags, spectre, *race, *msan, *shared, *dynlink, pgoprofilePath)
+	if err != nil {
+		return err
+	}
+
 	// Link in the bare minimum needed to the new GOROOT
 	if err := replicate(goroot, output, replicatePaths("src", "pkg/tool", "pkg/include")); err != nil {
 		return err
 	}
 
-	output, err := processPath(output)
+	output, err = processPath(output)
 	if err != nil {
 		return err
 	}
 
+	if *prebuilt != "" {
+		err := extractPrebuiltStdlib(abs(*prebuilt), output, key)
+		if err == nil {
+			return nil
+		}
+		if err != errStdlibKeyMismatch {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "%s: %v, building the standard library from source\n", *prebuilt, err)
+	}
+
 	// Now switch to the newly created GOROOT
 	os.Setenv("GOROOT", output)
 
@@ -135,7 +163,7 @@ You may need to use the flags --cpu=x64_windows --compiler=mingw-gcc.`)
 		installArgs = append(installArgs, "-msan")
 	}
 	if *pgoprofile != "" {
-		gcflags = append(gcflags, "-pgoprofile=" + abs(*pgoprofile))
+		gcflags = append(gcflags, "-pgoprofile=" + pgoprofilePath)
 	}
 	if *shared {
 		gcflags = append(gcflags, "-shared")
@@ -147,6 +175,8 @@ You may need to use the flags --cpu=x64_windows --compiler=mingw-gcc.`)
 		ldflags = append(ldflags, "-dynlink")
 		asmflags = append(asmflags, "-dynlink")
 	}
+	gcflags = append(gcflags, spectre.gcflags()...)
+	asmflags = append(asmflags, spectre.asmflags()...)
 
 	// Since Go 1.10, an all= prefix indicates the flags should apply to the package
 	// and its dependencies, rather than just the package itself. This was the
@@ -159,6 +189,15 @@ You may need to use the flags --cpu=x64_windows --compiler=mingw-gcc.`)
 		}
 	}
 	installArgs = append(installArgs, "-gcflags="+allSlug+strings.Join(gcflags, " "))
+	// The go command only applies the flags of the last pattern that matches a
+	// package, so those of all packages are repeated for each pattern.
+	for _, entry := range packageGcflags {
+		i := strings.Index(entry, "=")
+		if i <= 0 {
+			return fmt.Errorf("-package_gcflags %q doesn't have the form pattern=flags", entry)
+		}
+		installArgs = append(installArgs, "-gcflags="+entry[:i]+"="+strings.Join(append(gcflags[:len(gcflags):len(gcflags)], entry[i+1:]), " "))
+	}
 	installArgs = append(installArgs, "-ldflags="+allSlug+strings.Join(ldflags, " "))
 	installArgs = append(installArgs, "-asmflags="+allSlug+strings.Join(asmflags, " "))
 
@@ -170,5 +209,5 @@ You may need to use the flags --cpu=x64_windows --compiler=mingw-gcc.`)
 	if err := goenv.runCommand(installArgs); err != nil {
 		return err
 	}
-	return nil
+	return writeStdlibKey(output, key)
 }
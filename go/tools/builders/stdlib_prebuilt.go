@@ -0,0 +1,395 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This file implements prebuilt standard libraries: archives of a standard
+// library built by the stdlib action, which later stdlib actions extract
+// instead of compiling the standard library again if it was built for the
+// same configuration.
+
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go/build"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// stdlibKeyFile is the slash-separated path of the file in the root directory
+// of a standard library built by the stdlib action that holds its stdlibKey.
+// Only the pkg directory is an output of the action.
+const stdlibKeyFile = "pkg/stdlib_key.json"
+
+// stdlibKeyEnvVars are the environment variables that affect how the
+// standard library is compiled.
+var stdlibKeyEnvVars = []string{
+	"CGO_ENABLED",
+	"GO386",
+	"GOAMD64",
+	"GOARCH",
+	"GOARM",
+	"GOARM64",
+	"GOEXPERIMENT",
+	"GOMIPS",
+	"GOMIPS64",
+	"GOOS",
+	"GOPPC64",
+	"GORISCV64",
+	"GOWASM",
+}
+
+// stdlibKeyCgoEnvVars are the environment variables that affect how the
+// standard library is compiled if cgo is enabled. The stdlib action appends
+// flags that depend on its output directory to CGO_CFLAGS and CGO_LDFLAGS
+// later, so those are left out.
+var stdlibKeyCgoEnvVars = []string{
+	"CGO_CFLAGS",
+	"CGO_CPPFLAGS",
+	"CGO_CXXFLAGS",
+	"CGO_LDFLAGS",
+}
+
+// A stdlibKey describes the configuration that a standard library is built
+// in. A prebuilt standard library can be reused if its key is equal to the
+// key of the standard library to build.
+type stdlibKey struct {
+	GoVersion      string
+	CC             string            `json:",omitempty"`
+	Env            map[string]string `json:",omitempty"`
+	Tags           []string          `json:",omitempty"`
+	Race           bool              `json:",omitempty"`
+	Msan           bool              `json:",omitempty"`
+	Shared         bool              `json:",omitempty"`
+	Dynlink        bool              `json:",omitempty"`
+	Gcflags        []string          `json:",omitempty"`
+	PackageGcflags []string          `json:",omitempty"`
+	Spectre        []string          `json:",omitempty"`
+	PGOProfile     string            `json:",omitempty"`
+	Packages       []string
+}
+
+// newStdlibKey returns the key of the standard library of the SDK in goroot
+// built with the given options, the build tags of the builder and the
+// environment. The PGO profile is identified by the hash of its content, and
+// the C compiler in $CC, if cgo is enabled, by what it reports about itself.
+func newStdlibKey(goroot string, packages, gcflags, packageGcflags, spectre []string, race, msan, shared, dynlink bool, pgoprofile string) (stdlibKey, error) {
+	version, err := readGoVersion(goroot)
+	if err != nil {
+		return stdlibKey{}, err
+	}
+	key := stdlibKey{
+		GoVersion:      version,
+		Env:            map[string]string{},
+		Tags:           append([]string{}, build.Default.BuildTags...),
+		Race:           race,
+		Msan:           msan,
+		Shared:         shared,
+		Dynlink:        dynlink,
+		Gcflags:        gcflags,
+		PackageGcflags: packageGcflags,
+		Spectre:        append([]string{}, spectre...),
+		Packages:       append([]string{}, packages...),
+	}
+	sort.Strings(key.Tags)
+	sort.Strings(key.Spectre)
+	sort.Strings(key.Packages)
+	for _, name := range stdlibKeyEnvVars {
+		if value := os.Getenv(name); value != "" {
+			key.Env[name] = value
+		}
+	}
+	if key.Env["CGO_ENABLED"] == "1" {
+		for _, name := range stdlibKeyCgoEnvVars {
+			if value := os.Getenv(name); value != "" {
+				key.Env[name] = value
+			}
+		}
+		if key.CC, err = cCompilerIdentity(os.Getenv("CC")); err != nil {
+			return stdlibKey{}, err
+		}
+	}
+	if pgoprofile != "" {
+		data, err := os.ReadFile(pgoprofile)
+		if err != nil {
+			return stdlibKey{}, err
+		}
+		sum := sha256.Sum256(data)
+		key.PGOProfile = hex.EncodeToString(sum[:])
+	}
+	return key, nil
+}
+
+// cCompilerIdentity returns the target and the version of the C compiler
+// command cc, which may include arguments. The path of the compiler isn't part
+// of it, since it differs between machines with the same toolchain. Only the
+// first line of the version is used, as compilers like clang print their
+// installation directory after it.
+func cCompilerIdentity(cc string) (string, error) {
+	args, err := splitQuoted(cc)
+	if err != nil {
+		return "", err
+	}
+	if len(args) == 0 {
+		return "", errors.New("cgo is enabled, but CC is not set")
+	}
+	if strings.ContainsAny(args[0], `/\`) {
+		args[0] = abs(args[0])
+	}
+	var identity []string
+	for _, flag := range []string{"-dumpmachine", "--version"} {
+		cmd := exec.Command(args[0], append(args[1:len(args):len(args)], flag)...)
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("running %s %s: %v", cc, flag, err)
+		}
+		line := string(out)
+		if i := strings.IndexByte(line, '\n'); i >= 0 {
+			line = line[:i]
+		}
+		identity = append(identity, strings.TrimSpace(line))
+	}
+	return strings.Join(identity, "; "), nil
+}
+
+// readGoVersion returns the version of the SDK in goroot, like "go1.22.1".
+func readGoVersion(goroot string) (string, error) {
+	f, err := os.Open(filepath.Join(goroot, "VERSION"))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("%s is empty", f.Name())
+	}
+	return strings.TrimSpace(scanner.Text()), nil
+}
+
+func (k stdlibKey) encode() ([]byte, error) {
+	return json.MarshalIndent(k, "", "  ")
+}
+
+// writeStdlibKey records the key of the standard library built in root.
+func writeStdlibKey(root string, key stdlibKey) error {
+	data, err := key.encode()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(root, filepath.FromSlash(stdlibKeyFile)), data, 0o666)
+}
+
+// isPrebuiltStdlibFile reports whether the file at the slash-separated path
+// rel in the root directory of a standard library built by the stdlib action
+// belongs in a prebuilt standard library. The tools and headers of the SDK are
+// linked into the pkg directory, so they are left out.
+func isPrebuiltStdlibFile(rel string) bool {
+	return strings.HasPrefix(rel, "pkg/") && !strings.HasPrefix(rel, "pkg/tool/") && !strings.HasPrefix(rel, "pkg/include/")
+}
+
+// writePrebuiltStdlib writes the standard library built in root to a gzipped
+// tar file. The archive doesn't depend on when and where the standard library
+// was built, so equal standard libraries produce identical archives.
+func writePrebuiltStdlib(w io.Writer, root string) error {
+	if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(stdlibKeyFile))); err != nil {
+		return fmt.Errorf("%s was not built by the stdlib action: %v", root, err)
+	}
+	var files []string
+	err := filepath.Walk(filepath.Join(root, "pkg"), func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if info.IsDir() {
+			if rel == "pkg/tool" || rel == "pkg/include" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() && isPrebuiltStdlibFile(rel) {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// The key comes first so that readers can validate it before extracting
+	// anything.
+	sort.Slice(files, func(i, j int) bool {
+		if files[i] == stdlibKeyFile || files[j] == stdlibKeyFile {
+			return files[i] == stdlibKeyFile
+		}
+		return files[i] < files[j]
+	})
+
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+	for _, rel := range files {
+		if err := addPrebuiltStdlibFile(tw, filepath.Join(root, filepath.FromSlash(rel)), rel); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func addPrebuiltStdlibFile(tw *tar.Writer, p, name string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0o644,
+		Size:     info.Size(),
+		ModTime:  time.Unix(0, 0),
+		Format:   tar.FormatPAX,
+	}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// errStdlibKeyMismatch is returned by extractPrebuiltStdlib if the prebuilt
+// standard library was built in a different configuration.
+var errStdlibKeyMismatch = errors.New("the prebuilt standard library was built in a different configuration")
+
+// extractPrebuiltStdlib extracts the prebuilt standard library in the archive
+// at archivePath into root if it was built with the given key. Otherwise, it
+// returns errStdlibKeyMismatch without extracting anything.
+func extractPrebuiltStdlib(archivePath, root string, key stdlibKey) error {
+	want, err := key.encode()
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("%s: %v", archivePath, err)
+	}
+	tr := tar.NewReader(zr)
+	for i := 0; ; i++ {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			if i == 0 {
+				return fmt.Errorf("%s: empty archive", archivePath)
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", archivePath, err)
+		}
+		name := path.Clean(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || !isPrebuiltStdlibFile(name) || name != hdr.Name {
+			return fmt.Errorf("%s: unexpected entry %q", archivePath, hdr.Name)
+		}
+		if i == 0 {
+			if name != stdlibKeyFile {
+				return fmt.Errorf("%s: the archive doesn't start with %s", archivePath, stdlibKeyFile)
+			}
+			got, err := io.ReadAll(tr)
+			if err != nil {
+				return fmt.Errorf("%s: %v", archivePath, err)
+			}
+			if !bytes.Equal(got, want) {
+				return errStdlibKeyMismatch
+			}
+		}
+		dst := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return err
+		}
+		if i == 0 {
+			err = os.WriteFile(dst, want, 0o666)
+		} else {
+			err = extractFile(tr, dst)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func extractFile(r io.Reader, dst string) error {
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, r)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// stdlibArchive writes the standard library built by a stdlib action to an
+// archive that can be passed to the stdlib action of other builds with
+// -prebuilt.
+func stdlibArchive(args []string) error {
+	flags := flag.NewFlagSet("stdlibarchive", flag.ExitOnError)
+	goenv := envFlags(flags)
+	root := flags.String("root", "", "Path to the go root built by the stdlib action")
+	out := flags.String("out", "", "Path to the archive to write")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := goenv.checkFlagsAndSetGoroot(); err != nil {
+		return err
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := writePrebuiltStdlib(f, *root); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func writeTestStdlib(t *testing.T, key stdlibKey) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"pkg/linux_amd64/fmt.a":          "fmt",
+		"pkg/linux_amd64/net/http.a":     "http",
+		"pkg/linux_amd64_race/runtime.a": "runtime",
+		"pkg/tool/linux_amd64/compile":   "compile",
+		"pkg/include/textflag.h":         "textflag",
+		"src/fmt/print.go":               "package fmt",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writeStdlibKey(root, key); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestPrebuiltStdlib(t *testing.T) {
+	key := stdlibKey{
+		GoVersion: "go1.22.1",
+		Env:       map[string]string{"GOOS": "linux", "GOARCH": "amd64"},
+		Tags:      []string{"foo"},
+		Packages:  []string{"runtime/cgo", "std"},
+	}
+	root := writeTestStdlib(t, key)
+
+	var buf bytes.Buffer
+	if err := writePrebuiltStdlib(&buf, root); err != nil {
+		t.Fatal(err)
+	}
+	// Archives of equal standard libraries are identical.
+	var again bytes.Buffer
+	if err := writePrebuiltStdlib(&again, root); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), again.Bytes()) {
+		t.Error("writing the same standard library twice produced different archives")
+	}
+	archive := filepath.Join(t.TempDir(), "stdlib.tar.gz")
+	if err := os.WriteFile(archive, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("same configuration", func(t *testing.T) {
+		out := t.TempDir()
+		if err := extractPrebuiltStdlib(archive, out, key); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{stdlibKeyFile, "pkg/linux_amd64/fmt.a", "pkg/linux_amd64/net/http.a", "pkg/linux_amd64_race/runtime.a"} {
+			want, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(filepath.Join(out, filepath.FromSlash(name)))
+			if err != nil {
+				t.Error(err)
+			} else if !bytes.Equal(got, want) {
+				t.Errorf("%s: got %q, want %q", name, got, want)
+			}
+		}
+		for _, name := range []string{"pkg/tool", "pkg/include", "src"} {
+			if _, err := os.Stat(filepath.Join(out, filepath.FromSlash(name))); !os.IsNotExist(err) {
+				t.Errorf("%s was extracted from the archive", name)
+			}
+		}
+	})
+
+	t.Run("different configuration", func(t *testing.T) {
+		out := t.TempDir()
+		other := key
+		other.Race = true
+		if err := extractPrebuiltStdlib(archive, out, other); err != errStdlibKeyMismatch {
+			t.Fatalf("got error %v, want %v", err, errStdlibKeyMismatch)
+		}
+		if entries, err := os.ReadDir(out); err != nil {
+			t.Fatal(err)
+		} else if len(entries) > 0 {
+			t.Errorf("extracted %d files from a mismatching archive", len(entries))
+		}
+	})
+}
+
+func TestPrebuiltStdlibRejectsUnexpectedEntries(t *testing.T) {
+	key := stdlibKey{GoVersion: "go1.22.1"}
+	keyData, err := key.encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"pkg/../../escape.a", "src/fmt/print.go", "/pkg/linux_amd64/fmt.a"} {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(zw)
+		for _, f := range []struct {
+			name string
+			data []byte
+		}{{stdlibKeyFile, keyData}, {name, []byte("x")}} {
+			if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: f.name, Mode: 0o644, Size: int64(len(f.data))}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tw.Write(f.data); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		archive := filepath.Join(t.TempDir(), "stdlib.tar.gz")
+		if err := os.WriteFile(archive, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := extractPrebuiltStdlib(archive, t.TempDir(), key); err == nil {
+			t.Errorf("extracting an archive with %q succeeded", name)
+		}
+	}
+}
+
+func TestStdlibKeyCgo(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake C compilers are shell scripts")
+	}
+	goroot := t.TempDir()
+	if err := os.WriteFile(filepath.Join(goroot, "VERSION"), []byte("go1.22.1\ntime 2024-03-05T22:48:24Z\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	writeCC := func(name, target, version string) string {
+		path := filepath.Join(dir, name)
+		script := "#!/bin/sh\nif [ \"$1\" = -dumpmachine ]; then echo " + target + "; else echo '" + version + "'; echo InstalledDir: " + dir + "; fi\n"
+		if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	gnuCC := writeCC("gnu-cc", "x86_64-linux-gnu", "cc (GCC) 13.2.0")
+	muslCC := writeCC("musl-cc", "x86_64-linux-musl", "cc (GCC) 13.2.0")
+	otherGnuCC := writeCC("other-cc", "x86_64-linux-gnu", "cc (GCC) 13.2.0")
+
+	newKey := func(env map[string]string) stdlibKey {
+		t.Helper()
+		for _, name := range append([]string{"CC", "CGO_ENABLED"}, stdlibKeyCgoEnvVars...) {
+			t.Setenv(name, env[name])
+		}
+		key, err := newStdlibKey(goroot, []string{"std"}, nil, nil, nil, false, false, false, false, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	gnuKey := newKey(map[string]string{"CGO_ENABLED": "1", "CC": gnuCC})
+	if want := "x86_64-linux-gnu; cc (GCC) 13.2.0"; gnuKey.CC != want {
+		t.Errorf("got C compiler %q, want %q", gnuKey.CC, want)
+	}
+	archive := filepath.Join(t.TempDir(), "stdlib.tar.gz")
+	var buf bytes.Buffer
+	if err := writePrebuiltStdlib(&buf, writeTestStdlib(t, gnuKey)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(archive, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		desc      string
+		env       map[string]string
+		wantMatch bool
+	}{
+		{
+			desc:      "same toolchain at another path",
+			env:       map[string]string{"CGO_ENABLED": "1", "CC": otherGnuCC},
+			wantMatch: true,
+		},
+		{
+			desc: "different C compiler",
+			env:  map[string]string{"CGO_ENABLED": "1", "CC": muslCC},
+		},
+		{
+			desc: "different cgo flags",
+			env:  map[string]string{"CGO_ENABLED": "1", "CC": gnuCC, "CGO_CFLAGS": "-march=native"},
+		},
+		{
+			desc: "cgo disabled",
+			env:  map[string]string{"CGO_ENABLED": "0", "CC": gnuCC},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := extractPrebuiltStdlib(archive, t.TempDir(), newKey(tc.env))
+			if tc.wantMatch && err != nil {
+				t.Errorf("got error %v, want a match", err)
+			} else if !tc.wantMatch && err != errStdlibKeyMismatch {
+				t.Errorf("got error %v, want %v", err, errStdlibKeyMismatch)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// persistentWorkerFlag is appended by Bazel to the arguments of a tool it
+// starts as a persistent worker.
+const persistentWorkerFlag = "--persistent_worker"
+
+// workRequest and workResponse are the messages of the JSON variant of the
+// Bazel persistent worker protocol, see
+// https://bazel.build/remote/creating#work-request. Fields that the tools in
+// this directory don't use are omitted.
+type workRequest struct {
+	Arguments []string `json:"arguments"`
+	RequestID int      `json:"requestId,omitempty"`
+}
+
+type workResponse struct {
+	ExitCode  int    `json:"exitCode"`
+	Output    string `json:"output"`
+	RequestID int    `json:"requestId,omitempty"`
+}
+
+// persistentWorkerArgs returns the startup arguments of a persistent worker
+// without persistentWorkerFlag, or false if the tool was not started as one.
+func persistentWorkerArgs(args []string) ([]string, bool) {
+	for i, arg := range args {
+		if arg == persistentWorkerFlag {
+			return append(args[:i:i], args[i+1:]...), true
+		}
+	}
+	return args, false
+}
+
+// expandWorkerFlagFile replaces the flagfile that ends the arguments of an
+// action supporting workers with its contents. Bazel passes the arguments of
+// the work requests in it, one per line, which it expands itself when the
+// action runs on a worker but not when it runs as a regular process.
+func expandWorkerFlagFile(args []string) ([]string, error) {
+	if len(args) == 0 || !strings.HasPrefix(args[len(args)-1], "@") {
+		return args, nil
+	}
+	data, err := os.ReadFile(args[len(args)-1][1:])
+	if err != nil {
+		return nil, err
+	}
+	expanded := args[: len(args)-1 : len(args)-1]
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSuffix(line, "\r"); line != "" {
+			expanded = append(expanded, line)
+		}
+	}
+	return expanded, nil
+}
+
+// serveWorker handles work requests read from in until it is closed. handle
+// is called with the arguments of each request and returns the exit code of
+// the request after writing its output, e.g. the logs, to output. Requests are
+// handled one at a time.
+func serveWorker(in io.Reader, out io.Writer, handle func(args []string, output io.Writer) int) error {
+	dec := json.NewDecoder(in)
+	enc := json.NewEncoder(out)
+	for {
+		var req workRequest
+		if err := dec.Decode(&req); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("reading work request: %v", err)
+		}
+		var output bytes.Buffer
+		resp := workResponse{RequestID: req.RequestID}
+		resp.ExitCode = handle(req.Arguments, &output)
+		resp.Output = output.String()
+		if err := enc.Encode(resp); err != nil {
+			return fmt.Errorf("writing work response: %v", err)
+		}
+	}
+}
+
+// serveMultiplexWorker is like serveWorker, but handles the requests
+// concurrently as Bazel sends them to a multiplex worker, which tells them
+// apart by their request IDs. The responses are written as the requests
+// finish, so handle must be safe for concurrent use.
+func serveMultiplexWorker(in io.Reader, out io.Writer, handle func(args []string, output io.Writer) int) error {
+	dec := json.NewDecoder(in)
+	enc := json.NewEncoder(out)
+	var wg sync.WaitGroup
+	var mu sync.Mutex // guards enc and writeErr
+	var writeErr error
+	var readErr error
+	for {
+		var req workRequest
+		if err := dec.Decode(&req); err == io.EOF {
+			break
+		} else if err != nil {
+			readErr = fmt.Errorf("reading work request: %v", err)
+			break
+		}
+		wg.Add(1)
+		go func(req workRequest) {
+			defer wg.Done()
+			var output bytes.Buffer
+			resp := workResponse{RequestID: req.RequestID}
+			resp.ExitCode = handle(req.Arguments, &output)
+			resp.Output = output.String()
+			mu.Lock()
+			defer mu.Unlock()
+			if writeErr == nil {
+				if err := enc.Encode(resp); err != nil {
+					writeErr = fmt.Errorf("writing work response: %v", err)
+				}
+			}
+		}(req)
+	}
+	// Answer the requests that are still running before exiting.
+	wg.Wait()
+	if readErr != nil {
+		return readErr
+	}
+	return writeErr
+}
+
+// A workerProcess is a tool started as a persistent worker, which is sent
+// work requests with the protocol served by serveWorker.
+type workerProcess struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	enc   *json.Encoder
+	dec   *json.Decoder
+}
+
+// startWorkerProcess starts path with args as a persistent worker. The logs of
+// the worker that aren't part of a response go to the stderr of the caller.
+func startWorkerProcess(path string, args ...string) (*workerProcess, error) {
+	cmd := exec.Command(path, append(args, persistentWorkerFlag)...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &workerProcess{
+		cmd:   cmd,
+		stdin: stdin,
+		enc:   json.NewEncoder(stdin),
+		dec:   json.NewDecoder(stdout),
+	}, nil
+}
+
+// call sends a work request with args to the worker and waits for its
+// response. An error means that the worker can't be used anymore.
+func (w *workerProcess) call(args []string) (workResponse, error) {
+	if err := w.enc.Encode(workRequest{Arguments: args}); err != nil {
+		return workResponse{}, fmt.Errorf("sending work request: %v", err)
+	}
+	var resp workResponse
+	if err := w.dec.Decode(&resp); err != nil {
+		if err == io.EOF {
+			err = errors.New("worker exited")
+		}
+		return workResponse{}, fmt.Errorf("reading work response: %v", err)
+	}
+	return resp, nil
+}
+
+// close stops the worker by closing its input and waits for it to exit.
+func (w *workerProcess) close() error {
+	w.stdin.Close()
+	return w.cmd.Wait()
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// workerHelperEnv makes the test binary serve echoWorkerRequest as a
+// persistent worker if set to 1, for TestWorkerProcess, or fakeNogoRequest if
+// set to nogo, for TestExecNogoWorker.
+const workerHelperEnv = "WORKER_TEST_HELPER"
+
+func TestMain(m *testing.M) {
+	if _, ok := persistentWorkerArgs(os.Args[1:]); ok {
+		handle := echoWorkerRequest
+		switch os.Getenv(workerHelperEnv) {
+		case "1":
+		case "nogo":
+			handle = fakeNogoRequest
+		default:
+			os.Exit(m.Run())
+		}
+		if err := serveWorker(os.Stdin, os.Stdout, handle); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// echoWorkerRequest outputs the arguments of a request and fails it with the
+// number of arguments as exit code.
+func echoWorkerRequest(args []string, output io.Writer) int {
+	fmt.Fprint(output, strings.Join(args, " "))
+	return len(args)
+}
+
+// fakeNogoRequest outputs the process ID of the worker followed by the
+// arguments of a request and reports findings as nogo does.
+func fakeNogoRequest(args []string, output io.Writer) int {
+	fmt.Fprintf(output, "%d %s", os.Getpid(), strings.Join(args, " "))
+	return nogoViolation
+}
+
+func TestPersistentWorkerArgs(t *testing.T) {
+	args := []string{"nogo", "-sdk", "go_sdk", persistentWorkerFlag}
+	startupArgs, ok := persistentWorkerArgs(args)
+	if !ok {
+		t.Fatalf("persistentWorkerArgs(%q) did not detect the worker", args)
+	}
+	if want := []string{"nogo", "-sdk", "go_sdk"}; !reflect.DeepEqual(startupArgs, want) {
+		t.Errorf("got startup args %q, want %q", startupArgs, want)
+	}
+	if want := []string{"nogo", "-sdk", "go_sdk", persistentWorkerFlag}; !reflect.DeepEqual(args, want) {
+		t.Errorf("the arguments were modified to %q", args)
+	}
+	if _, ok := persistentWorkerArgs([]string{"nogo", "-sdk", "go_sdk"}); ok {
+		t.Error("persistentWorkerArgs detected a worker without the flag")
+	}
+}
+
+func TestExpandWorkerFlagFile(t *testing.T) {
+	flagFile := filepath.Join(t.TempDir(), "nogo.flags")
+	if err := os.WriteFile(flagFile, []byte("-p\nexample.com/a\r\n-out_log\nout dir/a.log\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	got, err := expandWorkerFlagFile([]string{"nogo", "-sdk", "go_sdk", "@" + flagFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"nogo", "-sdk", "go_sdk", "-p", "example.com/a", "-out_log", "out dir/a.log"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	args := []string{"nogo", "-sdk", "go_sdk"}
+	if got, err := expandWorkerFlagFile(args); err != nil || !reflect.DeepEqual(got, args) {
+		t.Errorf("got %q, %v without a flagfile, want the arguments", got, err)
+	}
+}
+
+func TestServeWorker(t *testing.T) {
+	in := strings.NewReader(`{"arguments": ["-p", "a"], "requestId": 0}
+{"arguments": ["-p", "b", "a.go"], "inputs": [{"path": "a.go", "digest": "1234"}]}`)
+	var out strings.Builder
+	if err := serveWorker(in, &out, echoWorkerRequest); err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(strings.NewReader(out.String()))
+	var got []workResponse
+	for dec.More() {
+		var resp workResponse
+		if err := dec.Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, resp)
+	}
+	want := []workResponse{
+		{ExitCode: 2, Output: "-p a"},
+		{ExitCode: 3, Output: "-p b a.go"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got responses %+v, want %+v", got, want)
+	}
+
+	if err := serveWorker(strings.NewReader(`{"arguments": `), io.Discard, echoWorkerRequest); err == nil {
+		t.Error("serveWorker did not fail on a truncated request")
+	}
+}
+
+func TestServeMultiplexWorker(t *testing.T) {
+	// The first request only finishes once the second one started, so they
+	// have to be handled concurrently.
+	second := make(chan struct{})
+	handle := func(args []string, output io.Writer) int {
+		switch args[0] {
+		case "first":
+			<-second
+		case "second":
+			close(second)
+		}
+		return echoWorkerRequest(args, output)
+	}
+	in := strings.NewReader(`{"arguments": ["first"], "requestId": 1}
+{"arguments": ["second", "a.go"], "requestId": 2}`)
+	var out strings.Builder
+	if err := serveMultiplexWorker(in, &out, handle); err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(strings.NewReader(out.String()))
+	got := make(map[int]workResponse)
+	for dec.More() {
+		var resp workResponse
+		if err := dec.Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		got[resp.RequestID] = resp
+	}
+	want := map[int]workResponse{
+		1: {ExitCode: 1, Output: "first", RequestID: 1},
+		2: {ExitCode: 2, Output: "second a.go", RequestID: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got responses %+v, want %+v", got, want)
+	}
+
+	if err := serveMultiplexWorker(strings.NewReader(`{"arguments": `), io.Discard, echoWorkerRequest); err == nil {
+		t.Error("serveMultiplexWorker did not fail on a truncated request")
+	}
+}
+
+func TestWorkerProcess(t *testing.T) {
+	t.Setenv(workerHelperEnv, "1")
+	w, err := startWorkerProcess(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"-param=a.params"}, {"-p", "b", "b.go"}} {
+		resp, err := w.call(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := (workResponse{ExitCode: len(args), Output: strings.Join(args, " ")}); resp != want {
+			t.Errorf("got response %+v, want %+v", resp, want)
+		}
+	}
+	if err := w.close(); err != nil {
+		t.Errorf("closing the worker: %v", err)
+	}
+}
+
+func TestExecNogoWorker(t *testing.T) {
+	t.Setenv(workerHelperEnv, "nogo")
+	startNogoWorkers()
+	defer closeNogoWorkers()
+	var pids []string
+	for _, paramsFile := range []string{"a.params", "b.params"} {
+		exitCode, out, err := execNogo(os.Args[0], paramsFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exitCode != nogoViolation {
+			t.Errorf("got exit code %d, want %d", exitCode, nogoViolation)
+		}
+		pid, args, _ := strings.Cut(string(out), " ")
+		if want := "-param=" + paramsFile; args != want {
+			t.Errorf("the worker got arguments %q, want %q", args, want)
+		}
+		pids = append(pids, pid)
+	}
+	if pids[0] != pids[1] {
+		t.Errorf("the packages were analyzed by processes %s and %s, want a single persistent worker", pids[0], pids[1])
+	}
+	if len(nogoWorkers) != 1 {
+		t.Errorf("got %d nogo workers, want 1", len(nogoWorkers))
+	}
+	closeNogoWorkers()
+	if nogoWorkers != nil {
+		t.Error("closeNogoWorkers did not stop the nogo workers")
+	}
+}
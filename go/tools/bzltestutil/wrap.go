@@ -64,6 +64,25 @@ func shouldAddTestV() bool {
 	return false
 }
 
+// CoverDir returns the directory to which a test binary built with the Go
+// coverage redesign writes its coverage data before converting it to a
+// profile. It is GOCOVERDIR if set, and otherwise a new directory in
+// TEST_TMPDIR, which CoverDir exports as GOCOVERDIR so that instrumented
+// programs started by the test write their coverage data there, too.
+func CoverDir() (string, error) {
+	if dir := os.Getenv("GOCOVERDIR"); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.MkdirTemp(os.Getenv("TEST_TMPDIR"), "gocoverdir")
+	if err != nil {
+		return "", err
+	}
+	if err := os.Setenv("GOCOVERDIR", dir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
 // streamMerger intelligently merges an input stdout and stderr stream and dumps
 // the output to the writer `inner`. Additional synchronization is applied to
 // ensure that one line at a time is written to the inner writer.
@@ -17,6 +17,7 @@ package bzltestutil
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -61,3 +62,27 @@ func TestShouldWrap(t *testing.T) {
 		})
 	}
 }
+
+func TestCoverDir(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("TEST_TMPDIR", tmpDir)
+	t.Setenv("GOCOVERDIR", "")
+
+	dir, err := CoverDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(dir) != tmpDir {
+		t.Errorf("CoverDir returned %q, expected a directory in %q", dir, tmpDir)
+	}
+	if got := os.Getenv("GOCOVERDIR"); got != dir {
+		t.Errorf("GOCOVERDIR is %q, expected %q", got, dir)
+	}
+
+	// The directory is reused by later calls and by subprocesses.
+	if again, err := CoverDir(); err != nil {
+		t.Fatal(err)
+	} else if again != dir {
+		t.Errorf("CoverDir returned %q on the second call, expected %q", again, dir)
+	}
+}
@@ -70,8 +70,14 @@ def _go_archive_to_pkg(archive):
             pkg.data.importpath: str(pkg.data.label)
             for pkg in archive.direct
         },
+        NogoDiagnosticsFile = file_path(archive.data._nogo_diagnostics_output) if archive.data._nogo_diagnostics_output else "",
     )
 
+def _nogo_diagnostics_files(archive):
+    if archive.data._nogo_diagnostics_output:
+        return [archive.data._nogo_diagnostics_output]
+    return []
+
 def make_pkg_json(ctx, name, pkg_info):
     pkg_json_file = ctx.actions.declare_file(name + ".pkg.json")
     ctx.actions.write(pkg_json_file, content = json.encode(pkg_info))
@@ -107,10 +113,15 @@ def _go_pkg_info_aspect_impl(target, ctx):
     compiled_go_files = []
     export_files = []
 
+    # The nogo findings are only built for the requested targets, not for
+    # their dependencies.
+    nogo_diagnostics_files = []
+
     if GoArchive in target:
         archive = target[GoArchive]
         compiled_go_files.extend(archive.source.srcs)
         export_files.append(archive.data.export_file)
+        nogo_diagnostics_files.extend(_nogo_diagnostics_files(archive))
         pkg = _go_archive_to_pkg(archive)
         pkg_json_files.append(make_pkg_json(ctx, archive.data.name, pkg))
 
@@ -122,6 +133,7 @@ def _go_pkg_info_aspect_impl(target, ctx):
                     pkg_json_files.append(make_pkg_json(ctx, dep_archive.data.name, pkg))
                     compiled_go_files.extend(dep_archive.source.srcs)
                     export_files.append(dep_archive.data.export_file)
+                    nogo_diagnostics_files.extend(_nogo_diagnostics_files(dep_archive))
                     break
 
     # If there was no stdlib json in any dependencies, fetch it from the
@@ -152,6 +164,7 @@ def _go_pkg_info_aspect_impl(target, ctx):
             go_pkg_driver_srcs = pkg_info.compiled_go_files,
             go_pkg_driver_export_file = pkg_info.export_files,
             go_pkg_driver_stdlib_json_file = depset([pkg_info.stdlib_json_file] if pkg_info.stdlib_json_file else []),
+            go_pkg_driver_nogo_diagnostics = depset(nogo_diagnostics_files),
         ),
     ]
 
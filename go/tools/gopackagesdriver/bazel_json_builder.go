@@ -163,6 +163,9 @@ func (b *BazelJSONBuilder) outputGroupsForMode(mode packages.LoadMode) string {
 	if mode&packages.NeedExportsFile != 0 {
 		og += ",go_pkg_driver_export_file"
 	}
+	if nogoDiagnostics {
+		og += ",go_pkg_driver_nogo_diagnostics"
+	}
 	return og
 }
 
@@ -67,6 +67,10 @@ type FlatPackage struct {
 	ExportFile      string              `json:",omitempty"`
 	Imports         map[string]string   `json:",omitempty"`
 	Standard        bool                `json:",omitempty"`
+
+	// NogoDiagnosticsFile is the nogo_diagnostics output of the package, read
+	// when GOPACKAGESDRIVER_NOGO_DIAGNOSTICS is set.
+	NogoDiagnosticsFile string `json:",omitempty"`
 }
 
 type (
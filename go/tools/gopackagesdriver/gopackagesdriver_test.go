@@ -327,6 +327,41 @@ func TestIncompatible(t *testing.T) {
 	}
 }
 
+// TestReadNogoDiagnostics checks that nogo findings are turned into package
+// errors with resolved paths.
+func TestReadNogoDiagnostics(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "nogo_diagnostics.json")
+	report := `{"package": "example.com/hello", "diagnostics": [
+		{"analyzer": "printf", "message": "bad format", "file": "hello.go", "line": 3, "column": 2},
+		{"analyzer": "nilness", "message": "nil dereference", "file": "bazel-out/k8-fastbuild/bin/gen.go", "line": 1, "column": 1},
+		{"analyzer": "unusedresult", "message": "no position"}
+	]}`
+	if err := os.WriteFile(file, []byte(report), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	prf := func(p string) string {
+		p = strings.Replace(p, "__BAZEL_EXECROOT__", "/execroot", 1)
+		return strings.Replace(p, "__BAZEL_WORKSPACE__", "/workspace", 1)
+	}
+	errs, err := readNogoDiagnostics(file, prf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []packages.Error{
+		{Pos: "/workspace/hello.go:3:2", Msg: "bad format (nogo: printf)", Kind: packages.UnknownError},
+		{Pos: "/execroot/bazel-out/k8-fastbuild/bin/gen.go:1:1", Msg: "nil dereference (nogo: nilness)", Kind: packages.UnknownError},
+		{Pos: "-", Msg: "no position (nogo: unusedresult)", Kind: packages.UnknownError},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("got %d errors, want %d: %v", len(errs), len(want), errs)
+	}
+	for i := range want {
+		if errs[i] != want[i] {
+			t.Errorf("error %d: got %+v, want %+v", i, errs[i], want[i])
+		}
+	}
+}
+
 func runForTest(t *testing.T, driverRequest packages.DriverRequest, relativeWorkingDir string, args ...string) packages.DriverResponse {
 	t.Helper()
 
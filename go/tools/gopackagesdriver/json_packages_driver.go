@@ -45,6 +45,12 @@ func NewJSONPackagesDriver(jsonFiles []string, prf PathResolverFunc, bazelVersio
 		return nil, fmt.Errorf("unable to resolve imports: %w", err)
 	}
 
+	if nogoDiagnostics {
+		if err := jpd.registry.AddNogoDiagnostics(prf); err != nil {
+			return nil, fmt.Errorf("unable to add nogo diagnostics: %w", err)
+		}
+	}
+
 	return jpd, nil
 }
 
@@ -66,6 +66,7 @@ var (
 	buildWorkingDirectory = os.Getenv("BUILD_WORKING_DIRECTORY")
 	additionalAspects     = strings.Fields(os.Getenv("GOPACKAGESDRIVER_BAZEL_ADDTL_ASPECTS"))
 	additionalKinds       = strings.Fields(os.Getenv("GOPACKAGESDRIVER_BAZEL_KINDS"))
+	nogoDiagnostics       = os.Getenv("GOPACKAGESDRIVER_NOGO_DIAGNOSTICS") == "1"
 	emptyResponse         = &driverResponse{
 		NotHandled: true,
 		Compiler:   "gc",
@@ -0,0 +1,83 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// nogoDiagnosticsReport is the subset of the nogo_diagnostics output of a
+// package that is passed on to the editor. Paths are relative to the
+// execroot.
+type nogoDiagnosticsReport struct {
+	Diagnostics []nogoDiagnostic `json:"diagnostics"`
+}
+
+type nogoDiagnostic struct {
+	Analyzer string `json:"analyzer"`
+	Message  string `json:"message"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+}
+
+// execrootPathPlaceholder returns the path, relative to the execroot, in the
+// form used in package JSON files, so that it can be resolved like the paths
+// of source files.
+func execrootPathPlaceholder(p string) string {
+	switch {
+	case strings.HasPrefix(p, "bazel-out/"):
+		return path.Join("__BAZEL_EXECROOT__", p)
+	case strings.HasPrefix(p, "external/"):
+		return path.Join("__BAZEL_OUTPUT_BASE__", p)
+	case strings.HasPrefix(p, "../"):
+		// With --experimental_sibling_repository_layout.
+		return path.Join("__BAZEL_OUTPUT_BASE__", "external", strings.TrimPrefix(p, "../"))
+	default:
+		return path.Join("__BAZEL_WORKSPACE__", p)
+	}
+}
+
+// readNogoDiagnostics returns the nogo findings in a nogo_diagnostics file as
+// package errors, which editors show like compile errors.
+func readNogoDiagnostics(file string, prf PathResolverFunc) ([]packages.Error, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var report nogoDiagnosticsReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("unable to decode nogo diagnostics in %s: %w", file, err)
+	}
+	var errs []packages.Error
+	for _, d := range report.Diagnostics {
+		pos := "-"
+		if d.File != "" {
+			pos = fmt.Sprintf("%s:%d:%d", prf(execrootPathPlaceholder(d.File)), d.Line, d.Column)
+		}
+		errs = append(errs, packages.Error{
+			Pos:  pos,
+			Msg:  fmt.Sprintf("%s (nogo: %s)", d.Message, d.Analyzer),
+			Kind: packages.UnknownError,
+		})
+	}
+	return errs, nil
+}
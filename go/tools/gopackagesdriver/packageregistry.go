@@ -16,6 +16,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
 	"golang.org/x/tools/go/packages"
@@ -25,13 +26,17 @@ type PackageRegistry struct {
 	packagesByID map[string]*packages.Package
 	stdlib       map[string]*packages.Package
 	bazelVersion bazelVersion
+
+	// nogoDiagnosticsFiles maps package IDs to their nogo diagnostics file.
+	nogoDiagnosticsFiles map[string]string
 }
 
 func NewPackageRegistry(bazelVersion bazelVersion, pkgs ...*FlatPackage) *PackageRegistry {
 	pr := &PackageRegistry{
-		packagesByID: map[string]*packages.Package{},
-		stdlib:       map[string]*packages.Package{},
-		bazelVersion: bazelVersion,
+		packagesByID:         map[string]*packages.Package{},
+		stdlib:               map[string]*packages.Package{},
+		bazelVersion:         bazelVersion,
+		nogoDiagnosticsFiles: map[string]string{},
 	}
 	pr.Add(pkgs...)
 	return pr
@@ -66,6 +71,10 @@ func (pr *PackageRegistry) Add(pkgs ...*FlatPackage) *PackageRegistry {
 		if flatPkg.IsStdlib() {
 			pr.stdlib[pkg.PkgPath] = pkg
 		}
+
+		if flatPkg.NogoDiagnosticsFile != "" {
+			pr.nogoDiagnosticsFiles[pkg.ID] = flatPkg.NogoDiagnosticsFile
+		}
 	}
 	return pr
 }
@@ -78,6 +87,26 @@ func (pr *PackageRegistry) ResolvePaths(prf PathResolverFunc) error {
 	return nil
 }
 
+// AddNogoDiagnostics adds the findings of nogo to the errors of the packages
+// they were reported for. Packages that nogo didn't check, e.g. because nogo
+// isn't configured or the package failed to compile, are skipped.
+func (pr *PackageRegistry) AddNogoDiagnostics(prf PathResolverFunc) error {
+	for id, file := range pr.nogoDiagnosticsFiles {
+		pkg := pr.packagesByID[id]
+		if pkg == nil {
+			continue
+		}
+		errs, err := readNogoDiagnostics(prf(file), prf)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		pkg.Errors = append(pkg.Errors, errs...)
+	}
+	return nil
+}
+
 // ResolveImports adds stdlib imports to packages. This is required because
 // stdlib packages are not part of the JSON file exports as bazel is unaware of
 // them.
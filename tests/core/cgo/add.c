@@ -2,7 +2,7 @@
 #include <generated_cppopts.h>
 #include <generated_copts.h>
 
-#if !defined(RULES_GO_C) || !defined(RULES_GO_CPP) || defined(RULES_GO_CXX)
+#if !defined(RULES_GO_C) || !defined(RULES_GO_CPP) || defined(RULES_GO_CXX) || defined(RULES_GO_OBJC) || defined(RULES_GO_OBJCXX)
 #error This is a C file, only RULES_GO_C and RULES_GO_CPP should be defined.
 #endif
 
@@ -2,10 +2,14 @@
 #include <generated_cppopts.h>
 #include <generated_cxxopts.h>
 
-#if !defined(RULES_GO_CPP) || !defined(RULES_GO_CXX) || defined(RULES_GO_C)
+#if !defined(RULES_GO_CPP) || !defined(RULES_GO_CXX) || defined(RULES_GO_C) || defined(RULES_GO_OBJC) || defined(RULES_GO_OBJCXX)
 #error This is a C++ file, only RULES_GO_CXX and RULES_GO_CPP should be defined.
 #endif
 
+#if __cplusplus != 201703L
+#error The C++ standard selected with cxxopts should be used.
+#endif
+
 #if !defined(GENERATED_CPPOPTS) || !defined(GENERATED_CXXOPTS) || defined(GENERATED_COPTS)
 #error Generated headers should be correctly included
 #endif
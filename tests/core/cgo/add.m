@@ -2,8 +2,8 @@
 #include <generated_cppopts.h>
 #include <generated_copts.h>
 
-#if !defined(RULES_GO_C) || !defined(RULES_GO_CPP) || defined(RULES_GO_CXX)
-#error This is an Objective-C file, only RULES_GO_C and RULES_GO_CPP should be defined.
+#if !defined(RULES_GO_C) || !defined(RULES_GO_CPP) || !defined(RULES_GO_OBJC) || defined(RULES_GO_CXX) || defined(RULES_GO_OBJCXX)
+#error This is an Objective-C file, only RULES_GO_C, RULES_GO_CPP and RULES_GO_OBJC should be defined.
 #endif
 
 #if !defined(GENERATED_COPTS) || !defined(GENERATED_CPPOPTS) || defined(GENERATED_CXXOPTS)
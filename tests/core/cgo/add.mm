@@ -2,8 +2,12 @@
 #include <generated_cppopts.h>
 #include <generated_cxxopts.h>
 
-#if !defined(RULES_GO_CPP) || !defined(RULES_GO_CXX) || defined(RULES_GO_C)
-#error This is an Objective-C++ file, only RULES_GO_CXX and RULES_GO_CPP should be defined.
+#if !defined(RULES_GO_CPP) || !defined(RULES_GO_CXX) || !defined(RULES_GO_OBJCXX) || defined(RULES_GO_C) || defined(RULES_GO_OBJC)
+#error This is an Objective-C++ file, only RULES_GO_CXX, RULES_GO_CPP and RULES_GO_OBJCXX should be defined.
+#endif
+
+#if __cplusplus != 201703L
+#error The C++ standard selected with cxxopts should be used.
 #endif
 
 #if !defined(GENERATED_CPPOPTS) || !defined(GENERATED_CXXOPTS) || defined(GENERATED_COPTS)
@@ -66,6 +66,12 @@ go_library(
     importpath = "example.com/coverage/c",
 )
 
+go_test(
+    name = "gocoverdir_test",
+    srcs = ["gocoverdir_test.go"],
+    embed = [":c"],
+)
+
 go_library(
 	name = "d",
 	srcs = ["d.go"],
@@ -133,6 +139,21 @@ func CDead() int {
 	return 34
 }
 
+-- gocoverdir_test.go --
+package c
+
+import (
+	"os"
+	"testing"
+)
+
+func TestGocoverdir(t *testing.T) {
+	if os.Getenv("GOCOVERDIR") == "" {
+		t.Error("GOCOVERDIR is not set")
+	}
+	CLive()
+}
+
 -- d.go --
 package lzma
 
@@ -263,3 +284,9 @@ func testCoverageWithCorrectLineNumbers(t *testing.T, extraArgs ...string) {
 		t.Fatal(err)
 	}
 }
+
+func TestGocoverdir(t *testing.T) {
+	if err := bazel_testing.RunBazel("coverage", ":gocoverdir_test"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gc_diagnostics_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_binary", "go_library")
+
+go_library(
+    name = "hot",
+    srcs = ["hot.go"],
+    importpath = "example.com/hot",
+)
+
+go_library(
+    name = "cold",
+    srcs = ["cold.go"],
+    importpath = "example.com/cold",
+)
+
+go_binary(
+    name = "main",
+    srcs = ["main.go"],
+    deps = [
+        ":cold",
+        ":hot",
+    ],
+)
+-- hot.go --
+package hot
+
+func Sum(xs []int) int {
+	s := 0
+	for i := range xs {
+		s += xs[i]
+	}
+	return s
+}
+-- cold.go --
+package cold
+
+func Double(x int) int {
+	return 2 * x
+}
+-- main.go --
+package main
+
+import (
+	"fmt"
+
+	"example.com/cold"
+	"example.com/hot"
+)
+
+func main() {
+	fmt.Println(cold.Double(hot.Sum([]int{1, 2})))
+}
+`,
+	})
+}
+
+func TestJSONDiagnostics(t *testing.T) {
+	if err := bazel_testing.RunBazel("build", "//:main", "--output_groups=gc_json_diagnostics", "--@io_bazel_rules_go//go/config:gc_json_diagnostics=example.com/hot"); err != nil {
+		t.Fatal(err)
+	}
+	dirs, err := filepath.Glob("bazel-bin/*.gcjson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) != 1 || !strings.HasPrefix(filepath.Base(dirs[0]), "hot") {
+		t.Fatalf("got diagnostics directories %v, want only one for :hot", dirs)
+	}
+	data, err := os.ReadFile(filepath.Join(dirs[0], "example.com%2Fhot", "hot.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "canInlineFunction") {
+		t.Errorf("diagnostics don't describe inlining:\n%s", data)
+	}
+}
+
+func TestDebug(t *testing.T) {
+	const debug = "--@io_bazel_rules_go//go/config:gc_debug=ssa/check_bce/debug=1"
+
+	out, err := bazel_testing.BazelOutput("aquery", "--output=jsonproto", debug, `mnemonic("GoCompilePkg|GoStdlib", //:main)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var graph struct {
+		Actions []struct {
+			Mnemonic  string
+			Arguments []string
+		}
+	}
+	if err := json.Unmarshal(out, &graph); err != nil {
+		t.Fatal(err)
+	}
+	enabled := map[string]bool{}
+	for _, action := range graph.Actions {
+		for _, arg := range action.Arguments {
+			if strings.Contains(arg, "-d=ssa/check_bce/debug=1") {
+				enabled[action.Mnemonic] = true
+			}
+		}
+	}
+	for _, mnemonic := range []string{"GoCompilePkg", "GoStdlib"} {
+		if !enabled[mnemonic] {
+			t.Errorf("%s action doesn't pass -d to the compiler", mnemonic)
+		}
+	}
+}
+
+func TestDebugRejectsFlags(t *testing.T) {
+	err := bazel_testing.RunBazel("build", "--@io_bazel_rules_go//go/config:gc_debug=-m", "//:main")
+	if err == nil {
+		t.Fatal("building with a flag in gc_debug succeeded")
+	}
+	if !strings.Contains(err.Error(), "isn't a setting of the compiler's -d flag") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
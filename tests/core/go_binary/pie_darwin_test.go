@@ -34,6 +34,19 @@ func TestPIE(t *testing.T) {
 	}
 }
 
+// Like the go command, executables for macOS are position-independent by
+// default.
+func TestDefaultPIE(t *testing.T) {
+	m, err := openMachO("tests/core/go_binary", "hello_nopie_bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Flags&macho.FlagPIE == 0 {
+		t.Error("MachO binary is not position-independent.")
+	}
+}
+
 func TestPIESetting(t *testing.T) {
 	m, err := openMachO("tests/core/go_binary", "hello_pie_setting_bin")
 	if err != nil {
@@ -0,0 +1,62 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedsrcs_other_package
+
+import (
+	"embed"
+	"io/fs"
+	"testing"
+)
+
+//go:embed embedsrcs_other_package_gen/data.txt
+var data string
+
+//go:embed embedsrcs_dynamic/file embedsrcs_dynamic/dir
+var dynamic embed.FS
+
+func TestGeneratedFile(t *testing.T) {
+	if data != "data\n" {
+		t.Errorf("got %q, want %q", data, "data\n")
+	}
+}
+
+func TestGeneratedDirectory(t *testing.T) {
+	var got []string
+	err := fs.WalkDir(dynamic, ".", func(path string, _ fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		got = append(got, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		".",
+		"embedsrcs_dynamic",
+		"embedsrcs_dynamic/dir",
+		"embedsrcs_dynamic/dir/f",
+		"embedsrcs_dynamic/file",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedsrcs_package_dirs_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+genrule(
+    name = "root",
+    outs = ["root.txt"],
+    cmd = "echo root > $@",
+    visibility = ["//visibility:public"],
+)
+-- a/BUILD.bazel --
+exports_files(["static.txt"])
+
+genrule(
+    name = "a",
+    outs = ["other/a.txt"],
+    cmd = "echo a > $@",
+    visibility = ["//visibility:public"],
+)
+-- a/static.txt --
+static
+-- a/b/BUILD.bazel --
+genrule(
+    name = "b",
+    outs = ["b.txt"],
+    cmd = "echo b > $@",
+    visibility = ["//visibility:public"],
+)
+-- p/BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_test")
+
+go_test(
+    name = "p_test",
+    srcs = ["p_test.go"],
+    embedsrcs = [
+        "//:root",
+        "//a",
+        "//a/b",
+    ],
+)
+
+go_library(
+    name = "static",
+    srcs = ["static.go"],
+    embedsrcs = ["//a:static.txt"],
+    importpath = "example.com/static",
+)
+-- p/p_test.go --
+package p
+
+import (
+	_ "embed"
+	"testing"
+)
+
+//go:embed root.txt
+var root string
+
+//go:embed other/a.txt
+var a string
+
+//go:embed b.txt
+var b string
+
+func TestEmbed(t *testing.T) {
+	for _, tc := range []struct{ got, want string }{
+		{root, "root\n"},
+		{a, "a\n"},
+		{b, "b\n"},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("got %q, want %q", tc.got, tc.want)
+		}
+	}
+}
+-- p/static.go --
+package p
+
+import _ "embed"
+
+//go:embed static.txt
+var static string
+`,
+	})
+}
+
+// Generated files are embedded at their path relative to the directory of the
+// package that owns them, even if that is the root package or the directory
+// of another package contains it.
+func TestGeneratedFiles(t *testing.T) {
+	if err := bazel_testing.RunBazel("test", "//p:p_test"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Static files of other packages can't be embedded.
+func TestStaticFile(t *testing.T) {
+	err := bazel_testing.RunBazel("build", "//p:static")
+	if err == nil {
+		t.Fatal("embedding a static file of another package succeeded")
+	}
+	if !strings.Contains(err.Error(), "static.txt") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
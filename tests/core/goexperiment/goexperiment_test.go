@@ -0,0 +1,144 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goexperiment_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_binary")
+
+go_binary(
+    name = "experiment",
+    srcs = [
+        "experiment.go",
+        "fieldtrack_off.go",
+        "fieldtrack_on.go",
+    ],
+)
+
+go_binary(
+    name = "panicnil",
+    srcs = ["panicnil.go"],
+)
+-- experiment.go --
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func main() {
+	fmt.Println(fieldtrack, runtime.Version())
+}
+-- fieldtrack_off.go --
+//go:build !goexperiment.fieldtrack
+
+package main
+
+const fieldtrack = "off"
+-- fieldtrack_on.go --
+//go:build goexperiment.fieldtrack
+
+package main
+
+const fieldtrack = "on"
+-- panicnil.go --
+package main
+
+import "fmt"
+
+func main() {
+	defer func() {
+		fmt.Printf("%T\n", recover())
+	}()
+	panic(nil)
+}
+`,
+	})
+}
+
+func TestGoExperiment(t *testing.T) {
+	for _, test := range []struct {
+		desc, fieldtrack string
+		args             []string
+	}{
+		{desc: "default", fieldtrack: "off"},
+		{desc: "enabled", fieldtrack: "on", args: []string{"--@io_bazel_rules_go//go/config:goexperiment=fieldtrack"}},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			out, err := bazel_testing.BazelOutput(append([]string{"run"}, append(test.args, "//:experiment")...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fields := strings.Fields(string(out))
+			if len(fields) < 2 {
+				t.Fatalf("unexpected output %q", out)
+			}
+			if fields[0] != test.fieldtrack {
+				t.Errorf("got goexperiment.fieldtrack %s, want %s", fields[0], test.fieldtrack)
+			}
+			// The runtime records the experiments it was built with in its
+			// version.
+			if got, want := strings.Contains(fields[1], "fieldtrack"), test.fieldtrack == "on"; got != want {
+				t.Errorf("got runtime version %s, want fieldtrack in it: %v", fields[1], want)
+			}
+		})
+	}
+}
+
+func TestGoDebug(t *testing.T) {
+	for _, test := range []struct {
+		desc, want string
+		args       []string
+	}{
+		{desc: "default", want: "*runtime.PanicNilError"},
+		{desc: "panicnil", want: "<nil>", args: []string{"--@io_bazel_rules_go//go/config:godebug=panicnil=1"}},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			out, err := bazel_testing.BazelOutput(append([]string{"run"}, append(test.args, "//:panicnil")...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(string(out)); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMalformedEntries(t *testing.T) {
+	for _, test := range []struct {
+		flag, wantErr string
+	}{
+		{"--@io_bazel_rules_go//go/config:goexperiment=fieldtrack=1", "isn't the name of a Go experiment"},
+		{"--@io_bazel_rules_go//go/config:godebug=panicnil", "isn't a GODEBUG setting"},
+	} {
+		err := bazel_testing.RunBazel("build", test.flag, "//:panicnil")
+		if err == nil {
+			t.Errorf("building with %s succeeded", test.flag)
+		} else if !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("building with %s: unexpected error: %v", test.flag, err)
+		}
+	}
+}
@@ -31,7 +31,7 @@ func TestMain(m *testing.M) {
 		Nogo: "@//:nogo",
 		Main: `
 -- BUILD.bazel --
-load("@io_bazel_rules_go//go:def.bzl", "go_binary", "go_library", "nogo")
+load("@io_bazel_rules_go//go:def.bzl", "go_binary", "go_library", "go_test", "nogo")
 
 nogo(
     name = "nogo",
@@ -118,6 +118,12 @@ go_library(
     deps = [":dep"],
 )
 
+go_test(
+    name = "has_errors_test",
+    srcs = ["has_errors_test.go"],
+    deps = [":dep"],
+)
+
 go_library(
     name = "dep",
     srcs = ["dep.go"],
@@ -353,6 +359,105 @@ func run(pass *analysis.Pass) (interface{}, error) {
   }
 }
 
+-- remediation.json --
+{
+  "foofuncname": {
+    "remediation": "Rename the function.\nSee the style guide if in doubt."
+  }
+}
+
+-- nodiagnostics.json --
+{
+  "_base": {
+    "diagnostics": false
+  }
+}
+
+-- notests.json --
+{
+  "_base": {
+    "tests": false
+  },
+  "visibility": {
+    "tests": true
+  }
+}
+
+-- failonfixable.json --
+{
+  "_base": {
+    "fail_on": "fixable"
+  }
+}
+
+-- failonunfixable.json --
+{
+  "_base": {
+    "fail_on": "unfixable"
+  }
+}
+
+-- severitywarning.json --
+{
+  "_base": {
+    "severity": "warning"
+  }
+}
+
+-- severitymixed.json --
+{
+  "foofuncname": {
+    "severity": "warning"
+  },
+  "visibility": {
+    "severity": "note"
+  }
+}
+
+-- baselinepartial.json --
+{
+  "version": 1,
+  "findings": [
+    {
+      "file": "has_errors.go",
+      "analyzer": "importfmt",
+      "message": "package fmt must not be imported",
+      "count": 1
+    },
+    {
+      "file": "has_errors.go",
+      "analyzer": "foofuncname",
+      "message": "function must not be named Foo",
+      "count": 1
+    }
+  ]
+}
+
+-- baselinefull.json --
+{
+  "version": 1,
+  "findings": [
+    {
+      "file": "has_errors.go",
+      "analyzer": "foofuncname",
+      "message": "function must not be named Foo",
+      "count": 1
+    },
+    {
+      "file": "has_errors.go",
+      "analyzer": "importfmt",
+      "message": "package fmt must not be imported",
+      "count": 1
+    },
+    {
+      "file": "has_errors.go",
+      "analyzer": "visibility",
+      "message": "function D is not visible in this package",
+      "count": 1
+    }
+  ]
+}
+
 -- baseconfig.json --
 {
   "_base": {
@@ -402,6 +507,20 @@ func Foo() bool { // This should fail foofuncname
 	return true
 }
 
+-- has_errors_test.go --
+package haserrors_test
+
+import (
+	_ "fmt" // This should fail importfmt
+
+	"dep"
+)
+
+func Foo() bool { // This should fail foofuncname
+	dep.D() // This should fail visibility
+	return true
+}
+
 -- no_errors.go --
 // package noerrors contains no analyzer errors.
 package noerrors
@@ -457,6 +576,7 @@ func Foo() bool {
 func Test(t *testing.T) {
 	for _, test := range []struct {
 		desc, config, target string
+		baseline             string
 		wantSuccess          bool
 		includes, excludes   []string
 		bazelArgs []string
@@ -515,6 +635,18 @@ func Test(t *testing.T) {
 			excludes: []string{
 				`importfmt`,
 			},
+		}, {
+			desc:        "remediation",
+			config:      "remediation.json",
+			target:      "//:has_errors",
+			wantSuccess: false,
+			includes: []string{
+				`has_errors.go:.*function must not be named Foo \(foofuncname\)\n    Rename the function.\n    See the style guide if in doubt.`,
+			},
+			excludes: []string{
+				`\(importfmt\)\n    Rename the function.`,
+				`\(visibility\)\n    Rename the function.`,
+			},
 		}, {
 			desc:        "uses_cgo_with_errors",
 			config:      "config.json",
@@ -534,6 +666,95 @@ func Test(t *testing.T) {
 			},
 			// Ensure that nogo runs even though compilation fails
 			bazelArgs: []string{"--keep_going"},
+		}, {
+			desc:        "diagnostics_disabled",
+			config:      "nodiagnostics.json",
+			target:      "//:has_errors",
+			wantSuccess: true,
+			excludes: []string{
+				`importfmt`,
+				`foofuncname`,
+				`visibility`,
+			},
+		}, {
+			desc:        "tests_default_config",
+			target:      "//:has_errors_test",
+			wantSuccess: false,
+			includes: []string{
+				`has_errors_test.go:.*package fmt must not be imported \(importfmt\)`,
+				`has_errors_test.go:.*function must not be named Foo \(foofuncname\)`,
+			},
+		}, {
+			desc:        "tests_disabled",
+			config:      "notests.json",
+			target:      "//:has_errors_test",
+			wantSuccess: false,
+			includes: []string{
+				`has_errors_test.go:.*function D is not visible in this package \(visibility\)`,
+			},
+			excludes: []string{
+				`importfmt`,
+				`foofuncname`,
+			},
+		}, {
+			desc:        "tests_disabled_library",
+			config:      "notests.json",
+			target:      "//:has_errors",
+			wantSuccess: false,
+			includes: []string{
+				`has_errors.go:.*package fmt must not be imported \(importfmt\)`,
+			},
+		}, {
+			// None of the analyzers suggest fixes.
+			desc:        "fail_on_fixable",
+			config:      "failonfixable.json",
+			target:      "//:has_errors",
+			wantSuccess: true,
+		}, {
+			desc:        "fail_on_unfixable",
+			config:      "failonunfixable.json",
+			target:      "//:has_errors",
+			wantSuccess: false,
+			includes: []string{
+				`has_errors.go:.*package fmt must not be imported \(importfmt\)`,
+			},
+		}, {
+			desc:        "severity_warning",
+			config:      "severitywarning.json",
+			target:      "//:has_errors",
+			wantSuccess: true,
+			includes: []string{
+				`has_errors.go:.*warning: package fmt must not be imported \(importfmt\)`,
+				`has_errors.go:.*warning: function must not be named Foo \(foofuncname\)`,
+			},
+		}, {
+			desc:        "severity_mixed",
+			config:      "severitymixed.json",
+			target:      "//:has_errors",
+			wantSuccess: false,
+			includes: []string{
+				`has_errors.go:.*: package fmt must not be imported \(importfmt\)`,
+				`has_errors.go:.*warning: function must not be named Foo \(foofuncname\)`,
+				`has_errors.go:.*note: function D is not visible in this package \(visibility\)`,
+			},
+		}, {
+			desc:        "baseline_partial",
+			baseline:    "baselinepartial.json",
+			target:      "//:has_errors",
+			wantSuccess: false,
+			includes: []string{
+				`has_errors.go:.*function D is not visible in this package \(visibility\)`,
+			},
+			excludes: []string{
+				`\(importfmt\)`,
+				`\(foofuncname\)`,
+			},
+		}, {
+			desc:        "baseline_full",
+			baseline:    "baselinefull.json",
+			target:      "//:has_errors",
+			wantSuccess: true,
+			excludes:    []string{"has_errors.go"},
 		}, {
 			desc:        "no_errors",
 			target:      "//:no_errors",
@@ -546,8 +767,14 @@ func Test(t *testing.T) {
 		},
 	} {
 		t.Run(test.desc, func(t *testing.T) {
-			if test.config != "" {
-				customConfig := fmt.Sprintf("config = %q,", test.config)
+			if test.config != "" || test.baseline != "" {
+				var customConfig string
+				if test.config != "" {
+					customConfig = fmt.Sprintf("config = %q,", test.config)
+				}
+				if test.baseline != "" {
+					customConfig += fmt.Sprintf("baseline = %q,", test.baseline)
+				}
 				if err := replaceInFile("BUILD.bazel", origConfig, customConfig); err != nil {
 					t.Fatal(err)
 				}
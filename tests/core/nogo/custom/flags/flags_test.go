@@ -163,6 +163,15 @@ func run(pass *analysis.Pass) (interface{}, error) {
   }
 }
 
+-- base_flags.json --
+{
+  "_base": {
+    "analyzer_flags": {
+      "int-switch": "1"
+    }
+  }
+}
+
 -- some_file.go --
 // package somefile contains a file and has a dep
 package somefile
@@ -214,6 +223,11 @@ func Test(t *testing.T) {
 			wantSuccess: false,
 			config:      "hyphenated_flag.json",
 			includes:    []string{"flagger: flag should not begin with '-': -int-switch"},
+		}, {
+			desc:        "base_flags_triggering_error",
+			wantSuccess: false,
+			config:      "base_flags.json",
+			includes:    []string{`analyzer_flags can't be set in "_base"`},
 		},
 	} {
 		t.Run(test.desc, func(t *testing.T) {
@@ -0,0 +1,121 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fix_size_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Nogo: "@//:nogo",
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "nogo")
+
+nogo(
+    name = "nogo",
+    deps = [":rename"],
+    config = "config.json",
+    max_fix_file_size = 1,
+    visibility = ["//visibility:public"],
+)
+
+go_library(
+    name = "rename",
+    srcs = ["rename.go"],
+    importpath = "renameanalyzer",
+    deps = ["@org_golang_x_tools//go/analysis"],
+    visibility = ["//visibility:public"],
+)
+
+go_library(
+    name = "lib",
+    srcs = ["lib.go"],
+    importpath = "example.com/lib",
+)
+-- config.json --
+{
+  "rename": {
+    "diagnostics": false
+  }
+}
+-- rename.go --
+// rename suggests replacing calls of Old with calls of New.
+package rename
+
+import (
+	"go/ast"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name: "rename",
+	Run:  run,
+	Doc:  "rename suggests replacing calls of Old with calls of New",
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	for _, f := range pass.Files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			if id, ok := call.Fun.(*ast.Ident); ok && id.Name == "Old" {
+				pass.Report(analysis.Diagnostic{
+					Pos:     id.Pos(),
+					End:     id.End(),
+					Message: "call New instead of Old",
+					SuggestedFixes: []analysis.SuggestedFix{{
+						Message:   "Replace Old with New",
+						TextEdits: []analysis.TextEdit{{Pos: id.Pos(), End: id.End(), NewText: []byte("New")}},
+					}},
+				})
+			}
+			return true
+		})
+	}
+	return nil, nil
+}
+-- lib.go --
+package lib
+
+func Old() int { return 42 }
+
+func New() int { return 42 }
+
+func Answer() int {
+	return Old()
+}
+`,
+	})
+}
+
+func TestFixFileSizeNotice(t *testing.T) {
+	cmd := bazel_testing.BazelCmd("build", "//:lib")
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("unexpected failure: %v\n%s", err, stderr)
+	}
+	if want := "left out of the fix file since max_fix_file_size is 1 bytes"; !bytes.Contains(stderr.Bytes(), []byte(want)) {
+		t.Errorf("the output doesn't note the fixes left out of the fix file:\n%s", stderr)
+	}
+}
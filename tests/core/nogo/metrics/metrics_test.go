@@ -0,0 +1,127 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics_test
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Nogo: "@//:nogo",
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "nogo")
+
+nogo(
+    name = "nogo",
+    visibility = ["//visibility:public"],
+    deps = [
+        "@org_golang_x_tools//go/analysis/passes/nilness",
+        "@org_golang_x_tools//go/analysis/passes/printf",
+    ],
+)
+
+go_library(
+    name = "src",
+    srcs = ["src.go"],
+    importpath = "example.com/src",
+)
+-- src.go --
+package src
+
+import "fmt"
+
+func Greet(name string) string {
+	return fmt.Sprintf("Hello, %s!", name)
+}
+`,
+	})
+}
+
+type metricsReport struct {
+	Package   string `json:"package"`
+	Analyzers []struct {
+		Analyzer   string `json:"analyzer"`
+		WallTimeNs int64  `json:"wall_time_ns"`
+		AllocBytes uint64 `json:"alloc_bytes"`
+	} `json:"analyzers"`
+}
+
+func buildMetrics(t *testing.T, mode string) metricsReport {
+	t.Helper()
+	if err := bazel_testing.RunBazel("build", "//:src", "--output_groups=nogo_metrics", "--@io_bazel_rules_go//go/config:nogo_metrics="+mode); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile("bazel-bin/src.nogo.metrics.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report metricsReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Package != "example.com/src" {
+		t.Errorf("got metrics of package %q, want example.com/src", report.Package)
+	}
+	analyzers := map[string]bool{}
+	for _, a := range report.Analyzers {
+		analyzers[a.Analyzer] = true
+		if a.WallTimeNs <= 0 {
+			t.Errorf("got wall time %d for %s, want a positive duration", a.WallTimeNs, a.Analyzer)
+		}
+	}
+	for _, name := range []string{"nilness", "printf"} {
+		if !analyzers[name] {
+			t.Errorf("no metrics for %s in %s", name, data)
+		}
+	}
+	return report
+}
+
+func TestAllocs(t *testing.T) {
+	report := buildMetrics(t, "allocs")
+	var allocBytes uint64
+	for _, a := range report.Analyzers {
+		allocBytes += a.AllocBytes
+	}
+	if allocBytes == 0 {
+		t.Errorf("got no allocations in %+v", report)
+	}
+}
+
+func TestWallTime(t *testing.T) {
+	report := buildMetrics(t, "wall_time")
+	for _, a := range report.Analyzers {
+		if a.AllocBytes != 0 {
+			t.Errorf("got %d allocated bytes for %s, want only the wall time", a.AllocBytes, a.Analyzer)
+		}
+	}
+}
+
+func TestOff(t *testing.T) {
+	out, err := bazel_testing.BazelOutput("aquery", "--output=text", `mnemonic("RunNogo", //:src)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(out), "-out_metrics") || strings.Contains(string(out), ".nogo.metrics.json") {
+		t.Errorf("nogo records metrics by default:\n%s", out)
+	}
+}
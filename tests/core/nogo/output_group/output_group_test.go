@@ -0,0 +1,113 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output_group_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Nogo: "@io_bazel_rules_go//:tools_nogo",
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_test")
+
+go_library(
+    name = "lib",
+    srcs = ["lib.go"],
+    importpath = "example.com/lib",
+)
+
+go_test(
+    name = "lib_test",
+    srcs = [
+        "internal_test.go",
+        "external_test.go",
+    ],
+    embed = [":lib"],
+)
+-- lib.go --
+package lib
+
+func Answer() int {
+	return 42
+}
+-- internal_test.go --
+package lib
+
+import "testing"
+
+func TestAnswer(t *testing.T) {
+	if Answer() != 42 {
+		t.Fail()
+	}
+}
+-- external_test.go --
+package lib_test
+
+import (
+	"testing"
+
+	"example.com/lib"
+)
+
+func TestExternalAnswer(t *testing.T) {
+	if lib.Answer() != 42 {
+		t.Fail()
+	}
+}
+`,
+	})
+}
+
+func TestOutputGroup(t *testing.T) {
+	if err := bazel_testing.RunBazel("clean"); err != nil {
+		t.Fatal(err)
+	}
+	if err := bazel_testing.RunBazel("build", "--output_groups=nogo_validation", "//:lib", "//:lib_test"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := bazel_testing.BazelOutput("info", "bazel-bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin := strings.TrimSpace(string(out))
+
+	for _, tc := range []struct {
+		desc, path string
+		built      bool
+	}{
+		{"validation of the library", "lib.nogo", true},
+		{"validation of the internal test", "lib_test.nogo", true},
+		{"validation of the external test", "lib_test_test.nogo", true},
+		{"archive of the library", "lib.a", false},
+		// nogo analyzes the external test with the export data of the internal
+		// test, so the internal test is compiled.
+		{"archive of the internal test", "lib_test.a", true},
+		{"archive of the external test", "lib_test_test.a", false},
+		{"test binary", "lib_test_/lib_test", false},
+	} {
+		_, err := os.Stat(filepath.Join(bin, filepath.FromSlash(tc.path)))
+		if built := err == nil; built != tc.built {
+			t.Errorf("%s (%s): got built %v, want %v", tc.desc, tc.path, built, tc.built)
+		}
+	}
+}
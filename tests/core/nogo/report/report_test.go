@@ -0,0 +1,117 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Nogo: "@//:nogo",
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "nogo", "nogo_report")
+
+nogo(
+    name = "nogo",
+    deps = [":importfmt"],
+    visibility = ["//visibility:public"],
+)
+
+go_library(
+    name = "importfmt",
+    srcs = ["importfmt.go"],
+    importpath = "importfmtanalyzer",
+    deps = ["@org_golang_x_tools//go/analysis"],
+    visibility = ["//visibility:public"],
+)
+
+go_library(
+    name = "lib",
+    srcs = ["lib.go"],
+    importpath = "example.com/lib",
+)
+
+nogo_report(
+    name = "nogo_report",
+    deps = [":lib"],
+)
+-- importfmt.go --
+// importfmt checks for functions that import "fmt".
+package importfmt
+
+import (
+	"strconv"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name: "importfmt",
+	Run:  run,
+	Doc:  "importfmt reports imports of the fmt package",
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	for _, f := range pass.Files {
+		for _, imp := range f.Imports {
+			if path, _ := strconv.Unquote(imp.Path.Value); path == "fmt" {
+				pass.Reportf(imp.Pos(), "package fmt must not be imported")
+			}
+		}
+	}
+	return nil, nil
+}
+-- lib.go --
+package lib
+
+import "fmt"
+
+func Greet() string {
+	return fmt.Sprint("hello")
+}
+`,
+	})
+}
+
+func TestReport(t *testing.T) {
+	const logCopy = "nogo-report/lib.nogo.log"
+	if err := bazel_testing.RunBazel("run", "--norun_validations", "//:nogo_report", "--", "nogo-report"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(logCopy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "package fmt must not be imported") {
+		t.Errorf("the copy of the log doesn't contain the finding:\n%s", data)
+	}
+
+	// The copy is removed once the findings are gone.
+	if err := os.WriteFile("lib.go", []byte("package lib\n\nfunc Greet() string {\n\treturn \"hello\"\n}\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := bazel_testing.RunBazel("run", "//:nogo_report", "--", "nogo-report"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(logCopy); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed, got %v", logCopy, err)
+	}
+}
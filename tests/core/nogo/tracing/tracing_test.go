@@ -0,0 +1,58 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Nogo: "@io_bazel_rules_go//:tools_nogo",
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "lib",
+    srcs = ["lib.go"],
+    importpath = "example.com/lib",
+)
+-- lib.go --
+package lib
+
+func Answer() int {
+	return 42
+}
+`,
+	})
+}
+
+func TestTraceExportError(t *testing.T) {
+	traceFile := filepath.Join(t.TempDir(), "missing", "trace.jsonl")
+	cmd := bazel_testing.BazelCmd("build", "--action_env=NOGO_TRACE_FILE="+traceFile, "//:lib")
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("unexpected failure: %v\n%s", err, stderr)
+	}
+	if want := "exporting trace:"; !bytes.Contains(stderr.Bytes(), []byte(want)) {
+		t.Errorf("the output doesn't report the failed export:\n%s", stderr)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spectre_test
+
+import (
+	"encoding/json"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_binary")
+
+go_binary(
+    name = "add",
+    srcs = [
+        "add.go",
+        "add_amd64.s",
+    ],
+)
+-- add.go --
+package main
+
+import "fmt"
+
+func add(a, b int) int
+
+func main() {
+	xs := []int{1, 2}
+	fmt.Println(add(xs[0], xs[1]))
+}
+-- add_amd64.s --
+#include "textflag.h"
+
+TEXT ·add(SB),NOSPLIT,$0-24
+	MOVQ a+0(FP), AX
+	ADDQ b+8(FP), AX
+	MOVQ AX, ret+16(FP)
+	RET
+`,
+	})
+}
+
+func TestSpectre(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skip("Spectre index mitigations are only supported on amd64")
+	}
+	const spectre = "--@io_bazel_rules_go//go/config:spectre=all"
+
+	out, err := bazel_testing.BazelOutput("aquery", "--output=jsonproto", spectre, `mnemonic("GoCompilePkg|GoStdlib", //:add)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var graph struct {
+		Actions []struct {
+			Mnemonic  string
+			Arguments []string
+		}
+	}
+	if err := json.Unmarshal(out, &graph); err != nil {
+		t.Fatal(err)
+	}
+	enabled := map[string]bool{}
+	for _, action := range graph.Actions {
+		for i, arg := range action.Arguments {
+			if arg == "-spectre" && i+1 < len(action.Arguments) && action.Arguments[i+1] == "all" {
+				enabled[action.Mnemonic] = true
+			}
+		}
+	}
+	for _, mnemonic := range []string{"GoCompilePkg", "GoStdlib"} {
+		if !enabled[mnemonic] {
+			t.Errorf("%s action doesn't enable the Spectre mitigations", mnemonic)
+		}
+	}
+
+	out, err = bazel_testing.BazelOutput("run", spectre, "//:add")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(out)); got != "3" {
+		t.Errorf("got output %q, want %q", got, "3")
+	}
+}
+
+func TestUnknownSpectreMitigation(t *testing.T) {
+	err := bazel_testing.RunBazel("build", "--@io_bazel_rules_go//go/config:spectre=load", "//:add")
+	if err == nil {
+		t.Fatal("building with an unknown Spectre mitigation succeeded")
+	}
+	if !strings.Contains(err.Error(), "unknown Spectre mitigation") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+load("@bazel_skylib//lib:unittest.bzl", "asserts", "unittest")
+load("//go/private:mode.bzl", "link_buildmode", "link_mode_arg", "linkmode_supported", "match_package_pattern", "package_gc_goopts", "stdlib_package_gc_goopts")
+
+def _match_package_pattern_test(ctx):
+    env = unittest.begin(ctx)
+
+    asserts.true(env, match_package_pattern("example.com/a", "example.com/a"))
+    asserts.false(env, match_package_pattern("example.com/a", "example.com/ab"))
+    asserts.true(env, match_package_pattern("example.com/a/...", "example.com/a"))
+    asserts.true(env, match_package_pattern("example.com/a/...", "example.com/a/b/c"))
+    asserts.false(env, match_package_pattern("example.com/a/...", "example.com/ab"))
+    asserts.true(env, match_package_pattern("example.com/a...", "example.com/ab"))
+    asserts.true(env, match_package_pattern(".../internal/...", "example.com/a/internal/b"))
+    asserts.false(env, match_package_pattern(".../internal/...", "example.com/a/internals"))
+    asserts.true(env, match_package_pattern("example.com/...x...y", "example.com/xy"))
+    asserts.false(env, match_package_pattern("example.com/...x...y", "example.com/y"))
+    asserts.false(env, match_package_pattern("a...a", "a"))
+    asserts.true(env, match_package_pattern("all", "example.com/a"))
+    asserts.false(env, match_package_pattern("std", "fmt"))
+
+    return unittest.end(env)
+
+match_package_pattern_test = unittest.make(_match_package_pattern_test)
+
+def _package_gc_goopts_test(ctx):
+    env = unittest.begin(ctx)
+
+    mode = struct(package_gc_goopts = [
+        "example.com/...=-m",
+        "example.com/a=-N -l",
+        "std=-N",
+        "runtime=-d=checkptr",
+        "cmd/...=-m",
+        "...=-l",
+    ])
+    asserts.equals(env, ["-l"], package_gc_goopts(mode, "example.com/a"))
+    asserts.equals(env, ["-l"], package_gc_goopts(mode, "other.org/b"))
+    asserts.equals(env, ["std=-N", "runtime=-d=checkptr", "...=-l"], stdlib_package_gc_goopts(mode))
+
+    # Like the go command, only the last matching entry applies, so a more
+    # specific pattern has to come after a broader one.
+    mode = struct(package_gc_goopts = [
+        "example.com/...=-m",
+        "example.com/a=-N -l",
+    ])
+    asserts.equals(env, ["-N -l"], package_gc_goopts(mode, "example.com/a"))
+    asserts.equals(env, ["-m"], package_gc_goopts(mode, "example.com/b"))
+    asserts.equals(env, [], package_gc_goopts(mode, "other.org/b"))
+    mode = struct(package_gc_goopts = [
+        "example.com/a=-N -l",
+        "example.com/...=-m",
+    ])
+    asserts.equals(env, ["-m"], package_gc_goopts(mode, "example.com/a"))
+
+    return unittest.end(env)
+
+package_gc_goopts_test = unittest.make(_package_gc_goopts_test)
+
+def _mode(goos, goarch, linkmode = "normal", race = False, pure = False):
+    return struct(goos = goos, goarch = goarch, linkmode = linkmode, race = race, pure = pure)
+
+def _link_buildmode_test(ctx):
+    env = unittest.begin(ctx)
+
+    # Like the go command, executables are position-independent by default
+    # on some platforms.
+    asserts.equals(env, None, link_buildmode(_mode("linux", "amd64")))
+    asserts.equals(env, None, link_mode_arg(_mode("linux", "amd64")))
+    asserts.equals(env, "pie", link_buildmode(_mode("darwin", "arm64")))
+    asserts.equals(env, "-shared", link_mode_arg(_mode("darwin", "arm64")))
+    asserts.equals(env, "pie", link_buildmode(_mode("windows", "amd64")))
+    asserts.equals(env, None, link_mode_arg(_mode("windows", "amd64")))
+    asserts.equals(env, None, link_buildmode(_mode("windows", "amd64", race = True)))
+    asserts.equals(env, "pie", link_buildmode(_mode("android", "arm64", pure = True)))
+    asserts.equals(env, None, link_buildmode(_mode("android", "amd64", pure = True)))
+
+    asserts.equals(env, "pie", link_buildmode(_mode("linux", "amd64", linkmode = "pie")))
+    asserts.equals(env, "-shared", link_mode_arg(_mode("linux", "amd64", linkmode = "pie")))
+    asserts.equals(env, "c-archive", link_buildmode(_mode("linux", "arm64", linkmode = "c-archive")))
+    asserts.equals(env, "-shared", link_mode_arg(_mode("linux", "ppc64", linkmode = "c-archive")))
+    asserts.equals(env, None, link_mode_arg(_mode("darwin", "amd64", linkmode = "c-archive")))
+    asserts.equals(env, None, link_mode_arg(_mode("windows", "amd64", linkmode = "c-shared")))
+    asserts.equals(env, "-dynlink", link_mode_arg(_mode("linux", "amd64", linkmode = "plugin")))
+
+    return unittest.end(env)
+
+link_buildmode_test = unittest.make(_link_buildmode_test)
+
+def _linkmode_supported_test(ctx):
+    env = unittest.begin(ctx)
+
+    asserts.true(env, linkmode_supported(_mode("js", "wasm")))
+    asserts.true(env, linkmode_supported(_mode("windows", "amd64", linkmode = "c-archive")))
+    asserts.true(env, linkmode_supported(_mode("linux", "riscv64", linkmode = "c-archive")))
+    asserts.false(env, linkmode_supported(_mode("linux", "mips", linkmode = "c-archive")))
+    asserts.true(env, linkmode_supported(_mode("wasip1", "wasm", linkmode = "c-shared")))
+    asserts.false(env, linkmode_supported(_mode("openbsd", "amd64", linkmode = "c-shared")))
+    asserts.true(env, linkmode_supported(_mode("darwin", "arm64", linkmode = "plugin")))
+    asserts.false(env, linkmode_supported(_mode("windows", "amd64", linkmode = "plugin")))
+    asserts.true(env, linkmode_supported(_mode("ios", "arm64", linkmode = "pie")))
+    asserts.false(env, linkmode_supported(_mode("netbsd", "amd64", linkmode = "pie")))
+
+    return unittest.end(env)
+
+linkmode_supported_test = unittest.make(_linkmode_supported_test)
+
+def mode_test_suite():
+    """Creates the test targets and test suite for mode.bzl tests."""
+    unittest.suite(
+        "mode_tests",
+        match_package_pattern_test,
+        package_gc_goopts_test,
+        link_buildmode_test,
+        linkmode_supported_test,
+    )
@@ -0,0 +1,90 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prebuilt_stdlib_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/rules_go/go/tools/bazel_testing"
+)
+
+func TestMain(m *testing.M) {
+	bazel_testing.TestMain(m, bazel_testing.Args{
+		Main: `
+-- BUILD.bazel --
+load("@io_bazel_rules_go//go:def.bzl", "go_binary")
+
+exports_files(["stdlib.tar.gz"])
+
+go_binary(
+    name = "hello",
+    srcs = ["hello.go"],
+)
+-- hello.go --
+package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("hello")
+}
+`,
+	})
+}
+
+const mismatchMessage = "building the standard library from source"
+
+func TestPrebuiltStdlib(t *testing.T) {
+	if err := bazel_testing.RunBazel("build", "@io_bazel_rules_go//:stdlib", "--output_groups=prebuilt_stdlib", "--@io_bazel_rules_go//go/config:pure"); err != nil {
+		t.Fatal(err)
+	}
+	archives, err := filepath.Glob("bazel-out/*/bin/external/*/stdlib_/stdlib.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archives) != 1 {
+		t.Fatalf("found %d prebuilt standard libraries, want 1: %v", len(archives), archives)
+	}
+	data, err := os.ReadFile(archives[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("stdlib.tar.gz", data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("same configuration", func(t *testing.T) {
+		_, stderr, err := bazel_testing.BazelOutputWithInput(nil, "build", "//:hello", "--@io_bazel_rules_go//go/config:pure", "--@io_bazel_rules_go//go/config:prebuilt_stdlib=//:stdlib.tar.gz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(stderr), mismatchMessage) {
+			t.Errorf("the prebuilt standard library wasn't used:\n%s", stderr)
+		}
+	})
+
+	t.Run("different configuration", func(t *testing.T) {
+		_, stderr, err := bazel_testing.BazelOutputWithInput(nil, "build", "//:hello", "--@io_bazel_rules_go//go/config:pure", "--@io_bazel_rules_go//go/config:tags=prebuilt_stdlib_test", "--@io_bazel_rules_go//go/config:prebuilt_stdlib=//:stdlib.tar.gz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(stderr), mismatchMessage) {
+			t.Errorf("the prebuilt standard library was used for different build tags:\n%s", stderr)
+		}
+	})
+}